@@ -0,0 +1,502 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/auth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	flagEvaluatePolicy   = "policy"
+	flagEvaluateJWT      = "jwt"
+	flagEvaluateBasic    = "basic"
+	flagEvaluateClaims   = "claims"
+	flagEvaluateJWKsFile = "jwks-file"
+	flagEvaluateMethod   = "method"
+	flagEvaluateURI      = "uri"
+	flagEvaluateHost     = "host"
+	flagEvaluateSecret   = "secret"
+)
+
+type acpCmd struct{}
+
+func newACPCmd() acpCmd {
+	return acpCmd{}
+}
+
+func (c acpCmd) build() *cli.Command {
+	return &cli.Command{
+		Name:  "acp",
+		Usage: "Manages access control policies",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "evaluate",
+				Usage: "Evaluates a JWT, basic credentials or claims against an access control policy",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     flagEvaluatePolicy,
+						Usage:    "Path of the AccessControlPolicy manifest",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateJWT,
+						Usage: "JWT to evaluate",
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateBasic,
+						Usage: "Basic credentials to evaluate, as user:password",
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateClaims,
+						Usage: "Claims to evaluate, as a JSON object",
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateJWKsFile,
+						Usage: "Path of a JWK set used instead of the JWKs URL of the policy",
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateMethod,
+						Usage: "Method of the evaluated request, matched by the rules of the policy",
+						Value: http.MethodGet,
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateURI,
+						Usage: "URI of the evaluated request, matched by the rules of the policy",
+						Value: "/",
+					},
+					&cli.StringFlag{
+						Name:  flagEvaluateHost,
+						Usage: "Host of the evaluated request, matched by the rules of the policy",
+					},
+					&cli.StringSliceFlag{
+						Name:  flagEvaluateSecret,
+						Usage: "Path of the manifest of a Secret referenced by the policy, can be repeated",
+					},
+				},
+				Action: c.evaluate,
+			},
+		},
+	}
+}
+
+func (c acpCmd) evaluate(cliCtx *cli.Context) error {
+	policy, err := readPolicy(cliCtx.String(flagEvaluatePolicy))
+	if err != nil {
+		return fmt.Errorf("read policy: %w", err)
+	}
+
+	cfg := acp.ConfigFromPolicy(policy)
+
+	secrets, err := readSecrets(cliCtx.StringSlice(flagEvaluateSecret))
+	if err != nil {
+		return fmt.Errorf("read secrets: %w", err)
+	}
+
+	if err = populateSecrets(cfg, secrets); err != nil {
+		return err
+	}
+
+	if jwksFile := cliCtx.String(flagEvaluateJWKsFile); jwksFile != "" {
+		if cfg.JWT == nil {
+			return errors.New("a JWKs file can only be used with JWT policies")
+		}
+
+		cfg.JWT.JWKsFile = jwt.FileOrContent(jwksFile)
+		cfg.JWT.JWKsURL = ""
+	}
+
+	var eval *evaluation
+	switch {
+	case cliCtx.IsSet(flagEvaluateJWT):
+		req := newEvaluatedRequest(cliCtx, policy.Name)
+		req.Header.Set("Authorization", "Bearer "+cliCtx.String(flagEvaluateJWT))
+
+		eval, err = evaluateRequest(cliCtx.Context, policy.Name, cfg, req)
+
+	case cliCtx.IsSet(flagEvaluateBasic):
+		user, password, _ := strings.Cut(cliCtx.String(flagEvaluateBasic), ":")
+
+		req := newEvaluatedRequest(cliCtx, policy.Name)
+		req.SetBasicAuth(user, password)
+
+		eval, err = evaluateRequest(cliCtx.Context, policy.Name, cfg, req)
+
+	case cliCtx.IsSet(flagEvaluateClaims):
+		var claims map[string]interface{}
+		if err = json.Unmarshal([]byte(cliCtx.String(flagEvaluateClaims)), &claims); err != nil {
+			return fmt.Errorf("decode claims: %w", err)
+		}
+
+		eval, err = evaluateClaims(policy.Name, cfg, claims)
+
+	default:
+		return fmt.Errorf("one of --%s, --%s or --%s is required", flagEvaluateJWT, flagEvaluateBasic, flagEvaluateClaims)
+	}
+	if err != nil {
+		return err
+	}
+
+	eval.print(os.Stdout)
+
+	if eval.result != authmetrics.ResultAllowed {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
+
+func readPolicy(path string) (*hubv1alpha1.AccessControlPolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var policy hubv1alpha1.AccessControlPolicy
+	if err = yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(&policy); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	if policy.Kind != "AccessControlPolicy" {
+		return nil, fmt.Errorf("unexpected kind %q", policy.Kind)
+	}
+
+	return &policy, nil
+}
+
+// readSecrets reads the Secret manifests at the given paths, by namespace and name.
+func readSecrets(paths []string) (map[string]*corev1.Secret, error) {
+	secrets := make(map[string]*corev1.Secret, len(paths))
+	for _, path := range paths {
+		secret, err := readSecret(path)
+		if err != nil {
+			return nil, fmt.Errorf("read secret %q: %w", path, err)
+		}
+
+		secrets[secret.Namespace+"/"+secret.Name] = secret
+	}
+
+	return secrets, nil
+}
+
+func readSecret(path string) (*corev1.Secret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var secret corev1.Secret
+	if err = yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	if secret.Kind != "Secret" {
+		return nil, fmt.Errorf("unexpected kind %q", secret.Kind)
+	}
+
+	// String data is merged into data, as the API server does.
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte, len(secret.StringData))
+	}
+	for key, value := range secret.StringData {
+		secret.Data[key] = []byte(value)
+	}
+
+	return &secret, nil
+}
+
+// populateSecrets populates the given configuration with the key material and credentials held by the Secrets it
+// references, which must be among the given ones.
+func populateSecrets(cfg *acp.Config, secrets map[string]*corev1.Secret) error {
+	find := func(field, namespace, name string) (*corev1.Secret, error) {
+		secret, ok := secrets[namespace+"/"+name]
+		if !ok {
+			return nil, fmt.Errorf("unresolved Secret %s/%s referenced by %s, provide it with --%s", namespace, name, field, flagEvaluateSecret)
+		}
+
+		return secret, nil
+	}
+
+	clientSecret := func(field, namespace, name string) (string, error) {
+		secret, err := find(field, namespace, name)
+		if err != nil {
+			return "", err
+		}
+
+		value := string(secret.Data["clientSecret"])
+		if value == "" {
+			return "", fmt.Errorf("clientSecret is missing in Secret %s/%s", secret.Namespace, secret.Name)
+		}
+
+		return value, nil
+	}
+
+	var err error
+	switch {
+	case cfg.JWT != nil:
+		ref := cfg.JWT.KeysSecret
+		if ref == nil {
+			return nil
+		}
+
+		var secret *corev1.Secret
+		if secret, err = find("jwt.keysSecret", ref.Namespace, ref.Name); err != nil {
+			return err
+		}
+
+		if cfg.JWT.SecretKeys, err = jwt.KeysFromSecretData(secret.Data); err != nil {
+			return fmt.Errorf("invalid Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+
+	case cfg.BasicAuth != nil:
+		if ref := cfg.BasicAuth.UsersSecret; ref != nil {
+			var secret *corev1.Secret
+			if secret, err = find("basicAuth.usersSecret", ref.Namespace, ref.Name); err != nil {
+				return err
+			}
+
+			if cfg.BasicAuth.SecretUsers, err = basicauth.UsersFromSecretData(secret.Data); err != nil {
+				return fmt.Errorf("invalid Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+			}
+		}
+
+		if ldap := cfg.BasicAuth.LDAP; ldap != nil && ldap.BindSecret != nil {
+			ref := ldap.BindSecret
+
+			var secret *corev1.Secret
+			if secret, err = find("basicAuth.ldap.bindSecret", ref.Namespace, ref.Name); err != nil {
+				return err
+			}
+
+			ldap.BindDN = string(secret.Data[basicauth.LDAPBindDNSecretKey])
+			ldap.BindPassword = string(secret.Data[basicauth.LDAPBindPasswordSecretKey])
+		}
+
+	case cfg.OAuthIntrospection != nil && cfg.OAuthIntrospection.Secret != nil:
+		ref := cfg.OAuthIntrospection.Secret
+		cfg.OAuthIntrospection.ClientSecret, err = clientSecret("oauthIntrospection.secret", ref.Namespace, ref.Name)
+
+	case cfg.OIDC != nil && cfg.OIDC.Secret != nil:
+		ref := cfg.OIDC.Secret
+		cfg.OIDC.ClientSecret, err = clientSecret("oidc.secret", ref.Namespace, ref.Name)
+
+	case cfg.OIDCGoogle != nil && cfg.OIDCGoogle.Secret != nil:
+		ref := cfg.OIDCGoogle.Secret
+		cfg.OIDCGoogle.ClientSecret, err = clientSecret("oidcGoogle.secret", ref.Namespace, ref.Name)
+
+	case cfg.GitHub != nil && cfg.GitHub.Secret != nil:
+		ref := cfg.GitHub.Secret
+		cfg.GitHub.ClientSecret, err = clientSecret("github.secret", ref.Namespace, ref.Name)
+	}
+
+	return err
+}
+
+// newEvaluatedRequest returns a forward auth request for the given ACP, forwarding the method, URI and host given on
+// the command line.
+func newEvaluatedRequest(cliCtx *cli.Context, name string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/"+name, http.NoBody)
+	req.Header.Set("X-Forwarded-Method", cliCtx.String(flagEvaluateMethod))
+	req.Header.Set("X-Forwarded-Uri", cliCtx.String(flagEvaluateURI))
+	req.Header.Set("X-Forwarded-Host", cliCtx.String(flagEvaluateHost))
+
+	return req
+}
+
+// evaluation is the evaluation of a request against an ACP.
+type evaluation struct {
+	policy  string
+	acpType string
+	result  string
+	reason  string
+	// failing lists the function calls of the claims expression which evaluated to false.
+	failing []string
+	headers http.Header
+}
+
+// evaluateRequest evaluates the given request with the handler the auth server would build for the given ACP.
+func evaluateRequest(ctx context.Context, name string, cfg *acp.Config, req *http.Request) (*evaluation, error) {
+	if len(cfg.References()) > 0 || cfg.Composite != nil {
+		return nil, errors.New("policies referencing other policies can't be evaluated")
+	}
+
+	route, err := auth.BuildRoute(ctx, name, cfg, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build ACP handler: %w", err)
+	}
+
+	eval := &evaluation{policy: name, acpType: auth.ACPType(cfg)}
+
+	rw := httptest.NewRecorder()
+	handler := authmetrics.Handler(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		route.ServeHTTP(rw, req)
+		eval.reason = authmetrics.Reason(req.Context(), rw.Code)
+	}), name, eval.acpType)
+
+	ctx, _ = identity.WithRecorder(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	eval.result = authmetrics.Result(rw.Code)
+	if eval.result == authmetrics.ResultAllowed {
+		eval.headers = rw.Header()
+	}
+
+	// The token signature was verified by the handler, its claims can be trusted to explain the decision.
+	if eval.reason == authmetrics.ReasonClaimsMismatch && cfg.JWT != nil {
+		// JWEs were decrypted by the handler too.
+		var rawToken string
+		rawToken, err = cfg.JWT.DecryptToken(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt JWT: %w", err)
+		}
+
+		var claims jwtgo.MapClaims
+		if _, _, err = jwtgo.NewParser().ParseUnverified(rawToken, &claims); err != nil {
+			return nil, fmt.Errorf("parse JWT: %w", err)
+		}
+
+		_, calls, err := expr.Trace(cfg.JWT.Claims, claims)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate claims expression: %w", err)
+		}
+
+		eval.failing = failingCalls(calls)
+	}
+
+	return eval, nil
+}
+
+// evaluateClaims evaluates the given claims against the claims expression of the given ACP.
+func evaluateClaims(name string, cfg *acp.Config, claims map[string]interface{}) (*evaluation, error) {
+	var (
+		claimsExpr string
+		fwdHeaders map[string]string
+	)
+	switch {
+	case cfg.JWT != nil:
+		claimsExpr, fwdHeaders = cfg.JWT.Claims, cfg.JWT.ForwardHeaders
+	case cfg.OIDC != nil:
+		claimsExpr, fwdHeaders = cfg.OIDC.Claims, cfg.OIDC.ForwardHeaders
+	case cfg.OIDCGoogle != nil:
+		claimsExpr, fwdHeaders = cfg.OIDCGoogle.Claims, cfg.OIDCGoogle.ForwardHeaders
+	case cfg.OAuthIntrospection != nil:
+		claimsExpr, fwdHeaders = cfg.OAuthIntrospection.Claims, cfg.OAuthIntrospection.ForwardHeaders
+	case cfg.MTLS != nil:
+		claimsExpr, fwdHeaders = cfg.MTLS.Claims, cfg.MTLS.ForwardHeaders
+	default:
+		return nil, fmt.Errorf("claims can't be evaluated against %s policies", auth.ACPType(cfg))
+	}
+
+	eval := &evaluation{
+		policy:  name,
+		acpType: auth.ACPType(cfg),
+		result:  authmetrics.ResultAllowed,
+		reason:  "authorized",
+	}
+
+	if claimsExpr != "" {
+		allowed, calls, err := expr.Trace(claimsExpr, claims)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate claims expression: %w", err)
+		}
+
+		if !allowed {
+			eval.result = authmetrics.ResultDenied
+			eval.reason = authmetrics.ReasonClaimsMismatch
+
+			eval.failing = failingCalls(calls)
+
+			return eval, nil
+		}
+	}
+
+	hdrs, err := expr.PluckClaims(fwdHeaders, claims)
+	if err != nil {
+		return nil, fmt.Errorf("extract forwarded headers: %w", err)
+	}
+
+	eval.headers = make(http.Header)
+	for name, values := range hdrs {
+		for _, value := range values {
+			eval.headers.Add(name, value)
+		}
+	}
+
+	return eval, nil
+}
+
+// failingCalls returns the expressions of the given calls which evaluated to false.
+func failingCalls(calls []expr.Call) []string {
+	var failing []string
+	for _, call := range calls {
+		if !call.Result {
+			failing = append(failing, call.Expr)
+		}
+	}
+
+	return failing
+}
+
+func (e *evaluation) print(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "Policy:   %s (%s)\n", e.policy, e.acpType)
+	_, _ = fmt.Fprintf(w, "Decision: %s\n", e.result)
+	_, _ = fmt.Fprintf(w, "Reason:   %s\n", e.reason)
+
+	if len(e.failing) > 0 {
+		_, _ = fmt.Fprintln(w, "Failing predicates:")
+		for _, call := range e.failing {
+			_, _ = fmt.Fprintf(w, "  %s\n", call)
+		}
+	}
+
+	if len(e.headers) > 0 {
+		names := make([]string, 0, len(e.headers))
+		for name := range e.headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		_, _ = fmt.Fprintln(w, "Forwarded headers:")
+		for _, name := range names {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", name, strings.Join(e.headers.Values(name), ", "))
+		}
+	}
+}
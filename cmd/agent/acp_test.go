@@ -0,0 +1,325 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	"gopkg.in/square/go-jose.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acp.yaml")
+	err := os.WriteFile(path, []byte(`
+apiVersion: hub.traefik.io/v1alpha1
+kind: AccessControlPolicy
+metadata:
+  name: my-acp
+spec:
+  jwt:
+    signingSecret: secret
+    claims: Equals("grp", "admin")
+`), 0o600)
+	require.NoError(t, err)
+
+	policy, err := readPolicy(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "my-acp", policy.Name)
+	require.NotNil(t, policy.Spec.JWT)
+	assert.Equal(t, "secret", policy.Spec.JWT.SigningSecret)
+	assert.Equal(t, `Equals("grp", "admin")`, policy.Spec.JWT.Claims)
+}
+
+func TestReadSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.yaml")
+	err := os.WriteFile(path, []byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+  namespace: my-ns
+data:
+  signingSecret: c2VjcmV0
+stringData:
+  clientSecret: client-secret
+`), 0o600)
+	require.NoError(t, err)
+
+	secret, err := readSecret(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "my-ns", secret.Namespace)
+	assert.Equal(t, "my-secret", secret.Name)
+	assert.Equal(t, map[string][]byte{
+		"signingSecret": []byte("secret"),
+		"clientSecret":  []byte("client-secret"),
+	}, secret.Data)
+
+	path = filepath.Join(t.TempDir(), "configmap.yaml")
+	err = os.WriteFile(path, []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+`), 0o600)
+	require.NoError(t, err)
+
+	_, err = readSecret(path)
+	assert.Error(t, err)
+}
+
+func TestPopulateSecrets(t *testing.T) {
+	secrets := map[string]*corev1.Secret{
+		"my-ns/jwt-keys": {
+			ObjectMeta: metav1.ObjectMeta{Name: "jwt-keys", Namespace: "my-ns"},
+			Data:       map[string][]byte{jwt.SigningSecretSecretKey: []byte("secret")},
+		},
+		"my-ns/users": {
+			ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: "my-ns"},
+			Data:       map[string][]byte{basicauth.UsersSecretKey: []byte("user:$apr1$9Cv/OMGj$ZomWQzuQbL.3TRCS81A1g/")},
+		},
+		"my-ns/oidc-client": {
+			ObjectMeta: metav1.ObjectMeta{Name: "oidc-client", Namespace: "my-ns"},
+			Data:       map[string][]byte{"clientSecret": []byte("client-secret")},
+		},
+		"my-ns/empty": {
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "my-ns"},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		cfg     *acp.Config
+		want    *acp.Config
+		wantErr string
+	}{
+		{
+			desc: "JWT keys",
+			cfg:  &acp.Config{JWT: &jwt.Config{KeysSecret: &corev1.SecretReference{Name: "jwt-keys", Namespace: "my-ns"}}},
+			want: &acp.Config{JWT: &jwt.Config{
+				KeysSecret: &corev1.SecretReference{Name: "jwt-keys", Namespace: "my-ns"},
+				SecretKeys: jwt.SecretKeys{SigningSecret: "secret"},
+			}},
+		},
+		{
+			desc: "basic auth users",
+			cfg:  &acp.Config{BasicAuth: &basicauth.Config{UsersSecret: &corev1.SecretReference{Name: "users", Namespace: "my-ns"}}},
+			want: &acp.Config{BasicAuth: &basicauth.Config{
+				UsersSecret: &corev1.SecretReference{Name: "users", Namespace: "my-ns"},
+				SecretUsers: basicauth.Users{"user:$apr1$9Cv/OMGj$ZomWQzuQbL.3TRCS81A1g/"},
+			}},
+		},
+		{
+			desc: "OIDC client secret",
+			cfg:  &acp.Config{OIDC: &oidc.Config{Secret: &oidc.SecretReference{Name: "oidc-client", Namespace: "my-ns"}}},
+			want: &acp.Config{OIDC: &oidc.Config{
+				Secret:       &oidc.SecretReference{Name: "oidc-client", Namespace: "my-ns"},
+				ClientSecret: "client-secret",
+			}},
+		},
+		{
+			desc:    "unresolved Secret",
+			cfg:     &acp.Config{BasicAuth: &basicauth.Config{UsersSecret: &corev1.SecretReference{Name: "users", Namespace: "default"}}},
+			wantErr: "unresolved Secret default/users referenced by basicAuth.usersSecret, provide it with --secret",
+		},
+		{
+			desc:    "missing client secret",
+			cfg:     &acp.Config{OIDC: &oidc.Config{Secret: &oidc.SecretReference{Name: "empty", Namespace: "my-ns"}}},
+			wantErr: "clientSecret is missing in Secret my-ns/empty",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := populateSecrets(test.cfg, secrets)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, test.cfg)
+		})
+	}
+}
+
+func TestEvaluateRequest(t *testing.T) {
+	cfg := &acp.Config{
+		JWT: &jwt.Config{
+			SigningSecret:  "secret",
+			Claims:         `Equals("grp", "admin") && Contains("scope", "read")`,
+			ForwardHeaders: map[string]string{"X-Group": "grp"},
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		claims      jwtgo.MapClaims
+		wantResult  string
+		wantReason  string
+		wantFailing []string
+		wantHeaders http.Header
+	}{
+		{
+			desc:        "allowed",
+			claims:      jwtgo.MapClaims{"grp": "admin", "scope": []string{"read"}},
+			wantResult:  authmetrics.ResultAllowed,
+			wantReason:  "authorized",
+			wantHeaders: http.Header{"X-Group": {"admin"}},
+		},
+		{
+			desc:        "claims mismatch",
+			claims:      jwtgo.MapClaims{"grp": "dev", "scope": []string{"read"}},
+			wantResult:  authmetrics.ResultDenied,
+			wantReason:  authmetrics.ReasonClaimsMismatch,
+			wantFailing: []string{`Equals("grp", "admin")`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, test.claims).SignedString([]byte("secret"))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/my-acp", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			eval, err := evaluateRequest(context.Background(), "my-acp", cfg, req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantResult, eval.result)
+			assert.Equal(t, test.wantReason, eval.reason)
+			assert.Equal(t, test.wantFailing, eval.failing)
+			for name, values := range test.wantHeaders {
+				assert.Equal(t, values, eval.headers.Values(name))
+			}
+		})
+	}
+}
+
+func TestEvaluateClaims(t *testing.T) {
+	cfg := &acp.Config{
+		JWT: &jwt.Config{
+			Claims:         `Equals("grp", "admin") || Equals("grp", "ops")`,
+			ForwardHeaders: map[string]string{"X-Group": "grp"},
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		claims      map[string]interface{}
+		wantResult  string
+		wantFailing []string
+		wantHeaders http.Header
+	}{
+		{
+			desc:        "allowed",
+			claims:      map[string]interface{}{"grp": "ops"},
+			wantResult:  authmetrics.ResultAllowed,
+			wantFailing: nil,
+			wantHeaders: http.Header{"X-Group": {"ops"}},
+		},
+		{
+			desc:        "denied",
+			claims:      map[string]interface{}{"grp": "dev"},
+			wantResult:  authmetrics.ResultDenied,
+			wantFailing: []string{`Equals("grp", "admin")`, `Equals("grp", "ops")`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			eval, err := evaluateClaims("my-acp", cfg, test.claims)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantResult, eval.result)
+			assert.Equal(t, test.wantFailing, eval.failing)
+			assert.Equal(t, test.wantHeaders, eval.headers)
+		})
+	}
+}
+
+func TestEvaluateRequest_jwe(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cfg := &acp.Config{
+		JWT: &jwt.Config{
+			SigningSecret: "secret",
+			Claims:        `Equals("grp", "admin")`,
+			SecretKeys: jwt.SecretKeys{
+				DecryptionKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			},
+		},
+	}
+
+	signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.MapClaims{"grp": "dev"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	enc, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &rsaKey.PublicKey},
+		(&jose.EncrypterOptions{}).WithContentType("JWT"))
+	require.NoError(t, err)
+
+	obj, err := enc.Encrypt([]byte(signed))
+	require.NoError(t, err)
+
+	token, err := obj.CompactSerialize()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/my-acp", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	eval, err := evaluateRequest(context.Background(), "my-acp", cfg, req)
+	require.NoError(t, err)
+
+	assert.Equal(t, authmetrics.ResultDenied, eval.result)
+	assert.Equal(t, authmetrics.ReasonClaimsMismatch, eval.reason)
+	assert.Equal(t, []string{`Equals("grp", "admin")`}, eval.failing)
+}
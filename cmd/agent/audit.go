@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ettle/strcase"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/audit"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagAuditSink                 = "audit.sink"
+	flagAuditClaims               = "audit.claims"
+	flagAuditRedactClaims         = "audit.redact-claims"
+	flagAuditRedactQuery          = "audit.redact-query"
+	flagAuditFilePath             = "audit.file.path"
+	flagAuditFileMaxSize          = "audit.file.max-size"
+	flagAuditFileMaxBackups       = "audit.file.max-backups"
+	flagAuditFileMaxAge           = "audit.file.max-age"
+	flagAuditWebhookURL           = "audit.webhook.url"
+	flagAuditWebhookBatchSize     = "audit.webhook.batch-size"
+	flagAuditWebhookFlushInterval = "audit.webhook.flush-interval"
+	flagAuditWebhookQueueSize     = "audit.webhook.queue-size"
+	flagAuditWebhookBlockTimeout  = "audit.webhook.block-timeout"
+)
+
+// Supported audit sinks.
+const (
+	auditSinkStdout  = "stdout"
+	auditSinkFile    = "file"
+	auditSinkWebhook = "webhook"
+)
+
+func auditFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    flagAuditSink,
+			Usage:   "Sink audit events are shipped to: stdout, file or webhook, empty to disable auditing",
+			EnvVars: []string{auditEnvVar(flagAuditSink)},
+		},
+		&cli.StringSliceFlag{
+			Name:    flagAuditClaims,
+			Usage:   "Claims of the authenticated identity added to audit events",
+			EnvVars: []string{auditEnvVar(flagAuditClaims)},
+		},
+		&cli.StringSliceFlag{
+			Name:    flagAuditRedactClaims,
+			Usage:   "Claims whose values are redacted in audit events, `sub` redacting the subject",
+			EnvVars: []string{auditEnvVar(flagAuditRedactClaims)},
+		},
+		&cli.BoolFlag{
+			Name:    flagAuditRedactQuery,
+			Usage:   "Remove query strings from the URIs of audit events",
+			EnvVars: []string{auditEnvVar(flagAuditRedactQuery)},
+		},
+		&cli.StringFlag{
+			Name:    flagAuditFilePath,
+			Usage:   "Path of the file audit events are written to by the file sink",
+			EnvVars: []string{auditEnvVar(flagAuditFilePath)},
+			Value:   "/var/log/hub-agent/audit.log",
+		},
+		&cli.IntFlag{
+			Name:    flagAuditFileMaxSize,
+			Usage:   "Size in megabytes the audit file is rotated at",
+			EnvVars: []string{auditEnvVar(flagAuditFileMaxSize)},
+			Value:   100,
+		},
+		&cli.IntFlag{
+			Name:    flagAuditFileMaxBackups,
+			Usage:   "Number of rotated audit files kept",
+			EnvVars: []string{auditEnvVar(flagAuditFileMaxBackups)},
+			Value:   3,
+		},
+		&cli.IntFlag{
+			Name:    flagAuditFileMaxAge,
+			Usage:   "Number of days rotated audit files are kept for, 0 to keep them regardless of their age",
+			EnvVars: []string{auditEnvVar(flagAuditFileMaxAge)},
+		},
+		&cli.StringFlag{
+			Name:    flagAuditWebhookURL,
+			Usage:   "URL audit events are posted to by the webhook sink",
+			EnvVars: []string{auditEnvVar(flagAuditWebhookURL)},
+		},
+		&cli.IntFlag{
+			Name:    flagAuditWebhookBatchSize,
+			Usage:   "Maximum number of audit events posted at once to the webhook",
+			EnvVars: []string{auditEnvVar(flagAuditWebhookBatchSize)},
+			Value:   100,
+		},
+		&cli.DurationFlag{
+			Name:    flagAuditWebhookFlushInterval,
+			Usage:   "Maximum time audit events wait before being posted to the webhook",
+			EnvVars: []string{auditEnvVar(flagAuditWebhookFlushInterval)},
+			Value:   5 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:    flagAuditWebhookQueueSize,
+			Usage:   "Maximum number of audit events waiting to be posted to the webhook",
+			EnvVars: []string{auditEnvVar(flagAuditWebhookQueueSize)},
+			Value:   10000,
+		},
+		&cli.DurationFlag{
+			Name:    flagAuditWebhookBlockTimeout,
+			Usage:   "Maximum time requests wait for room in a full webhook queue before their audit event is dropped",
+			EnvVars: []string{auditEnvVar(flagAuditWebhookBlockTimeout)},
+			Value:   100 * time.Millisecond,
+		},
+	}
+}
+
+func auditEnvVar(flag string) string {
+	return "AUTH_SERVER_" + strcase.ToSNAKE(flag)
+}
+
+// setupAuditor returns the auditor configured by the audit flags, or nil if auditing is disabled. Sinks run until the
+// command context is done.
+func setupAuditor(cliCtx *cli.Context) (*audit.Auditor, error) {
+	ctx := cliCtx.Context
+
+	var sink audit.Sink
+
+	switch name := cliCtx.String(flagAuditSink); name {
+	case "":
+		return nil, nil
+
+	case auditSinkStdout:
+		sink = audit.NewWriterSink(auditSinkStdout, os.Stdout)
+
+	case auditSinkFile:
+		fileSink, err := audit.NewFileSink(audit.FileConfig{
+			Path:       cliCtx.String(flagAuditFilePath),
+			MaxSize:    cliCtx.Int(flagAuditFileMaxSize),
+			MaxBackups: cliCtx.Int(flagAuditFileMaxBackups),
+			MaxAge:     cliCtx.Int(flagAuditFileMaxAge),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create file sink: %w", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			if err = fileSink.Close(); err != nil {
+				log.Error().Err(err).Msg("Unable to close audit file")
+			}
+		}()
+
+		sink = fileSink
+
+	case auditSinkWebhook:
+		webhookSink, err := audit.NewWebhookSink(audit.WebhookConfig{
+			URL:           cliCtx.String(flagAuditWebhookURL),
+			BatchSize:     cliCtx.Int(flagAuditWebhookBatchSize),
+			FlushInterval: cliCtx.Duration(flagAuditWebhookFlushInterval),
+			QueueSize:     cliCtx.Int(flagAuditWebhookQueueSize),
+			BlockTimeout:  cliCtx.Duration(flagAuditWebhookBlockTimeout),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create webhook sink: %w", err)
+		}
+
+		go webhookSink.Run(ctx)
+
+		sink = webhookSink
+
+	default:
+		return nil, fmt.Errorf("unsupported audit sink %q", name)
+	}
+
+	return audit.NewAuditor(sink, audit.Config{
+		Claims:       cliCtx.StringSlice(flagAuditClaims),
+		RedactClaims: cliCtx.StringSlice(flagAuditRedactClaims),
+		RedactQuery:  cliCtx.Bool(flagAuditRedactQuery),
+	}), nil
+}
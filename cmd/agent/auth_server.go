@@ -19,16 +19,21 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	stdlog "log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/auth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/keyring"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
 	hubinformer "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/informers/externalversions"
 	"github.com/traefik/hub-agent-kubernetes/pkg/kube"
@@ -40,6 +45,11 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 )
 
+const (
+	flagMetricsListenAddr = "metrics-listen-addr"
+	flagIdentityIssuer    = "identity-issuer"
+)
+
 type authServerCmd struct {
 	flags []cli.Flag
 }
@@ -52,8 +62,21 @@ func newAuthServerCmd() authServerCmd {
 			EnvVars: []string{"AUTH_SERVER_LISTEN_ADDR"},
 			Value:   "0.0.0.0:80",
 		},
+		&cli.StringFlag{
+			Name:    flagMetricsListenAddr,
+			Usage:   "Address on which the auth server exposes Prometheus metrics, empty to disable them",
+			EnvVars: []string{"AUTH_SERVER_METRICS_LISTEN_ADDR"},
+			Value:   "0.0.0.0:9090",
+		},
+		&cli.StringFlag{
+			Name:    flagIdentityIssuer,
+			Usage:   "URL upstreams can reach the auth server on, identifying the issuer of identity tokens",
+			EnvVars: []string{"AUTH_SERVER_IDENTITY_ISSUER"},
+			Value:   "http://hub-agent-auth-server.hub.svc.cluster.local",
+		},
 	}
 
+	flgs = append(flgs, auditFlags()...)
 	flgs = append(flgs, globalFlags()...)
 
 	return authServerCmd{
@@ -90,13 +113,49 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 		return fmt.Errorf("create Kube client set: %w", err)
 	}
 
-	key, err := readKey(cliCtx, kubeClientSet)
+	keys, err := readKeyring(cliCtx, kubeClientSet)
+	if err != nil {
+		return fmt.Errorf("read keyring: %w", err)
+	}
+
+	memorySessions := oidc.NewMemorySessionBackend()
+	go memorySessions.Run(cliCtx.Context, time.Minute)
+
+	// Secrets holding OIDC sessions and revocations are read from a dedicated informer, so that authenticating requests
+	// doesn't hit the API server.
+	sessionInformer := informers.NewSharedInformerFactoryWithOptions(kubeClientSet, 5*time.Minute,
+		informers.WithNamespace(currentNamespace()),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = oidc.SessionSecretLabel + "=true"
+		}),
+	)
+	secretSessions := oidc.NewSecretSessionBackend(kubeClientSet, sessionInformer, currentNamespace())
+	sessionInformer.Start(cliCtx.Context.Done())
+
+	for t, ok := range sessionInformer.WaitForCacheSync(cliCtx.Context.Done()) {
+		if !ok {
+			return fmt.Errorf("wait for session cache sync: %s: %w", t, cliCtx.Context.Err())
+		}
+	}
+
+	go secretSessions.Run(cliCtx.Context, 10*time.Minute)
+
+	auditor, err := setupAuditor(cliCtx)
 	if err != nil {
-		return fmt.Errorf("read key: %w", err)
+		return fmt.Errorf("setup auditor: %w", err)
+	}
+
+	issuerURL, err := url.Parse(cliCtx.String(flagIdentityIssuer))
+	if err != nil || !issuerURL.IsAbs() {
+		return fmt.Errorf("invalid identity issuer %q", cliCtx.String(flagIdentityIssuer))
 	}
+	issuer := idtoken.NewIssuer(issuerURL.String())
 
 	switcher := auth.NewHandlerSwitcher()
-	acpWatcher := auth.NewWatcher(switcher, key)
+	acpWatcher := auth.NewWatcher(switcher, keys, currentNamespace(), oidc.SessionBackends{
+		oidc.SessionStoreMemory: memorySessions,
+		oidc.SessionStoreSecret: secretSessions,
+	}, auditor, issuer)
 
 	hubInformer := hubinformer.NewSharedInformerFactory(hubClientSet, 5*time.Minute)
 	hubInformer.Hub().V1alpha1().AccessControlPolicies().Informer().AddEventHandler(acpWatcher)
@@ -108,8 +167,14 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 		}
 	}
 
-	kubeInformer := informers.NewSharedInformerFactory(kubeClientSet, 5*time.Minute)
+	// Secrets holding OIDC sessions are never referenced by ACPs, there's no need to watch them here.
+	kubeInformer := informers.NewSharedInformerFactoryWithOptions(kubeClientSet, 5*time.Minute,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = "!" + oidc.SessionSecretLabel
+		}),
+	)
 	kubeInformer.Core().V1().Secrets().Informer().AddEventHandler(acpWatcher)
+	kubeInformer.Core().V1().ConfigMaps().Informer().AddEventHandler(acpWatcher)
 	kubeInformer.Start(cliCtx.Context.Done())
 
 	for t, ok := range kubeInformer.WaitForCacheSync(cliCtx.Context.Done()) {
@@ -120,6 +185,10 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 
 	go acpWatcher.Run(cliCtx.Context)
 
+	if metricsAddr := cliCtx.String(flagMetricsListenAddr); metricsAddr != "" {
+		go runMetricsServer(cliCtx.Context, metricsAddr)
+	}
+
 	listenAddr := cliCtx.String(flagListenAddr)
 
 	mux := http.NewServeMux()
@@ -131,6 +200,12 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 		rw.WriteHeader(http.StatusOK)
 	}))
 
+	// Identity token documents are served under the path of the issuer so that the discovery document can be found
+	// from the issuer URL.
+	issuerPath := strings.TrimSuffix(issuerURL.Path, "/")
+	mux.HandleFunc(issuerPath+idtoken.DiscoveryPath, issuer.ServeDiscovery)
+	mux.HandleFunc(issuerPath+idtoken.JWKSPath, issuer.ServeJWKS)
+
 	mux.Handle("/", switcher)
 
 	server := &http.Server{
@@ -168,19 +243,43 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 	return nil
 }
 
-func readKey(cliCtx *cli.Context, client clientset.Interface) (string, error) {
+// runMetricsServer serves the auth server metrics on the given address until the given context is done.
+func runMetricsServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", authmetrics.HTTPHandler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ErrorLog:          stdlog.New(log.Logger.Level(zerolog.DebugLevel), "", 0),
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		gracefulCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(gracefulCtx); err != nil {
+			log.Error().Err(err).Msg("Failed to shutdown metrics server gracefully")
+		}
+	}()
+
+	log.Info().Str("addr", addr).Msg("Starting metrics server")
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Error().Err(err).Msg("Unable to listen and serve metrics requests")
+	}
+}
+
+func readKeyring(cliCtx *cli.Context, client clientset.Interface) (keyring.Keyring, error) {
 	ctx, cancel := context.WithTimeout(cliCtx.Context, 5*time.Second)
 	defer cancel()
 
-	secret, err := client.CoreV1().Secrets(currentNamespace()).Get(ctx, "hub-secret", metav1.GetOptions{})
+	secret, err := client.CoreV1().Secrets(currentNamespace()).Get(ctx, keyring.SecretName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("get secret: %w", err)
-	}
-
-	key, found := secret.Data["key"]
-	if !found {
-		return "", errors.New("key not found")
+		return keyring.Keyring{}, fmt.Errorf("get secret: %w", err)
 	}
 
-	return fmt.Sprintf("%x", sha256.Sum256(key))[:32], nil
+	return keyring.FromSecret(secret)
 }
@@ -22,13 +22,14 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/keyring"
 	"github.com/urfave/cli/v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubemock "k8s.io/client-go/kubernetes/fake"
 )
 
-func TestReadKey(t *testing.T) {
+func TestReadKeyring(t *testing.T) {
 	cliCtx := &cli.Context{Context: context.Background()}
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -43,7 +44,7 @@ func TestReadKey(t *testing.T) {
 
 	clientSetHub := kubemock.NewSimpleClientset(secret)
 
-	key, err := readKey(cliCtx, clientSetHub)
+	keys, err := readKeyring(cliCtx, clientSetHub)
 	require.NoError(t, err)
-	require.Equal(t, "5e78863ed1ffb9fc66b1d61634b126bf", key)
+	require.Equal(t, keyring.Keyring{Primary: "5e78863ed1ffb9fc66b1d61634b126bf"}, keys)
 }
@@ -26,6 +26,7 @@ import (
 
 	"github.com/ettle/strcase"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/keyring"
 	"github.com/traefik/hub-agent-kubernetes/pkg/commands"
 	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
 	traefikclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/traefik/clientset/versioned"
@@ -46,10 +47,11 @@ import (
 )
 
 const (
-	pidFilePath           = "/var/run/hub-agent-kubernetes.pid"
-	flagPlatformURL       = "platform-url"
-	flagToken             = "token"
-	flagTraefikMetricsURL = "traefik.metrics-url"
+	pidFilePath             = "/var/run/hub-agent-kubernetes.pid"
+	flagPlatformURL         = "platform-url"
+	flagToken               = "token"
+	flagTraefikMetricsURL   = "traefik.metrics-url"
+	flagKeyRotationInterval = "key-rotation-interval"
 )
 
 type controllerCmd struct {
@@ -76,6 +78,11 @@ func newControllerCmd() controllerCmd {
 			Usage:   "The url used by Traefik to expose metrics",
 			EnvVars: []string{strcase.ToSNAKE(flagTraefikMetricsURL)},
 		},
+		&cli.DurationFlag{
+			Name:    flagKeyRotationInterval,
+			Usage:   "Interval at which the keys encrypting session cookies are rotated, must exceed the lifetime of sessions (0 disables rotation)",
+			EnvVars: []string{strcase.ToSNAKE(flagKeyRotationInterval)},
+		},
 	}
 
 	flgs = append(flgs, globalFlags()...)
@@ -202,6 +209,15 @@ func (c controllerCmd) run(cliCtx *cli.Context) error {
 		return nil
 	})
 
+	if interval := cliCtx.Duration(flagKeyRotationInterval); interval > 0 {
+		rotator := keyring.NewRotator(kubeClient, currentNamespace(), interval)
+
+		group.Go(func() error {
+			rotator.Run(ctx)
+			return nil
+		})
+	}
+
 	return group.Wait()
 }
 
@@ -211,7 +227,7 @@ func setupOIDCSecret(cliCtx *cli.Context, client clientset.Interface, token stri
 
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "hub-secret",
+			Name: keyring.SecretName,
 			Annotations: map[string]string{
 				"app.kubernetes.io/managed-by": "traefik-hub",
 			},
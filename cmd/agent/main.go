@@ -50,6 +50,7 @@ func run() error {
 		Commands: []*cli.Command{
 			newControllerCmd().build(),
 			newAuthServerCmd().build(),
+			newACPCmd().build(),
 			newRefreshConfigCmd().build(),
 			newTunnelCmd().build(),
 			newVersionCmd().build(),
@@ -36,10 +36,12 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/ingclass"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/reviewer"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/health"
 	"github.com/traefik/hub-agent-kubernetes/pkg/catalog"
 	catalogadmission "github.com/traefik/hub-agent-kubernetes/pkg/catalog/admission"
 	traefikv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/traefik/v1alpha1"
 	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
+	hubscheme "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned/scheme"
 	hubinformer "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/informers/externalversions"
 	traefikclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/traefik/clientset/versioned"
 	"github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/traefik/clientset/versioned/typed/traefik/v1alpha1"
@@ -50,14 +52,17 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/platform"
 	"github.com/traefik/hub-agent-kubernetes/pkg/topology"
 	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
 	kerror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -179,13 +184,11 @@ func webhookAdmission(ctx context.Context, cliCtx *cli.Context, platformClient *
 		IngressClassName:         cliCtx.String(flagIngressClassName),
 	}
 
-	acpAdmission, edgeIngressAdmission, catalogAdmission, err := setupAdmissionHandlers(ctx, platformClient, topoWatch, authServerAddr, edgeIngressWatcherCfg, catalogWatcherCfg)
+	acpAdmission, webAdmissionACP, edgeIngressAdmission, catalogAdmission, err := setupAdmissionHandlers(ctx, platformClient, topoWatch, authServerAddr, edgeIngressWatcherCfg, catalogWatcherCfg)
 	if err != nil {
 		return fmt.Errorf("create admission handler: %w", err)
 	}
 
-	webAdmissionACP := admission.NewACPHandler(platformClient)
-
 	router := chi.NewRouter()
 	router.Handle("/edge-ingress", edgeIngressAdmission)
 	router.Handle("/catalog", catalogAdmission)
@@ -227,33 +230,33 @@ func webhookAdmission(ctx context.Context, cliCtx *cli.Context, platformClient *
 	return nil
 }
 
-func setupAdmissionHandlers(ctx context.Context, platformClient *platform.Client, topoWatch *topology.Watcher, authServerAddr string, edgeIngressWatcherCfg edgeingress.WatcherConfig, catalogWatcherCfg catalog.WatcherConfig) (acpHdl, edgeIngressHdl, catalogHdl http.Handler, err error) {
+func setupAdmissionHandlers(ctx context.Context, platformClient *platform.Client, topoWatch *topology.Watcher, authServerAddr string, edgeIngressWatcherCfg edgeingress.WatcherConfig, catalogWatcherCfg catalog.WatcherConfig) (acpHdl, acpPolicyHdl, edgeIngressHdl, catalogHdl http.Handler, err error) {
 	config, err := kube.InClusterConfigWithRetrier(2)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("create Kubernetes in-cluster configuration: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("create Kubernetes in-cluster configuration: %w", err)
 	}
 
 	kubeClientSet, err := clientset.NewForConfig(config)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("create Kubernetes client set: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("create Kubernetes client set: %w", err)
 	}
 
 	if err = initIngressClass(ctx, kubeClientSet, edgeIngressWatcherCfg.IngressClassName); err != nil {
-		return nil, nil, nil, fmt.Errorf("initialize ingressClass: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("initialize ingressClass: %w", err)
 	}
 
 	hubClientSet, err := hubclientset.NewForConfig(config)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("create Hub client set: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("create Hub client set: %w", err)
 	}
 	traefikClientSet, err := createTraefikClientSet(kubeClientSet, config)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("create Traefik client set: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("create Traefik client set: %w", err)
 	}
 
 	kubeVers, err := kubeClientSet.Discovery().ServerVersion()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("detect Kubernetes version: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("detect Kubernetes version: %w", err)
 	}
 
 	kubeInformer := informers.NewSharedInformerFactory(kubeClientSet, 5*time.Minute)
@@ -266,12 +269,12 @@ func setupAdmissionHandlers(ctx context.Context, platformClient *platform.Client
 
 	err = startKubeInformer(ctx, kubeVers.GitVersion, kubeInformer, ingClassWatcher)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
 	}
 
 	err = startHubInformer(ctx, hubInformer, ingClassWatcher, acpEventHandler)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
 	}
 
 	oasRegistry := catalog.NewServiceRegistry()
@@ -281,22 +284,30 @@ func setupAdmissionHandlers(ctx context.Context, platformClient *platform.Client
 
 	acpWatcher := acp.NewWatcher(time.Minute, platformClient, hubClientSet, hubInformer)
 
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClientSet.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(hubscheme.Scheme, corev1.EventSource{Component: "hub-agent"})
+
+	acpHealthController := health.NewController(time.Minute, hubInformer.Hub().V1alpha1().AccessControlPolicies().Lister(),
+		hubClientSet, kubeClientSet, kubeInformer, kubeVers.GitVersion, recorder, health.HTTPProber{})
+
 	edgeIngressWatcher, err := edgeingress.NewWatcher(platformClient, hubClientSet, kubeClientSet, traefikClientSet, hubInformer, edgeIngressWatcherCfg)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("create edge ingress watcher: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("create edge ingress watcher: %w", err)
 	}
 
 	err = startKubeInformer(ctx, kubeVers.GitVersion, kubeInformer, ingClassWatcher)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("start kube informer: %w", err)
 	}
 
 	err = startHubInformer(ctx, hubInformer, ingClassWatcher, acpEventHandler)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("start hub informer: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("start hub informer: %w", err)
 	}
 
 	go acpWatcher.Run(ctx)
+	go acpHealthController.Run(ctx)
 	go ingressUpdater.Run(ctx)
 	go edgeIngressWatcher.Run(ctx)
 	go catalogWatcher.Run(ctx)
@@ -312,7 +323,9 @@ func setupAdmissionHandlers(ctx context.Context, platformClient *platform.Client
 		traefikReviewer,
 	}
 
-	return admission.NewHandler(reviewers, traefikReviewer), edgeadmission.NewHandler(platformClient), catalogadmission.NewHandler(platformClient, oasRegistry), nil
+	acpPolicyAdmission := admission.NewACPHandler(platformClient, hubInformer.Hub().V1alpha1().AccessControlPolicies().Lister(), kubeClientSet.CoreV1())
+
+	return admission.NewHandler(reviewers, traefikReviewer), acpPolicyAdmission, edgeadmission.NewHandler(platformClient), catalogadmission.NewHandler(platformClient, oasRegistry), nil
 }
 
 func createTraefikClientSet(clientSet *clientset.Clientset, config *rest.Config) (v1alpha1.TraefikV1alpha1Interface, error) {
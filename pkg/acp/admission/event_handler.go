@@ -20,8 +20,11 @@ package admission
 import (
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 )
 
@@ -30,15 +33,21 @@ type Updatable interface {
 	Update(polName string)
 }
 
-// EventHandler watches ACP resources and calls its set Updatable when they are modified.
+// EventHandler watches ACP resources and calls its set Updatable when they are modified. ACPs referencing a modified ACP
+// are considered modified as well.
 type EventHandler struct {
 	listener Updatable
+
+	referencesMu sync.Mutex
+	// references holds the ACPs referenced by each composite or external authorization ACP.
+	references map[string][]string
 }
 
 // NewEventHandler returns a new event handler meant to listen for ACP changes. It calls the given Updatable when an ACP is modified.
 func NewEventHandler(listener Updatable) *EventHandler {
 	return &EventHandler{
-		listener: listener,
+		listener:   listener,
+		references: make(map[string][]string),
 	}
 }
 
@@ -53,7 +62,8 @@ func (w *EventHandler) OnAdd(obj interface{}) {
 		return
 	}
 
-	w.listener.Update(v.ObjectMeta.Name)
+	w.setReferences(v.ObjectMeta.Name, v.Spec)
+	w.update(v.ObjectMeta.Name)
 }
 
 // OnUpdate implements Kubernetes cache.ResourceEventHandler so it can be used as an informer event handler.
@@ -76,11 +86,13 @@ func (w *EventHandler) OnUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
+	w.setReferences(newACP.ObjectMeta.Name, newACP.Spec)
+
 	if !headersChanged(oldACP.Spec, newACP.Spec) {
 		return
 	}
 
-	w.listener.Update(newACP.ObjectMeta.Name)
+	w.update(newACP.ObjectMeta.Name)
 }
 
 // OnDelete implements Kubernetes cache.ResourceEventHandler so it can be used as an informer event handler.
@@ -94,10 +106,88 @@ func (w *EventHandler) OnDelete(obj interface{}) {
 		return
 	}
 
-	w.listener.Update(v.ObjectMeta.Name)
+	w.referencesMu.Lock()
+	delete(w.references, v.ObjectMeta.Name)
+	w.referencesMu.Unlock()
+
+	w.update(v.ObjectMeta.Name)
+}
+
+func (w *EventHandler) setReferences(polName string, spec hubv1alpha1.AccessControlPolicySpec) {
+	w.referencesMu.Lock()
+	defer w.referencesMu.Unlock()
+
+	refs := policyReferences(spec)
+	if len(refs) == 0 {
+		delete(w.references, polName)
+		return
+	}
+
+	w.references[polName] = refs
+}
+
+// update calls the listener for the given ACP and for all the ACPs referencing it, directly or not.
+func (w *EventHandler) update(polName string) {
+	w.referencesMu.Lock()
+	updated := map[string]struct{}{polName: {}}
+	polNames := []string{polName}
+	for i := 0; i < len(polNames); i++ {
+		for name, refs := range w.references {
+			if _, ok := updated[name]; ok {
+				continue
+			}
+
+			for _, ref := range refs {
+				if ref == polNames[i] {
+					updated[name] = struct{}{}
+					polNames = append(polNames, name)
+					break
+				}
+			}
+		}
+	}
+	w.referencesMu.Unlock()
+
+	for _, name := range polNames {
+		w.listener.Update(name)
+	}
+}
+
+// policyReferences returns the ACPs referenced by the given ACP spec, if it's a composite or an external authorization
+// ACP.
+func policyReferences(spec hubv1alpha1.AccessControlPolicySpec) []string {
+	switch {
+	case spec.Composite != nil:
+		return append(append([]string{}, spec.Composite.AllOf...), spec.Composite.AnyOf...)
+
+	case spec.ExternalAuthz != nil && spec.ExternalAuthz.AuthenticationPolicy != "":
+		return []string{spec.ExternalAuthz.AuthenticationPolicy}
+
+	default:
+		return nil
+	}
+}
+
+// identityTokenHeader returns the header identity tokens are forwarded in by the given ACP spec, if any.
+func identityTokenHeader(spec hubv1alpha1.AccessControlPolicySpec) string {
+	if spec.IdentityToken == nil {
+		return ""
+	}
+
+	return (&idtoken.Config{Header: spec.IdentityToken.Header}).HeaderName()
 }
 
 func headersChanged(oldCfg, newCfg hubv1alpha1.AccessControlPolicySpec) bool {
+	// Policies in audit mode forward the decision they would have made.
+	if (oldCfg.Enforcement == string(acp.EnforcementAudit)) != (newCfg.Enforcement == string(acp.EnforcementAudit)) {
+		return true
+	}
+
+	// Policies forwarding identity tokens forward the header holding them.
+	if identityTokenHeader(oldCfg) != identityTokenHeader(newCfg) {
+		return true
+	}
+
 	switch {
 	case newCfg.OIDC != nil:
 		if oldCfg.OIDC == nil {
@@ -127,9 +217,66 @@ func headersChanged(oldCfg, newCfg hubv1alpha1.AccessControlPolicySpec) bool {
 		}
 
 		return newCfg.BasicAuth.ForwardUsernameHeader != oldCfg.BasicAuth.ForwardUsernameHeader ||
-			newCfg.BasicAuth.StripAuthorizationHeader != oldCfg.BasicAuth.StripAuthorizationHeader
+			newCfg.BasicAuth.StripAuthorizationHeader != oldCfg.BasicAuth.StripAuthorizationHeader ||
+			ldapGroupsHeader(oldCfg.BasicAuth) != ldapGroupsHeader(newCfg.BasicAuth)
+
+	case newCfg.GitHub != nil:
+		if oldCfg.GitHub == nil {
+			return true
+		}
+
+		return !reflect.DeepEqual(oldCfg.GitHub.ForwardHeaders, newCfg.GitHub.ForwardHeaders)
+
+	case newCfg.OAuthIntrospection != nil:
+		if oldCfg.OAuthIntrospection == nil {
+			return true
+		}
+
+		return !reflect.DeepEqual(oldCfg.OAuthIntrospection.ForwardHeaders, newCfg.OAuthIntrospection.ForwardHeaders) ||
+			oldCfg.OAuthIntrospection.StripAuthorizationHeader != newCfg.OAuthIntrospection.StripAuthorizationHeader
+
+	case newCfg.APIKey != nil:
+		if oldCfg.APIKey == nil {
+			return true
+		}
+
+		return !reflect.DeepEqual(oldCfg.APIKey.ForwardHeaders, newCfg.APIKey.ForwardHeaders)
+
+	case newCfg.MTLS != nil:
+		if oldCfg.MTLS == nil {
+			return true
+		}
+
+		return !reflect.DeepEqual(oldCfg.MTLS.ForwardHeaders, newCfg.MTLS.ForwardHeaders)
+
+	case newCfg.IPAllowList != nil:
+		return oldCfg.IPAllowList == nil
+
+	case newCfg.Composite != nil:
+		if oldCfg.Composite == nil {
+			return true
+		}
+
+		return !reflect.DeepEqual(oldCfg.Composite.AllOf, newCfg.Composite.AllOf) ||
+			!reflect.DeepEqual(oldCfg.Composite.AnyOf, newCfg.Composite.AnyOf)
+
+	case newCfg.ExternalAuthz != nil:
+		if oldCfg.ExternalAuthz == nil {
+			return true
+		}
+
+		return oldCfg.ExternalAuthz.AuthenticationPolicy != newCfg.ExternalAuthz.AuthenticationPolicy ||
+			!reflect.DeepEqual(oldCfg.ExternalAuthz.ResponseHeaders, newCfg.ExternalAuthz.ResponseHeaders)
 
 	default:
 		return false
 	}
 }
+
+func ldapGroupsHeader(cfg *hubv1alpha1.AccessControlPolicyBasicAuth) string {
+	if cfg.LDAP == nil {
+		return ""
+	}
+
+	return cfg.LDAP.ForwardGroupsHeader
+}
@@ -87,7 +87,84 @@ func TestEventHandler_OnUpdate(t *testing.T) {
 		createPolicy("2", "my-policy-2", false),
 	)
 
-	expected := []string{"my-policy-1"}
+	auditPolicy := createPolicy("3", "my-policy-3", false)
+	auditPolicy.Spec.Enforcement = "audit"
+	handler.OnUpdate(createPolicy("3", "my-policy-3", false), auditPolicy)
+
+	idTokenPolicy := createPolicy("4", "my-policy-4", false)
+	idTokenPolicy.Spec.IdentityToken = &hubv1alpha1.AccessControlPolicyIdentityToken{}
+	handler.OnUpdate(createPolicy("4", "my-policy-4", false), idTokenPolicy)
+
+	renamedIDTokenPolicy := idTokenPolicy.DeepCopy()
+	renamedIDTokenPolicy.Spec.IdentityToken.Header = "X-Identity"
+	handler.OnUpdate(idTokenPolicy, renamedIDTokenPolicy)
+
+	// Setting the default header explicitly doesn't change the forwarded headers.
+	defaultIDTokenPolicy := idTokenPolicy.DeepCopy()
+	defaultIDTokenPolicy.Spec.IdentityToken.Header = "x-hub-identity"
+	handler.OnUpdate(idTokenPolicy, defaultIDTokenPolicy)
+
+	handler.OnUpdate(renamedIDTokenPolicy, createPolicy("4", "my-policy-4", false))
+
+	expected := []string{"my-policy-1", "my-policy-3", "my-policy-4", "my-policy-4", "my-policy-4"}
 
 	assert.Equal(t, expected, updater.policies)
 }
+
+func TestEventHandler_policyReferences(t *testing.T) {
+	updater := fakeUpdater{}
+
+	handler := NewEventHandler(&updater)
+
+	createComposite := func(name string, allOf ...string) *hubv1alpha1.AccessControlPolicy {
+		return &hubv1alpha1.AccessControlPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: hubv1alpha1.AccessControlPolicySpec{
+				Composite: &hubv1alpha1.AccessControlPolicyComposite{AllOf: allOf},
+			},
+		}
+	}
+
+	handler.OnAdd(createComposite("composite-1", "my-policy-1", "my-policy-2"))
+	handler.OnAdd(createComposite("composite-2", "composite-1"))
+	handler.OnAdd(createComposite("composite-3", "my-policy-2"))
+	updater.policies = nil
+
+	handler.OnUpdate(
+		createPolicy("1", "my-policy-1", false),
+		createPolicy("1", "my-policy-1", true),
+	)
+	assert.Equal(t, []string{"my-policy-1", "composite-1", "composite-2"}, updater.policies)
+
+	// A composite ACP no longer references the deleted ACP once updated.
+	updater.policies = nil
+	handler.OnUpdate(
+		createComposite("composite-1", "my-policy-1", "my-policy-2"),
+		createComposite("composite-1", "my-policy-2"),
+	)
+	handler.OnDelete(createPolicy("1", "my-policy-1", false))
+	assert.Equal(t, []string{"composite-1", "composite-2", "my-policy-1"}, updater.policies)
+
+	// Deleted composite ACPs are no longer updated.
+	updater.policies = nil
+	handler.OnDelete(createComposite("composite-3", "my-policy-2"))
+	handler.OnAdd(createPolicy("2", "my-policy-2", false))
+	assert.Equal(t, []string{"composite-3", "my-policy-2", "composite-1", "composite-2"}, updater.policies)
+
+	// External authorization ACPs are updated along with their authentication ACP.
+	handler.OnAdd(&hubv1alpha1.AccessControlPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "authz"},
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			ExternalAuthz: &hubv1alpha1.AccessControlPolicyExternalAuthz{
+				URL:                  "http://authz.default.svc",
+				AuthenticationPolicy: "my-policy-3",
+			},
+		},
+	})
+	updater.policies = nil
+	handler.OnUpdate(
+		createPolicy("3", "my-policy-3", false),
+		createPolicy("3", "my-policy-3", true),
+	)
+	assert.Equal(t, []string{"my-policy-3", "authz"}, updater.policies)
+}
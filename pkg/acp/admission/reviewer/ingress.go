@@ -23,6 +23,7 @@ import (
 	"fmt"
 
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/dryrun"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -86,7 +87,28 @@ func parseIngressClass(obj []byte) (ingClassName, ingClassAnno string, err error
 	return ing.Spec.IngressClassName, ing.ObjectMeta.Annotations["kubernetes.io/ingress.class"], nil
 }
 
+// headerToForward returns the headers set by the handler of the given ACP. The dry-run decision and identity token
+// headers are only set by top-level handlers, referenced ACPs aren't wrapped.
 func headerToForward(cfg *acp.Config) ([]string, error) {
+	headerToFwd, err := handlerHeaderToForward(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Enforcement == acp.EnforcementAudit && !contains(headerToFwd, dryrun.DecisionHeader) {
+		headerToFwd = append(headerToFwd, dryrun.DecisionHeader)
+	}
+
+	if cfg.IdentityToken != nil && !contains(headerToFwd, cfg.IdentityToken.HeaderName()) {
+		headerToFwd = append(headerToFwd, cfg.IdentityToken.HeaderName())
+	}
+
+	return headerToFwd, nil
+}
+
+// handlerHeaderToForward returns the headers set by the handler of the given ACP method, including the ones of the
+// ACPs it references.
+func handlerHeaderToForward(cfg *acp.Config) ([]string, error) {
 	var headerToFwd []string
 
 	switch {
@@ -102,6 +124,9 @@ func headerToForward(cfg *acp.Config) ([]string, error) {
 		if headerName := cfg.BasicAuth.ForwardUsernameHeader; headerName != "" {
 			headerToFwd = append(headerToFwd, headerName)
 		}
+		if cfg.BasicAuth.LDAP != nil && cfg.BasicAuth.LDAP.ForwardGroupsHeader != "" {
+			headerToFwd = append(headerToFwd, cfg.BasicAuth.LDAP.ForwardGroupsHeader)
+		}
 		if cfg.BasicAuth.StripAuthorizationHeader {
 			headerToFwd = append(headerToFwd, "Authorization")
 		}
@@ -118,6 +143,67 @@ func headerToForward(cfg *acp.Config) ([]string, error) {
 		}
 		headerToFwd = append(headerToFwd, "Authorization", "Cookie")
 
+	case cfg.GitHub != nil:
+		for headerName := range cfg.GitHub.ForwardHeaders {
+			headerToFwd = append(headerToFwd, headerName)
+		}
+		headerToFwd = append(headerToFwd, "Cookie")
+
+	case cfg.OAuthIntrospection != nil:
+		for headerName := range cfg.OAuthIntrospection.ForwardHeaders {
+			headerToFwd = append(headerToFwd, headerName)
+		}
+		if cfg.OAuthIntrospection.StripAuthorizationHeader {
+			headerToFwd = append(headerToFwd, "Authorization")
+		}
+
+	case cfg.APIKey != nil:
+		for headerName := range cfg.APIKey.ForwardHeaders {
+			headerToFwd = append(headerToFwd, headerName)
+		}
+
+	case cfg.MTLS != nil:
+		for headerName := range cfg.MTLS.ForwardHeaders {
+			headerToFwd = append(headerToFwd, headerName)
+		}
+
+	case cfg.IPAllowList != nil:
+		// No header is forwarded.
+
+	case cfg.Composite != nil:
+		for _, ref := range cfg.Composite.References() {
+			refCfg, ok := cfg.Composite.Policies[ref]
+			if !ok {
+				continue
+			}
+
+			refHeaders, err := handlerHeaderToForward(refCfg)
+			if err != nil {
+				return nil, fmt.Errorf("referenced ACP %q: %w", ref, err)
+			}
+
+			for _, headerName := range refHeaders {
+				if !contains(headerToFwd, headerName) {
+					headerToFwd = append(headerToFwd, headerName)
+				}
+			}
+		}
+
+	case cfg.ExternalAuthz != nil:
+		if authnCfg := cfg.ExternalAuthz.AuthenticationConfig; authnCfg != nil {
+			authnHeaders, err := handlerHeaderToForward(authnCfg)
+			if err != nil {
+				return nil, fmt.Errorf("authentication ACP %q: %w", cfg.ExternalAuthz.AuthenticationPolicy, err)
+			}
+			headerToFwd = append(headerToFwd, authnHeaders...)
+		}
+
+		for _, headerName := range cfg.ExternalAuthz.ResponseHeaders {
+			if !contains(headerToFwd, headerName) {
+				headerToFwd = append(headerToFwd, headerName)
+			}
+		}
+
 	default:
 		return nil, errors.New("unsupported ACP type")
 	}
@@ -28,6 +28,7 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/ingclass"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	admv1 "k8s.io/api/admission/v1"
@@ -258,6 +259,23 @@ func TestNginxIngress_Review(t *testing.T) {
 				"custom-annotation":                                 "foobar",
 			},
 		},
+		{
+			desc: "adds IP allow list",
+			config: &acp.Config{
+				IPAllowList: &ipallowlist.Config{
+					SourceRange: []string{"10.0.0.0/8"},
+				},
+			},
+			ingAnnotations: map[string]string{
+				"hub.traefik.io/access-control-policy": "my-policy",
+				"custom-annotation":                    "foobar",
+			},
+			wantPatch: map[string]string{
+				"hub.traefik.io/access-control-policy": "my-policy",
+				"nginx.ingress.kubernetes.io/auth-url": "http://hub-agent.default.svc.cluster.local/my-policy",
+				"custom-annotation":                    "foobar",
+			},
+		},
 		{
 			desc: "adds authentication and strip Authorization header",
 			config: &acp.Config{
@@ -54,5 +54,61 @@ func (p PolGetter) GetConfig(canonicalName string) (*acp.Config, error) {
 		return nil, fmt.Errorf("get ACP: %w", err)
 	}
 
-	return acp.ConfigFromPolicy(policy), nil
+	cfg := acp.ConfigFromPolicy(policy)
+	if err = p.resolveReferences(cfg, []string{canonicalName}); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// resolveReferences resolves the configurations of the ACPs referenced by the given composite or external
+// authorization ACP configuration, recursively. Missing ACPs are ignored as they may be created later on. Visited holds
+// the ACPs being resolved, to stop on reference cycles.
+func (p PolGetter) resolveReferences(cfg *acp.Config, visited []string) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.Composite != nil {
+		cfg.Composite.Policies = make(map[string]*acp.Config)
+	}
+
+	for _, ref := range cfg.References() {
+		if contains(visited, ref) {
+			continue
+		}
+
+		policy, err := p.informer.Hub().V1alpha1().AccessControlPolicies().Lister().Get(ref)
+		if err != nil {
+			if kerror.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("get referenced ACP %q: %w", ref, err)
+		}
+
+		refCfg := acp.ConfigFromPolicy(policy)
+		if err = p.resolveReferences(refCfg, append(visited, ref)); err != nil {
+			return err
+		}
+
+		switch {
+		case cfg.Composite != nil:
+			cfg.Composite.Policies[ref] = refCfg
+		case cfg.ExternalAuthz != nil:
+			cfg.ExternalAuthz.AuthenticationConfig = refCfg
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
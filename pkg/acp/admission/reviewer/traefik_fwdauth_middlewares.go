@@ -81,31 +81,42 @@ func (m FwdAuthMiddlewares) Setup(ctx context.Context, polName, namespace string
 }
 
 func (m *FwdAuthMiddlewares) setupMiddleware(ctx context.Context, name, namespace, canonicalPolName string, cfg *acp.Config) error {
-	logger := log.Ctx(ctx)
-
-	currentMiddleware, err := m.findMiddleware(ctx, name, namespace)
+	spec, deps, err := m.newMiddlewareSpec(name, namespace, canonicalPolName, cfg)
 	if err != nil {
-		return err
+		return fmt.Errorf("new middleware spec: %w", err)
 	}
 
-	if currentMiddleware == nil {
-		logger.Debug().Msg("No ForwardAuth middleware found, creating a new one")
-		return m.createMiddleware(ctx, name, namespace, canonicalPolName, cfg)
+	// Dependencies are set up first so the ACP middleware never references missing middlewares.
+	for depName, depSpec := range deps {
+		if err = m.ensureMiddleware(ctx, depName, namespace, depSpec); err != nil {
+			return err
+		}
 	}
 
-	newSpec, err := m.newMiddlewareSpec(canonicalPolName, cfg)
+	return m.ensureMiddleware(ctx, name, namespace, spec)
+}
+
+func (m *FwdAuthMiddlewares) ensureMiddleware(ctx context.Context, name, namespace string, spec traefikv1alpha1.MiddlewareSpec) error {
+	logger := log.Ctx(ctx).With().Str("middleware_name", name).Logger()
+
+	currentMiddleware, err := m.findMiddleware(ctx, name, namespace)
 	if err != nil {
 		return err
 	}
 
-	if reflect.DeepEqual(currentMiddleware.Spec, newSpec) {
-		logger.Debug().Msg("Existing ForwardAuth middleware is up do date")
+	if currentMiddleware == nil {
+		logger.Debug().Msg("No middleware found, creating a new one")
+		return m.createMiddleware(ctx, name, namespace, spec)
+	}
+
+	if reflect.DeepEqual(currentMiddleware.Spec, spec) {
+		logger.Debug().Msg("Existing middleware is up do date")
 		return nil
 	}
 
-	logger.Debug().Msg("Existing ForwardAuth middleware is outdated, updating it")
+	logger.Debug().Msg("Existing middleware is outdated, updating it")
 
-	currentMiddleware.Spec = newSpec
+	currentMiddleware.Spec = spec
 
 	_, err = m.traefikClientSet.Middlewares(namespace).Update(ctx, currentMiddleware, metav1.UpdateOptions{FieldManager: "hub-auth"})
 	if err != nil {
@@ -127,26 +138,100 @@ func (m *FwdAuthMiddlewares) findMiddleware(ctx context.Context, name, namespace
 	return mdlwr, nil
 }
 
-func (m *FwdAuthMiddlewares) newMiddlewareSpec(canonicalPolName string, cfg *acp.Config) (traefikv1alpha1.MiddlewareSpec, error) {
+// newMiddlewareSpec returns the spec of the ACP middleware along with the specs of the middlewares it depends on, by
+// name. The ACP middleware is a ForwardAuth middleware, except for mTLS policies, or composite policies referencing
+// one: the client certificate must be passed to the auth server, so the ACP middleware chains a PassTLSClientCert
+// middleware and the ForwardAuth middleware.
+func (m *FwdAuthMiddlewares) newMiddlewareSpec(name, namespace, canonicalPolName string, cfg *acp.Config) (traefikv1alpha1.MiddlewareSpec, map[string]traefikv1alpha1.MiddlewareSpec, error) {
 	authResponseHeaders, err := headerToForward(cfg)
 	if err != nil {
-		return traefikv1alpha1.MiddlewareSpec{}, err
+		return traefikv1alpha1.MiddlewareSpec{}, nil, err
 	}
 
-	return traefikv1alpha1.MiddlewareSpec{
+	fwdAuthSpec := traefikv1alpha1.MiddlewareSpec{
 		ForwardAuth: &traefikv1alpha1.ForwardAuth{
 			Address:             m.agentAddress + "/" + canonicalPolName,
 			AuthResponseHeaders: authResponseHeaders,
 		},
-	}, nil
+	}
+
+	if !requiresClientCert(cfg) {
+		return fwdAuthSpec, nil, nil
+	}
+
+	passTLSName := name + "-pass-tls"
+	fwdAuthName := name + "-fwdauth"
+
+	spec := traefikv1alpha1.MiddlewareSpec{
+		Chain: &traefikv1alpha1.Chain{
+			Middlewares: []traefikv1alpha1.MiddlewareRef{
+				{Name: passTLSName, Namespace: namespace},
+				{Name: fwdAuthName, Namespace: namespace},
+			},
+		},
+	}
+
+	deps := map[string]traefikv1alpha1.MiddlewareSpec{
+		passTLSName: {PassTLSClientCert: newPassTLSClientCert()},
+		fwdAuthName: fwdAuthSpec,
+	}
+
+	return spec, deps, nil
 }
 
-func (m *FwdAuthMiddlewares) createMiddleware(ctx context.Context, name, namespace, canonicalPolName string, cfg *acp.Config) error {
-	spec, err := m.newMiddlewareSpec(canonicalPolName, cfg)
-	if err != nil {
-		return fmt.Errorf("new middleware spec: %w", err)
+// requiresClientCert returns whether the given ACP needs the client certificate to be passed to the auth server.
+func requiresClientCert(cfg *acp.Config) bool {
+	if cfg.MTLS != nil {
+		return true
+	}
+
+	if cfg.Composite == nil {
+		return false
 	}
 
+	for _, refCfg := range cfg.Composite.Policies {
+		if requiresClientCert(refCfg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newPassTLSClientCert returns a PassTLSClientCert configuration passing the client certificate and all its
+// information.
+func newPassTLSClientCert() *traefikv1alpha1.PassTLSClientCert {
+	return &traefikv1alpha1.PassTLSClientCert{
+		PEM: true,
+		Info: &traefikv1alpha1.TLSClientCertificateInfo{
+			NotAfter:     true,
+			NotBefore:    true,
+			Sans:         true,
+			SerialNumber: true,
+			Subject: &traefikv1alpha1.TLSClientCertificateSubjectDNInfo{
+				Country:            true,
+				Province:           true,
+				Locality:           true,
+				Organization:       true,
+				OrganizationalUnit: true,
+				CommonName:         true,
+				SerialNumber:       true,
+				DomainComponent:    true,
+			},
+			Issuer: &traefikv1alpha1.TLSClientCertificateIssuerDNInfo{
+				Country:         true,
+				Province:        true,
+				Locality:        true,
+				Organization:    true,
+				CommonName:      true,
+				SerialNumber:    true,
+				DomainComponent: true,
+			},
+		},
+	}
+}
+
+func (m *FwdAuthMiddlewares) createMiddleware(ctx context.Context, name, namespace string, spec traefikv1alpha1.MiddlewareSpec) error {
 	mdlwr := &traefikv1alpha1.Middleware{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
@@ -155,7 +240,7 @@ func (m *FwdAuthMiddlewares) createMiddleware(ctx context.Context, name, namespa
 		Spec: spec,
 	}
 
-	_, err = m.traefikClientSet.Middlewares(namespace).Create(ctx, mdlwr, metav1.CreateOptions{FieldManager: "hub-auth"})
+	_, err := m.traefikClientSet.Middlewares(namespace).Create(ctx, mdlwr, metav1.CreateOptions{FieldManager: "hub-auth"})
 	if err != nil {
 		return fmt.Errorf("create middleware: %w", err)
 	}
@@ -28,11 +28,15 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/ingclass"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/extauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	traefikv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/traefik/v1alpha1"
 	traefikkubemock "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/traefik/clientset/versioned/fake"
 	admv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -360,6 +364,44 @@ func TestTraefikIngress_ReviewAddsAuthentication(t *testing.T) {
 			},
 			wantAuthResponseHeaders: []string{"User", "Authorization"},
 		},
+		{
+			desc: "add Basic authentication in audit mode",
+			config: &acp.Config{
+				BasicAuth: &basicauth.Config{
+					ForwardUsernameHeader: "User",
+				},
+				Enforcement: acp.EnforcementAudit,
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy@test",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy@test",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy-test@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"User", "X-Hub-Acp-Decision"},
+		},
+		{
+			desc: "add Basic authentication with an identity token",
+			config: &acp.Config{
+				BasicAuth: &basicauth.Config{
+					ForwardUsernameHeader: "User",
+				},
+				IdentityToken: &idtoken.Config{
+					Secret: &corev1.SecretReference{Name: "signing-key", Namespace: "hub"},
+				},
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy@test",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy@test",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy-test@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"User", "X-Hub-Identity"},
+		},
 		{
 			desc: "add OIDC authentication",
 			config: &acp.Config{OIDC: &oidc.Config{
@@ -502,6 +544,69 @@ func TestTraefikIngress_ReviewAddsAuthentication(t *testing.T) {
 	}
 }
 
+func TestTraefikIngress_ReviewAddsMTLSMiddlewares(t *testing.T) {
+	traefikClientSet := traefikkubemock.NewSimpleClientset()
+
+	policies := newPolicyGetterMock(t)
+	policies.OnGetConfig("my-policy@test").TypedReturns(&acp.Config{
+		MTLS: &mtls.Config{
+			ForwardHeaders: map[string]string{"X-Client-CN": "subject.commonName"},
+		},
+	}, nil).Once()
+
+	fwdAuthMdlwrs := NewFwdAuthMiddlewares("http://hub-agent-auth-server", policies, traefikClientSet.TraefikV1alpha1())
+
+	rev := NewTraefikIngress(newIngressClassesMock(t), fwdAuthMdlwrs)
+
+	ing := struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}{
+		Metadata: metav1.ObjectMeta{
+			Name:        "name",
+			Namespace:   "test",
+			Annotations: map[string]string{AnnotationHubAuth: "my-policy@test"},
+		},
+	}
+	b, err := json.Marshal(ing)
+	require.NoError(t, err)
+
+	ar := admv1.AdmissionReview{
+		Request: &admv1.AdmissionRequest{
+			Object: runtime.RawExtension{Raw: b},
+		},
+	}
+
+	patch, err := rev.Review(context.Background(), ar)
+	require.NoError(t, err)
+	assert.Equal(t, "test-zz-my-policy-test@kubernetescrd", patch["value"].(map[string]string)["traefik.ingress.kubernetes.io/router.middlewares"])
+
+	m, err := traefikClientSet.TraefikV1alpha1().Middlewares("test").
+		Get(context.Background(), "zz-my-policy-test", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &traefikv1alpha1.Chain{
+		Middlewares: []traefikv1alpha1.MiddlewareRef{
+			{Name: "zz-my-policy-test-pass-tls", Namespace: "test"},
+			{Name: "zz-my-policy-test-fwdauth", Namespace: "test"},
+		},
+	}, m.Spec.Chain)
+	assert.Nil(t, m.Spec.ForwardAuth)
+
+	m, err = traefikClientSet.TraefikV1alpha1().Middlewares("test").
+		Get(context.Background(), "zz-my-policy-test-pass-tls", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, m.Spec.PassTLSClientCert)
+	assert.True(t, m.Spec.PassTLSClientCert.PEM)
+	assert.True(t, m.Spec.PassTLSClientCert.Info.Subject.CommonName)
+
+	m, err = traefikClientSet.TraefikV1alpha1().Middlewares("test").
+		Get(context.Background(), "zz-my-policy-test-fwdauth", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &traefikv1alpha1.ForwardAuth{
+		Address:             "http://hub-agent-auth-server/my-policy@test",
+		AuthResponseHeaders: []string{"X-Client-CN"},
+	}, m.Spec.ForwardAuth)
+}
+
 func TestTraefikIngress_ReviewUpdatesExistingMiddleware(t *testing.T) {
 	tests := []struct {
 		desc                    string
@@ -526,6 +631,123 @@ func TestTraefikIngress_ReviewUpdatesExistingMiddleware(t *testing.T) {
 			},
 			wantAuthResponseHeaders: []string{"Authorization"},
 		},
+		{
+			desc: "Update middleware with composite configuration",
+			config: &acp.Config{
+				Composite: &acp.Composite{
+					AllOf: []string{"my-jwt", "my-basic", "missing"},
+					Policies: map[string]*acp.Config{
+						"my-jwt": {
+							JWT: &jwt.Config{
+								ForwardHeaders:           map[string]string{"X-User": "sub"},
+								StripAuthorizationHeader: true,
+							},
+						},
+						"my-basic": {
+							BasicAuth: &basicauth.Config{
+								ForwardUsernameHeader:    "X-User",
+								StripAuthorizationHeader: true,
+							},
+						},
+					},
+				},
+			},
+			wantAuthResponseHeaders: []string{"X-User", "Authorization"},
+		},
+		{
+			desc: "Update middleware with composite configuration referencing a policy in audit mode",
+			config: &acp.Config{
+				Composite: &acp.Composite{
+					AllOf: []string{"my-jwt"},
+					Policies: map[string]*acp.Config{
+						"my-jwt": {
+							JWT: &jwt.Config{
+								ForwardHeaders: map[string]string{"X-User": "sub"},
+							},
+							Enforcement: acp.EnforcementAudit,
+						},
+					},
+				},
+			},
+			wantAuthResponseHeaders: []string{"X-User"},
+		},
+		{
+			desc: "Update middleware with composite configuration in audit mode",
+			config: &acp.Config{
+				Composite: &acp.Composite{
+					AllOf: []string{"my-jwt"},
+					Policies: map[string]*acp.Config{
+						"my-jwt": {
+							JWT: &jwt.Config{
+								ForwardHeaders: map[string]string{"X-User": "sub"},
+							},
+						},
+					},
+				},
+				Enforcement: acp.EnforcementAudit,
+			},
+			wantAuthResponseHeaders: []string{"X-User", "X-Hub-Acp-Decision"},
+		},
+		{
+			desc: "Update middleware with external authorization configuration",
+			config: &acp.Config{
+				ExternalAuthz: &acp.ExternalAuthz{
+					Config: extauthz.Config{
+						AuthenticationPolicy: "my-jwt",
+						ResponseHeaders:      []string{"X-Tenant", "X-User"},
+					},
+					AuthenticationConfig: &acp.Config{
+						JWT: &jwt.Config{
+							ForwardHeaders:           map[string]string{"X-User": "sub"},
+							StripAuthorizationHeader: true,
+						},
+					},
+				},
+			},
+			wantAuthResponseHeaders: []string{"X-User", "Authorization", "X-Tenant"},
+		},
+		{
+			desc: "Update middleware with external authorization configuration and identity tokens",
+			config: &acp.Config{
+				ExternalAuthz: &acp.ExternalAuthz{
+					Config: extauthz.Config{
+						AuthenticationPolicy: "my-jwt",
+					},
+					AuthenticationConfig: &acp.Config{
+						JWT: &jwt.Config{
+							ForwardHeaders: map[string]string{"X-User": "sub"},
+						},
+						IdentityToken: &idtoken.Config{
+							Header: "X-Authn-Identity",
+							Secret: &corev1.SecretReference{Name: "signing-key", Namespace: "hub"},
+						},
+					},
+				},
+				IdentityToken: &idtoken.Config{
+					Secret: &corev1.SecretReference{Name: "signing-key", Namespace: "hub"},
+				},
+			},
+			wantAuthResponseHeaders: []string{"X-User", "X-Hub-Identity"},
+		},
+		{
+			desc: "Update middleware with composite configuration referencing a policy with an identity token",
+			config: &acp.Config{
+				Composite: &acp.Composite{
+					AnyOf: []string{"my-jwt"},
+					Policies: map[string]*acp.Config{
+						"my-jwt": {
+							JWT: &jwt.Config{
+								ForwardHeaders: map[string]string{"X-User": "sub"},
+							},
+							IdentityToken: &idtoken.Config{
+								Secret: &corev1.SecretReference{Name: "signing-key", Namespace: "hub"},
+							},
+						},
+					},
+				},
+			},
+			wantAuthResponseHeaders: []string{"X-User"},
+		},
 	}
 
 	for _, test := range tests {
@@ -23,14 +23,22 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	hublistersv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/listers/hub/v1alpha1"
 	"github.com/traefik/hub-agent-kubernetes/pkg/platform"
 	admv1 "k8s.io/api/admission/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 type patch struct {
@@ -48,15 +56,20 @@ type Backend interface {
 
 // ACPHandler is an HTTP handler that can be used as a Kubernetes Mutating Admission Controller.
 type ACPHandler struct {
-	backend Backend
-	now     func() time.Time
+	backend  Backend
+	policies hublistersv1alpha1.AccessControlPolicyLister
+	secrets  corev1client.SecretsGetter
+	now      func() time.Time
 }
 
-// NewACPHandler returns a new Handler.
-func NewACPHandler(backend Backend) *ACPHandler {
+// NewACPHandler returns a new Handler. The given ACP lister and Secrets getter are used to validate the resources
+// referenced by ACPs.
+func NewACPHandler(backend Backend, policies hublistersv1alpha1.AccessControlPolicyLister, secrets corev1client.SecretsGetter) *ACPHandler {
 	return &ACPHandler{
-		backend: backend,
-		now:     time.Now,
+		backend:  backend,
+		policies: policies,
+		secrets:  secrets,
+		now:      time.Now,
 	}
 }
 
@@ -149,6 +162,10 @@ func (h ACPHandler) review(ctx context.Context, req *admv1.AdmissionRequest) ([]
 			log.Debug().Str("name", newACP.Name).Str("namespace", newACP.Namespace).Msg("No patch applied since the admission request came from platform")
 			return nil, nil
 		}
+
+		if err = h.validate(ctx, newACP); err != nil {
+			return nil, fmt.Errorf("invalid ACP: %w", err)
+		}
 	}
 
 	switch req.Operation {
@@ -189,6 +206,287 @@ func (h ACPHandler) review(ctx context.Context, req *admv1.AdmissionRequest) ([]
 	}
 }
 
+// validate validates the given ACP against the resources it may reference.
+func (h ACPHandler) validate(ctx context.Context, policy *hubv1alpha1.AccessControlPolicy) error {
+	if claims := claimsExpression(policy.Spec); claims != "" {
+		if _, err := expr.Parse(claims); err != nil {
+			return fmt.Errorf("invalid claims expression: %w", err)
+		}
+	}
+
+	if err := acp.ValidateRules(acp.ConfigFromPolicy(policy)); err != nil {
+		return fmt.Errorf("invalid rules: %w", err)
+	}
+
+	if err := h.validateIdentityToken(ctx, acp.ConfigFromPolicy(policy).IdentityToken); err != nil {
+		return fmt.Errorf("invalid identity token: %w", err)
+	}
+
+	switch {
+	case policy.Spec.JWT != nil:
+		return h.validateJWT(ctx, acp.ConfigFromPolicy(policy).JWT)
+
+	case policy.Spec.BasicAuth != nil:
+		return h.validateBasicAuth(ctx, policy.Spec.BasicAuth)
+
+	case policy.Spec.APIKey != nil:
+		return validateAPIKey(policy.Spec.APIKey)
+
+	case policy.Spec.Composite != nil:
+		return h.validateComposite(policy)
+
+	case policy.Spec.ExternalAuthz != nil:
+		return h.validateExternalAuthz(policy)
+
+	case policy.Spec.OIDC != nil && policy.Spec.OIDC.Provider != nil:
+		if err := acp.ConfigFromPolicy(policy).OIDC.Provider.Validate(); err != nil {
+			return fmt.Errorf("invalid provider: %w", err)
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// validateAPIKey makes sure the Secrets holding keys are selected in a given namespace.
+func validateAPIKey(cfg *hubv1alpha1.AccessControlPolicyAPIKey) error {
+	if len(cfg.SecretSelector) > 0 && cfg.SecretNamespace == "" {
+		return errors.New("a Secret namespace is required along a Secret selector")
+	}
+
+	return nil
+}
+
+// validateBasicAuth makes sure the users Secret referenced by the given configuration exists and holds valid users.
+func (h ACPHandler) validateBasicAuth(ctx context.Context, cfg *hubv1alpha1.AccessControlPolicyBasicAuth) error {
+	if cfg.LDAP != nil {
+		return h.validateLDAP(ctx, cfg)
+	}
+
+	if cfg.UsersSecret == nil {
+		return nil
+	}
+
+	if cfg.UsersSecret.Name == "" || cfg.UsersSecret.Namespace == "" {
+		return errors.New("users Secret must have a name and a namespace")
+	}
+
+	if h.secrets == nil {
+		return nil
+	}
+
+	secret, err := h.secrets.Secrets(cfg.UsersSecret.Namespace).Get(ctx, cfg.UsersSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return fmt.Errorf("users Secret %s/%s not found", cfg.UsersSecret.Namespace, cfg.UsersSecret.Name)
+		}
+		return fmt.Errorf("get users Secret: %w", err)
+	}
+
+	if _, err = basicauth.UsersFromSecretData(secret.Data); err != nil {
+		return fmt.Errorf("invalid users Secret %s/%s: %w", cfg.UsersSecret.Namespace, cfg.UsersSecret.Name, err)
+	}
+
+	return nil
+}
+
+// validateJWT makes sure the given JWT configuration is valid and that its keys Secret, if any, exists and holds
+// supported key material.
+func (h ACPHandler) validateJWT(ctx context.Context, cfg *jwt.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid JWT configuration: %w", err)
+	}
+
+	if cfg.KeysSecret == nil {
+		return nil
+	}
+
+	if cfg.KeysSecret.Name == "" || cfg.KeysSecret.Namespace == "" {
+		return errors.New("keys Secret must have a name and a namespace")
+	}
+
+	if h.secrets == nil {
+		return nil
+	}
+
+	secret, err := h.secrets.Secrets(cfg.KeysSecret.Namespace).Get(ctx, cfg.KeysSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return fmt.Errorf("keys Secret %s/%s not found", cfg.KeysSecret.Namespace, cfg.KeysSecret.Name)
+		}
+		return fmt.Errorf("get keys Secret: %w", err)
+	}
+
+	if _, err = jwt.KeysFromSecretData(secret.Data); err != nil {
+		return fmt.Errorf("invalid keys Secret %s/%s: %w", cfg.KeysSecret.Namespace, cfg.KeysSecret.Name, err)
+	}
+
+	return nil
+}
+
+// validateIdentityToken makes sure the given identity token configuration is valid and that its Secret exists and holds
+// a supported signing key.
+func (h ACPHandler) validateIdentityToken(ctx context.Context, cfg *idtoken.Config) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Secret.Namespace == "" {
+		return errors.New("signing key Secret must have a name and a namespace")
+	}
+
+	if h.secrets == nil {
+		return nil
+	}
+
+	secret, err := h.secrets.Secrets(cfg.Secret.Namespace).Get(ctx, cfg.Secret.Name, metav1.GetOptions{})
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return fmt.Errorf("signing key Secret %s/%s not found", cfg.Secret.Namespace, cfg.Secret.Name)
+		}
+		return fmt.Errorf("get signing key Secret: %w", err)
+	}
+
+	if _, err = idtoken.ParseKey(secret.Data[idtoken.SigningKeySecretKey]); err != nil {
+		return fmt.Errorf("invalid signing key Secret %s/%s: %w", cfg.Secret.Namespace, cfg.Secret.Name, err)
+	}
+
+	return nil
+}
+
+// validateLDAP makes sure the LDAP configuration is valid and that its bind Secret exists and holds credentials.
+func (h ACPHandler) validateLDAP(ctx context.Context, cfg *hubv1alpha1.AccessControlPolicyBasicAuth) error {
+	bindSecret := cfg.LDAP.BindSecret
+	if bindSecret == nil || bindSecret.Name == "" || bindSecret.Namespace == "" {
+		return errors.New("LDAP bind Secret must have a name and a namespace")
+	}
+
+	if h.secrets == nil {
+		return nil
+	}
+
+	secret, err := h.secrets.Secrets(bindSecret.Namespace).Get(ctx, bindSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return fmt.Errorf("LDAP bind Secret %s/%s not found", bindSecret.Namespace, bindSecret.Name)
+		}
+		return fmt.Errorf("get LDAP bind Secret: %w", err)
+	}
+
+	ldapCfg := acp.ConfigFromPolicy(&hubv1alpha1.AccessControlPolicy{
+		Spec: hubv1alpha1.AccessControlPolicySpec{BasicAuth: cfg},
+	}).BasicAuth
+	ldapCfg.LDAP.BindDN = string(secret.Data[basicauth.LDAPBindDNSecretKey])
+	ldapCfg.LDAP.BindPassword = string(secret.Data[basicauth.LDAPBindPasswordSecretKey])
+
+	return ldapCfg.Validate()
+}
+
+// validateExternalAuthz makes sure the given external authorization ACP is well-formed and that its authentication ACP,
+// if it already exists, is a JWT or OIDC ACP.
+func (h ACPHandler) validateExternalAuthz(policy *hubv1alpha1.AccessControlPolicy) error {
+	cfg := acp.ConfigFromPolicy(policy).ExternalAuthz
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.AuthenticationPolicy == "" || h.policies == nil {
+		return nil
+	}
+
+	authnPolicy, err := h.policies.Get(cfg.AuthenticationPolicy)
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get authentication ACP %q: %w", cfg.AuthenticationPolicy, err)
+	}
+
+	return acp.ValidateAuthentication(acp.ConfigFromPolicy(authnPolicy))
+}
+
+// validateComposite makes sure the given composite ACP is well-formed and doesn't introduce a reference cycle.
+func (h ACPHandler) validateComposite(policy *hubv1alpha1.AccessControlPolicy) error {
+	if err := acp.ConfigFromPolicy(policy).Composite.Validate(); err != nil {
+		return err
+	}
+
+	cycle, err := h.findReferenceCycle(policy)
+	if err != nil {
+		return err
+	}
+	if cycle != nil {
+		return fmt.Errorf("reference cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// findReferenceCycle returns the path of the first reference cycle found starting from the given composite ACP, or nil
+// if there is none. The given ACP replaces its current version, if any. Referenced ACPs that don't exist are ignored
+// as they may be created later on.
+func (h ACPHandler) findReferenceCycle(policy *hubv1alpha1.AccessControlPolicy) ([]string, error) {
+	references := func(name string) ([]string, error) {
+		if name == policy.Name {
+			return policyReferences(policy.Spec), nil
+		}
+
+		if h.policies == nil {
+			return nil, nil
+		}
+
+		p, err := h.policies.Get(name)
+		if err != nil {
+			if kerror.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("get referenced ACP %q: %w", name, err)
+		}
+
+		return policyReferences(p.Spec), nil
+	}
+
+	visited := make(map[string]struct{})
+
+	var visit func(path []string) ([]string, error)
+	visit = func(path []string) ([]string, error) {
+		name := path[len(path)-1]
+
+		refs, err := references(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			for i, p := range path {
+				if p == ref {
+					return append(append([]string{}, path[i:]...), ref), nil
+				}
+			}
+
+			if _, ok := visited[ref]; ok {
+				continue
+			}
+
+			cycle, err := visit(append(path, ref))
+			if err != nil || cycle != nil {
+				return cycle, err
+			}
+		}
+
+		visited[name] = struct{}{}
+
+		return nil, nil
+	}
+
+	return visit([]string{policy.Name})
+}
+
 func (h ACPHandler) buildPatches(policy *hubv1alpha1.AccessControlPolicy) ([]byte, error) {
 	var err error
 
@@ -226,3 +524,23 @@ func parseRawACPs(newRaw, oldRaw []byte) (newACP, oldACP *hubv1alpha1.AccessCont
 func isACPRequest(kind metav1.GroupVersionKind) bool {
 	return kind.Kind == "AccessControlPolicy" && kind.Group == "hub.traefik.io" && kind.Version == "v1alpha1"
 }
+
+// claimsExpression returns the claims expression of the given ACP spec, if any.
+func claimsExpression(spec hubv1alpha1.AccessControlPolicySpec) string {
+	switch {
+	case spec.JWT != nil:
+		return spec.JWT.Claims
+
+	case spec.OIDC != nil:
+		return spec.OIDC.Claims
+
+	case spec.OAuthIntrospection != nil:
+		return spec.OAuthIntrospection.Claims
+
+	case spec.MTLS != nil:
+		return spec.MTLS.Claims
+
+	default:
+		return ""
+	}
+}
@@ -20,7 +20,12 @@ package admission
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -30,10 +35,14 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	hublistersv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/listers/hub/v1alpha1"
 	"github.com/traefik/hub-agent-kubernetes/pkg/platform"
 	admv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	kubemock "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestWebhookPolicy_ServeHTTP_Create(t *testing.T) {
@@ -80,7 +89,7 @@ func TestWebhookPolicy_ServeHTTP_Create(t *testing.T) {
 	require.NoError(t, err)
 
 	now := time.Now()
-	h := NewACPHandler(client)
+	h := NewACPHandler(client, nil, nil)
 	h.now = func() time.Time {
 		return now
 	}
@@ -195,7 +204,7 @@ func TestWebhookPolicy_ServeHTTP_Update(t *testing.T) {
 	require.NoError(t, err)
 
 	now := time.Now()
-	h := NewACPHandler(client)
+	h := NewACPHandler(client, nil, nil)
 	h.now = func() time.Time {
 		return now
 	}
@@ -336,7 +345,7 @@ func TestWebhookPolicy_ServeHTTP_Delete(t *testing.T) {
 			require.NoError(t, err)
 
 			now := time.Now()
-			h := NewACPHandler(test.backendMock(t))
+			h := NewACPHandler(test.backendMock(t), nil, nil)
 			h.now = func() time.Time {
 				return now
 			}
@@ -414,7 +423,7 @@ func TestWebhookPolicy_ServeHTTP_UpdateWithSameHash(t *testing.T) {
 	require.NoError(t, err)
 
 	now := time.Now()
-	h := NewACPHandler(nil)
+	h := NewACPHandler(nil, nil, nil)
 	h.now = func() time.Time {
 		return now
 	}
@@ -434,8 +443,922 @@ func TestWebhookPolicy_ServeHTTP_UpdateWithSameHash(t *testing.T) {
 	assert.Equal(t, &wantResp, gotAr.Response)
 }
 
+func TestWebhookPolicy_ServeHTTP_compositeReferences(t *testing.T) {
+	createPolicy := func(name string, spec hubv1alpha1.AccessControlPolicySpec) *hubv1alpha1.AccessControlPolicy {
+		return &hubv1alpha1.AccessControlPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "AccessControlPolicy",
+				APIVersion: "hub.traefik.io/v1alpha1",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       spec,
+		}
+	}
+	allOf := func(refs ...string) hubv1alpha1.AccessControlPolicySpec {
+		return hubv1alpha1.AccessControlPolicySpec{Composite: &hubv1alpha1.AccessControlPolicyComposite{AllOf: refs}}
+	}
+	anyOf := func(refs ...string) hubv1alpha1.AccessControlPolicySpec {
+		return hubv1alpha1.AccessControlPolicySpec{Composite: &hubv1alpha1.AccessControlPolicyComposite{AnyOf: refs}}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(createPolicy("a", allOf("b"))))
+	require.NoError(t, indexer.Add(createPolicy("b", anyOf("jwt"))))
+	require.NoError(t, indexer.Add(createPolicy("jwt", hubv1alpha1.AccessControlPolicySpec{
+		JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "secret"},
+	})))
+	policies := hublistersv1alpha1.NewAccessControlPolicyLister(indexer)
+
+	tests := []struct {
+		desc    string
+		policy  *hubv1alpha1.AccessControlPolicy
+		wantErr string
+	}{
+		{
+			desc:   "valid references",
+			policy: createPolicy("c", allOf("a", "jwt")),
+		},
+		{
+			desc:   "missing reference",
+			policy: createPolicy("c", anyOf("jwt", "unknown")),
+		},
+		{
+			desc:    "self reference",
+			policy:  createPolicy("c", allOf("jwt", "c")),
+			wantErr: "invalid ACP: reference cycle detected: c -> c",
+		},
+		{
+			desc:    "indirect reference cycle",
+			policy:  createPolicy("b", anyOf("jwt", "a")),
+			wantErr: "invalid ACP: reference cycle detected: b -> a -> b",
+		},
+		{
+			desc:    "allOf and anyOf",
+			policy:  createPolicy("c", hubv1alpha1.AccessControlPolicySpec{Composite: &hubv1alpha1.AccessControlPolicyComposite{AllOf: []string{"a"}, AnyOf: []string{"b"}}}),
+			wantErr: "invalid ACP: allOf and anyOf are mutually exclusive",
+		},
+		{
+			desc:    "no reference",
+			policy:  createPolicy("c", allOf()),
+			wantErr: "invalid ACP: missing referenced policies",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(test.policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      test.policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, test.policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, policies, nil).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_externalAuthz(t *testing.T) {
+	createPolicy := func(name string, spec hubv1alpha1.AccessControlPolicySpec) *hubv1alpha1.AccessControlPolicy {
+		return &hubv1alpha1.AccessControlPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "AccessControlPolicy",
+				APIVersion: "hub.traefik.io/v1alpha1",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       spec,
+		}
+	}
+	externalAuthz := func(url, authnPolicy string) hubv1alpha1.AccessControlPolicySpec {
+		return hubv1alpha1.AccessControlPolicySpec{
+			ExternalAuthz: &hubv1alpha1.AccessControlPolicyExternalAuthz{URL: url, AuthenticationPolicy: authnPolicy},
+		}
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(createPolicy("jwt", hubv1alpha1.AccessControlPolicySpec{
+		JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "secret"},
+	})))
+	require.NoError(t, indexer.Add(createPolicy("ips", hubv1alpha1.AccessControlPolicySpec{
+		IPAllowList: &hubv1alpha1.AccessControlPolicyIPAllowList{SourceRange: []string{"10.0.0.0/8"}},
+	})))
+	policies := hublistersv1alpha1.NewAccessControlPolicyLister(indexer)
+
+	tests := []struct {
+		desc    string
+		policy  *hubv1alpha1.AccessControlPolicy
+		wantErr string
+	}{
+		{
+			desc:   "valid",
+			policy: createPolicy("authz", externalAuthz("http://authz.default.svc:8080", "jwt")),
+		},
+		{
+			desc:   "without authentication policy",
+			policy: createPolicy("authz", externalAuthz("http://authz.default.svc:8080", "")),
+		},
+		{
+			desc:   "missing authentication policy",
+			policy: createPolicy("authz", externalAuthz("http://authz.default.svc:8080", "unknown")),
+		},
+		{
+			desc:    "authentication policy is not a JWT or OIDC policy",
+			policy:  createPolicy("authz", externalAuthz("http://authz.default.svc:8080", "ips")),
+			wantErr: "invalid ACP: authentication policy must be a JWT or OIDC policy",
+		},
+		{
+			desc:    "invalid URL",
+			policy:  createPolicy("authz", externalAuthz("authz.default.svc", "jwt")),
+			wantErr: `invalid ACP: unsupported URL scheme ""`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(test.policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      test.policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, test.policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, policies, nil).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_claimsExpression(t *testing.T) {
+	createPolicy := func(spec hubv1alpha1.AccessControlPolicySpec) *hubv1alpha1.AccessControlPolicy {
+		return &hubv1alpha1.AccessControlPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "AccessControlPolicy",
+				APIVersion: "hub.traefik.io/v1alpha1",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+			Spec:       spec,
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		policy  *hubv1alpha1.AccessControlPolicy
+		wantErr string
+	}{
+		{
+			desc: "valid JWT claims expression",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					PublicKey: "secret",
+					Claims:    "Gte(`acr`, 2) && AnyOf(`groups`, `admin`, `ops`)",
+				},
+			}),
+		},
+		{
+			desc: "invalid JWT claims expression",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					PublicKey: "secret",
+					Claims:    "Matches(`email`, `[a-z`)",
+				},
+			}),
+			wantErr: "invalid ACP: invalid claims expression: unable to parse expression: Matches: invalid regular expression: error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			desc: "JWT algorithm without matching key",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					PublicKey:  "secret",
+					Algorithms: []string{"RS256", "HS256"},
+				},
+			}),
+			wantErr: "invalid ACP: invalid JWT configuration: algorithm HS256 requires a signing secret",
+		},
+		{
+			desc: "invalid OIDC claims expression",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Issuer: "https://idp.example.com",
+					Claims: "Equals(`grp`)",
+				},
+			}),
+			wantErr: "invalid ACP: invalid claims expression: unable to parse expression: Equals: expected 2 arguments, got 1",
+		},
+		{
+			desc: "valid OIDC provider allowed groups and domains",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Provider: &hubv1alpha1.OIDCProvider{
+						Name:           "keycloak",
+						URL:            "https://keycloak.example.com",
+						Realm:          "hub",
+						AllowedGroups:  []string{"admin", `"quoted"`},
+						AllowedDomains: []string{"example.com"},
+					},
+				},
+			}),
+		},
+		{
+			desc: "incomplete OIDC provider",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Provider: &hubv1alpha1.OIDCProvider{Name: "azureAD"},
+				},
+			}),
+			wantErr: "invalid ACP: invalid provider: missing Azure AD tenant ID",
+		},
+		{
+			desc: "valid rules",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "secret"},
+				Rules: []hubv1alpha1.AccessControlPolicyRule{
+					{Path: "/healthz", Action: "allow"},
+					{Methods: []string{"POST", "DELETE"}, Claims: "Equals(`grp`, `admin`)"},
+				},
+			}),
+		},
+		{
+			desc: "invalid rule claims expression",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "secret"},
+				Rules: []hubv1alpha1.AccessControlPolicyRule{
+					{Path: "/healthz", Action: "allow"},
+					{Claims: "Equals(`grp`)"},
+				},
+			}),
+			wantErr: "invalid ACP: invalid rules: rule 1: invalid claims expression: unable to parse expression: Equals: expected 2 arguments, got 1",
+		},
+		{
+			desc: "rules on an unsupported policy",
+			policy: createPolicy(hubv1alpha1.AccessControlPolicySpec{
+				IPAllowList: &hubv1alpha1.AccessControlPolicyIPAllowList{SourceRange: []string{"10.0.0.0/8"}},
+				Rules: []hubv1alpha1.AccessControlPolicyRule{
+					{Path: "/healthz", Action: "allow"},
+				},
+			}),
+			wantErr: "invalid ACP: invalid rules: rules are only supported by JWT, OIDC and basic auth policies",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(test.policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      test.policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, test.policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, nil).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_apiKeySecretNamespace(t *testing.T) {
+	tests := []struct {
+		desc    string
+		apiKey  *hubv1alpha1.AccessControlPolicyAPIKey
+		wantErr string
+	}{
+		{
+			desc:   "Secret selector with a namespace",
+			apiKey: &hubv1alpha1.AccessControlPolicyAPIKey{SecretNamespace: "default", SecretSelector: map[string]string{"app": "api-key"}},
+		},
+		{
+			desc:   "inline keys only",
+			apiKey: &hubv1alpha1.AccessControlPolicyAPIKey{Keys: []string{"partner:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}},
+		},
+		{
+			desc:    "Secret selector without namespace",
+			apiKey:  &hubv1alpha1.AccessControlPolicyAPIKey{SecretSelector: map[string]string{"app": "api-key"}},
+			wantErr: "invalid ACP: a Secret namespace is required along a Secret selector",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "AccessControlPolicy",
+					APIVersion: "hub.traefik.io/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					APIKey: test.apiKey,
+				},
+			}
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, nil).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_basicAuthUsersSecret(t *testing.T) {
+	kubeClient := kubemock.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "users"},
+			Data:       map[string][]byte{"users": []byte("test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/\n")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "invalid-users"},
+			Data:       map[string][]byte{"users": []byte("test:password\n")},
+		},
+	)
+
+	tests := []struct {
+		desc        string
+		usersSecret *corev1.SecretReference
+		wantErr     string
+	}{
+		{
+			desc:        "valid users Secret",
+			usersSecret: &corev1.SecretReference{Namespace: "default", Name: "users"},
+		},
+		{
+			desc:        "missing users Secret",
+			usersSecret: &corev1.SecretReference{Namespace: "default", Name: "unknown"},
+			wantErr:     "invalid ACP: users Secret default/unknown not found",
+		},
+		{
+			desc:        "invalid users Secret",
+			usersSecret: &corev1.SecretReference{Namespace: "default", Name: "invalid-users"},
+			wantErr:     `invalid ACP: invalid users Secret default/invalid-users: unsupported password hash for user "test" on line 1`,
+		},
+		{
+			desc:        "users Secret without namespace",
+			usersSecret: &corev1.SecretReference{Name: "users"},
+			wantErr:     "invalid ACP: users Secret must have a name and a namespace",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "AccessControlPolicy",
+					APIVersion: "hub.traefik.io/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					BasicAuth: &hubv1alpha1.AccessControlPolicyBasicAuth{UsersSecret: test.usersSecret},
+				},
+			}
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, kubeClient.CoreV1()).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_basicAuthLDAP(t *testing.T) {
+	kubeClient := kubemock.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ldap"},
+			Data: map[string][]byte{
+				"bindDN":       []byte("cn=admin,dc=example,dc=org"),
+				"bindPassword": []byte("secret"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "empty"},
+		},
+	)
+
+	tests := []struct {
+		desc    string
+		ldap    *hubv1alpha1.AccessControlPolicyBasicAuthLDAP
+		wantErr string
+	}{
+		{
+			desc: "valid LDAP configuration",
+			ldap: &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:        "ldap://ldap.example.org",
+				BindSecret: &corev1.SecretReference{Namespace: "default", Name: "ldap"},
+				BaseDN:     "dc=example,dc=org",
+			},
+		},
+		{
+			desc: "missing bind Secret",
+			ldap: &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:        "ldap://ldap.example.org",
+				BindSecret: &corev1.SecretReference{Namespace: "default", Name: "unknown"},
+				BaseDN:     "dc=example,dc=org",
+			},
+			wantErr: "invalid ACP: LDAP bind Secret default/unknown not found",
+		},
+		{
+			desc: "bind Secret without credentials",
+			ldap: &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:        "ldap://ldap.example.org",
+				BindSecret: &corev1.SecretReference{Namespace: "default", Name: "empty"},
+				BaseDN:     "dc=example,dc=org",
+			},
+			wantErr: "invalid ACP: invalid LDAP configuration: missing bind credentials",
+		},
+		{
+			desc: "bind Secret without namespace",
+			ldap: &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:        "ldap://ldap.example.org",
+				BindSecret: &corev1.SecretReference{Name: "ldap"},
+				BaseDN:     "dc=example,dc=org",
+			},
+			wantErr: "invalid ACP: LDAP bind Secret must have a name and a namespace",
+		},
+		{
+			desc: "unsupported URL scheme",
+			ldap: &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:        "http://ldap.example.org",
+				BindSecret: &corev1.SecretReference{Namespace: "default", Name: "ldap"},
+				BaseDN:     "dc=example,dc=org",
+			},
+			wantErr: `invalid ACP: invalid LDAP configuration: unsupported URL scheme "http"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "AccessControlPolicy",
+					APIVersion: "hub.traefik.io/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					BasicAuth: &hubv1alpha1.AccessControlPolicyBasicAuth{LDAP: test.ldap},
+				},
+			}
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, kubeClient.CoreV1()).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_jwtKeysSecret(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(private)
+	require.NoError(t, err)
+
+	kubeClient := kubemock.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "keys"},
+			Data: map[string][]byte{
+				"signingSecret": []byte("secret"),
+				"decryptionKey": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "invalid-keys"},
+			Data:       map[string][]byte{"decryptionKey": []byte("secret")},
+		},
+	)
+
+	tests := []struct {
+		desc    string
+		jwt     *hubv1alpha1.AccessControlPolicyJWT
+		wantErr string
+	}{
+		{
+			desc: "valid keys Secret",
+			jwt: &hubv1alpha1.AccessControlPolicyJWT{
+				KeysSecret: &corev1.SecretReference{Namespace: "default", Name: "keys"},
+				Algorithms: []string{"HS256"},
+			},
+		},
+		{
+			desc: "keys Secret without namespace",
+			jwt: &hubv1alpha1.AccessControlPolicyJWT{
+				KeysSecret: &corev1.SecretReference{Name: "keys"},
+			},
+			wantErr: "invalid ACP: keys Secret must have a name and a namespace",
+		},
+		{
+			desc: "missing keys Secret",
+			jwt: &hubv1alpha1.AccessControlPolicyJWT{
+				KeysSecret: &corev1.SecretReference{Namespace: "default", Name: "unknown"},
+			},
+			wantErr: "invalid ACP: keys Secret default/unknown not found",
+		},
+		{
+			desc: "invalid decryption key",
+			jwt: &hubv1alpha1.AccessControlPolicyJWT{
+				KeysSecret: &corev1.SecretReference{Namespace: "default", Name: "invalid-keys"},
+			},
+			wantErr: `invalid ACP: invalid keys Secret default/invalid-keys: invalid "decryptionKey" key: empty or ill-formatted private key`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "AccessControlPolicy",
+					APIVersion: "hub.traefik.io/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+				Spec:       hubv1alpha1.AccessControlPolicySpec{JWT: test.jwt},
+			}
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, kubeClient.CoreV1()).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
+func TestWebhookPolicy_ServeHTTP_identityToken(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(private)
+	require.NoError(t, err)
+
+	kubeClient := kubemock.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "signing-key"},
+			Data:       map[string][]byte{"signingKey": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "invalid-signing-key"},
+			Data:       map[string][]byte{"signingKey": []byte("secret")},
+		},
+	)
+
+	tests := []struct {
+		desc          string
+		identityToken *hubv1alpha1.AccessControlPolicyIdentityToken
+		wantErr       string
+	}{
+		{
+			desc: "valid identity token",
+			identityToken: &hubv1alpha1.AccessControlPolicyIdentityToken{
+				Secret: &corev1.SecretReference{Namespace: "default", Name: "signing-key"},
+				Claims: []string{"groups"},
+			},
+		},
+		{
+			desc: "missing signing key Secret",
+			identityToken: &hubv1alpha1.AccessControlPolicyIdentityToken{
+				Secret: &corev1.SecretReference{Namespace: "default", Name: "unknown"},
+			},
+			wantErr: "invalid ACP: invalid identity token: signing key Secret default/unknown not found",
+		},
+		{
+			desc: "invalid signing key",
+			identityToken: &hubv1alpha1.AccessControlPolicyIdentityToken{
+				Secret: &corev1.SecretReference{Namespace: "default", Name: "invalid-signing-key"},
+			},
+			wantErr: "invalid ACP: invalid identity token: invalid signing key Secret default/invalid-signing-key: no PEM data found",
+		},
+		{
+			desc: "reserved claim",
+			identityToken: &hubv1alpha1.AccessControlPolicyIdentityToken{
+				Secret: &corev1.SecretReference{Namespace: "default", Name: "signing-key"},
+				Claims: []string{"exp"},
+			},
+			wantErr: `invalid ACP: invalid identity token: claim "exp" is reserved`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "AccessControlPolicy",
+					APIVersion: "hub.traefik.io/v1alpha1",
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "acp"},
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					IPAllowList:   &hubv1alpha1.AccessControlPolicyIPAllowList{SourceRange: []string{"10.0.0.0/8"}},
+					IdentityToken: test.identityToken,
+				},
+			}
+
+			client := newBackendMock(t)
+			if test.wantErr == "" {
+				client.OnCreateACP(policy).TypedReturns(&acp.ACP{Version: "version-1"}, nil).Once()
+			}
+
+			b := mustMarshal(t, admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					UID: "id",
+					Kind: metav1.GroupVersionKind{
+						Group:   "hub.traefik.io",
+						Version: "v1alpha1",
+						Kind:    "AccessControlPolicy",
+					},
+					Name:      policy.Name,
+					Operation: admv1.Create,
+					Object: runtime.RawExtension{
+						Raw: mustMarshal(t, policy),
+					},
+				},
+				Response: &admv1.AdmissionResponse{},
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+
+			NewACPHandler(client, nil, kubeClient.CoreV1()).ServeHTTP(rec, req)
+
+			var gotAr admv1.AdmissionReview
+			err = json.NewDecoder(rec.Body).Decode(&gotAr)
+			require.NoError(t, err)
+
+			if test.wantErr != "" {
+				assert.False(t, gotAr.Response.Allowed)
+				require.NotNil(t, gotAr.Response.Result)
+				assert.Equal(t, test.wantErr, gotAr.Response.Result.Message)
+				return
+			}
+
+			assert.True(t, gotAr.Response.Allowed)
+		})
+	}
+}
+
 func TestHandler_ServeHTTP_notAnAccessControlPolicy(t *testing.T) {
-	h := NewACPHandler(nil)
+	h := NewACPHandler(nil, nil, nil)
 
 	b := mustMarshal(t, admv1.AdmissionReview{
 		Request: &admv1.AdmissionRequest{
@@ -497,7 +1420,7 @@ func TestHandler_ServeHTTP_unsupportedOperation(t *testing.T) {
 		Response: &admv1.AdmissionResponse{},
 	})
 
-	h := NewACPHandler(nil)
+	h := NewACPHandler(nil, nil, nil)
 
 	rec := httptest.NewRecorder()
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
@@ -0,0 +1,273 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+)
+
+const defaultHeader = "X-Api-Key"
+
+// Keys in the data of Secrets holding API keys.
+const (
+	SecretKeyHash      = "keyHash"
+	SecretKeyConsumer  = "consumer"
+	SecretKeyExpiresAt = "expiresAt"
+	SecretKeyGroups    = "groups"
+)
+
+// Config configures an API key ACP handler.
+type Config struct {
+	// Header, Query and Cookie define where the API key is looked for in requests, in this order.
+	Header string `json:"header,omitempty"`
+	Query  string `json:"query,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+
+	// Keys holds inline keys, given as `consumer:sha256-hex-hash`.
+	Keys []string `json:"keys,omitempty"`
+
+	// SecretNamespace and SecretSelector select the Secrets holding keys.
+	// SecretNamespace is required when SecretSelector is set.
+	SecretNamespace string            `json:"secretNamespace,omitempty"`
+	SecretSelector  map[string]string `json:"secretSelector,omitempty"`
+	// SecretKeys are the keys resolved from the selected Secrets.
+	SecretKeys []Key `json:"-"`
+
+	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the
+	// key metadata: `consumer`, `groups` and `expiresAt`.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// Key is an API key known by its hash.
+type Key struct {
+	Consumer  string
+	Hash      string
+	ExpiresAt time.Time
+	Groups    []string
+}
+
+// KeyFromSecretData builds a key from the data of a Secret.
+// The consumer defaults to the given name when not present in data.
+func KeyFromSecretData(name string, data map[string][]byte) (Key, error) {
+	key := Key{
+		Consumer: name,
+		Hash:     strings.ToLower(strings.TrimSpace(string(data[SecretKeyHash]))),
+	}
+
+	if err := validateHash(key.Hash); err != nil {
+		return Key{}, err
+	}
+
+	if consumer := strings.TrimSpace(string(data[SecretKeyConsumer])); consumer != "" {
+		key.Consumer = consumer
+	}
+
+	if rawExpiresAt := strings.TrimSpace(string(data[SecretKeyExpiresAt])); rawExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, rawExpiresAt)
+		if err != nil {
+			return Key{}, fmt.Errorf("parse %s: %w", SecretKeyExpiresAt, err)
+		}
+		key.ExpiresAt = expiresAt
+	}
+
+	for _, group := range strings.Split(string(data[SecretKeyGroups]), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			key.Groups = append(key.Groups, group)
+		}
+	}
+
+	return key, nil
+}
+
+// Handler is an API key ACP Handler.
+type Handler struct {
+	name string
+
+	header string
+	query  string
+	cookie string
+
+	keys       map[string]Key
+	fwdHeaders map[string]string
+
+	now func() time.Time
+}
+
+// NewHandler creates a new API key ACP Handler.
+func NewHandler(cfg *Config, name string) (*Handler, error) {
+	keys := make(map[string]Key, len(cfg.Keys)+len(cfg.SecretKeys))
+
+	for _, rawKey := range cfg.Keys {
+		key, err := parseInlineKey(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		keys[key.Hash] = key
+	}
+
+	for _, key := range cfg.SecretKeys {
+		if err := validateHash(key.Hash); err != nil {
+			return nil, fmt.Errorf("invalid key for consumer %q: %w", key.Consumer, err)
+		}
+		keys[key.Hash] = key
+	}
+
+	h := &Handler{
+		name:       name,
+		header:     cfg.Header,
+		query:      cfg.Query,
+		cookie:     cfg.Cookie,
+		keys:       keys,
+		fwdHeaders: cfg.ForwardHeaders,
+		now:        time.Now,
+	}
+
+	if h.header == "" && h.query == "" && h.cookie == "" {
+		h.header = defaultHeader
+	}
+
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "APIKey").Str("handler_name", h.name).Logger()
+
+	rawKey := h.extractKey(req)
+	if rawKey == "" {
+		l.Debug().Msg("No API key found in request")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(rawKey))
+	key, ok := h.keys[hex.EncodeToString(sum[:])]
+	if !ok {
+		l.Debug().Msg("Unknown API key")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !key.ExpiresAt.IsZero() && !h.now().Before(key.ExpiresAt) {
+		l.Debug().Str("consumer", key.Consumer).Msg("Expired API key")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	metadata := key.metadata()
+
+	hdrs, err := expr.PluckClaims(h.fwdHeaders, metadata)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to set forwarded header")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, vals := range hdrs {
+		for _, val := range vals {
+			rw.Header().Add(name, val)
+		}
+	}
+
+	identity.RecordClaims(req.Context(), metadata)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// extractKey extracts an API key from an HTTP request. It looks in the configured header, query parameter and cookie,
+// in this order.
+func (h *Handler) extractKey(req *http.Request) string {
+	if h.header != "" {
+		if key := req.Header.Get(h.header); key != "" {
+			return key
+		}
+	}
+
+	if h.query != "" {
+		if key := req.URL.Query().Get(h.query); key != "" {
+			return key
+		}
+	}
+
+	if h.cookie != "" {
+		if c, err := req.Cookie(h.cookie); err == nil {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+func (k Key) metadata() map[string]interface{} {
+	md := map[string]interface{}{
+		"consumer": k.Consumer,
+	}
+
+	if len(k.Groups) > 0 {
+		groups := make([]interface{}, 0, len(k.Groups))
+		for _, group := range k.Groups {
+			groups = append(groups, group)
+		}
+		md["groups"] = groups
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		md["expiresAt"] = k.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return md
+}
+
+func parseInlineKey(rawKey string) (Key, error) {
+	split := strings.Split(rawKey, ":")
+	if len(split) != 2 {
+		return Key{}, fmt.Errorf("parse API key: %v", rawKey)
+	}
+
+	key := Key{
+		Consumer: split[0],
+		Hash:     strings.ToLower(split[1]),
+	}
+	if err := validateHash(key.Hash); err != nil {
+		return Key{}, fmt.Errorf("invalid key for consumer %q: %w", key.Consumer, err)
+	}
+
+	return key, nil
+}
+
+func validateHash(hash string) error {
+	if hash == "" {
+		return errors.New("missing key hash")
+	}
+
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) != sha256.Size {
+		return errors.New("key hash must be a hex-encoded SHA-256 digest")
+	}
+
+	return nil
+}
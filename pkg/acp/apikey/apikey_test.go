@@ -0,0 +1,238 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hash of "password".
+const passwordHash = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+
+// Hash of "secret".
+const secretHash = "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+func TestNewHandler_invalidKeys(t *testing.T) {
+	_, err := NewHandler(&Config{Keys: []string{"partner"}}, "acp")
+	require.Error(t, err)
+
+	_, err = NewHandler(&Config{Keys: []string{"partner:password"}}, "acp")
+	require.Error(t, err)
+
+	_, err = NewHandler(&Config{SecretKeys: []Key{{Consumer: "partner", Hash: "abc"}}}, "acp")
+	require.Error(t, err)
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc        string
+		cfg         Config
+		req         func(req *http.Request)
+		wantCode    int
+		wantHeaders map[string][]string
+	}{
+		{
+			desc:     "no key",
+			cfg:      Config{Keys: []string{"partner:" + passwordHash}},
+			req:      func(req *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "unknown key",
+			cfg:  Config{Keys: []string{"partner:" + passwordHash}},
+			req: func(req *http.Request) {
+				req.Header.Set("X-Api-Key", "unknown")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "inline key in default header",
+			cfg: Config{
+				Keys:           []string{"partner:" + passwordHash},
+				ForwardHeaders: map[string]string{"X-Consumer": "consumer"},
+			},
+			req: func(req *http.Request) {
+				req.Header.Set("X-Api-Key", "password")
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-Consumer": {"partner"},
+			},
+		},
+		{
+			desc: "secret key in query",
+			cfg: Config{
+				Query: "api_key",
+				SecretKeys: []Key{
+					{Consumer: "partner", Hash: secretHash, Groups: []string{"reader", "writer"}},
+				},
+				ForwardHeaders: map[string]string{
+					"X-Consumer": "consumer",
+					"X-Groups":   "groups",
+				},
+			},
+			req: func(req *http.Request) {
+				req.URL.RawQuery = "api_key=secret"
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-Consumer": {"partner"},
+				"X-Groups":   {"reader", "writer"},
+			},
+		},
+		{
+			desc: "secret key in cookie",
+			cfg: Config{
+				Cookie:     "api_key",
+				SecretKeys: []Key{{Consumer: "partner", Hash: secretHash}},
+			},
+			req: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "api_key", Value: "secret"})
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc: "key in an unconfigured source",
+			cfg: Config{
+				Cookie:     "api_key",
+				SecretKeys: []Key{{Consumer: "partner", Hash: secretHash}},
+			},
+			req: func(req *http.Request) {
+				req.Header.Set("X-Api-Key", "secret")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "expired key",
+			cfg: Config{
+				SecretKeys: []Key{{Consumer: "partner", Hash: secretHash, ExpiresAt: now}},
+			},
+			req: func(req *http.Request) {
+				req.Header.Set("X-Api-Key", "secret")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "not yet expired key",
+			cfg: Config{
+				SecretKeys:     []Key{{Consumer: "partner", Hash: secretHash, ExpiresAt: now.Add(time.Hour)}},
+				ForwardHeaders: map[string]string{"X-Expires-At": "expiresAt"},
+			},
+			req: func(req *http.Request) {
+				req.Header.Set("X-Api-Key", "secret")
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-Expires-At": {"2023-01-01T01:00:00Z"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&test.cfg, "acp")
+			require.NoError(t, err)
+			handler.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			test.req(req)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, values := range test.wantHeaders {
+				assert.Equal(t, values, rw.Header().Values(name))
+			}
+		})
+	}
+}
+
+func TestKeyFromSecretData(t *testing.T) {
+	tests := []struct {
+		desc    string
+		data    map[string][]byte
+		want    Key
+		wantErr bool
+	}{
+		{
+			desc: "hash only",
+			data: map[string][]byte{
+				"keyHash": []byte(secretHash),
+			},
+			want: Key{Consumer: "my-secret", Hash: secretHash},
+		},
+		{
+			desc: "all metadata",
+			data: map[string][]byte{
+				"keyHash":   []byte(secretHash),
+				"consumer":  []byte("partner"),
+				"expiresAt": []byte("2023-01-01T00:00:00Z"),
+				"groups":    []byte("reader, writer"),
+			},
+			want: Key{
+				Consumer:  "partner",
+				Hash:      secretHash,
+				ExpiresAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				Groups:    []string{"reader", "writer"},
+			},
+		},
+		{
+			desc:    "missing hash",
+			data:    map[string][]byte{"consumer": []byte("partner")},
+			wantErr: true,
+		},
+		{
+			desc: "invalid expiration date",
+			data: map[string][]byte{
+				"keyHash":   []byte(secretHash),
+				"expiresAt": []byte("tomorrow"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := KeyFromSecretData("my-secret", test.data)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package audit records an audit event for each decision made by the ACPs of the auth server.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+)
+
+// redacted replaces the values of redacted claims.
+const redacted = "REDACTED"
+
+// Event is an audit event, describing a decision made by an ACP.
+type Event struct {
+	Time    time.Time              `json:"time"`
+	Policy  string                 `json:"policy"`
+	Type    string                 `json:"type"`
+	Method  string                 `json:"method,omitempty"`
+	Host    string                 `json:"host,omitempty"`
+	URI     string                 `json:"uri,omitempty"`
+	Subject string                 `json:"subject,omitempty"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+	Result  string                 `json:"result"`
+	Reason  string                 `json:"reason"`
+}
+
+// Sink ships audit events.
+type Sink interface {
+	Send(ctx context.Context, event Event)
+}
+
+// Config configures the content of audit events.
+type Config struct {
+	// Claims lists the claims of the authenticated identity to add to events.
+	Claims []string
+	// RedactClaims lists the claims whose values are replaced in events. Redacting the `sub` claim redacts the subject.
+	RedactClaims []string
+	// RedactQuery removes the query of forwarded URIs.
+	RedactQuery bool
+}
+
+// Auditor records audit events in a Sink.
+type Auditor struct {
+	sink Sink
+	cfg  Config
+
+	now func() time.Time
+}
+
+// NewAuditor returns a new Auditor sending events to the given sink.
+func NewAuditor(sink Sink, cfg Config) *Auditor {
+	return &Auditor{
+		sink: sink,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Handler returns the given ACP handler, recording an audit event for each of its decisions.
+func (a *Auditor) Handler(handler http.Handler, policy, typ string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx, rec := identity.WithRecorder(req.Context())
+		srw := &statusResponseWriter{ResponseWriter: rw}
+
+		handler.ServeHTTP(srw, req.WithContext(ctx))
+
+		code := srw.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+
+		a.sink.Send(req.Context(), a.newEvent(req, rec.Claims(), policy, typ, code))
+	})
+}
+
+func (a *Auditor) newEvent(req *http.Request, claims map[string]interface{}, policy, typ string, code int) Event {
+	event := Event{
+		Time:   a.now().UTC(),
+		Policy: policy,
+		Type:   typ,
+		Method: req.Header.Get("X-Forwarded-Method"),
+		Host:   req.Header.Get("X-Forwarded-Host"),
+		URI:    req.Header.Get("X-Forwarded-Uri"),
+		Result: authmetrics.Result(code),
+		Reason: authmetrics.Reason(req.Context(), code),
+	}
+
+	if a.cfg.RedactQuery {
+		if u, err := url.ParseRequestURI(event.URI); err == nil {
+			u.RawQuery = ""
+			event.URI = u.String()
+		}
+	}
+
+	if sub, ok := claims["sub"].(string); ok {
+		event.Subject = sub
+		if a.isRedacted("sub") {
+			event.Subject = redacted
+		}
+	}
+
+	for _, name := range a.cfg.Claims {
+		value, ok := claims[name]
+		if !ok {
+			continue
+		}
+
+		if event.Claims == nil {
+			event.Claims = make(map[string]interface{})
+		}
+
+		if a.isRedacted(name) {
+			value = redacted
+		}
+		event.Claims[name] = value
+	}
+
+	return event
+}
+
+func (a *Auditor) isRedacted(claim string) bool {
+	for _, name := range a.cfg.RedactClaims {
+		if name == claim {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statusResponseWriter is an http.ResponseWriter recording the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+)
+
+func TestAuditor_Handler(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	allow := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		identity.RecordClaims(req.Context(), map[string]interface{}{
+			"sub":   "jane",
+			"email": "jane@example.com",
+			"group": "admin",
+		})
+		rw.WriteHeader(http.StatusOK)
+	})
+	deny := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidToken)
+		rw.WriteHeader(http.StatusUnauthorized)
+	})
+
+	tests := []struct {
+		desc      string
+		cfg       Config
+		handler   http.Handler
+		wantEvent Event
+	}{
+		{
+			desc:    "allowed",
+			cfg:     Config{Claims: []string{"group", "missing"}},
+			handler: allow,
+			wantEvent: Event{
+				Time:    now,
+				Policy:  "my-policy",
+				Type:    "JWT",
+				Method:  http.MethodGet,
+				Host:    "app.example.com",
+				URI:     "/foo?token=secret",
+				Subject: "jane",
+				Claims:  map[string]interface{}{"group": "admin"},
+				Result:  authmetrics.ResultAllowed,
+				Reason:  "authorized",
+			},
+		},
+		{
+			desc: "redacted",
+			cfg: Config{
+				Claims:       []string{"group", "email"},
+				RedactClaims: []string{"sub", "email"},
+				RedactQuery:  true,
+			},
+			handler: allow,
+			wantEvent: Event{
+				Time:    now,
+				Policy:  "my-policy",
+				Type:    "JWT",
+				Method:  http.MethodGet,
+				Host:    "app.example.com",
+				URI:     "/foo",
+				Subject: "REDACTED",
+				Claims:  map[string]interface{}{"group": "admin", "email": "REDACTED"},
+				Result:  authmetrics.ResultAllowed,
+				Reason:  "authorized",
+			},
+		},
+		{
+			desc:    "denied",
+			handler: deny,
+			wantEvent: Event{
+				Time:   now,
+				Policy: "my-policy",
+				Type:   "JWT",
+				Method: http.MethodGet,
+				Host:   "app.example.com",
+				URI:    "/foo?token=secret",
+				Result: authmetrics.ResultDenied,
+				Reason: authmetrics.ReasonInvalidToken,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &recordingSink{}
+			auditor := NewAuditor(sink, test.cfg)
+			auditor.now = func() time.Time { return now }
+
+			handler := authmetrics.Handler(auditor.Handler(test.handler, "my-policy", "JWT"), "my-policy", "JWT")
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/my-policy", nil)
+			req.Header.Set("X-Forwarded-Method", http.MethodGet)
+			req.Header.Set("X-Forwarded-Host", "app.example.com")
+			req.Header.Set("X-Forwarded-Uri", "/foo?token=secret")
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			require.Len(t, sink.events, 1)
+			assert.Equal(t, test.wantEvent, sink.events[0])
+		})
+	}
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Send(_ context.Context, event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+}
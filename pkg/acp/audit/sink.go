@@ -0,0 +1,106 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// WriterSink writes audit events to an io.Writer as JSON lines.
+type WriterSink struct {
+	name string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a new WriterSink writing to w. The name identifies the sink in logs and metrics.
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{
+		name: name,
+		w:    w,
+	}
+}
+
+// Send writes the given event.
+func (s *WriterSink) Send(_ context.Context, event Event) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Str("sink", s.name).Msg("Unable to marshal audit event")
+		authmetrics.ObserveAuditEventsDropped(s.name, 1)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err = s.w.Write(append(b, '\n')); err != nil {
+		log.Error().Err(err).Str("sink", s.name).Msg("Unable to write audit event")
+		authmetrics.ObserveAuditEventsDropped(s.name, 1)
+	}
+}
+
+// Close closes the underlying writer if it's an io.Closer.
+func (s *WriterSink) Close() error {
+	closer, ok := s.w.(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return closer.Close()
+}
+
+// FileConfig configures a FileSink.
+type FileConfig struct {
+	// Path is the path of the file events are written to.
+	Path string
+	// MaxSize is the size in megabytes the file is rotated at.
+	MaxSize int
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+	// MaxAge is the number of days rotated files are kept for. Zero keeps them regardless of their age.
+	MaxAge int
+	// Compress compresses rotated files with gzip.
+	Compress bool
+}
+
+// NewFileSink returns a new sink writing events to a local file, rotated once it reaches its maximum size.
+func NewFileSink(cfg FileConfig) (*WriterSink, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing audit file path")
+	}
+
+	return NewWriterSink("file", &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	}), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterSink_Send(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink("test", &buf)
+
+	sink.Send(context.Background(), Event{Policy: "a", Result: "allowed"})
+	sink.Send(context.Background(), Event{Policy: "b", Result: "denied"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, "b", event.Policy)
+	assert.Equal(t, "denied", event.Result)
+}
+
+func TestNewFileSink(t *testing.T) {
+	_, err := NewFileSink(FileConfig{})
+	assert.EqualError(t, err, "missing audit file path")
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(FileConfig{Path: path, MaxSize: 1})
+	require.NoError(t, err)
+
+	sink.Send(context.Background(), Event{Time: time.Now(), Policy: "my-policy"})
+	require.NoError(t, sink.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"policy":"my-policy"`)
+}
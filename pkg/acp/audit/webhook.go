@@ -0,0 +1,230 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/logger"
+	"github.com/traefik/hub-agent-kubernetes/pkg/version"
+)
+
+const (
+	defaultWebhookBatchSize     = 100
+	defaultWebhookFlushInterval = 5 * time.Second
+	defaultWebhookQueueSize     = 10000
+	defaultWebhookBlockTimeout  = 100 * time.Millisecond
+	webhookShutdownTimeout      = 10 * time.Second
+	webhookRequestTimeout       = 10 * time.Second
+	webhookPostTimeout          = 30 * time.Second
+)
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	// URL is the URL events are posted to, as JSON arrays.
+	URL string
+	// BatchSize is the maximum number of events posted at once.
+	BatchSize int
+	// FlushInterval is the maximum time events wait before being posted.
+	FlushInterval time.Duration
+	// QueueSize is the maximum number of events waiting to be posted.
+	QueueSize int
+	// BlockTimeout is the maximum time requests wait for room in a full queue before their event is dropped.
+	BlockTimeout time.Duration
+}
+
+// WebhookSink posts audit events to an HTTP webhook, in batches. Events are queued, and once the queue is full,
+// requests are slowed down for at most BlockTimeout waiting for the webhook to catch up before their events are dropped.
+type WebhookSink struct {
+	url           string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	blockTimeout  time.Duration
+	// postTimeout is the maximum time spent posting a batch, retries included.
+	postTimeout time.Duration
+
+	queue chan Event
+}
+
+// NewWebhookSink returns a new WebhookSink. Run must be called for events to be posted.
+func NewWebhookSink(cfg WebhookConfig) (*WebhookSink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported webhook URL scheme %q", u.Scheme)
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWebhookBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultWebhookFlushInterval
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultWebhookQueueSize
+	}
+	if cfg.BlockTimeout < 0 {
+		return nil, errors.New("block timeout must be positive")
+	}
+	if cfg.BlockTimeout == 0 {
+		cfg.BlockTimeout = defaultWebhookBlockTimeout
+	}
+
+	rc := retryablehttp.NewClient()
+	rc.RetryWaitMin = time.Second
+	rc.RetryWaitMax = 10 * time.Second
+	rc.RetryMax = 4
+	rc.HTTPClient.Timeout = webhookRequestTimeout
+	rc.Logger = logger.NewRetryableHTTPWrapper(log.Logger.With().Str("component", "audit_webhook_client").Logger())
+
+	return &WebhookSink{
+		url:           cfg.URL,
+		client:        rc.StandardClient(),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		blockTimeout:  cfg.BlockTimeout,
+		postTimeout:   webhookPostTimeout,
+		queue:         make(chan Event, cfg.QueueSize),
+	}, nil
+}
+
+// Send queues the given event.
+func (s *WebhookSink) Send(ctx context.Context, event Event) {
+	select {
+	case s.queue <- event:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(s.blockTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.queue <- event:
+	case <-timer.C:
+		log.Warn().Str("sink", "webhook").Msg("Audit queue is full, dropping event")
+		authmetrics.ObserveAuditEventsDropped("webhook", 1)
+	case <-ctx.Done():
+		authmetrics.ObserveAuditEventsDropped("webhook", 1)
+	}
+}
+
+// Run posts queued events until the given context is done. Queued events are then flushed.
+func (s *WebhookSink) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, s.batchSize)
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < s.batchSize {
+				continue
+			}
+
+			s.flush(ctx, batch)
+			batch = batch[:0]
+
+		case <-ticker.C:
+			s.flush(ctx, batch)
+			batch = batch[:0]
+
+		case <-ctx.Done():
+			s.shutdown(batch)
+			return
+		}
+	}
+}
+
+// shutdown posts the given batch along with the events still queued.
+func (s *WebhookSink) shutdown(batch []Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < s.batchSize {
+				continue
+			}
+
+			s.flush(ctx, batch)
+			batch = batch[:0]
+
+		default:
+			s.flush(ctx, batch)
+			return
+		}
+	}
+}
+
+func (s *WebhookSink) flush(ctx context.Context, batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctxPost, cancel := context.WithTimeout(ctx, s.postTimeout)
+	defer cancel()
+
+	if err := s.post(ctxPost, batch); err != nil {
+		log.Error().Err(err).Int("events", len(batch)).Msg("Unable to post audit events")
+		authmetrics.ObserveAuditEventsDropped("webhook", len(batch))
+	}
+}
+
+func (s *WebhookSink) post(ctx context.Context, batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	version.SetUserAgent(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post events: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhookSink(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     WebhookConfig
+		wantErr string
+	}{
+		{
+			desc: "valid",
+			cfg:  WebhookConfig{URL: "https://audit.example.com"},
+		},
+		{
+			desc:    "unsupported scheme",
+			cfg:     WebhookConfig{URL: "ftp://audit.example.com"},
+			wantErr: `unsupported webhook URL scheme "ftp"`,
+		},
+		{
+			desc:    "negative block timeout",
+			cfg:     WebhookConfig{URL: "https://audit.example.com", BlockTimeout: -time.Second},
+			wantErr: "block timeout must be positive",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			sink, err := NewWebhookSink(test.cfg)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, defaultWebhookBatchSize, sink.batchSize)
+			assert.Equal(t, defaultWebhookFlushInterval, sink.flushInterval)
+			assert.Equal(t, defaultWebhookQueueSize, cap(sink.queue))
+			assert.Equal(t, defaultWebhookBlockTimeout, sink.blockTimeout)
+		})
+	}
+}
+
+func TestWebhookSink_Run(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var batch []Event
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	sink, err := NewWebhookSink(WebhookConfig{URL: srv.URL, BatchSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	sink.client = srv.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.Run(ctx)
+		close(done)
+	}()
+
+	for _, policy := range []string{"a", "b", "c"} {
+		sink.Send(context.Background(), Event{Policy: policy})
+	}
+
+	// The first batch is posted once full, the remaining event is flushed on shutdown.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(batches) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, batches, 2)
+	assert.Equal(t, []Event{{Policy: "a"}, {Policy: "b"}}, batches[0])
+	assert.Equal(t, []Event{{Policy: "c"}}, batches[1])
+}
+
+func TestWebhookSink_flush_hangingWebhook(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	sink, err := NewWebhookSink(WebhookConfig{URL: srv.URL})
+	require.NoError(t, err)
+	sink.client = srv.Client()
+	sink.postTimeout = 50 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		sink.flush(context.Background(), []Event{{Policy: "a"}})
+		close(done)
+	}()
+
+	// Posting the batch is given up once the post timeout is reached.
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("flush is blocked by the hanging webhook")
+	}
+}
+
+func TestWebhookSink_Send_fullQueue(t *testing.T) {
+	sink, err := NewWebhookSink(WebhookConfig{URL: "http://audit.example.com", QueueSize: 1, BlockTimeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	sink.Send(context.Background(), Event{Policy: "a"})
+
+	start := time.Now()
+	sink.Send(context.Background(), Event{Policy: "b"})
+
+	// The second event waited for room in the queue before being dropped.
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	require.Len(t, sink.queue, 1)
+	assert.Equal(t, "a", (<-sink.queue).Policy)
+}
@@ -20,6 +20,8 @@ package auth
 import (
 	"net/http"
 	"sync"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
 )
 
 // HTTPHandlerSwitcher allows hot switching of http.ServeMux.
@@ -43,7 +45,7 @@ func (h *HTTPHandlerSwitcher) ServeHTTP(rw http.ResponseWriter, req *http.Reques
 	handler.ServeHTTP(rw, req)
 }
 
-// UpdateHandler safely updates the current http.ServeMux with a new one.
+// UpdateHandler safely updates the current http.ServeMux with a new one. Each update is counted as a route rebuild.
 func (h *HTTPHandlerSwitcher) UpdateHandler(handler http.Handler) {
 	if handler == nil {
 		return
@@ -52,4 +54,6 @@ func (h *HTTPHandlerSwitcher) UpdateHandler(handler http.Handler) {
 	h.handlerMu.Lock()
 	h.handler = handler
 	h.handlerMu.Unlock()
+
+	authmetrics.ObserveRouteRebuild()
 }
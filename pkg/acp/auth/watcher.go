@@ -22,16 +22,31 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/audit"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/composite"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/dryrun"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/extauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/github"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/keyring"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // NOTE: if we use the same watcher for all resources, then we need to restart it when new CRDs are
@@ -40,34 +55,47 @@ import (
 // Also, if multiple clients of this watcher are not interested in the same resources
 // add a parameter to NewWatcher to subscribe only to a subset of events.
 
-type oidcSecret struct {
-	ClientSecret string
-}
-
 // Watcher watches access control policy resources and builds configurations out of them.
 type Watcher struct {
-	key string
+	// keys is the initial keyring encrypting cookies. It is superseded by the keyring held by the hub Secret of
+	// keysNamespace once known, so that keys can be rotated.
+	keys          keyring.Keyring
+	keysNamespace string
 
 	configsMu sync.RWMutex
 	configs   map[string]*acp.Config
 	previous  uint64
 
-	secrets map[string]oidcSecret
+	secrets    map[string]*corev1.Secret
+	configMaps map[string]*corev1.ConfigMap
+
+	// sessionBackends holds the backends of server-side OIDC session stores, by store name. They outlive handlers so
+	// that sessions aren't lost when handlers are rebuilt.
+	sessionBackends oidc.SessionBackends
 
 	refresh chan struct{}
 
 	switcher *HTTPHandlerSwitcher
+	auditor  *audit.Auditor
+	issuer   *idtoken.Issuer
 }
 
 // NewWatcher returns a new watcher to track ACP resources. It calls the given Updater when an ACP is modified at most
-// once every throttle.
-func NewWatcher(switcher *HTTPHandlerSwitcher, key string) *Watcher {
+// once every throttle. The given session backends are used by OIDC policies storing sessions server-side. Cookies are
+// encrypted with the given keys, reloaded from the hub Secret of keysNamespace when it changes. Decisions are recorded by
+// the given auditor, if any. Identity tokens are minted by the given issuer, if any.
+func NewWatcher(switcher *HTTPHandlerSwitcher, keys keyring.Keyring, keysNamespace string, sessionBackends oidc.SessionBackends, auditor *audit.Auditor, issuer *idtoken.Issuer) *Watcher {
 	return &Watcher{
-		key:      key,
-		configs:  make(map[string]*acp.Config),
-		secrets:  make(map[string]oidcSecret),
-		refresh:  make(chan struct{}, 1),
-		switcher: switcher,
+		keys:            keys,
+		keysNamespace:   keysNamespace,
+		configs:         make(map[string]*acp.Config),
+		secrets:         make(map[string]*corev1.Secret),
+		configMaps:      make(map[string]*corev1.ConfigMap),
+		sessionBackends: sessionBackends,
+		refresh:         make(chan struct{}, 1),
+		switcher:        switcher,
+		auditor:         auditor,
+		issuer:          issuer,
 	}
 }
 
@@ -104,8 +132,76 @@ func (w *Watcher) Run(ctx context.Context) {
 }
 
 func (w *Watcher) populateSecrets() {
+	keys := w.currentKeys()
+
 	for name, config := range w.configs {
 		logger := log.With().Str("acp_name", name).Logger()
+
+		populateKeys(config, keys)
+
+		if cfg := config.IdentityToken; cfg != nil {
+			cfg.SigningKey = w.findSigningKey(logger, cfg)
+		}
+
+		if cfg := config.JWT; cfg != nil {
+			cfg.SecretKeys = w.findJWTKeys(logger, cfg)
+			continue
+		}
+
+		if cfg := config.APIKey; cfg != nil {
+			cfg.SecretKeys = w.findAPIKeys(logger, cfg)
+			continue
+		}
+
+		if cfg := config.BasicAuth; cfg != nil {
+			cfg.SecretUsers = w.findBasicAuthUsers(logger, cfg)
+			if cfg.LDAP != nil {
+				w.populateLDAPBindCredentials(logger, cfg.LDAP)
+			}
+			continue
+		}
+
+		if cfg := config.MTLS; cfg != nil {
+			if cfg.CA != nil {
+				cfg.CABundle = w.findCABundle(logger, cfg.CA)
+			}
+			continue
+		}
+
+		if cfg := config.OAuthIntrospection; cfg != nil {
+			if cfg.Secret == nil {
+				logger.Error().Msg("Secret is missing")
+				continue
+			}
+
+			secret, ok := w.findSecret(logger, cfg.Secret.Namespace, cfg.Secret.Name)
+			if !ok {
+				continue
+			}
+
+			if err := populateIntrospectionSecrets(cfg, secret); err != nil {
+				logger.Error().Err(err).Msg("error while populating secrets")
+			}
+			continue
+		}
+
+		if cfg := config.GitHub; cfg != nil {
+			if cfg.Secret == nil {
+				logger.Error().Msg("Secret is missing")
+				continue
+			}
+
+			secret, ok := w.findSecret(logger, cfg.Secret.Namespace, cfg.Secret.Name)
+			if !ok {
+				continue
+			}
+
+			if err := populateGitHubSecrets(cfg, secret); err != nil {
+				logger.Error().Err(err).Msg("error while populating secrets")
+			}
+			continue
+		}
+
 		cfg := config.OIDC
 		if cfg == nil && config.OIDCGoogle != nil {
 			cfg = &config.OIDCGoogle.Config
@@ -120,12 +216,8 @@ func (w *Watcher) populateSecrets() {
 			continue
 		}
 
-		logger = logger.With().Str("secret_namespace", cfg.Secret.Namespace).
-			Str("secret_name", cfg.Secret.Name).Logger()
-
-		secret, ok := w.secrets[cfg.Secret.Namespace+"@"+cfg.Secret.Name]
+		secret, ok := w.findSecret(logger, cfg.Secret.Namespace, cfg.Secret.Name)
 		if !ok {
-			logger.Error().Msg("Secret is missing")
 			continue
 		}
 
@@ -135,6 +227,212 @@ func (w *Watcher) populateSecrets() {
 	}
 }
 
+// currentKeys returns the keyring held by the hub Secret, or the initial keyring if the Secret isn't known yet.
+func (w *Watcher) currentKeys() keyring.Keyring {
+	secret, ok := w.secrets[w.keysNamespace+"@"+keyring.SecretName]
+	if !ok {
+		return w.keys
+	}
+
+	keys, err := keyring.FromSecret(secret)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read keyring, using the initial one")
+		return w.keys
+	}
+
+	return keys
+}
+
+// populateKeys sets the keys encrypting cookies on the given configuration.
+func populateKeys(config *acp.Config, keys keyring.Keyring) {
+	switch {
+	case config.OIDC != nil:
+		config.OIDC.Key = keys.Primary
+		config.OIDC.DecryptionKeys = keys.Secondary
+
+	case config.OIDCGoogle != nil:
+		config.OIDCGoogle.Key = keys.Primary
+		config.OIDCGoogle.DecryptionKeys = keys.Secondary
+
+	case config.GitHub != nil:
+		config.GitHub.Key = keys.Primary
+		config.GitHub.DecryptionKeys = keys.Secondary
+	}
+}
+
+// findSigningKey returns the identity token signing key held by the Secret referenced by the given configuration.
+func (w *Watcher) findSigningKey(logger zerolog.Logger, cfg *idtoken.Config) []byte {
+	if cfg.Secret == nil {
+		logger.Error().Msg("Identity token Secret is missing")
+		return nil
+	}
+
+	secret, ok := w.findSecret(logger, cfg.Secret.Namespace, cfg.Secret.Name)
+	if !ok {
+		return nil
+	}
+
+	return secret.Data[idtoken.SigningKeySecretKey]
+}
+
+// findJWTKeys returns the key material held by the Secret referenced by the given configuration.
+func (w *Watcher) findJWTKeys(logger zerolog.Logger, cfg *jwt.Config) jwt.SecretKeys {
+	if cfg.KeysSecret == nil {
+		return jwt.SecretKeys{}
+	}
+
+	secret, ok := w.findSecret(logger, cfg.KeysSecret.Namespace, cfg.KeysSecret.Name)
+	if !ok {
+		return jwt.SecretKeys{}
+	}
+
+	keys, err := jwt.KeysFromSecretData(secret.Data)
+	if err != nil {
+		logger.Error().Err(err).
+			Str("secret_namespace", secret.Namespace).
+			Str("secret_name", secret.Name).
+			Msg("Invalid JWT keys secret")
+		return jwt.SecretKeys{}
+	}
+
+	return keys
+}
+
+// findAPIKeys returns the API keys held by the Secrets matching the given configuration, sorted by hash.
+func (w *Watcher) findAPIKeys(logger zerolog.Logger, cfg *apikey.Config) []apikey.Key {
+	if len(cfg.SecretSelector) == 0 {
+		return nil
+	}
+
+	// Selecting Secrets across namespaces would let anyone able to create a Secret grant themselves a key.
+	if cfg.SecretNamespace == "" {
+		logger.Error().Msg("Secret namespace is missing")
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(cfg.SecretSelector)
+
+	var keys []apikey.Key
+	for _, secret := range w.secrets {
+		if secret.Namespace != cfg.SecretNamespace {
+			continue
+		}
+
+		if !selector.Matches(labels.Set(secret.Labels)) {
+			continue
+		}
+
+		key, err := apikey.KeyFromSecretData(secret.Name, secret.Data)
+		if err != nil {
+			logger.Error().Err(err).
+				Str("secret_namespace", secret.Namespace).
+				Str("secret_name", secret.Name).
+				Msg("Invalid API key secret")
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	// Keys are sorted to keep the configuration hash stable.
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Hash < keys[j].Hash
+	})
+
+	return keys
+}
+
+// findBasicAuthUsers returns the users held by the Secret referenced by the given configuration.
+func (w *Watcher) findBasicAuthUsers(logger zerolog.Logger, cfg *basicauth.Config) basicauth.Users {
+	if cfg.UsersSecret == nil {
+		return nil
+	}
+
+	secret, ok := w.findSecret(logger, cfg.UsersSecret.Namespace, cfg.UsersSecret.Name)
+	if !ok {
+		return nil
+	}
+
+	users, err := basicauth.UsersFromSecretData(secret.Data)
+	if err != nil {
+		logger.Error().Err(err).
+			Str("secret_namespace", secret.Namespace).
+			Str("secret_name", secret.Name).
+			Msg("Invalid basic auth users secret")
+		return nil
+	}
+
+	return users
+}
+
+// populateLDAPBindCredentials populates the LDAP service account credentials from the Secret referenced by the given
+// configuration.
+func (w *Watcher) populateLDAPBindCredentials(logger zerolog.Logger, cfg *basicauth.LDAPConfig) {
+	cfg.BindDN, cfg.BindPassword = "", ""
+
+	if cfg.BindSecret == nil {
+		logger.Error().Msg("LDAP bind Secret is missing")
+		return
+	}
+
+	secret, ok := w.findSecret(logger, cfg.BindSecret.Namespace, cfg.BindSecret.Name)
+	if !ok {
+		return
+	}
+
+	cfg.BindDN = string(secret.Data[basicauth.LDAPBindDNSecretKey])
+	cfg.BindPassword = string(secret.Data[basicauth.LDAPBindPasswordSecretKey])
+}
+
+// findCABundle returns the CA bundle referenced by the given reference, or nil if it can't be found.
+func (w *Watcher) findCABundle(logger zerolog.Logger, ref *mtls.CAReference) []byte {
+	key := ref.Key
+	if key == "" {
+		key = mtls.DefaultCAKey
+	}
+
+	switch {
+	case ref.Secret != nil:
+		secret, ok := w.findSecret(logger, ref.Secret.Namespace, ref.Secret.Name)
+		if !ok {
+			return nil
+		}
+
+		return secret.Data[key]
+
+	case ref.ConfigMap != nil:
+		configMap, ok := w.configMaps[ref.ConfigMap.Namespace+"@"+ref.ConfigMap.Name]
+		if !ok {
+			logger.Error().
+				Str("config_map_namespace", ref.ConfigMap.Namespace).
+				Str("config_map_name", ref.ConfigMap.Name).
+				Msg("ConfigMap is missing")
+			return nil
+		}
+
+		if bundle, ok := configMap.Data[key]; ok {
+			return []byte(bundle)
+		}
+
+		return configMap.BinaryData[key]
+
+	default:
+		return nil
+	}
+}
+
+func (w *Watcher) findSecret(logger zerolog.Logger, namespace, name string) (*corev1.Secret, bool) {
+	secret, ok := w.secrets[namespace+"@"+name]
+	if !ok {
+		logger.Error().
+			Str("secret_namespace", namespace).
+			Str("secret_name", name).
+			Msg("Secret is missing")
+	}
+
+	return secret, ok
+}
+
 // OnAdd implements Kubernetes cache.ResourceEventHandler so it can be used as an informer event handler.
 func (w *Watcher) OnAdd(obj interface{}) {
 	switch v := obj.(type) {
@@ -143,9 +441,12 @@ func (w *Watcher) OnAdd(obj interface{}) {
 
 	case *corev1.Secret:
 		w.configsMu.Lock()
-		w.secrets[v.Namespace+"@"+v.Name] = oidcSecret{
-			ClientSecret: string(v.Data["clientSecret"]),
-		}
+		w.secrets[v.Namespace+"@"+v.Name] = v
+		w.configsMu.Unlock()
+
+	case *corev1.ConfigMap:
+		w.configsMu.Lock()
+		w.configMaps[v.Namespace+"@"+v.Name] = v
 		w.configsMu.Unlock()
 
 	default:
@@ -170,9 +471,12 @@ func (w *Watcher) OnUpdate(_, newObj interface{}) {
 
 	case *corev1.Secret:
 		w.configsMu.Lock()
-		w.secrets[v.Namespace+"@"+v.Name] = oidcSecret{
-			ClientSecret: string(v.Data["clientSecret"]),
-		}
+		w.secrets[v.Namespace+"@"+v.Name] = v
+		w.configsMu.Unlock()
+
+	case *corev1.ConfigMap:
+		w.configsMu.Lock()
+		w.configMaps[v.Namespace+"@"+v.Name] = v
 		w.configsMu.Unlock()
 
 	default:
@@ -194,12 +498,6 @@ func (w *Watcher) updateConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy
 	defer w.configsMu.Unlock()
 
 	w.configs[policy.ObjectMeta.Name] = acp.ConfigFromPolicy(policy)
-	if w.configs[policy.ObjectMeta.Name].OIDC != nil {
-		w.configs[policy.ObjectMeta.Name].OIDC.Key = w.key
-	}
-	if w.configs[policy.ObjectMeta.Name].OIDCGoogle != nil {
-		w.configs[policy.ObjectMeta.Name].OIDCGoogle.Key = w.key
-	}
 }
 
 // OnDelete implements Kubernetes cache.ResourceEventHandler so it can be used as an informer event handler.
@@ -215,6 +513,11 @@ func (w *Watcher) OnDelete(obj interface{}) {
 		delete(w.secrets, v.Namespace+"@"+v.Name)
 		w.configsMu.Unlock()
 
+	case *corev1.ConfigMap:
+		w.configsMu.Lock()
+		delete(w.configMaps, v.Namespace+"@"+v.Name)
+		w.configsMu.Unlock()
+
 	default:
 		log.Error().
 			Str("component", "acp_watcher").
@@ -233,47 +536,230 @@ func (w *Watcher) buildRoutes(ctx context.Context) http.Handler {
 	w.configsMu.RLock()
 	defer w.configsMu.RUnlock()
 
-	mux := http.NewServeMux()
+	routes := make(map[string]http.Handler, len(w.configs))
 
 	for name, cfg := range w.configs {
-		path := "/" + name
+		// ACPs referencing other ACPs are built once the ACPs they reference are.
+		if hasReferences(cfg) {
+			continue
+		}
 
-		logger := log.With().Str("acp_name", name).Str("acp_type", getACPType(cfg)).Logger()
+		logger := log.With().Str("acp_name", name).Str("acp_type", ACPType(cfg)).Logger()
 
-		route, err := buildRoute(ctx, name, cfg)
+		route, err := BuildRoute(ctx, name, cfg, w.sessionBackends)
 		if err != nil {
 			logger.Error().Err(err).Msg("create ACP handler")
 			continue
 		}
 
+		routes[name] = route
+	}
+
+	for name, cfg := range w.configs {
+		if !hasReferences(cfg) {
+			continue
+		}
+
+		logger := log.With().Str("acp_name", name).Str("acp_type", ACPType(cfg)).Logger()
+
+		if _, err := buildReferencingRoute(name, w.configs, routes, nil); err != nil {
+			logger.Error().Err(err).Msg("create ACP handler")
+		}
+	}
+
+	mux := http.NewServeMux()
+
+	var signingKeys []*idtoken.Key
+	for name, route := range routes {
+		acpType := ACPType(w.configs[name])
+
+		logger := log.With().Str("acp_name", name).Str("acp_type", acpType).Logger()
+
+		var handler http.Handler = route
+		if tokCfg := w.configs[name].IdentityToken; tokCfg != nil {
+			key, err := w.identityTokenKey(tokCfg)
+			if err != nil {
+				logger.Error().Err(err).Msg("create ACP handler")
+				continue
+			}
+
+			signingKeys = append(signingKeys, key)
+			handler = w.issuer.Handler(handler, tokCfg, key, name, acpType)
+		}
+
 		logger.Debug().Msg("Registering ACP handler")
 
-		mux.Handle(path, route)
+		if w.configs[name].Enforcement == acp.EnforcementAudit {
+			handler = dryrun.NewHandler(handler, name, acpType)
+		}
+		if w.auditor != nil {
+			handler = w.auditor.Handler(handler, name, acpType)
+		}
+
+		mux.Handle("/"+name, authmetrics.Handler(handler, name, acpType))
+
+		// OIDC providers notify the auth server directly of logouts, not through the forward auth.
+		if oidcHandler, ok := route.(*oidc.Handler); ok {
+			mux.HandleFunc("/"+name+"/backchannel-logout", oidcHandler.ServeBackChannelLogout)
+		}
+	}
+
+	if w.issuer != nil {
+		w.issuer.SetKeys(signingKeys)
 	}
 
 	return mux
 }
 
-func buildRoute(ctx context.Context, name string, cfg *acp.Config) (http.Handler, error) {
+// identityTokenKey returns the key signing the identity tokens configured by the given configuration.
+func (w *Watcher) identityTokenKey(cfg *idtoken.Config) (*idtoken.Key, error) {
+	if w.issuer == nil {
+		return nil, errors.New("identity tokens are not enabled")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate identity token configuration: %w", err)
+	}
+
+	if len(cfg.SigningKey) == 0 {
+		return nil, errors.New("missing identity token signing key")
+	}
+
+	key, err := idtoken.ParseKey(cfg.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse identity token signing key: %w", err)
+	}
+
+	return key, nil
+}
+
+// buildReferencingRoute builds the handler of the given ACP referencing other ACPs (composite or external
+// authorization) and stores it in routes, building the ACPs it references first. Visiting holds the referencing ACPs
+// being built, to detect reference cycles.
+func buildReferencingRoute(name string, configs map[string]*acp.Config, routes map[string]http.Handler, visiting []string) (http.Handler, error) {
+	if route, ok := routes[name]; ok {
+		return route, nil
+	}
+
+	for _, v := range visiting {
+		if v == name {
+			return nil, fmt.Errorf("reference cycle detected on ACP %q", name)
+		}
+	}
+
+	cfg, ok := configs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown ACP %q", name)
+	}
+	if !hasReferences(cfg) {
+		return nil, fmt.Errorf("ACP %q has no handler", name)
+	}
+
+	refs := cfg.References()
+	handlers := make([]http.Handler, 0, len(refs))
+	for _, ref := range refs {
+		handler, err := buildReferencingRoute(ref, configs, routes, append(visiting, name))
+		if err != nil {
+			return nil, fmt.Errorf("build referenced ACP %q: %w", ref, err)
+		}
+
+		handlers = append(handlers, handler)
+	}
+
+	var (
+		route http.Handler
+		err   error
+	)
+	switch {
+	case cfg.Composite != nil:
+		route, err = buildCompositeRoute(name, cfg.Composite, handlers)
+
+	case cfg.ExternalAuthz != nil:
+		if err = acp.ValidateAuthentication(configs[cfg.ExternalAuthz.AuthenticationPolicy]); err != nil {
+			return nil, fmt.Errorf("validate configuration: %w", err)
+		}
+		route, err = extauthz.NewHandler(&cfg.ExternalAuthz.Config, handlers[0], name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	routes[name] = route
+
+	return route, nil
+}
+
+func buildCompositeRoute(name string, cfg *acp.Composite, handlers []http.Handler) (http.Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	mode := composite.ModeAllOf
+	if len(cfg.AnyOf) > 0 {
+		mode = composite.ModeAnyOf
+	}
+
+	return composite.NewHandler(mode, handlers, name), nil
+}
+
+// hasReferences returns whether the given configuration references other ACPs. Those ACPs must be built first.
+func hasReferences(cfg *acp.Config) bool {
+	return cfg.Composite != nil || len(cfg.References()) > 0
+}
+
+// BuildRoute builds the handler of the given ACP. ACPs referencing other ACPs aren't supported. The given session
+// backends are used by OIDC policies storing sessions server-side.
+func BuildRoute(ctx context.Context, name string, cfg *acp.Config, sessionBackends oidc.SessionBackends) (http.Handler, error) {
 	switch {
 	case cfg.JWT != nil:
-		return jwt.NewHandler(cfg.JWT, name)
+		jwtCfg := *cfg.JWT
+		jwtCfg.Rules = cfg.Rules
+
+		return jwt.NewHandler(&jwtCfg, name)
 
 	case cfg.BasicAuth != nil:
-		return basicauth.NewHandler(cfg.BasicAuth, name)
+		basicCfg := *cfg.BasicAuth
+		basicCfg.Rules = cfg.Rules
+
+		return basicauth.NewHandler(&basicCfg, name)
 
 	case cfg.OIDC != nil:
-		return oidc.NewHandler(ctx, cfg.OIDC, name)
+		oidcCfg := *cfg.OIDC
+		oidcCfg.Rules = cfg.Rules
+
+		return oidc.NewHandler(ctx, &oidcCfg, name, sessionBackends)
 
 	case cfg.OIDCGoogle != nil:
-		return oidc.NewHandler(ctx, &cfg.OIDCGoogle.Config, name)
+		oidcCfg := cfg.OIDCGoogle.Config
+		oidcCfg.Rules = cfg.Rules
+
+		return oidc.NewHandler(ctx, &oidcCfg, name, sessionBackends)
+
+	case cfg.GitHub != nil:
+		return github.NewHandler(cfg.GitHub, name)
+
+	case cfg.OAuthIntrospection != nil:
+		return oauthintro.NewHandler(cfg.OAuthIntrospection, name)
+
+	case cfg.APIKey != nil:
+		return apikey.NewHandler(cfg.APIKey, name)
+
+	case cfg.MTLS != nil:
+		return mtls.NewHandler(cfg.MTLS, name)
+
+	case cfg.IPAllowList != nil:
+		return ipallowlist.NewHandler(cfg.IPAllowList, name)
+
+	case cfg.ExternalAuthz != nil:
+		return extauthz.NewHandler(&cfg.ExternalAuthz.Config, nil, name)
 
 	default:
 		return nil, fmt.Errorf("unknown handler type for ACP %s", name)
 	}
 }
 
-func getACPType(cfg *acp.Config) string {
+// ACPType returns the type of the given ACP.
+func ACPType(cfg *acp.Config) string {
 	switch {
 	case cfg.JWT != nil:
 		return "JWT"
@@ -287,17 +773,61 @@ func getACPType(cfg *acp.Config) string {
 	case cfg.OIDCGoogle != nil:
 		return "OIDCGoogle"
 
+	case cfg.GitHub != nil:
+		return "GitHub"
+
+	case cfg.OAuthIntrospection != nil:
+		return "OAuthIntrospection"
+
+	case cfg.APIKey != nil:
+		return "APIKey"
+
+	case cfg.MTLS != nil:
+		return "MTLS"
+
+	case cfg.IPAllowList != nil:
+		return "IPAllowList"
+
+	case cfg.Composite != nil:
+		return "Composite"
+
+	case cfg.ExternalAuthz != nil:
+		return "ExternalAuthz"
+
 	default:
 		return "unknown"
 	}
 }
 
-func populateSecrets(config *oidc.Config, secret oidcSecret) error {
-	if secret.ClientSecret == "" {
+func populateSecrets(config *oidc.Config, secret *corev1.Secret) error {
+	clientSecret := string(secret.Data["clientSecret"])
+	if clientSecret == "" {
+		return errors.New("clientSecret is missing in secret")
+	}
+
+	config.ClientSecret = clientSecret
+
+	return nil
+}
+
+func populateGitHubSecrets(config *github.Config, secret *corev1.Secret) error {
+	clientSecret := string(secret.Data["clientSecret"])
+	if clientSecret == "" {
+		return errors.New("clientSecret is missing in secret")
+	}
+
+	config.ClientSecret = clientSecret
+
+	return nil
+}
+
+func populateIntrospectionSecrets(config *oauthintro.Config, secret *corev1.Secret) error {
+	clientSecret := string(secret.Data["clientSecret"])
+	if clientSecret == "" {
 		return errors.New("clientSecret is missing in secret")
 	}
 
-	config.ClientSecret = secret.ClientSecret
+	config.ClientSecret = clientSecret
 
 	return nil
 }
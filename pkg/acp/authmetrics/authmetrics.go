@@ -0,0 +1,305 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package authmetrics exposes Prometheus metrics describing the decisions made by the ACP handlers of the auth server.
+package authmetrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Decision results.
+const (
+	ResultAllowed    = "allowed"
+	ResultDenied     = "denied"
+	ResultRedirected = "redirected"
+	ResultError      = "error"
+)
+
+// Reasons of decisions, set by ACP handlers to detail their results.
+const (
+	ReasonMissingCredentials  = "missing_credentials"
+	ReasonInvalidCredentials  = "invalid_credentials"
+	ReasonInvalidToken        = "invalid_token"
+	ReasonUnverifiableToken   = "unverifiable_token"
+	ReasonTokenExpired        = "token_expired"
+	ReasonTokenNotYetValid    = "token_not_yet_valid"
+	ReasonTokenTooOld         = "token_too_old"
+	ReasonIssuerMismatch      = "issuer_mismatch"
+	ReasonAudienceMismatch    = "audience_mismatch"
+	ReasonAlgorithmNotAllowed = "algorithm_not_allowed"
+	ReasonDecryptionFailed    = "decryption_failed"
+	ReasonClaimsMismatch      = "claims_mismatch"
+	ReasonGroupsMismatch      = "groups_mismatch"
+	ReasonUpstreamError       = "upstream_error"
+	ReasonNoSession           = "no_session"
+	ReasonSessionRevoked      = "session_revoked"
+	ReasonRefreshFailed       = "refresh_failed"
+	ReasonSessionRefreshed    = "session_refreshed"
+	ReasonCallback            = "callback"
+	ReasonLogout              = "logout"
+	ReasonDryRun              = "dry_run"
+	ReasonRuleAllowed         = "rule_allowed"
+	ReasonRuleDenied          = "rule_denied"
+	ReasonAmbiguousPath       = "ambiguous_path"
+)
+
+// Outcomes of calls made to external services.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+const (
+	namespace = "hub"
+	subsystem = "acp"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	decisions = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "decisions_total",
+		Help:      "Number of requests evaluated by ACPs, by policy, type, result and reason.",
+	}, []string{"policy", "type", "result", "reason"})
+
+	decisionDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "decision_duration_seconds",
+		Help:      "Time taken by ACPs to evaluate requests, by policy, type and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"policy", "type", "result"})
+
+	jwksFetches = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "jwks_fetches_total",
+		Help:      "Number of JWK sets fetched from remote URLs, by outcome.",
+	}, []string{"outcome"})
+
+	jwksFetchDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "jwks_fetch_duration_seconds",
+		Help:      "Time taken to fetch JWK sets from remote URLs, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	oidcTokenRefreshes = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "oidc_token_refreshes_total",
+		Help:      "Number of OIDC token refreshes, by policy and outcome.",
+	}, []string{"policy", "outcome"})
+
+	routeRebuilds = promauto.With(registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "route_rebuilds_total",
+		Help:      "Number of times the ACP routes of the auth server were rebuilt.",
+	})
+
+	dryRunDecisions = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "dry_run_decisions_total",
+		Help:      "Number of decisions ACPs in audit mode would have made, by policy, type, result and reason.",
+	}, []string{"policy", "type", "result", "reason"})
+
+	auditEventsDropped = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "audit_events_dropped_total",
+		Help:      "Number of audit events which couldn't be shipped, by sink.",
+	}, []string{"sink"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// HTTPHandler returns an http.Handler serving the metrics in the Prometheus exposition format.
+func HTTPHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveJWKSFetch records the outcome and the duration of a JWK set fetch.
+func ObserveJWKSFetch(duration time.Duration, err error) {
+	outcome := outcomeOf(err)
+
+	jwksFetches.WithLabelValues(outcome).Inc()
+	jwksFetchDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObserveTokenRefresh records the outcome of an OIDC token refresh made by the given policy.
+func ObserveTokenRefresh(policy string, err error) {
+	oidcTokenRefreshes.WithLabelValues(policy, outcomeOf(err)).Inc()
+}
+
+// ObserveRouteRebuild records a rebuild of the ACP routes.
+func ObserveRouteRebuild() {
+	routeRebuilds.Inc()
+}
+
+// ObserveDryRunDecision records the decision an ACP in audit mode would have made.
+func ObserveDryRunDecision(policy, typ, result, reason string) {
+	dryRunDecisions.WithLabelValues(policy, typ, result, reason).Inc()
+}
+
+// ObserveAuditEventsDropped records audit events dropped by the given sink.
+func ObserveAuditEventsDropped(sink string, count int) {
+	auditEventsDropped.WithLabelValues(sink).Add(float64(count))
+}
+
+func outcomeOf(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+
+	return OutcomeSuccess
+}
+
+type reasonKey struct{}
+
+// reasonRecorder records the reason of the decision made by an ACP handler.
+type reasonRecorder struct {
+	mu     sync.Mutex
+	reason string
+}
+
+// SetReason sets the reason of the decision made on the request the given context belongs to. Reasons must come from a
+// bounded set of values as they are used as labels. It's a no-op if the request isn't instrumented.
+func SetReason(ctx context.Context, reason string) {
+	rec, ok := ctx.Value(reasonKey{}).(*reasonRecorder)
+	if !ok {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.reason = reason
+}
+
+// Reason returns the reason of the decision made on the request the given context belongs to, given the status code of
+// the response. It falls back to a reason derived from the status code if the handler didn't set one.
+func Reason(ctx context.Context, code int) string {
+	if rec, ok := ctx.Value(reasonKey{}).(*reasonRecorder); ok {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+
+		if rec.reason != "" {
+			return rec.reason
+		}
+	}
+
+	return defaultReason(code)
+}
+
+// Handler instruments the given ACP handler, recording its decisions and the time it takes to make them.
+func Handler(handler http.Handler, policy, typ string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rec := &reasonRecorder{}
+		req = req.WithContext(context.WithValue(req.Context(), reasonKey{}, rec))
+
+		srw := &statusResponseWriter{ResponseWriter: rw}
+
+		start := time.Now()
+		handler.ServeHTTP(srw, req)
+		duration := time.Since(start)
+
+		code := srw.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+
+		result := Result(code)
+		reason := Reason(req.Context(), code)
+
+		decisions.WithLabelValues(policy, typ, result, reason).Inc()
+		decisionDuration.WithLabelValues(policy, typ, result).Observe(duration.Seconds())
+	})
+}
+
+// Result returns the result of a decision given the status code of the response.
+func Result(code int) string {
+	switch {
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		return ResultAllowed
+	case code >= http.StatusMultipleChoices && code < http.StatusBadRequest:
+		return ResultRedirected
+	case code >= http.StatusInternalServerError:
+		return ResultError
+	default:
+		return ResultDenied
+	}
+}
+
+// defaultReason returns the reason of decisions made by handlers which didn't set one.
+func defaultReason(code int) string {
+	switch {
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		return "authorized"
+	case code >= http.StatusMultipleChoices && code < http.StatusBadRequest:
+		return "login_required"
+	case code == http.StatusUnauthorized:
+		return "unauthenticated"
+	case code == http.StatusForbidden:
+		return "forbidden"
+	case code >= http.StatusInternalServerError:
+		return "internal_error"
+	default:
+		return "bad_request"
+	}
+}
+
+// statusResponseWriter is an http.ResponseWriter recording the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
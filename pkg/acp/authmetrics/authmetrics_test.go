@@ -0,0 +1,145 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package authmetrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		desc       string
+		handler    http.HandlerFunc
+		wantCode   int
+		wantResult string
+		wantReason string
+	}{
+		{
+			desc:       "allowed without explicit status code",
+			handler:    func(rw http.ResponseWriter, req *http.Request) {},
+			wantCode:   http.StatusOK,
+			wantResult: ResultAllowed,
+			wantReason: "authorized",
+		},
+		{
+			desc: "denied with a reason",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				SetReason(req.Context(), ReasonInvalidToken)
+				rw.WriteHeader(http.StatusUnauthorized)
+			},
+			wantCode:   http.StatusUnauthorized,
+			wantResult: ResultDenied,
+			wantReason: ReasonInvalidToken,
+		},
+		{
+			desc: "denied without reason",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusForbidden)
+			},
+			wantCode:   http.StatusForbidden,
+			wantResult: ResultDenied,
+			wantReason: "forbidden",
+		},
+		{
+			desc: "redirected",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				SetReason(req.Context(), ReasonNoSession)
+				http.Redirect(rw, req, "https://idp.example.com", http.StatusFound)
+			},
+			wantCode:   http.StatusFound,
+			wantResult: ResultRedirected,
+			wantReason: ReasonNoSession,
+		},
+		{
+			desc: "error",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			},
+			wantCode:   http.StatusInternalServerError,
+			wantResult: ResultError,
+			wantReason: "internal_error",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Each test uses its own policy name as metrics are global.
+			policy := strings.ReplaceAll(test.desc, " ", "-")
+
+			handler := Handler(test.handler, policy, "JWT")
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/"+policy, nil)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, 1.0, testutil.ToFloat64(decisions.WithLabelValues(policy, "JWT", test.wantResult, test.wantReason)))
+
+			var m dto.Metric
+			require.NoError(t, decisionDuration.WithLabelValues(policy, "JWT", test.wantResult).(prometheus.Histogram).Write(&m))
+			assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+		})
+	}
+}
+
+func TestSetReason_notInstrumented(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+
+	assert.NotPanics(t, func() { SetReason(req.Context(), ReasonInvalidToken) })
+}
+
+func TestObserveJWKSFetch(t *testing.T) {
+	success := testutil.ToFloat64(jwksFetches.WithLabelValues(OutcomeSuccess))
+	failure := testutil.ToFloat64(jwksFetches.WithLabelValues(OutcomeError))
+
+	ObserveJWKSFetch(time.Millisecond, nil)
+	ObserveJWKSFetch(time.Millisecond, errors.New("boom"))
+	ObserveJWKSFetch(time.Millisecond, errors.New("boom"))
+
+	assert.Equal(t, success+1, testutil.ToFloat64(jwksFetches.WithLabelValues(OutcomeSuccess)))
+	assert.Equal(t, failure+2, testutil.ToFloat64(jwksFetches.WithLabelValues(OutcomeError)))
+}
+
+func TestHTTPHandler(t *testing.T) {
+	ObserveTokenRefresh("my-oidc", nil)
+	ObserveRouteRebuild()
+
+	rw := httptest.NewRecorder()
+	HTTPHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://auth.example.com/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `hub_acp_oidc_token_refreshes_total{outcome="success",policy="my-oidc"} 1`)
+	assert.Contains(t, rw.Body.String(), "hub_acp_route_rebuilds_total")
+	assert.Contains(t, rw.Body.String(), "go_goroutines")
+}
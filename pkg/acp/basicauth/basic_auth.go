@@ -18,25 +18,61 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package basicauth
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	goauth "github.com/abbot/go-http-auth"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const defaultRealm = "hub"
 
+// UsersSecretKey is the data key of the Secret holding users in the htpasswd format.
+const UsersSecretKey = "users"
+
+// hashPrefixes are the prefixes of the password hashes supported in htpasswd files: SHA1, bcrypt and MD5 (apr1).
+var hashPrefixes = []string{"{SHA}", "$2a$", "$2b$", "$2x$", "$2y$", "$apr1$", "$1$"}
+
 // Users holds a list of users.
 type Users []string
 
 // Config configures a basic auth ACP handler.
 type Config struct {
-	Users                    Users
+	Users Users
+	// UsersSecret references a Secret holding users in the htpasswd format under the UsersSecretKey key.
+	UsersSecret *corev1.SecretReference
+	// SecretUsers are the users read from UsersSecret. They are merged with Users, which take precedence.
+	SecretUsers              Users `json:"-"`
 	Realm                    string
 	StripAuthorizationHeader bool
 	ForwardUsernameHeader    string
+	// LDAP authenticates users against an LDAP server instead of Users and UsersSecret.
+	LDAP *LDAPConfig
+	// Rules are the rules of the ACP, set from its configuration.
+	Rules []rules.Config `json:"-"`
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil || cfg.LDAP == nil {
+		return nil
+	}
+
+	if len(cfg.Users) > 0 || cfg.UsersSecret != nil {
+		return errors.New("users can't be used along with LDAP")
+	}
+
+	if err := cfg.LDAP.Validate(); err != nil {
+		return fmt.Errorf("invalid LDAP configuration: %w", err)
+	}
+
+	return nil
 }
 
 // Handler is a basic auth ACP Handler.
@@ -46,20 +82,36 @@ type Handler struct {
 	forwardUsername    string
 	stripAuthorization bool
 	name               string
+
+	ldap           *ldapAuthenticator
+	requiredGroups []string
+	forwardGroups  string
+
+	rules rules.Rules
 }
 
 // NewHandler creates a new basic auth ACP Handler.
 func NewHandler(cfg *Config, name string) (*Handler, error) {
-	users, err := getUsers(cfg.Users, basicUserParser)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	users, err := getUsers(append(append(Users{}, cfg.SecretUsers...), cfg.Users...), basicUserParser)
 	if err != nil {
 		return nil, err
 	}
 
+	rs, err := rules.New(cfg.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("compile rules: %w", err)
+	}
+
 	h := &Handler{
 		users:              users,
 		forwardUsername:    cfg.ForwardUsernameHeader,
 		stripAuthorization: cfg.StripAuthorizationHeader,
 		name:               name,
+		rules:              rs,
 	}
 
 	realm := defaultRealm
@@ -69,16 +121,35 @@ func NewHandler(cfg *Config, name string) (*Handler, error) {
 
 	h.auth = &goauth.BasicAuth{Realm: realm, Secrets: h.secretBasic}
 
+	if cfg.LDAP != nil {
+		h.ldap = newLDAPAuthenticator(cfg.LDAP)
+		h.requiredGroups = cfg.LDAP.RequiredGroups
+		h.forwardGroups = cfg.LDAP.ForwardGroupsHeader
+	}
+
 	return h, nil
 }
 
 func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rule := h.rules.Match(req)
+	if rule.ServeAction(rw, req) {
+		return
+	}
+
+	if h.ldap != nil {
+		h.serveLDAP(rw, req, rule)
+		return
+	}
+
 	l := log.With().Str("handler_type", "BasicAuth").Str("handler_name", h.name).Logger()
 
 	username, password, ok := req.BasicAuth()
-	if ok {
+	if !ok {
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonMissingCredentials)
+	} else {
 		secret := h.auth.Secrets(username, h.auth.Realm)
 		if secret == "" || !goauth.CheckSecret(password, secret) {
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidCredentials)
 			ok = false
 		}
 	}
@@ -90,6 +161,15 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	claims := map[string]interface{}{"sub": username}
+
+	if !rule.AllowClaims(claims) {
+		l.Debug().Str("username", username).Msg("User doesn't satisfy the claims of the matching rule")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	if h.forwardUsername != "" {
 		rw.Header().Set(h.forwardUsername, username)
 	}
@@ -98,6 +178,69 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		rw.Header().Add("Authorization", "")
 	}
 
+	identity.RecordClaims(req.Context(), claims)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) serveLDAP(rw http.ResponseWriter, req *http.Request, rule *rules.Rule) {
+	l := log.With().Str("handler_type", "BasicAuth").Str("handler_name", h.name).Logger()
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		l.Debug().Msg("Authentication failed")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonMissingCredentials)
+
+		h.auth.RequireAuth(rw, req)
+		return
+	}
+
+	user, err := h.ldap.authenticate(username, password)
+	if err != nil {
+		if !errors.Is(err, errInvalidCredentials) {
+			l.Error().Err(err).Msg("Unable to authenticate user against LDAP server")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonUpstreamError)
+		} else {
+			l.Debug().Msg("Authentication failed")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidCredentials)
+		}
+
+		h.auth.RequireAuth(rw, req)
+		return
+	}
+
+	if len(h.requiredGroups) > 0 && !isMember(user.groups, h.requiredGroups) {
+		l.Debug().Str("username", username).Msg("User is not a member of the required groups")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonGroupsMismatch)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	claims := map[string]interface{}{"sub": username, "groups": user.groups}
+
+	if !rule.AllowClaims(claims) {
+		l.Debug().Str("username", username).Msg("User doesn't satisfy the claims of the matching rule")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if h.forwardUsername != "" {
+		rw.Header().Set(h.forwardUsername, username)
+	}
+
+	if h.forwardGroups != "" {
+		for _, group := range user.groups {
+			rw.Header().Add(h.forwardGroups, group)
+		}
+	}
+
+	if h.stripAuthorization {
+		rw.Header().Add("Authorization", "")
+	}
+
+	identity.RecordClaims(req.Context(), claims)
+
 	rw.WriteHeader(http.StatusOK)
 }
 
@@ -132,3 +275,49 @@ func getUsers(users []string, parser userParser) (map[string]string, error) {
 
 	return userMap, nil
 }
+
+// UsersFromSecretData returns the users held by the given Secret data in the htpasswd format.
+func UsersFromSecretData(data map[string][]byte) (Users, error) {
+	htpasswd, ok := data[UsersSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("missing %q key", UsersSecretKey)
+	}
+
+	return ParseHTPasswd(htpasswd)
+}
+
+// ParseHTPasswd parses users in the htpasswd format. Empty lines and comments are ignored.
+func ParseHTPasswd(htpasswd []byte) (Users, error) {
+	var users Users
+
+	for i, line := range strings.Split(string(htpasswd), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Errors must not hold the line content as it holds a password hash.
+		username, hash, err := basicUserParser(line)
+		if err != nil || username == "" {
+			return nil, fmt.Errorf("malformed user on line %d", i+1)
+		}
+
+		if !isSupportedHash(hash) {
+			return nil, fmt.Errorf("unsupported password hash for user %q on line %d", username, i+1)
+		}
+
+		users = append(users, line)
+	}
+
+	return users, nil
+}
+
+func isSupportedHash(hash string) bool {
+	for _, prefix := range hashPrefixes {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
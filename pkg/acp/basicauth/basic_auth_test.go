@@ -24,6 +24,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
 )
 
 func TestBasicAuthFail(t *testing.T) {
@@ -65,3 +66,161 @@ func TestBasicAuthUserHeader(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "test", rec.Header().Get("User"))
 }
+
+func TestBasicAuthSecretUsers(t *testing.T) {
+	// Passwords are "test" and "secret".
+	cfg := &Config{
+		Users:       []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		SecretUsers: []string{"test:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "other:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns")
+	require.NoError(t, err)
+
+	serve := func(username, password string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.SetBasicAuth(username, password)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// Inline users take precedence.
+	assert.Equal(t, http.StatusOK, serve("test", "test"))
+	assert.Equal(t, http.StatusUnauthorized, serve("test", "secret"))
+	assert.Equal(t, http.StatusOK, serve("other", "test"))
+}
+
+func TestBasicAuthRules(t *testing.T) {
+	// Password is "test".
+	cfg := &Config{
+		Users:                 []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/", "admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		ForwardUsernameHeader: "User",
+		Rules: []rules.Config{
+			{Path: "/healthz", Action: rules.ActionAllow},
+			{Methods: []string{http.MethodDelete}, Claims: "Equals(`sub`, `admin`)"},
+			{Path: "/internal/**", Action: rules.ActionDeny},
+		},
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns")
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		method   string
+		uri      string
+		username string
+		wantCode int
+		wantUser string
+	}{
+		{
+			desc:     "allowed without credentials",
+			method:   http.MethodGet,
+			uri:      "/healthz",
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "denied with valid credentials",
+			method:   http.MethodGet,
+			uri:      "/internal/metrics",
+			username: "admin",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "claims satisfied",
+			method:   http.MethodDelete,
+			uri:      "/items/1",
+			username: "admin",
+			wantCode: http.StatusOK,
+			wantUser: "admin",
+		},
+		{
+			desc:     "claims not satisfied",
+			method:   http.MethodDelete,
+			uri:      "/items/1",
+			username: "test",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "no matching rule",
+			method:   http.MethodGet,
+			uri:      "/items/1",
+			username: "test",
+			wantCode: http.StatusOK,
+			wantUser: "test",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header.Set("X-Forwarded-Method", test.method)
+			req.Header.Set("X-Forwarded-Uri", test.uri)
+			if test.username != "" {
+				req.SetBasicAuth(test.username, "test")
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantCode, rec.Code)
+			assert.Equal(t, test.wantUser, rec.Header().Get("User"))
+		})
+	}
+}
+
+func TestParseHTPasswd(t *testing.T) {
+	tests := []struct {
+		desc     string
+		htpasswd string
+		want     Users
+		wantErr  string
+	}{
+		{
+			desc: "supported hashes",
+			htpasswd: `# Comment
+bcrypt:$2y$05$gYPjr6qbTtpjoxKqDlDu2uUCc66TJa.KVJgjdvBq8gZpAu8Ieu2tW
+
+sha1:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+apr1:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/
+`,
+			want: Users{
+				"bcrypt:$2y$05$gYPjr6qbTtpjoxKqDlDu2uUCc66TJa.KVJgjdvBq8gZpAu8Ieu2tW",
+				"sha1:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+				"apr1:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+			},
+		},
+		{
+			desc:     "malformed user",
+			htpasswd: "sha1:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\nmalformed",
+			wantErr:  "malformed user on line 2",
+		},
+		{
+			desc:     "plain text password",
+			htpasswd: "plain:password",
+			wantErr:  `unsupported password hash for user "plain" on line 1`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseHTPasswd([]byte(test.htpasswd))
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
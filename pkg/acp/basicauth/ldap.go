@@ -0,0 +1,278 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package basicauth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys of the Secret holding the LDAP service account credentials.
+const (
+	LDAPBindDNSecretKey       = "bindDN"
+	LDAPBindPasswordSecretKey = "bindPassword"
+)
+
+const (
+	defaultLDAPUserFilter     = "(uid=%s)"
+	defaultLDAPGroupAttribute = "memberOf"
+	defaultLDAPCacheTTL       = 60 * time.Second
+	ldapTimeout               = 5 * time.Second
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// LDAPConfig configures the authentication of users against an LDAP server.
+type LDAPConfig struct {
+	// URL is the URL of the LDAP server, using the `ldap` or `ldaps` scheme.
+	URL string
+	// StartTLS upgrades `ldap` connections to TLS.
+	StartTLS bool
+
+	// BindSecret references the Secret holding the credentials of the service account used to search users.
+	BindSecret *corev1.SecretReference
+	// BindDN and BindPassword are the service account credentials read from BindSecret.
+	BindDN       string `json:"-"`
+	BindPassword string `json:"-"`
+
+	// BaseDN is the DN users are searched from.
+	BaseDN string
+	// UserFilter is the filter used to search users, `%s` being replaced by the username.
+	UserFilter string
+	// GroupAttribute is the user attribute listing the groups of the user.
+	GroupAttribute string
+	// RequiredGroups lists the groups users must be a member of at least one of.
+	RequiredGroups []string
+	// ForwardGroupsHeader is the header populated with the groups of the user.
+	ForwardGroupsHeader string
+	// CacheTTL is the number of seconds successful authentications are cached for. Negative values disable caching.
+	CacheTTL int
+}
+
+// Validate validates configuration.
+func (cfg *LDAPConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	if cfg.StartTLS && u.Scheme == "ldaps" {
+		return errors.New("startTls can't be used with the ldaps scheme")
+	}
+
+	if cfg.BaseDN == "" {
+		return errors.New("missing base DN")
+	}
+
+	if cfg.UserFilter != "" && !strings.Contains(cfg.UserFilter, "%s") {
+		return errors.New("user filter must hold a %s placeholder")
+	}
+
+	if cfg.BindDN == "" || cfg.BindPassword == "" {
+		return errors.New("missing bind credentials")
+	}
+
+	return nil
+}
+
+// ldapUser is a user authenticated against an LDAP server.
+type ldapUser struct {
+	groups []string
+}
+
+type ldapCacheEntry struct {
+	user      ldapUser
+	expiresAt time.Time
+}
+
+// ldapAuthenticator authenticates users against an LDAP server: it binds with the service account, searches the user,
+// and binds as the user to verify their password. Successful authentications are cached.
+type ldapAuthenticator struct {
+	url            string
+	startTLS       bool
+	bindDN         string
+	bindPassword   string
+	baseDN         string
+	userFilter     string
+	groupAttribute string
+	cacheTTL       time.Duration
+
+	cacheMu sync.Mutex
+	cache   map[[sha256.Size]byte]ldapCacheEntry
+
+	now func() time.Time
+}
+
+func newLDAPAuthenticator(cfg *LDAPConfig) *ldapAuthenticator {
+	auth := &ldapAuthenticator{
+		url:            cfg.URL,
+		startTLS:       cfg.StartTLS,
+		bindDN:         cfg.BindDN,
+		bindPassword:   cfg.BindPassword,
+		baseDN:         cfg.BaseDN,
+		userFilter:     cfg.UserFilter,
+		groupAttribute: cfg.GroupAttribute,
+		cacheTTL:       time.Duration(cfg.CacheTTL) * time.Second,
+		cache:          make(map[[sha256.Size]byte]ldapCacheEntry),
+		now:            time.Now,
+	}
+
+	if auth.userFilter == "" {
+		auth.userFilter = defaultLDAPUserFilter
+	}
+	if auth.groupAttribute == "" {
+		auth.groupAttribute = defaultLDAPGroupAttribute
+	}
+	if cfg.CacheTTL == 0 {
+		auth.cacheTTL = defaultLDAPCacheTTL
+	}
+
+	return auth
+}
+
+// authenticate authenticates the given user. It returns errInvalidCredentials if the user doesn't exist or if the
+// password is wrong.
+func (a *ldapAuthenticator) authenticate(username, password string) (ldapUser, error) {
+	if username == "" || password == "" {
+		return ldapUser{}, errInvalidCredentials
+	}
+
+	// Passwords are hashed to avoid keeping them in memory.
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+
+	if user, ok := a.cached(key); ok {
+		return user, nil
+	}
+
+	user, err := a.bind(username, password)
+	if err != nil {
+		return ldapUser{}, err
+	}
+
+	if a.cacheTTL > 0 {
+		a.store(key, user)
+	}
+
+	return user, nil
+}
+
+func (a *ldapAuthenticator) bind(username, password string) (ldapUser, error) {
+	conn, err := ldap.DialURL(a.url, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return ldapUser{}, fmt.Errorf("dial LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetTimeout(ldapTimeout)
+
+	if a.startTLS {
+		u, _ := url.Parse(a.url)
+		if err = conn.StartTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}); err != nil {
+			return ldapUser{}, fmt.Errorf("start TLS: %w", err)
+		}
+	}
+
+	if err = conn.Bind(a.bindDN, a.bindPassword); err != nil {
+		return ldapUser{}, fmt.Errorf("bind service account: %w", err)
+	}
+
+	// The size limit is set to 2 to detect filters matching multiple users.
+	res, err := conn.Search(ldap.NewSearchRequest(
+		a.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(a.userFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{a.groupAttribute},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return ldapUser{}, errInvalidCredentials
+		}
+		return ldapUser{}, fmt.Errorf("search user: %w", err)
+	}
+
+	if len(res.Entries) != 1 {
+		return ldapUser{}, errInvalidCredentials
+	}
+	entry := res.Entries[0]
+
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return ldapUser{}, errInvalidCredentials
+		}
+		return ldapUser{}, fmt.Errorf("bind user: %w", err)
+	}
+
+	return ldapUser{groups: entry.GetAttributeValues(a.groupAttribute)}, nil
+}
+
+func (a *ldapAuthenticator) cached(key [sha256.Size]byte) (ldapUser, bool) {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
+
+	entry, ok := a.cache[key]
+	if !ok || !a.now().Before(entry.expiresAt) {
+		return ldapUser{}, false
+	}
+
+	return entry.user, true
+}
+
+func (a *ldapAuthenticator) store(key [sha256.Size]byte, user ldapUser) {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
+
+	now := a.now()
+	for k, entry := range a.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(a.cache, k)
+		}
+	}
+
+	a.cache[key] = ldapCacheEntry{user: user, expiresAt: now.Add(a.cacheTTL)}
+}
+
+// isMember returns whether the given groups hold at least one of the required groups. Group DNs are compared
+// case-insensitively.
+func isMember(groups, required []string) bool {
+	for _, group := range groups {
+		for _, req := range required {
+			if strings.EqualFold(group, req) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
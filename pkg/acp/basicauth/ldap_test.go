@@ -0,0 +1,495 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package basicauth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDAPConfig_Validate(t *testing.T) {
+	valid := func() *LDAPConfig {
+		return &LDAPConfig{
+			URL:          "ldap://ldap.example.com",
+			BindDN:       "cn=hub,dc=example,dc=com",
+			BindPassword: "secret",
+			BaseDN:       "dc=example,dc=com",
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		update  func(cfg *LDAPConfig)
+		wantErr string
+	}{
+		{
+			desc:   "valid",
+			update: func(cfg *LDAPConfig) {},
+		},
+		{
+			desc:    "unsupported scheme",
+			update:  func(cfg *LDAPConfig) { cfg.URL = "http://ldap.example.com" },
+			wantErr: `unsupported URL scheme "http"`,
+		},
+		{
+			desc: "startTls with ldaps",
+			update: func(cfg *LDAPConfig) {
+				cfg.URL = "ldaps://ldap.example.com"
+				cfg.StartTLS = true
+			},
+			wantErr: "startTls can't be used with the ldaps scheme",
+		},
+		{
+			desc:    "missing base DN",
+			update:  func(cfg *LDAPConfig) { cfg.BaseDN = "" },
+			wantErr: "missing base DN",
+		},
+		{
+			desc:    "user filter without placeholder",
+			update:  func(cfg *LDAPConfig) { cfg.UserFilter = "(uid=jane)" },
+			wantErr: "user filter must hold a %s placeholder",
+		},
+		{
+			desc:    "missing bind credentials",
+			update:  func(cfg *LDAPConfig) { cfg.BindPassword = "" },
+			wantErr: "missing bind credentials",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := valid()
+			test.update(cfg)
+
+			err := cfg.Validate()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := NewHandler(&Config{Users: []string{"test:test"}, LDAP: valid()}, "acp")
+	assert.EqualError(t, err, "validate configuration: users can't be used along with LDAP")
+}
+
+func TestHandler_ServeHTTP_LDAP(t *testing.T) {
+	srv := &testLDAPServer{
+		bindDN:       "cn=hub,dc=example,dc=com",
+		bindPassword: "hub-secret",
+		entries: []testLDAPEntry{
+			{
+				dn:       "uid=jane,ou=people,dc=example,dc=com",
+				password: "jane-secret",
+				attributes: map[string][]string{
+					"uid":      {"jane"},
+					"memberOf": {"cn=admins,ou=groups,dc=example,dc=com", "cn=devs,ou=groups,dc=example,dc=com"},
+				},
+			},
+			{
+				dn:       "uid=john,ou=people,dc=example,dc=com",
+				password: "john-secret",
+				attributes: map[string][]string{
+					"uid":      {"john"},
+					"memberOf": {"cn=devs,ou=groups,dc=example,dc=com"},
+				},
+			},
+			{
+				dn:       "uid=twin,ou=people,dc=example,dc=com",
+				password: "twin-secret",
+				attributes: map[string][]string{
+					"uid": {"twin"},
+				},
+			},
+			{
+				dn:       "uid=twin,ou=others,dc=example,dc=com",
+				password: "twin-secret",
+				attributes: map[string][]string{
+					"uid": {"twin"},
+				},
+			},
+		},
+	}
+	url := srv.start(t)
+
+	tests := []struct {
+		desc        string
+		bindPwd     string
+		username    string
+		password    string
+		wantCode    int
+		wantHeaders map[string][]string
+	}{
+		{
+			desc:     "valid credentials",
+			username: "jane",
+			password: "jane-secret",
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-User":   {"jane"},
+				"X-Groups": {"cn=admins,ou=groups,dc=example,dc=com", "cn=devs,ou=groups,dc=example,dc=com"},
+			},
+		},
+		{
+			desc:     "wrong password",
+			username: "jane",
+			password: "john-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "empty password",
+			username: "jane",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "unknown user",
+			username: "unknown",
+			password: "jane-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "ambiguous user",
+			username: "twin",
+			password: "twin-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "filter injection",
+			username: "*",
+			password: "jane-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "not a member of the required groups",
+			username: "john",
+			password: "john-secret",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "wrong service account password",
+			bindPwd:  "wrong",
+			username: "jane",
+			password: "jane-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			bindPwd := srv.bindPassword
+			if test.bindPwd != "" {
+				bindPwd = test.bindPwd
+			}
+
+			handler, err := NewHandler(&Config{
+				ForwardUsernameHeader: "X-User",
+				LDAP: &LDAPConfig{
+					URL:                 url,
+					BindDN:              srv.bindDN,
+					BindPassword:        bindPwd,
+					BaseDN:              "dc=example,dc=com",
+					RequiredGroups:      []string{"CN=admins,ou=groups,dc=example,dc=com"},
+					ForwardGroupsHeader: "X-Groups",
+				},
+			}, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			req.SetBasicAuth(test.username, test.password)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, values := range test.wantHeaders {
+				assert.Equal(t, values, rw.Header().Values(name))
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_LDAPCache(t *testing.T) {
+	srv := &testLDAPServer{
+		bindDN:       "cn=hub,dc=example,dc=com",
+		bindPassword: "hub-secret",
+		entries: []testLDAPEntry{
+			{
+				dn:         "uid=jane,ou=people,dc=example,dc=com",
+				password:   "jane-secret",
+				attributes: map[string][]string{"uid": {"jane"}},
+			},
+		},
+	}
+	url := srv.start(t)
+
+	handler, err := NewHandler(&Config{
+		LDAP: &LDAPConfig{
+			URL:          url,
+			BindDN:       srv.bindDN,
+			BindPassword: srv.bindPassword,
+			BaseDN:       "dc=example,dc=com",
+			UserFilter:   "(&(objectClass=person)(uid=%s))",
+			CacheTTL:     30,
+		},
+	}, "acp")
+	require.NoError(t, err)
+
+	now := time.Now()
+	handler.ldap.now = func() time.Time { return now }
+
+	serve := func(password string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+		req.SetBasicAuth("jane", password)
+		rw := httptest.NewRecorder()
+
+		handler.ServeHTTP(rw, req)
+
+		return rw.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("jane-secret"))
+	assert.Equal(t, http.StatusOK, serve("jane-secret"))
+	assert.Equal(t, int32(1), srv.searches.Load())
+
+	// Failed authentications are not cached.
+	assert.Equal(t, http.StatusUnauthorized, serve("wrong"))
+	assert.Equal(t, http.StatusUnauthorized, serve("wrong"))
+	assert.Equal(t, int32(3), srv.searches.Load())
+
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, http.StatusOK, serve("jane-secret"))
+	assert.Equal(t, int32(4), srv.searches.Load())
+}
+
+type testLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testLDAPServer is an in-process LDAP server supporting simple binds and searches with equality, presence, AND and OR
+// filters. Entries implicitly have the `person` object class.
+type testLDAPServer struct {
+	bindDN       string
+	bindPassword string
+	entries      []testLDAPEntry
+
+	searches atomic.Int32
+}
+
+func (s *testLDAPServer) start(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return "ldap://" + listener.Addr().String()
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var boundAsService bool
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		msgID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if s.checkPassword(dn, password) {
+				code = ldap.LDAPResultSuccess
+			}
+			boundAsService = dn == s.bindDN && code == ldap.LDAPResultSuccess
+
+			responses = append(responses, ldapResult(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			s.searches.Add(1)
+
+			if !boundAsService {
+				responses = append(responses, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				break
+			}
+
+			baseDN, _ := op.Children[0].Value.(string)
+			sizeLimit, _ := op.Children[3].Value.(int64)
+
+			code := uint16(ldap.LDAPResultSuccess)
+			for _, entry := range s.entries {
+				if !strings.HasSuffix(entry.dn, baseDN) || !matchFilter(op.Children[6], entry) {
+					continue
+				}
+
+				if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+					code = ldap.LDAPResultSizeLimitExceeded
+					break
+				}
+
+				responses = append(responses, ldapSearchEntry(entry))
+			}
+
+			responses = append(responses, ldapResult(ldap.ApplicationSearchResultDone, code))
+
+		default:
+			// Unbind requests and unsupported operations end the connection.
+			return
+		}
+
+		for _, resp := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+			envelope.AppendChild(resp)
+
+			if _, err = conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *testLDAPServer) checkPassword(dn, password string) bool {
+	if password == "" {
+		return false
+	}
+
+	if dn == s.bindDN {
+		return password == s.bindPassword
+	}
+
+	for _, entry := range s.entries {
+		if entry.dn == dn {
+			return entry.password == password
+		}
+	}
+
+	return false
+}
+
+func matchFilter(filter *ber.Packet, entry testLDAPEntry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterEqualityMatch:
+		attr := filter.Children[0].Data.String()
+		value := filter.Children[1].Data.String()
+
+		if strings.EqualFold(attr, "objectClass") {
+			return value == "person"
+		}
+
+		for _, v := range entry.attributes[attr] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+
+	case ldap.FilterPresent:
+		return len(entry.attributes[filter.Data.String()]) > 0
+
+	default:
+		return false
+	}
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+
+	return res
+}
+
+func ldapSearchEntry(entry testLDAPEntry) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.attributes {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attr.AppendChild(vals)
+
+		attrs.AppendChild(attr)
+	}
+	res.AppendChild(attrs)
+
+	return res
+}
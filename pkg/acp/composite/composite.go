@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package composite
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Mode defines how the responses of the referenced ACP handlers are combined.
+type Mode string
+
+// Supported modes.
+const (
+	// ModeAllOf requires all the referenced handlers to allow the request.
+	ModeAllOf Mode = "allOf"
+	// ModeAnyOf requires at least one of the referenced handlers to allow the request.
+	ModeAnyOf Mode = "anyOf"
+)
+
+// Handler is a composite ACP Handler. It runs the handlers of the referenced ACPs in order and combines their responses.
+type Handler struct {
+	name string
+
+	mode     Mode
+	handlers []http.Handler
+}
+
+// NewHandler returns a new composite ACP Handler.
+func NewHandler(mode Mode, handlers []http.Handler, polName string) *Handler {
+	return &Handler{
+		name:     polName,
+		mode:     mode,
+		handlers: handlers,
+	}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "Composite").Str("handler_name", h.name).Str("mode", string(h.mode)).Logger()
+
+	if h.mode == ModeAnyOf {
+		resp := h.anyOf(req)
+		l.Debug().Int("code", resp.code).Msg("Composite policy evaluated")
+		resp.writeTo(rw)
+		return
+	}
+
+	resp := h.allOf(req)
+	l.Debug().Int("code", resp.code).Msg("Composite policy evaluated")
+	resp.writeTo(rw)
+}
+
+// allOf returns the first denying response, or an allowing response holding the headers forwarded by all handlers.
+func (h *Handler) allOf(req *http.Request) *response {
+	merged := newResponse()
+
+	for _, handler := range h.handlers {
+		resp := serve(handler, req)
+		if resp.code != http.StatusOK {
+			return resp
+		}
+
+		for name, values := range resp.header {
+			for _, value := range values {
+				merged.header.Add(name, value)
+			}
+		}
+	}
+
+	merged.code = http.StatusOK
+
+	return merged
+}
+
+// anyOf returns the first allowing response. When all handlers deny the request, the first redirection is returned so
+// that users can authenticate with an interactive policy, otherwise the last denying response is returned.
+func (h *Handler) anyOf(req *http.Request) *response {
+	var redirect, last *response
+
+	for _, handler := range h.handlers {
+		resp := serve(handler, req)
+		if resp.code == http.StatusOK {
+			return resp
+		}
+
+		if redirect == nil && resp.code >= http.StatusMultipleChoices && resp.code < http.StatusBadRequest {
+			redirect = resp
+		}
+		last = resp
+	}
+
+	if redirect != nil {
+		return redirect
+	}
+
+	if last == nil {
+		return &response{header: make(http.Header), code: http.StatusForbidden}
+	}
+
+	return last
+}
+
+// serve runs the given handler, recording its response.
+func serve(handler http.Handler, req *http.Request) *response {
+	resp := newResponse()
+	handler.ServeHTTP(resp, req)
+
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+
+	return resp
+}
+
+// response is an http.ResponseWriter recording the response of an ACP handler.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newResponse() *response {
+	return &response{header: make(http.Header)}
+}
+
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+func (r *response) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *response) writeTo(rw http.ResponseWriter) {
+	for name, values := range r.header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+
+	rw.WriteHeader(r.code)
+	_, _ = rw.Write(r.body.Bytes())
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package composite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	allow := func(name, value string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Add(name, value)
+			rw.WriteHeader(http.StatusOK)
+		})
+	}
+	deny := func(code int) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if code == http.StatusFound {
+				rw.Header().Set("Location", "https://idp.example.com")
+			}
+			rw.WriteHeader(code)
+		})
+	}
+
+	tests := []struct {
+		desc        string
+		mode        Mode
+		handlers    []http.Handler
+		wantCode    int
+		wantHeaders map[string][]string
+	}{
+		{
+			desc:     "allOf: all allowed",
+			mode:     ModeAllOf,
+			handlers: []http.Handler{allow("X-User", "jane"), allow("X-Group", "admin"), allow("X-User", "john")},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-User":  {"jane", "john"},
+				"X-Group": {"admin"},
+			},
+		},
+		{
+			desc:     "allOf: one denied",
+			mode:     ModeAllOf,
+			handlers: []http.Handler{allow("X-User", "jane"), deny(http.StatusForbidden), deny(http.StatusUnauthorized)},
+			wantCode: http.StatusForbidden,
+			wantHeaders: map[string][]string{
+				"X-User": nil,
+			},
+		},
+		{
+			desc:     "allOf: redirected",
+			mode:     ModeAllOf,
+			handlers: []http.Handler{deny(http.StatusFound), allow("X-User", "jane")},
+			wantCode: http.StatusFound,
+			wantHeaders: map[string][]string{
+				"Location": {"https://idp.example.com"},
+			},
+		},
+		{
+			desc:     "anyOf: first allowed",
+			mode:     ModeAnyOf,
+			handlers: []http.Handler{deny(http.StatusUnauthorized), allow("X-User", "jane"), allow("X-User", "john")},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string][]string{
+				"X-User": {"jane"},
+			},
+		},
+		{
+			desc:     "anyOf: all denied",
+			mode:     ModeAnyOf,
+			handlers: []http.Handler{deny(http.StatusUnauthorized), deny(http.StatusForbidden)},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "anyOf: all denied with a redirection",
+			mode:     ModeAnyOf,
+			handlers: []http.Handler{deny(http.StatusUnauthorized), deny(http.StatusFound), deny(http.StatusForbidden)},
+			wantCode: http.StatusFound,
+			wantHeaders: map[string][]string{
+				"Location": {"https://idp.example.com"},
+			},
+		},
+		{
+			desc:     "anyOf: no handlers",
+			mode:     ModeAnyOf,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := NewHandler(test.mode, test.handlers, "acp")
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, values := range test.wantHeaders {
+				assert.Equal(t, values, rw.Header().Values(name))
+			}
+		})
+	}
+}
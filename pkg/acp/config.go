@@ -18,23 +18,56 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package acp
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/extauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/github"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 )
 
 // Config is the configuration of an Access Control Policy. It is used to setup ACP handlers.
 type Config struct {
-	JWT        *jwt.Config
-	BasicAuth  *basicauth.Config
-	OIDC       *oidc.Config
-	OIDCGoogle *OIDCGoogle
+	JWT                *jwt.Config
+	BasicAuth          *basicauth.Config
+	OIDC               *oidc.Config
+	OIDCGoogle         *OIDCGoogle
+	GitHub             *github.Config
+	OAuthIntrospection *oauthintro.Config
+	APIKey             *apikey.Config
+	MTLS               *mtls.Config
+	IPAllowList        *ipallowlist.Config
+	Composite          *Composite
+	ExternalAuthz      *ExternalAuthz
+
+	Enforcement Enforcement
+	// Rules is an ordered list of rules refining the decisions of JWT, OIDC and basic auth ACPs per request.
+	Rules []rules.Config
+	// IdentityToken configures the identity tokens forwarded to upstreams for the requests the ACP allows, if any.
+	IdentityToken *idtoken.Config
 }
 
+// Enforcement defines whether the decisions of an ACP are enforced.
+type Enforcement string
+
+// Supported enforcement modes.
+const (
+	// EnforcementEnforce denies requests the ACP doesn't allow.
+	EnforcementEnforce Enforcement = "enforce"
+	// EnforcementAudit never denies requests, reporting the decisions the ACP would have made.
+	EnforcementAudit Enforcement = "audit"
+)
+
 // OIDCGoogle is the Google OIDC configuration.
 type OIDCGoogle struct {
 	oidc.Config
@@ -42,8 +75,118 @@ type OIDCGoogle struct {
 	Emails []string `json:"emails,omitempty"`
 }
 
+// Composite is the configuration of an ACP combining other ACPs, referenced by name.
+type Composite struct {
+	// AllOf requires all the referenced ACPs to allow the request.
+	AllOf []string `json:"allOf,omitempty"`
+	// AnyOf requires at least one of the referenced ACPs to allow the request.
+	AnyOf []string `json:"anyOf,omitempty"`
+
+	// Policies holds the configuration of the referenced ACPs, by name, when resolved. Missing ACPs are absent.
+	Policies map[string]*Config `json:"-"`
+}
+
+// Validate validates configuration.
+func (c *Composite) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if len(c.AllOf) > 0 && len(c.AnyOf) > 0 {
+		return errors.New("allOf and anyOf are mutually exclusive")
+	}
+
+	if len(c.AllOf) == 0 && len(c.AnyOf) == 0 {
+		return errors.New("missing referenced policies")
+	}
+
+	return nil
+}
+
+// References returns the names of the referenced ACPs.
+func (c *Composite) References() []string {
+	if len(c.AnyOf) > 0 {
+		return c.AnyOf
+	}
+
+	return c.AllOf
+}
+
+// ExternalAuthz is the configuration of an ACP delegating authorization decisions to an external service.
+type ExternalAuthz struct {
+	extauthz.Config
+
+	// AuthenticationConfig holds the configuration of the referenced authentication ACP, when resolved.
+	AuthenticationConfig *Config `json:"-"`
+}
+
+// ValidateAuthentication makes sure the given configuration can be used to authenticate requests before asking for an
+// authorization decision.
+func ValidateAuthentication(cfg *Config) error {
+	if cfg.JWT == nil && cfg.OIDC == nil && cfg.OIDCGoogle == nil {
+		return errors.New("authentication policy must be a JWT or OIDC policy")
+	}
+
+	return nil
+}
+
+// References returns the names of the ACPs referenced by the given configuration.
+func (cfg *Config) References() []string {
+	switch {
+	case cfg.Composite != nil:
+		return cfg.Composite.References()
+
+	case cfg.ExternalAuthz != nil && cfg.ExternalAuthz.AuthenticationPolicy != "":
+		return []string{cfg.ExternalAuthz.AuthenticationPolicy}
+
+	default:
+		return nil
+	}
+}
+
 // ConfigFromPolicy returns an ACP configuration for the given policy.
 func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
+	cfg := configFromSpec(policy)
+	cfg.Enforcement = Enforcement(policy.Spec.Enforcement)
+
+	for _, rule := range policy.Spec.Rules {
+		cfg.Rules = append(cfg.Rules, rules.Config{
+			Methods:   rule.Methods,
+			Path:      rule.Path,
+			PathRegex: rule.PathRegex,
+			Host:      rule.Host,
+			Action:    rules.Action(rule.Action),
+			Claims:    rule.Claims,
+		})
+	}
+
+	if tok := policy.Spec.IdentityToken; tok != nil {
+		cfg.IdentityToken = &idtoken.Config{
+			Secret:   tok.Secret,
+			Header:   tok.Header,
+			Audience: tok.Audience,
+			Claims:   tok.Claims,
+			TTL:      tok.TTL,
+		}
+	}
+
+	return cfg
+}
+
+// ValidateRules makes sure the rules of the given configuration are valid and that its ACP type supports them.
+func ValidateRules(cfg *Config) error {
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+
+	if cfg.JWT == nil && cfg.BasicAuth == nil && cfg.OIDC == nil && cfg.OIDCGoogle == nil {
+		return errors.New("rules are only supported by JWT, OIDC and basic auth policies")
+	}
+
+	return rules.Validate(cfg.Rules)
+}
+
+func configFromSpec(policy *hubv1alpha1.AccessControlPolicy) *Config {
 	switch {
 	case policy.Spec.JWT != nil:
 		jwtCfg := policy.Spec.JWT
@@ -59,34 +202,59 @@ func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
 				ForwardHeaders:             jwtCfg.ForwardHeaders,
 				TokenQueryKey:              jwtCfg.TokenQueryKey,
 				Claims:                     jwtCfg.Claims,
+				Issuers:                    jwtCfg.Issuers,
+				Audiences:                  jwtCfg.Audiences,
+				Leeway:                     jwtCfg.Leeway,
+				MaxAge:                     jwtCfg.MaxAge,
+				Algorithms:                 jwtCfg.Algorithms,
+				KeysSecret:                 jwtCfg.KeysSecret,
 			},
 		}
 
 	case policy.Spec.BasicAuth != nil:
 		basicCfg := policy.Spec.BasicAuth
 
-		return &Config{
+		cfg := &Config{
 			BasicAuth: &basicauth.Config{
 				Users:                    basicCfg.Users,
+				UsersSecret:              basicCfg.UsersSecret,
 				Realm:                    basicCfg.Realm,
 				StripAuthorizationHeader: basicCfg.StripAuthorizationHeader,
 				ForwardUsernameHeader:    basicCfg.ForwardUsernameHeader,
 			},
 		}
 
+		if ldapCfg := basicCfg.LDAP; ldapCfg != nil {
+			cfg.BasicAuth.LDAP = &basicauth.LDAPConfig{
+				URL:                 ldapCfg.URL,
+				StartTLS:            ldapCfg.StartTLS,
+				BindSecret:          ldapCfg.BindSecret,
+				BaseDN:              ldapCfg.BaseDN,
+				UserFilter:          ldapCfg.UserFilter,
+				GroupAttribute:      ldapCfg.GroupAttribute,
+				RequiredGroups:      ldapCfg.RequiredGroups,
+				ForwardGroupsHeader: ldapCfg.ForwardGroupsHeader,
+				CacheTTL:            ldapCfg.CacheTTL,
+			}
+		}
+
+		return cfg
+
 	case policy.Spec.OIDC != nil:
 		oidcCfg := policy.Spec.OIDC
 
 		conf := &Config{
 			OIDC: &oidc.Config{
-				Issuer:         oidcCfg.Issuer,
-				ClientID:       oidcCfg.ClientID,
-				RedirectURL:    oidcCfg.RedirectURL,
-				LogoutURL:      oidcCfg.LogoutURL,
-				Scopes:         oidcCfg.Scopes,
-				AuthParams:     oidcCfg.AuthParams,
-				ForwardHeaders: oidcCfg.ForwardHeaders,
-				Claims:         oidcCfg.Claims,
+				Issuer:                oidcCfg.Issuer,
+				ClientID:              oidcCfg.ClientID,
+				RedirectURL:           oidcCfg.RedirectURL,
+				LogoutURL:             oidcCfg.LogoutURL,
+				PostLogoutRedirectURL: oidcCfg.PostLogoutRedirectURL,
+				Scopes:                oidcCfg.Scopes,
+				AuthParams:            oidcCfg.AuthParams,
+				ForwardHeaders:        oidcCfg.ForwardHeaders,
+				Claims:                oidcCfg.Claims,
+				PKCE:                  oidcCfg.PKCE,
 			},
 		}
 
@@ -97,6 +265,22 @@ func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
 			}
 		}
 
+		if oidcCfg.UserInfo != nil {
+			conf.OIDC.UserInfo = &oidc.UserInfo{Precedence: oidcCfg.UserInfo.Precedence}
+		}
+
+		if oidcCfg.Provider != nil {
+			conf.OIDC.Provider = &oidc.ProviderConfig{
+				Name:           oidcCfg.Provider.Name,
+				TenantID:       oidcCfg.Provider.TenantID,
+				URL:            oidcCfg.Provider.URL,
+				Realm:          oidcCfg.Provider.Realm,
+				GroupsClaim:    oidcCfg.Provider.GroupsClaim,
+				AllowedGroups:  oidcCfg.Provider.AllowedGroups,
+				AllowedDomains: oidcCfg.Provider.AllowedDomains,
+			}
+		}
+
 		if oidcCfg.StateCookie != nil {
 			conf.OIDC.StateCookie = &oidc.AuthStateCookie{
 				Path:     oidcCfg.StateCookie.Path,
@@ -106,13 +290,15 @@ func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
 			}
 		}
 
-		if oidcCfg.StateCookie != nil {
+		if oidcCfg.Session != nil {
 			conf.OIDC.Session = &oidc.AuthSession{
 				Path:     oidcCfg.Session.Path,
 				Domain:   oidcCfg.Session.Domain,
 				SameSite: oidcCfg.Session.SameSite,
 				Secure:   oidcCfg.Session.Secure,
 				Refresh:  oidcCfg.Session.Refresh,
+				Store:    oidcCfg.Session.Store,
+				TTL:      oidcCfg.Session.TTL,
 			}
 		}
 
@@ -131,6 +317,7 @@ func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
 					AuthParams:     oidcGoogleCfg.AuthParams,
 					ForwardHeaders: oidcGoogleCfg.ForwardHeaders,
 					Claims:         buildClaims(oidcGoogleCfg.Emails),
+					PKCE:           oidcGoogleCfg.PKCE,
 				},
 				Emails: oidcGoogleCfg.Emails,
 			},
@@ -152,17 +339,170 @@ func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
 			}
 		}
 
-		if oidcGoogleCfg.StateCookie != nil {
+		if oidcGoogleCfg.Session != nil {
 			conf.OIDCGoogle.Session = &oidc.AuthSession{
 				Path:     oidcGoogleCfg.Session.Path,
 				Domain:   oidcGoogleCfg.Session.Domain,
 				SameSite: oidcGoogleCfg.Session.SameSite,
 				Secure:   oidcGoogleCfg.Session.Secure,
 				Refresh:  oidcGoogleCfg.Session.Refresh,
+				Store:    oidcGoogleCfg.Session.Store,
+				TTL:      oidcGoogleCfg.Session.TTL,
 			}
 		}
 
 		return conf
+
+	case policy.Spec.GitHub != nil:
+		githubCfg := policy.Spec.GitHub
+
+		conf := &Config{
+			GitHub: &github.Config{
+				ClientID:       githubCfg.ClientID,
+				BaseURL:        githubCfg.BaseURL,
+				RedirectURL:    githubCfg.RedirectURL,
+				LogoutURL:      githubCfg.LogoutURL,
+				Orgs:           githubCfg.Orgs,
+				Teams:          githubCfg.Teams,
+				ForwardHeaders: githubCfg.ForwardHeaders,
+			},
+		}
+
+		if githubCfg.Secret != nil {
+			conf.GitHub.Secret = &github.SecretReference{
+				Name:      githubCfg.Secret.Name,
+				Namespace: githubCfg.Secret.Namespace,
+			}
+		}
+
+		if githubCfg.StateCookie != nil {
+			conf.GitHub.StateCookie = &oidc.AuthStateCookie{
+				Path:     githubCfg.StateCookie.Path,
+				Domain:   githubCfg.StateCookie.Domain,
+				SameSite: githubCfg.StateCookie.SameSite,
+				Secure:   githubCfg.StateCookie.Secure,
+			}
+		}
+
+		if githubCfg.Session != nil {
+			conf.GitHub.Session = &oidc.AuthSession{
+				Path:     githubCfg.Session.Path,
+				Domain:   githubCfg.Session.Domain,
+				SameSite: githubCfg.Session.SameSite,
+				Secure:   githubCfg.Session.Secure,
+				Refresh:  githubCfg.Session.Refresh,
+				Store:    githubCfg.Session.Store,
+				TTL:      githubCfg.Session.TTL,
+			}
+		}
+
+		return conf
+
+	case policy.Spec.OAuthIntrospection != nil:
+		introCfg := policy.Spec.OAuthIntrospection
+
+		conf := &Config{
+			OAuthIntrospection: &oauthintro.Config{
+				URL:                      introCfg.URL,
+				ClientID:                 introCfg.ClientID,
+				TokenTypeHint:            introCfg.TokenTypeHint,
+				TokenQueryKey:            introCfg.TokenQueryKey,
+				StripAuthorizationHeader: introCfg.StripAuthorizationHeader,
+				ForwardHeaders:           introCfg.ForwardHeaders,
+				Claims:                   introCfg.Claims,
+			},
+		}
+
+		if introCfg.Secret != nil {
+			conf.OAuthIntrospection.Secret = &oauthintro.SecretReference{
+				Name:      introCfg.Secret.Name,
+				Namespace: introCfg.Secret.Namespace,
+			}
+		}
+
+		return conf
+
+	case policy.Spec.APIKey != nil:
+		apiKeyCfg := policy.Spec.APIKey
+
+		return &Config{
+			APIKey: &apikey.Config{
+				Header:          apiKeyCfg.Header,
+				Query:           apiKeyCfg.Query,
+				Cookie:          apiKeyCfg.Cookie,
+				Keys:            apiKeyCfg.Keys,
+				SecretNamespace: apiKeyCfg.SecretNamespace,
+				SecretSelector:  apiKeyCfg.SecretSelector,
+				ForwardHeaders:  apiKeyCfg.ForwardHeaders,
+			},
+		}
+
+	case policy.Spec.MTLS != nil:
+		mtlsCfg := policy.Spec.MTLS
+
+		conf := &Config{
+			MTLS: &mtls.Config{
+				ForwardHeaders: mtlsCfg.ForwardHeaders,
+				Claims:         mtlsCfg.Claims,
+			},
+		}
+
+		if ca := mtlsCfg.CA; ca != nil {
+			conf.MTLS.CA = &mtls.CAReference{Key: ca.Key}
+
+			if ca.Secret != nil {
+				conf.MTLS.CA.Secret = &mtls.ObjectReference{
+					Name:      ca.Secret.Name,
+					Namespace: ca.Secret.Namespace,
+				}
+			}
+
+			if ca.ConfigMap != nil {
+				conf.MTLS.CA.ConfigMap = &mtls.ObjectReference{
+					Name:      ca.ConfigMap.Name,
+					Namespace: ca.ConfigMap.Namespace,
+				}
+			}
+		}
+
+		return conf
+
+	case policy.Spec.IPAllowList != nil:
+		ipAllowListCfg := policy.Spec.IPAllowList
+
+		return &Config{
+			IPAllowList: &ipallowlist.Config{
+				SourceRange:       ipAllowListCfg.SourceRange,
+				DeniedSourceRange: ipAllowListCfg.DeniedSourceRange,
+				Depth:             ipAllowListCfg.Depth,
+			},
+		}
+
+	case policy.Spec.Composite != nil:
+		return &Config{
+			Composite: &Composite{
+				AllOf: policy.Spec.Composite.AllOf,
+				AnyOf: policy.Spec.Composite.AnyOf,
+			},
+		}
+
+	case policy.Spec.ExternalAuthz != nil:
+		extAuthzCfg := policy.Spec.ExternalAuthz
+
+		return &Config{
+			ExternalAuthz: &ExternalAuthz{
+				Config: extauthz.Config{
+					URL:                  extAuthzCfg.URL,
+					AuthenticationPolicy: extAuthzCfg.AuthenticationPolicy,
+					RequestHeaders:       extAuthzCfg.RequestHeaders,
+					ResponseHeaders:      extAuthzCfg.ResponseHeaders,
+					Timeout:              extAuthzCfg.Timeout,
+					FailOpen:             extAuthzCfg.FailOpen,
+					CacheTTL:             extAuthzCfg.CacheTTL,
+				},
+			},
+		}
+
 	default:
 		return &Config{}
 	}
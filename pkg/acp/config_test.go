@@ -21,8 +21,74 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 )
 
+func TestConfigFromPolicy_oidcSession(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		stateCookie         *hubv1alpha1.StateCookie
+		session             *hubv1alpha1.Session
+		expectedStateCookie *oidc.AuthStateCookie
+		expectedSession     *oidc.AuthSession
+	}{
+		{
+			desc: "no state cookie nor session",
+		},
+		{
+			desc:            "session without state cookie",
+			session:         &hubv1alpha1.Session{Store: oidc.SessionStoreSecret, TTL: 3600},
+			expectedSession: &oidc.AuthSession{Store: oidc.SessionStoreSecret, TTL: 3600},
+		},
+		{
+			desc:                "state cookie without session",
+			stateCookie:         &hubv1alpha1.StateCookie{Path: "/", Secure: true},
+			expectedStateCookie: &oidc.AuthStateCookie{Path: "/", Secure: true},
+		},
+		{
+			desc:                "state cookie and session",
+			stateCookie:         &hubv1alpha1.StateCookie{Path: "/"},
+			session:             &hubv1alpha1.Session{Domain: "example.com", Store: oidc.SessionStoreMemory},
+			expectedStateCookie: &oidc.AuthStateCookie{Path: "/"},
+			expectedSession:     &oidc.AuthSession{Domain: "example.com", Store: oidc.SessionStoreMemory},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := ConfigFromPolicy(&hubv1alpha1.AccessControlPolicy{
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					OIDC: &hubv1alpha1.AccessControlOIDC{
+						StateCookie: test.stateCookie,
+						Session:     test.session,
+					},
+				},
+			})
+			require.NotNil(t, cfg.OIDC)
+			assert.Equal(t, test.expectedStateCookie, cfg.OIDC.StateCookie)
+			assert.Equal(t, test.expectedSession, cfg.OIDC.Session)
+
+			cfg = ConfigFromPolicy(&hubv1alpha1.AccessControlPolicy{
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					OIDCGoogle: &hubv1alpha1.AccessControlOIDCGoogle{
+						StateCookie: test.stateCookie,
+						Session:     test.session,
+					},
+				},
+			})
+			require.NotNil(t, cfg.OIDCGoogle)
+			assert.Equal(t, test.expectedStateCookie, cfg.OIDCGoogle.StateCookie)
+			assert.Equal(t, test.expectedSession, cfg.OIDCGoogle.Session)
+		})
+	}
+}
+
 func TestBuildClaims(t *testing.T) {
 	testCases := []struct {
 		desc     string
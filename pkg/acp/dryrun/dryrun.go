@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package dryrun allows evaluating ACPs against real traffic without enforcing their decisions.
+package dryrun
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+)
+
+// DecisionHeader is the header forwarded upstream describing the decision the ACP would have made.
+const DecisionHeader = "X-Hub-Acp-Decision"
+
+// Handler is an ACP handler which evaluates requests with another ACP handler but never denies them. The decision
+// the wrapped handler would have made is forwarded upstream, logged and recorded as a metric. Responses which aren't
+// denials, such as the redirections to the login page of a provider or the responses of its callback, are passed
+// through unchanged so that sessions can still be established.
+type Handler struct {
+	name    string
+	acpType string
+
+	handler http.Handler
+}
+
+// NewHandler returns a new dry-run Handler evaluating requests with the given handler.
+func NewHandler(handler http.Handler, polName, acpType string) *Handler {
+	return &Handler{
+		name:    polName,
+		acpType: acpType,
+		handler: handler,
+	}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "DryRun").Str("handler_name", h.name).Logger()
+
+	resp := newResponse()
+	h.handler.ServeHTTP(resp, req)
+
+	code := resp.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	result := authmetrics.Result(code)
+	reason := authmetrics.Reason(req.Context(), code)
+
+	authmetrics.ObserveDryRunDecision(h.name, h.acpType, result, reason)
+
+	if code == http.StatusUnauthorized || code == http.StatusForbidden {
+		l.Info().
+			Str("method", req.Header.Get("X-Forwarded-Method")).
+			Str("host", req.Header.Get("X-Forwarded-Host")).
+			Str("uri", req.Header.Get("X-Forwarded-Uri")).
+			Str("result", result).
+			Str("reason", reason).
+			Msg("Request would have been denied")
+
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonDryRun)
+
+		// The header is always set to override the one sent by clients, if any.
+		rw.Header().Set(DecisionHeader, result+"; reason="+reason)
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Other responses are kept, so that upstream services and clients behave as if the ACP was enforced.
+	for name, values := range resp.header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+
+	rw.Header().Set(DecisionHeader, result+"; reason="+reason)
+	rw.WriteHeader(code)
+
+	if _, err := rw.Write(resp.body.Bytes()); err != nil {
+		l.Error().Err(err).Msg("Unable to write response body")
+	}
+}
+
+// response is an http.ResponseWriter recording the response of an ACP handler.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newResponse() *response {
+	return &response{header: make(http.Header)}
+}
+
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+func (r *response) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package dryrun
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc               string
+		handler            http.HandlerFunc
+		wantCode           int
+		wantResult         string
+		wantReason         string
+		wantHeaders        map[string][]string
+		wantEnforcedResult string
+		wantEnforcedReason string
+	}{
+		{
+			desc: "allowed",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-User", "jane")
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantCode:   http.StatusOK,
+			wantResult: authmetrics.ResultAllowed,
+			wantReason: "authorized",
+			wantHeaders: map[string][]string{
+				"X-User": {"jane"},
+			},
+			wantEnforcedResult: authmetrics.ResultAllowed,
+			wantEnforcedReason: "authorized",
+		},
+		{
+			desc: "denied",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
+				rw.Header().Set("X-User", "jane")
+				rw.WriteHeader(http.StatusForbidden)
+			},
+			wantCode:   http.StatusOK,
+			wantResult: authmetrics.ResultDenied,
+			wantReason: authmetrics.ReasonClaimsMismatch,
+			wantHeaders: map[string][]string{
+				"X-User": nil,
+			},
+			wantEnforcedResult: authmetrics.ResultAllowed,
+			wantEnforcedReason: authmetrics.ReasonDryRun,
+		},
+		{
+			desc: "unauthenticated",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			},
+			wantCode:           http.StatusOK,
+			wantResult:         authmetrics.ResultDenied,
+			wantReason:         "unauthenticated",
+			wantEnforcedResult: authmetrics.ResultAllowed,
+			wantEnforcedReason: authmetrics.ReasonDryRun,
+		},
+		{
+			desc: "redirected",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				http.Redirect(rw, req, "https://idp.example.com", http.StatusFound)
+			},
+			wantCode:   http.StatusFound,
+			wantResult: authmetrics.ResultRedirected,
+			wantReason: "login_required",
+			wantHeaders: map[string][]string{
+				"Location": {"https://idp.example.com"},
+			},
+			wantEnforcedResult: authmetrics.ResultRedirected,
+			wantEnforcedReason: "login_required",
+		},
+		{
+			desc: "callback",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				http.SetCookie(rw, &http.Cookie{Name: "session", Value: "value"})
+				http.Redirect(rw, req, "https://app.example.com/origin", http.StatusFound)
+			},
+			wantCode:   http.StatusFound,
+			wantResult: authmetrics.ResultRedirected,
+			wantReason: "login_required",
+			wantHeaders: map[string][]string{
+				"Location":   {"https://app.example.com/origin"},
+				"Set-Cookie": {"session=value"},
+			},
+			wantEnforcedResult: authmetrics.ResultRedirected,
+			wantEnforcedReason: "login_required",
+		},
+		{
+			desc: "invalid-callback",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			},
+			wantCode:           http.StatusBadRequest,
+			wantResult:         authmetrics.ResultDenied,
+			wantReason:         "bad_request",
+			wantEnforcedResult: authmetrics.ResultDenied,
+			wantEnforcedReason: "bad_request",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Each test uses its own policy name as metrics are global.
+			policy := "dry-run-" + test.desc
+
+			handler := authmetrics.Handler(NewHandler(test.handler, policy, "JWT"), policy, "JWT")
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/"+policy, nil)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, []string{test.wantResult + "; reason=" + test.wantReason}, rw.Header().Values(DecisionHeader))
+			for name, values := range test.wantHeaders {
+				assert.Equal(t, values, rw.Header().Values(name))
+			}
+
+			metrics := httptest.NewRecorder()
+			authmetrics.HTTPHandler().ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "http://auth.example.com/metrics", nil))
+
+			assert.Contains(t, metrics.Body.String(),
+				fmt.Sprintf(`hub_acp_dry_run_decisions_total{policy=%q,reason=%q,result=%q,type="JWT"} 1`, policy, test.wantReason, test.wantResult))
+			assert.Contains(t, metrics.Body.String(),
+				fmt.Sprintf(`hub_acp_decisions_total{policy=%q,reason=%q,result=%q,type="JWT"} 1`, policy, test.wantEnforcedReason, test.wantEnforcedResult))
+		})
+	}
+}
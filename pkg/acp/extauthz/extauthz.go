@@ -0,0 +1,379 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package extauthz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// maxCacheEntries is the maximum number of decisions kept in memory.
+const maxCacheEntries = 10000
+
+// Config configures an external authorization ACP handler.
+type Config struct {
+	URL                  string
+	AuthenticationPolicy string
+	RequestHeaders       []string
+	ResponseHeaders      []string
+	Timeout              int
+	FailOpen             bool
+	CacheTTL             int
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing URL host")
+	}
+
+	if cfg.Timeout < 0 {
+		return errors.New("timeout must be positive")
+	}
+	if cfg.CacheTTL < 0 {
+		return errors.New("cache TTL must be positive")
+	}
+
+	return nil
+}
+
+// DecisionRequest is the request sent to the decision service.
+type DecisionRequest struct {
+	Policy  string                 `json:"policy"`
+	Method  string                 `json:"method"`
+	Host    string                 `json:"host"`
+	Path    string                 `json:"path"`
+	Headers map[string]string      `json:"headers,omitempty"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Decision is the response of the decision service.
+type Decision struct {
+	Allow bool `json:"allow"`
+	// Headers holds headers to add to the request. Only the headers listed in the ResponseHeaders option are added.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type cacheEntry struct {
+	decision  Decision
+	expiresAt time.Time
+}
+
+// Handler is an external authorization ACP Handler. It optionally authenticates requests with another ACP handler,
+// then asks an external service whether the request is allowed.
+type Handler struct {
+	name string
+
+	url             string
+	authn           http.Handler
+	client          *http.Client
+	requestHeaders  []string
+	responseHeaders map[string]struct{}
+	failOpen        bool
+
+	cacheTTL  time.Duration
+	cacheMu   sync.Mutex
+	cache     map[string]cacheEntry
+	nextSweep time.Time
+
+	now func() time.Time
+}
+
+// NewHandler returns a new external authorization ACP Handler. The given authentication handler is optional. When set,
+// requests it doesn't allow are rejected with its response, and the claims it verified are sent to the decision service.
+func NewHandler(cfg *Config, authn http.Handler, polName string) (*Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	respHeaders := make(map[string]struct{}, len(cfg.ResponseHeaders))
+	for _, name := range cfg.ResponseHeaders {
+		respHeaders[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+
+	return &Handler{
+		name:            polName,
+		url:             cfg.URL,
+		authn:           authn,
+		client:          &http.Client{Timeout: timeout},
+		requestHeaders:  cfg.RequestHeaders,
+		responseHeaders: respHeaders,
+		failOpen:        cfg.FailOpen,
+		cacheTTL:        time.Duration(cfg.CacheTTL) * time.Second,
+		cache:           make(map[string]cacheEntry),
+		now:             time.Now,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "ExternalAuthz").Str("handler_name", h.name).Logger()
+
+	header := make(http.Header)
+
+	var claims map[string]interface{}
+	if h.authn != nil {
+		ctx, rec := identity.WithRecorder(req.Context())
+
+		resp := newResponse()
+		h.authn.ServeHTTP(resp, req.WithContext(ctx))
+		if resp.code != 0 && resp.code != http.StatusOK {
+			resp.writeTo(rw)
+			return
+		}
+
+		header = resp.header
+		claims = rec.Claims()
+	}
+
+	decisionReq := h.newDecisionRequest(req, claims)
+
+	decision, err := h.decide(req, decisionReq, subject(claims))
+	if err != nil {
+		if !h.failOpen {
+			l.Error().Err(err).Msg("Unable to get authorization decision, denying request")
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		l.Error().Err(err).Msg("Unable to get authorization decision, allowing request")
+		decision = Decision{Allow: true}
+	}
+
+	if !decision.Allow {
+		l.Debug().Str("method", decisionReq.Method).Str("host", decisionReq.Host).Str("path", decisionReq.Path).Msg("Request denied")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	h.addDecisionHeaders(l, header, decision.Headers)
+
+	if claims != nil {
+		identity.RecordClaims(req.Context(), claims)
+	}
+
+	for name, values := range header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) newDecisionRequest(req *http.Request, claims map[string]interface{}) DecisionRequest {
+	path := req.Header.Get("X-Forwarded-Uri")
+	if u, err := url.ParseRequestURI(path); err == nil {
+		path = u.Path
+	}
+
+	decisionReq := DecisionRequest{
+		Policy: h.name,
+		Method: req.Header.Get("X-Forwarded-Method"),
+		Host:   req.Header.Get("X-Forwarded-Host"),
+		Path:   path,
+		Claims: claims,
+	}
+
+	for _, name := range h.requestHeaders {
+		value := req.Header.Get(name)
+		if value == "" {
+			continue
+		}
+
+		if decisionReq.Headers == nil {
+			decisionReq.Headers = make(map[string]string)
+		}
+		decisionReq.Headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	return decisionReq
+}
+
+// decide returns the decision of the decision service for the given request. Decisions are cached per subject and
+// route, when a subject is known.
+func (h *Handler) decide(req *http.Request, decisionReq DecisionRequest, sub string) (Decision, error) {
+	var key string
+	if h.cacheTTL > 0 && sub != "" {
+		key = sub + "\x00" + decisionReq.Method + "\x00" + decisionReq.Host + "\x00" + decisionReq.Path
+
+		if decision, ok := h.cached(key); ok {
+			return decision, nil
+		}
+	}
+
+	decision, err := h.requestDecision(req, decisionReq)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if key != "" {
+		h.store(key, decision)
+	}
+
+	return decision, nil
+}
+
+func (h *Handler) requestDecision(req *http.Request, decisionReq DecisionRequest) (Decision, error) {
+	body, err := json.Marshal(decisionReq)
+	if err != nil {
+		return Decision{}, fmt.Errorf("marshal decision request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, fmt.Errorf("build decision request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return Decision{}, fmt.Errorf("request decision: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return Decision{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var decision Decision
+	if err = json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, fmt.Errorf("decode decision: %w", err)
+	}
+
+	return decision, nil
+}
+
+// addDecisionHeaders adds the headers returned by the decision service which are allowed to be forwarded.
+func (h *Handler) addDecisionHeaders(l zerolog.Logger, header http.Header, decisionHeaders map[string]string) {
+	for name, value := range decisionHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := h.responseHeaders[name]; !ok {
+			l.Debug().Str("header", name).Msg("Ignoring header not listed in the response headers")
+			continue
+		}
+
+		header.Set(name, value)
+	}
+}
+
+func (h *Handler) cached(key string) (Decision, bool) {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	entry, ok := h.cache[key]
+	if !ok || !h.now().Before(entry.expiresAt) {
+		return Decision{}, false
+	}
+
+	return entry.decision, true
+}
+
+// store caches the given decision. Expired decisions are swept at most once per cache TTL, and the decision is not
+// cached if the cache is full until then.
+func (h *Handler) store(key string, decision Decision) {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	now := h.now()
+	if !now.Before(h.nextSweep) {
+		for k, entry := range h.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(h.cache, k)
+			}
+		}
+		h.nextSweep = now.Add(h.cacheTTL)
+	}
+
+	if _, ok := h.cache[key]; !ok && len(h.cache) >= maxCacheEntries {
+		return
+	}
+
+	h.cache[key] = cacheEntry{decision: decision, expiresAt: now.Add(h.cacheTTL)}
+}
+
+// subject returns the subject of the given claims, or an empty string if there is none.
+func subject(claims map[string]interface{}) string {
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// response is an http.ResponseWriter recording the response of the authentication handler.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newResponse() *response {
+	return &response{header: make(http.Header)}
+}
+
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+func (r *response) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *response) writeTo(rw http.ResponseWriter) {
+	for name, values := range r.header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+
+	rw.WriteHeader(r.code)
+	_, _ = rw.Write(r.body.Bytes())
+}
@@ -0,0 +1,360 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package extauthz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc: "valid",
+			cfg:  Config{URL: "http://authz.default.svc:8080/decide", Timeout: 2, CacheTTL: 30},
+		},
+		{
+			desc:    "unsupported scheme",
+			cfg:     Config{URL: "ftp://authz.default.svc"},
+			wantErr: `unsupported URL scheme "ftp"`,
+		},
+		{
+			desc:    "missing host",
+			cfg:     Config{URL: "http:///decide"},
+			wantErr: "missing URL host",
+		},
+		{
+			desc:    "negative timeout",
+			cfg:     Config{URL: "http://authz.default.svc", Timeout: -1},
+			wantErr: "timeout must be positive",
+		},
+		{
+			desc:    "negative cache TTL",
+			cfg:     Config{URL: "http://authz.default.svc", CacheTTL: -1},
+			wantErr: "cache TTL must be positive",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.cfg.Validate()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	authn := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.Header.Get("Authorization") {
+		case "jane":
+			identity.RecordClaims(req.Context(), map[string]interface{}{"sub": "jane", "tenant": "acme"})
+			rw.Header().Set("X-User", "jane")
+			rw.WriteHeader(http.StatusOK)
+		case "":
+			rw.Header().Set("Location", "https://idp.example.com")
+			rw.WriteHeader(http.StatusFound)
+		default:
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	tests := []struct {
+		desc        string
+		cfg         Config
+		authn       http.Handler
+		auth        string
+		decision    func(rw http.ResponseWriter, decisionReq DecisionRequest)
+		wantCode    int
+		wantHeaders map[string]string
+		wantRequest *DecisionRequest
+	}{
+		{
+			desc:  "allowed",
+			cfg:   Config{RequestHeaders: []string{"x-tenant", "X-Missing"}, ResponseHeaders: []string{"x-plan"}},
+			authn: authn,
+			auth:  "jane",
+			decision: func(rw http.ResponseWriter, _ DecisionRequest) {
+				_ = json.NewEncoder(rw).Encode(Decision{
+					Allow:   true,
+					Headers: map[string]string{"X-Plan": "gold", "X-Admin": "true"},
+				})
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-User":  "jane",
+				"X-Plan":  "gold",
+				"X-Admin": "",
+			},
+			wantRequest: &DecisionRequest{
+				Policy:  "acp",
+				Method:  http.MethodPost,
+				Host:    "api.example.com",
+				Path:    "/orders",
+				Headers: map[string]string{"X-Tenant": "acme"},
+				Claims:  map[string]interface{}{"sub": "jane", "tenant": "acme"},
+			},
+		},
+		{
+			desc:  "denied",
+			authn: authn,
+			auth:  "jane",
+			decision: func(rw http.ResponseWriter, _ DecisionRequest) {
+				_ = json.NewEncoder(rw).Encode(Decision{Allow: false})
+			},
+			wantCode:    http.StatusForbidden,
+			wantHeaders: map[string]string{"X-User": ""},
+		},
+		{
+			desc:        "authentication redirects",
+			authn:       authn,
+			wantCode:    http.StatusFound,
+			wantHeaders: map[string]string{"Location": "https://idp.example.com"},
+		},
+		{
+			desc:     "authentication fails",
+			authn:    authn,
+			auth:     "john",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc: "without authentication",
+			decision: func(rw http.ResponseWriter, _ DecisionRequest) {
+				_ = json.NewEncoder(rw).Encode(Decision{Allow: true})
+			},
+			wantCode: http.StatusOK,
+			wantRequest: &DecisionRequest{
+				Policy: "acp",
+				Method: http.MethodPost,
+				Host:   "api.example.com",
+				Path:   "/orders",
+			},
+		},
+		{
+			desc:  "decision service failure: fail closed",
+			authn: authn,
+			auth:  "jane",
+			decision: func(rw http.ResponseWriter, _ DecisionRequest) {
+				rw.WriteHeader(http.StatusInternalServerError)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:  "decision service failure: fail open",
+			cfg:   Config{FailOpen: true},
+			authn: authn,
+			auth:  "jane",
+			decision: func(rw http.ResponseWriter, _ DecisionRequest) {
+				_, _ = rw.Write([]byte("not json"))
+			},
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"X-User": "jane"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var gotRequest *DecisionRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				var decisionReq DecisionRequest
+				if err := json.NewDecoder(req.Body).Decode(&decisionReq); err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				gotRequest = &decisionReq
+
+				if test.decision == nil {
+					rw.WriteHeader(http.StatusTeapot)
+					return
+				}
+				test.decision(rw, decisionReq)
+			}))
+			t.Cleanup(srv.Close)
+
+			cfg := test.cfg
+			cfg.URL = srv.URL
+
+			handler, err := NewHandler(&cfg, test.authn, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			req.Header.Set("X-Forwarded-Method", http.MethodPost)
+			req.Header.Set("X-Forwarded-Host", "api.example.com")
+			req.Header.Set("X-Forwarded-Uri", "/orders?limit=10")
+			req.Header.Set("X-Tenant", "acme")
+			if test.auth != "" {
+				req.Header.Set("Authorization", test.auth)
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, value := range test.wantHeaders {
+				assert.Equal(t, value, rw.Header().Get(name), name)
+			}
+			if test.wantRequest != nil {
+				assert.Equal(t, test.wantRequest, gotRequest)
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+
+	tests := []struct {
+		desc     string
+		failOpen bool
+		wantCode int
+	}{
+		{
+			desc:     "fail closed",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "fail open",
+			failOpen: true,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&Config{URL: srv.URL, FailOpen: test.failOpen}, nil, "acp")
+			require.NoError(t, err)
+			handler.client.Timeout = 50 * time.Millisecond
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil))
+
+			assert.Equal(t, test.wantCode, rw.Code)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_cache(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		_ = json.NewEncoder(rw).Encode(Decision{Allow: true})
+	}))
+	t.Cleanup(srv.Close)
+
+	authn := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		identity.RecordClaims(req.Context(), map[string]interface{}{"sub": req.Header.Get("Authorization")})
+	})
+
+	handler, err := NewHandler(&Config{URL: srv.URL, CacheTTL: 10}, authn, "acp")
+	require.NoError(t, err)
+
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+
+	serve := func(sub, uri string) {
+		req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+		req.Header.Set("Authorization", sub)
+		req.Header.Set("X-Forwarded-Method", http.MethodGet)
+		req.Header.Set("X-Forwarded-Host", "api.example.com")
+		req.Header.Set("X-Forwarded-Uri", uri)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		require.Equal(t, http.StatusOK, rw.Code)
+	}
+
+	serve("jane", "/orders")
+	serve("jane", "/orders?page=2")
+	assert.Equal(t, int32(1), calls.Load())
+
+	serve("john", "/orders")
+	serve("jane", "/invoices")
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Requests without subject are never cached.
+	serve("", "/orders")
+	serve("", "/orders")
+	assert.Equal(t, int32(5), calls.Load())
+
+	now = now.Add(11 * time.Second)
+	serve("jane", "/orders")
+	assert.Equal(t, int32(6), calls.Load())
+}
+
+func TestHandler_store(t *testing.T) {
+	handler, err := NewHandler(&Config{URL: "http://decision.example.com", CacheTTL: 10}, nil, "acp")
+	require.NoError(t, err)
+
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+
+	for i := 0; i < maxCacheEntries; i++ {
+		handler.store(strconv.Itoa(i), Decision{Allow: true})
+	}
+
+	// The cache is full until expired decisions are swept.
+	handler.store("new", Decision{Allow: true})
+	_, ok := handler.cached("new")
+	assert.False(t, ok)
+
+	// Decisions already cached can still be refreshed.
+	handler.store("0", Decision{})
+	decision, ok := handler.cached("0")
+	require.True(t, ok)
+	assert.False(t, decision.Allow)
+
+	now = now.Add(11 * time.Second)
+	handler.store("new", Decision{Allow: true})
+	_, ok = handler.cached("new")
+	assert.True(t, ok)
+	assert.Len(t, handler.cache, 1)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"context"
+	"fmt"
+
+	gogithub "github.com/google/go-github/v47/github"
+)
+
+// maxPages is the maximum number of pages fetched when listing memberships.
+const maxPages = 10
+
+// User is a GitHub user along with their organization and team memberships.
+type User struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// Orgs holds the logins of the organizations the user is a member of.
+	Orgs []string `json:"orgs,omitempty"`
+	// Teams holds the teams the user is a member of, formatted as `org/team-slug`.
+	Teams []string `json:"teams,omitempty"`
+}
+
+// fetchUser resolves the user authenticated by the given client along with their memberships.
+func fetchUser(ctx context.Context, client *gogithub.Client) (User, error) {
+	u, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return User{}, fmt.Errorf("get user: %w", err)
+	}
+
+	user := User{
+		ID:    u.GetID(),
+		Login: u.GetLogin(),
+		Name:  u.GetName(),
+		Email: u.GetEmail(),
+	}
+
+	// The public email of the user is empty unless they chose to expose one.
+	if user.Email == "" {
+		user.Email, err = fetchPrimaryEmail(ctx, client)
+		if err != nil {
+			return User{}, err
+		}
+	}
+
+	user.Orgs, err = fetchOrgs(ctx, client)
+	if err != nil {
+		return User{}, err
+	}
+
+	user.Teams, err = fetchTeams(ctx, client)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// fetchPrimaryEmail returns the primary email of the user, if verified.
+func fetchPrimaryEmail(ctx context.Context, client *gogithub.Client) (string, error) {
+	opts := &gogithub.ListOptions{PerPage: 100}
+
+	for i := 0; i < maxPages; i++ {
+		emails, resp, err := client.Users.ListEmails(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("list emails: %w", err)
+		}
+
+		for _, email := range emails {
+			if email.GetPrimary() && email.GetVerified() {
+				return email.GetEmail(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return "", nil
+}
+
+func fetchOrgs(ctx context.Context, client *gogithub.Client) ([]string, error) {
+	opts := &gogithub.ListOptions{PerPage: 100}
+
+	var orgs []string
+	for i := 0; i < maxPages; i++ {
+		page, resp, err := client.Organizations.List(ctx, "", opts)
+		if err != nil {
+			return nil, fmt.Errorf("list organizations: %w", err)
+		}
+
+		for _, org := range page {
+			orgs = append(orgs, org.GetLogin())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return orgs, nil
+}
+
+func fetchTeams(ctx context.Context, client *gogithub.Client) ([]string, error) {
+	opts := &gogithub.ListOptions{PerPage: 100}
+
+	var teams []string
+	for i := 0; i < maxPages; i++ {
+		page, resp, err := client.Teams.ListUserTeams(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("list teams: %w", err)
+		}
+
+		for _, team := range page {
+			teams = append(teams, team.GetOrganization().GetLogin()+"/"+team.GetSlug())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return teams, nil
+}
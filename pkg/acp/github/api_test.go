@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	gogithub "github.com/google/go-github/v47/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestFetchUser(t *testing.T) {
+	tests := []struct {
+		desc      string
+		user      string
+		emails    string
+		emailCode int
+		wantUser  User
+		wantErr   bool
+	}{
+		{
+			desc: "public email",
+			user: `{"id": 42, "login": "alice", "name": "Alice", "email": "alice@example.com"}`,
+			wantUser: User{
+				ID:    42,
+				Login: "alice",
+				Name:  "Alice",
+				Email: "alice@example.com",
+				Orgs:  []string{"traefik", "containous", "acme"},
+				Teams: []string{"traefik/hub", "acme/ops"},
+			},
+		},
+		{
+			desc:   "private email",
+			user:   `{"id": 42, "login": "alice"}`,
+			emails: `[{"email": "old@example.com", "primary": false, "verified": true}, {"email": "alice@example.com", "primary": true, "verified": true}]`,
+			wantUser: User{
+				ID:    42,
+				Login: "alice",
+				Email: "alice@example.com",
+				Orgs:  []string{"traefik", "containous", "acme"},
+				Teams: []string{"traefik/hub", "acme/ops"},
+			},
+		},
+		{
+			desc:   "unverified primary email",
+			user:   `{"id": 42, "login": "alice"}`,
+			emails: `[{"email": "alice@example.com", "primary": true, "verified": false}]`,
+			wantUser: User{
+				ID:    42,
+				Login: "alice",
+				Orgs:  []string{"traefik", "containous", "acme"},
+				Teams: []string{"traefik/hub", "acme/ops"},
+			},
+		},
+		{
+			desc:      "unable to list emails",
+			user:      `{"id": 42, "login": "alice"}`,
+			emailCode: http.StatusForbidden,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newAPIServer(t, test.user, test.emails, test.emailCode)
+
+			client := gogithub.NewClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})))
+			client.BaseURL, _ = url.Parse(srv.URL + "/api/v3/")
+
+			user, err := fetchUser(context.Background(), client)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantUser, user)
+		})
+	}
+}
+
+// newAPIServer returns a stand-in of the GitHub Enterprise Server REST API. The organizations of the user are
+// paginated.
+func newAPIServer(t *testing.T, user, emails string, emailCode int) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = fmt.Fprint(rw, user)
+	})
+	mux.HandleFunc("/api/v3/user/emails", func(rw http.ResponseWriter, req *http.Request) {
+		if emailCode != 0 {
+			rw.WriteHeader(emailCode)
+			return
+		}
+
+		_, _ = fmt.Fprint(rw, emails)
+	})
+	mux.HandleFunc("/api/v3/user/orgs", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "2" {
+			_, _ = fmt.Fprint(rw, `[{"login": "acme"}]`)
+			return
+		}
+
+		rw.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/user/orgs?per_page=100&page=2>; rel="next"`, req.Host))
+		_, _ = fmt.Fprint(rw, `[{"login": "traefik"}, {"login": "containous"}]`)
+	})
+	mux.HandleFunc("/api/v3/user/teams", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(rw, `[{"slug": "hub", "organization": {"login": "traefik"}}, {"slug": "ops", "organization": {"login": "acme"}}]`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
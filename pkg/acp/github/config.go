@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+)
+
+const defaultBaseURL = "https://github.com"
+
+// Config configures a GitHub ACP handler, authenticating users through the GitHub OAuth2 web application flow and
+// authorizing them based on their organization and team memberships.
+type Config struct {
+	ClientID     string           `json:"clientId,omitempty"`
+	ClientSecret string           `json:"-"`
+	Secret       *SecretReference `json:"secret,omitempty"`
+	// BaseURL is the URL of the GitHub instance. It defaults to https://github.com and must be set to the URL of the
+	// instance when using GitHub Enterprise Server.
+	BaseURL string `json:"baseUrl,omitempty"`
+
+	RedirectURL string                `json:"redirectUrl,omitempty"`
+	LogoutURL   string                `json:"logoutUrl,omitempty"`
+	Key         string                `json:"-"`
+	StateCookie *oidc.AuthStateCookie `json:"stateCookie,omitempty"`
+	// Session configures the session cookie. Its TTL is the number of seconds memberships are trusted for before
+	// being resolved again.
+	Session *oidc.AuthSession `json:"session,omitempty"`
+	// DecryptionKeys holds keys only used to decrypt cookies, encrypted before a rotation of Key.
+	DecryptionKeys []string `json:"-"`
+
+	// Orgs lists the organizations users must be a member of at least one of.
+	Orgs []string `json:"orgs,omitempty"`
+	// Teams lists the teams users must be a member of at least one of, formatted as `org/team-slug`.
+	Teams []string `json:"teams,omitempty"`
+
+	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the
+	// user. Available claims are `login`, `id`, `name`, `email`, `orgs` and `teams`.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string
+	Namespace string
+}
+
+// ApplyDefaultValues applies default values on the given dynamic configuration.
+func (cfg *Config) ApplyDefaultValues() {
+	if cfg == nil {
+		return
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+
+	if cfg.StateCookie == nil {
+		cfg.StateCookie = &oidc.AuthStateCookie{}
+	}
+
+	if cfg.StateCookie.Path == "" {
+		cfg.StateCookie.Path = "/"
+	}
+
+	if cfg.StateCookie.SameSite == "" {
+		cfg.StateCookie.SameSite = "lax"
+	}
+
+	if cfg.Session == nil {
+		cfg.Session = &oidc.AuthSession{}
+	}
+
+	if cfg.Session.Path == "" {
+		cfg.Session.Path = "/"
+	}
+
+	if cfg.Session.SameSite == "" {
+		cfg.Session.SameSite = "lax"
+	}
+
+	if cfg.Session.Store == "" {
+		cfg.Session.Store = oidc.SessionStoreCookie
+	}
+
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = "/callback"
+	}
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	cfg.ApplyDefaultValues()
+
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return fmt.Errorf("parse base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported base URL scheme %q", u.Scheme)
+	}
+
+	if cfg.ClientID == "" {
+		return errors.New("missing client ID")
+	}
+
+	if cfg.ClientSecret == "" {
+		return errors.New("missing client secret")
+	}
+
+	if cfg.Key == "" {
+		return errors.New("missing key")
+	}
+
+	for _, key := range append([]string{cfg.Key}, cfg.DecryptionKeys...) {
+		switch len(key) {
+		case 16, 24, 32:
+			break
+		default:
+			return errors.New("key must be 16, 24 or 32 characters long")
+		}
+	}
+
+	if cfg.Session.Store != oidc.SessionStoreCookie {
+		return fmt.Errorf("unsupported session store %q", cfg.Session.Store)
+	}
+
+	if cfg.Session.TTL < 0 {
+		return errors.New("session TTL must not be negative")
+	}
+
+	if len(cfg.Orgs) == 0 && len(cfg.Teams) == 0 {
+		return errors.New("missing allowed organizations or teams")
+	}
+
+	for _, team := range cfg.Teams {
+		org, slug, ok := strings.Cut(team, "/")
+		if !ok || org == "" || slug == "" || strings.Contains(slug, "/") {
+			return fmt.Errorf("team %q must be formatted as org/team-slug", team)
+		}
+	}
+
+	return nil
+}
+
+// apiURL returns the URL of the REST API of the configured GitHub instance.
+func (cfg *Config) apiURL() string {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == defaultBaseURL {
+		return "https://api.github.com"
+	}
+
+	// GitHub Enterprise Server exposes its REST API under /api/v3.
+	return baseURL + "/api/v3"
+}
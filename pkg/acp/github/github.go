@@ -0,0 +1,409 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gogithub "github.com/google/go-github/v47/github"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"golang.org/x/oauth2"
+)
+
+const defaultSessionTTL = time.Hour
+
+// scopes are the OAuth2 scopes required to resolve the user email along with their organization and team memberships.
+var scopes = []string{"read:user", "user:email", "read:org"}
+
+// Handler is a GitHub ACP Handler. It authenticates users through the GitHub OAuth2 web application flow and allows
+// members of the configured organizations and teams.
+type Handler struct {
+	name string
+	cfg  *Config
+
+	oauth  *oauth2.Config
+	apiURL *url.URL
+	client *http.Client
+
+	orgs  map[string]struct{}
+	teams map[string]struct{}
+
+	sealer     sealer
+	sessionTTL time.Duration
+	now        func() time.Time
+}
+
+// NewHandler returns a new GitHub ACP Handler.
+func NewHandler(cfg *Config, name string) (*Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	apiURL, err := url.Parse(cfg.apiURL() + "/")
+	if err != nil {
+		return nil, fmt.Errorf("parse API URL: %w", err)
+	}
+
+	sealer, err := newSealer(append([]string{cfg.Key}, cfg.DecryptionKeys...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTTL := defaultSessionTTL
+	if cfg.Session.TTL > 0 {
+		sessionTTL = time.Duration(cfg.Session.TTL) * time.Second
+	}
+
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+
+	return &Handler{
+		name: name,
+		cfg:  cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   baseURL + "/login/oauth/authorize",
+				TokenURL:  baseURL + "/login/oauth/access_token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			Scopes: scopes,
+		},
+		apiURL:     apiURL,
+		client:     newHTTPClient(),
+		orgs:       toSet(cfg.Orgs),
+		teams:      toSet(cfg.Teams),
+		sealer:     sealer,
+		sessionTTL: sessionTTL,
+		now:        time.Now,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "GitHub").Str("handler_name", h.name).Logger()
+
+	logoutURL := resolveURL(req, h.cfg.LogoutURL)
+	forwardedURL := fmt.Sprintf("%s://%s%s", req.Header.Get("X-Forwarded-Proto"), req.Header.Get("X-Forwarded-Host"), req.Header.Get("X-Forwarded-Uri"))
+
+	if equalURL(forwardedURL, logoutURL) && req.Header.Get("X-Forwarded-Method") == http.MethodDelete {
+		h.deleteSession(rw)
+		rw.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	sess, err := h.getSession(req)
+	if err != nil {
+		// Sessions sealed with a previous key can't be opened, users must authenticate again.
+		logger.Debug().Err(err).Msg("Unable to get the session")
+		sess = nil
+	}
+
+	if sess == nil || h.now().Unix() >= sess.ExpiresAt {
+		redirectURL := resolveURL(req, h.cfg.RedirectURL)
+
+		if equalURL(forwardedURL, redirectURL) {
+			logger.Debug().Msg("Handle provider callback")
+			h.handleProviderCallback(rw, req, redirectURL)
+
+			return
+		}
+
+		if !shouldRedirect(req) {
+			logger.Debug().Msg("Received a request that should not be redirected")
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		h.redirectToProvider(rw, req, redirectURL)
+
+		return
+	}
+
+	// Memberships are checked on each request as the allowed organizations and teams may have changed since the
+	// session was created.
+	if !h.authorized(sess.User) {
+		logger.Debug().Str("login", sess.Login).Msg("User is not a member of the allowed organizations or teams")
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	claims := sess.claims()
+
+	hdrs, err := expr.PluckClaims(h.cfg.ForwardHeaders, claims)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to set forwarded header")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	for name, vals := range hdrs {
+		rw.Header().Del(name)
+		for _, val := range vals {
+			rw.Header().Add(name, val)
+		}
+	}
+
+	identity.RecordClaims(req.Context(), claims)
+
+	h.removeSessionCookie(rw, req)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) redirectToProvider(rw http.ResponseWriter, req *http.Request, redirectURL string) {
+	logger := log.With().Str("handler_type", "GitHub").Str("handler_name", h.name).Logger()
+
+	redirectID, err := h.randomString(20)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to generate state")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	state := StateData{
+		RedirectID: redirectID,
+		OriginURL:  fmt.Sprintf("%s://%s%s", req.Header.Get("X-Forwarded-Proto"), req.Header.Get("X-Forwarded-Host"), req.Header.Get("X-Forwarded-Uri")),
+	}
+
+	if err = h.setState(rw, state); err != nil {
+		logger.Debug().Err(err).Msg("Unable to create state cookie")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	authCodeURL := h.oauth.AuthCodeURL(state.RedirectID, oauth2.SetAuthURLParam("redirect_uri", redirectURL))
+
+	if req.Header.Get("From") == "nginx" {
+		rw.Header().Add("url_redirect", authCodeURL)
+		rw.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	http.Redirect(rw, req, authCodeURL, http.StatusFound)
+}
+
+func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Request, redirectURL string) {
+	logger := log.With().Str("handler_type", "GitHub").Str("handler_name", h.name).Logger()
+
+	state, err := h.getState(req)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Malformed state payload")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(req.Header.Get("X-Forwarded-Uri"))
+	if err != nil || state == nil || u.Query().Get("state") != state.RedirectID {
+		logger.Debug().Err(err).Msg("Mismatched request ID or empty state")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), oauth2.HTTPClient, h.client)
+
+	token, err := h.oauth.Exchange(ctx, u.Query().Get("code"), oauth2.SetAuthURLParam("redirect_uri", redirectURL))
+	if err != nil {
+		logger.Debug().Err(err).Msg("Unable to exchange code")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	client := gogithub.NewClient(h.oauth.Client(ctx, token))
+	client.BaseURL = h.apiURL
+
+	user, err := fetchUser(ctx, client)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to fetch user")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.clearState(rw)
+
+	// Only memberships relevant to the policy are kept, to bound the size of the session.
+	user.Orgs, user.Teams = h.allowedMemberships(user)
+	if !h.authorized(user) {
+		logger.Debug().Str("login", user.Login).Msg("User is not a member of the allowed organizations or teams")
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	sess := SessionData{
+		User:      user,
+		ExpiresAt: h.now().Add(h.sessionTTL).Unix(),
+	}
+
+	if err = h.setSession(rw, sess); err != nil {
+		logger.Error().Err(err).Msg("Unable to create session")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(rw, req, state.OriginURL, http.StatusFound)
+}
+
+// allowedMemberships returns the organizations and teams of the given user which are allowed by the policy.
+// Memberships are compared case-insensitively.
+func (h *Handler) allowedMemberships(user User) (orgs, teams []string) {
+	for _, org := range user.Orgs {
+		if _, ok := h.orgs[strings.ToLower(org)]; ok {
+			orgs = append(orgs, org)
+		}
+	}
+
+	for _, team := range user.Teams {
+		if _, ok := h.teams[strings.ToLower(team)]; ok {
+			teams = append(teams, team)
+		}
+	}
+
+	return orgs, teams
+}
+
+// authorized returns whether the given user is a member of at least one of the allowed organizations or teams.
+func (h *Handler) authorized(user User) bool {
+	orgs, teams := h.allowedMemberships(user)
+
+	return len(orgs) > 0 || len(teams) > 0
+}
+
+func (h *Handler) randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(h.sealer.rand, b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// claims returns the claims of the session, which can be forwarded as headers.
+func (s *SessionData) claims() map[string]interface{} {
+	claims := map[string]interface{}{
+		"id":    json.Number(strconv.FormatInt(s.ID, 10)),
+		"login": s.Login,
+	}
+
+	if s.Name != "" {
+		claims["name"] = s.Name
+	}
+	if s.Email != "" {
+		claims["email"] = s.Email
+	}
+	if len(s.Orgs) > 0 {
+		claims["orgs"] = toInterfaces(s.Orgs)
+	}
+	if len(s.Teams) > 0 {
+		claims["teams"] = toInterfaces(s.Teams)
+	}
+
+	return claims
+}
+
+func toInterfaces(values []string) []interface{} {
+	res := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		res = append(res, value)
+	}
+
+	return res
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[strings.ToLower(value)] = struct{}{}
+	}
+
+	return set
+}
+
+func shouldRedirect(req *http.Request) bool {
+	switch req.Header.Get("X-Forwarded-Method") {
+	case http.MethodPost, http.MethodDelete, http.MethodPatch, http.MethodPut:
+		return false
+	}
+
+	// The favicon seems to do bad things, ban it.
+	return !strings.Contains(req.Header.Get("X-Forwarded-Uri"), "favicon.ico")
+}
+
+func resolveURL(r *http.Request, u string) string {
+	if u == "" {
+		return u
+	}
+
+	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+
+	proto := r.Header.Get("X-Forwarded-Proto")
+
+	if u[0] == '/' {
+		host := r.Header.Get("X-Forwarded-Host")
+		return proto + "://" + host + u
+	}
+
+	return proto + "://" + u
+}
+
+func equalURL(originalURL, otherURL string) bool {
+	oURL, err := url.Parse(originalURL)
+	if err != nil {
+		return false
+	}
+
+	otURL, err := url.Parse(otherURL)
+	if err != nil {
+		return false
+	}
+
+	return oURL.Host == otURL.Host && oURL.Path == otURL.Path
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			Proxy:               http.ProxyFromEnvironment,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,414 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc: "valid",
+			cfg:  Config{ClientID: "id", ClientSecret: "secret", Key: "1234567890123456", Orgs: []string{"traefik"}},
+		},
+		{
+			desc:    "unsupported base URL scheme",
+			cfg:     Config{BaseURL: "ftp://github.example.com", ClientID: "id", ClientSecret: "secret", Key: "1234567890123456", Orgs: []string{"traefik"}},
+			wantErr: `unsupported base URL scheme "ftp"`,
+		},
+		{
+			desc:    "missing client secret",
+			cfg:     Config{ClientID: "id", Key: "1234567890123456", Orgs: []string{"traefik"}},
+			wantErr: "missing client secret",
+		},
+		{
+			desc:    "invalid key",
+			cfg:     Config{ClientID: "id", ClientSecret: "secret", Key: "1234", Orgs: []string{"traefik"}},
+			wantErr: "key must be 16, 24 or 32 characters long",
+		},
+		{
+			desc:    "missing orgs and teams",
+			cfg:     Config{ClientID: "id", ClientSecret: "secret", Key: "1234567890123456"},
+			wantErr: "missing allowed organizations or teams",
+		},
+		{
+			desc:    "invalid team",
+			cfg:     Config{ClientID: "id", ClientSecret: "secret", Key: "1234567890123456", Teams: []string{"hub"}},
+			wantErr: `team "hub" must be formatted as org/team-slug`,
+		},
+		{
+			desc: "unsupported session store",
+			cfg: Config{
+				ClientID:     "id",
+				ClientSecret: "secret",
+				Key:          "1234567890123456",
+				Orgs:         []string{"traefik"},
+				Session:      &oidc.AuthSession{Store: oidc.SessionStoreMemory},
+			},
+			wantErr: `unsupported session store "memory"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.cfg.Validate()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfig_apiURL(t *testing.T) {
+	tests := []struct {
+		desc    string
+		baseURL string
+		want    string
+	}{
+		{
+			desc:    "github.com",
+			baseURL: "https://github.com",
+			want:    "https://api.github.com",
+		},
+		{
+			desc:    "GitHub Enterprise Server",
+			baseURL: "https://github.example.com/",
+			want:    "https://github.example.com/api/v3",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := Config{BaseURL: test.baseURL}
+
+			assert.Equal(t, test.want, cfg.apiURL())
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_authenticatesUsers(t *testing.T) {
+	srv := newGitHubServer(t)
+
+	h := newTestHandler(t, srv, &Config{
+		Orgs:  []string{"Containous"},
+		Teams: []string{"traefik/hub"},
+		ForwardHeaders: map[string]string{
+			"X-Login": "login",
+			"X-Email": "email",
+			"X-Orgs":  "orgs",
+			"X-Teams": "teams",
+		},
+	})
+
+	// Unauthenticated users are redirected to GitHub.
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, newForwardedRequest(http.MethodGet, "/foo"))
+
+	require.Equal(t, http.StatusFound, rw.Code)
+
+	location, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/login/oauth/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, "client-id", location.Query().Get("client_id"))
+	assert.Equal(t, "https://app.example.com/callback", location.Query().Get("redirect_uri"))
+	assert.Equal(t, "read:user user:email read:org", location.Query().Get("scope"))
+
+	stateCookie := findCookie(rw.Result().Cookies(), "acp-state")
+	require.NotNil(t, stateCookie)
+
+	// GitHub redirects users to the callback URL.
+	req := newForwardedRequest(http.MethodGet, "/callback?code=code&state="+location.Query().Get("state"))
+	req.AddCookie(stateCookie)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	require.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "https://app.example.com/foo", rw.Header().Get("Location"))
+
+	sessionCookie := findCookie(rw.Result().Cookies(), "acp")
+	require.NotNil(t, sessionCookie)
+	assert.Equal(t, 3600, sessionCookie.MaxAge)
+
+	// Authenticated users are allowed and their identity is forwarded.
+	req = newForwardedRequest(http.MethodGet, "/foo")
+	req.AddCookie(sessionCookie)
+	req.AddCookie(&http.Cookie{Name: "other", Value: "value"})
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "alice", rw.Header().Get("X-Login"))
+	assert.Equal(t, "alice@example.com", rw.Header().Get("X-Email"))
+	assert.Equal(t, []string{"containous"}, rw.Header().Values("X-Orgs"))
+	assert.Equal(t, []string{"traefik/hub"}, rw.Header().Values("X-Teams"))
+	assert.Equal(t, []string{"other=value"}, rw.Header().Values("Cookie"))
+}
+
+func TestHandler_ServeHTTP_forbidsNonMembers(t *testing.T) {
+	srv := newGitHubServer(t)
+
+	h := newTestHandler(t, srv, &Config{Teams: []string{"traefik/other"}})
+
+	state, err := h.sealer.seal(StateData{RedirectID: "redirect-id", OriginURL: "https://app.example.com/foo"})
+	require.NoError(t, err)
+
+	req := newForwardedRequest(http.MethodGet, "/callback?code=code&state=redirect-id")
+	req.AddCookie(&http.Cookie{Name: "acp-state", Value: state})
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Nil(t, findCookie(rw.Result().Cookies(), "acp"))
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc       string
+		method     string
+		uri        string
+		session    *SessionData
+		rawSession string
+		state      *StateData
+		wantCode   int
+	}{
+		{
+			desc:     "no session on a mutating request",
+			method:   http.MethodPost,
+			uri:      "/foo",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:   "expired session",
+			method: http.MethodGet,
+			uri:    "/foo",
+			session: &SessionData{
+				User:      User{Login: "alice", Orgs: []string{"traefik"}},
+				ExpiresAt: now.Unix(),
+			},
+			wantCode: http.StatusFound,
+		},
+		{
+			desc:       "tampered session",
+			method:     http.MethodGet,
+			uri:        "/foo",
+			rawSession: "tampered",
+			wantCode:   http.StatusFound,
+		},
+		{
+			desc:   "member of an organization no longer allowed",
+			method: http.MethodGet,
+			uri:    "/foo",
+			session: &SessionData{
+				User:      User{Login: "alice", Orgs: []string{"containous"}},
+				ExpiresAt: now.Add(time.Minute).Unix(),
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:   "valid session",
+			method: http.MethodPost,
+			uri:    "/foo",
+			session: &SessionData{
+				User:      User{Login: "alice", Orgs: []string{"traefik"}},
+				ExpiresAt: now.Add(time.Minute).Unix(),
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "callback with mismatched state",
+			method:   http.MethodGet,
+			uri:      "/callback?code=code&state=other",
+			state:    &StateData{RedirectID: "redirect-id", OriginURL: "https://app.example.com/foo"},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "callback with an invalid code",
+			method:   http.MethodGet,
+			uri:      "/callback?code=invalid&state=redirect-id",
+			state:    &StateData{RedirectID: "redirect-id", OriginURL: "https://app.example.com/foo"},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			desc:   "logout",
+			method: http.MethodDelete,
+			uri:    "/logout",
+			session: &SessionData{
+				User:      User{Login: "alice", Orgs: []string{"traefik"}},
+				ExpiresAt: now.Add(time.Minute).Unix(),
+			},
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newGitHubServer(t)
+
+			h := newTestHandler(t, srv, &Config{Orgs: []string{"traefik"}, LogoutURL: "/logout"})
+			h.now = func() time.Time { return now }
+
+			req := newForwardedRequest(test.method, test.uri)
+
+			if test.session != nil {
+				value, err := h.sealer.seal(test.session)
+				require.NoError(t, err)
+
+				req.AddCookie(&http.Cookie{Name: "acp", Value: value})
+			}
+			if test.rawSession != "" {
+				req.AddCookie(&http.Cookie{Name: "acp", Value: test.rawSession})
+			}
+			if test.state != nil {
+				value, err := h.sealer.seal(test.state)
+				require.NoError(t, err)
+
+				req.AddCookie(&http.Cookie{Name: "acp-state", Value: value})
+			}
+
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_opensSessionsSealedWithPreviousKeys(t *testing.T) {
+	srv := newGitHubServer(t)
+
+	previous, err := newSealer("previous12345678")
+	require.NoError(t, err)
+
+	value, err := previous.seal(SessionData{
+		User:      User{Login: "alice", Orgs: []string{"traefik"}},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+
+	req := newForwardedRequest(http.MethodGet, "/foo")
+	req.AddCookie(&http.Cookie{Name: "acp", Value: value})
+
+	h := newTestHandler(t, srv, &Config{Orgs: []string{"traefik"}})
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	h = newTestHandler(t, srv, &Config{Orgs: []string{"traefik"}, DecryptionKeys: []string{"previous12345678"}})
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func newTestHandler(t *testing.T, srv *httptest.Server, cfg *Config) *Handler {
+	t.Helper()
+
+	cfg.BaseURL = srv.URL
+	cfg.ClientID = "client-id"
+	cfg.ClientSecret = "client-secret"
+	cfg.Key = "1234567890123456"
+
+	h, err := NewHandler(cfg, "acp")
+	require.NoError(t, err)
+
+	h.client = srv.Client()
+
+	return h
+}
+
+// newGitHubServer returns a stand-in of a GitHub Enterprise Server instance, exchanging the "code" code.
+func newGitHubServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	api := newAPIServer(t, `{"id": 42, "login": "alice", "email": "alice@example.com"}`, "", 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil || req.Form.Get("code") != "code" || req.Form.Get("client_secret") != "client-secret" {
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(rw, `{"error": "bad_verification_code"}`)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(rw, `{"access_token": "token", "token_type": "bearer", "scope": "read:org,read:user,user:email"}`)
+	})
+	mux.Handle("/api/v3/", api.Config.Handler)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newForwardedRequest(method, uri string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+	req.Header.Set("X-Forwarded-Method", method)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	req.Header.Set("X-Forwarded-Uri", uri)
+
+	return req
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package github
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxCookieSize is the maximum size of a cookie value most browsers accept.
+const maxCookieSize = 4000
+
+// SessionData is the session of a user authenticated through GitHub.
+type SessionData struct {
+	User
+
+	// ExpiresAt is the Unix time after which memberships must be resolved again.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// StateData is the state of an ongoing authentication.
+type StateData struct {
+	RedirectID string `json:"redirectId"`
+	OriginURL  string `json:"originUrl"`
+}
+
+// sealer encrypts and authenticates cookie values. Unlike sessions of the OIDC handler, which hold tokens verified on
+// each request, sessions hold the memberships used for authorization, so their integrity must be guaranteed.
+// Values are sealed with the first AEAD and opened with any of them, so that keys can be rotated.
+type sealer struct {
+	aeads []cipher.AEAD
+	rand  io.Reader
+}
+
+func newSealer(keys ...string) (sealer, error) {
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher([]byte(key))
+		if err != nil {
+			return sealer{}, fmt.Errorf("new cipher: %w", err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return sealer{}, fmt.Errorf("new AEAD: %w", err)
+		}
+
+		aeads = append(aeads, aead)
+	}
+
+	return sealer{aeads: aeads, rand: rand.Reader}, nil
+}
+
+func (s sealer) seal(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("serialize: %w", err)
+	}
+
+	aead := s.aeads[0]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(s.rand, nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, nil)), nil
+}
+
+func (s sealer) open(value string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	for _, aead := range s.aeads {
+		nonceSize := aead.NonceSize()
+		if len(decoded) < nonceSize {
+			return errors.New("value too short")
+		}
+
+		payload, err := aead.Open(nil, decoded[:nonceSize], decoded[nonceSize:], nil)
+		if err != nil {
+			continue
+		}
+
+		if err = json.Unmarshal(payload, v); err != nil {
+			return fmt.Errorf("deserialize: %w", err)
+		}
+
+		return nil
+	}
+
+	return errors.New("unable to decrypt")
+}
+
+func (h *Handler) getSession(req *http.Request) (*SessionData, error) {
+	c, err := req.Cookie(h.name)
+	if err != nil {
+		return nil, nil
+	}
+
+	var sess SessionData
+	if err = h.sealer.open(c.Value, &sess); err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+
+	return &sess, nil
+}
+
+func (h *Handler) setSession(rw http.ResponseWriter, sess SessionData) error {
+	value, err := h.sealer.seal(sess)
+	if err != nil {
+		return fmt.Errorf("seal session: %w", err)
+	}
+
+	if len(value)+len(h.name) > maxCookieSize {
+		return errors.New("session too large")
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     h.name,
+		Value:    value,
+		Path:     h.cfg.Session.Path,
+		Domain:   h.cfg.Session.Domain,
+		MaxAge:   int(h.sessionTTL / time.Second),
+		HttpOnly: true,
+		SameSite: parseSameSite(h.cfg.Session.SameSite),
+		Secure:   h.cfg.Session.Secure,
+	})
+
+	return nil
+}
+
+func (h *Handler) deleteSession(rw http.ResponseWriter) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:   h.name,
+		Path:   h.cfg.Session.Path,
+		Domain: h.cfg.Session.Domain,
+		MaxAge: -1,
+	})
+}
+
+// removeSessionCookie removes the session cookie from the request forwarded to the upstream service.
+func (h *Handler) removeSessionCookie(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Del("Cookie")
+	for _, c := range req.Cookies() {
+		if c.Name != h.name {
+			rw.Header().Add("Cookie", c.String())
+		}
+	}
+}
+
+func (h *Handler) getState(req *http.Request) (*StateData, error) {
+	c, err := req.Cookie(h.name + "-state")
+	if err != nil {
+		return nil, nil
+	}
+
+	var state StateData
+	if err = h.sealer.open(c.Value, &state); err != nil {
+		return nil, fmt.Errorf("open state: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (h *Handler) setState(rw http.ResponseWriter, state StateData) error {
+	value, err := h.sealer.seal(state)
+	if err != nil {
+		return fmt.Errorf("seal state: %w", err)
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     h.name + "-state",
+		Value:    value,
+		Path:     h.cfg.StateCookie.Path,
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: parseSameSite(h.cfg.StateCookie.SameSite),
+		Secure:   h.cfg.StateCookie.Secure,
+		Domain:   h.cfg.StateCookie.Domain,
+	})
+
+	return nil
+}
+
+func (h *Handler) clearState(rw http.ResponseWriter) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:   h.name + "-state",
+		Path:   h.cfg.StateCookie.Path,
+		MaxAge: -1,
+		Domain: h.cfg.StateCookie.Domain,
+	})
+}
+
+func parseSameSite(raw string) http.SameSite {
+	switch strings.ToLower(raw) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
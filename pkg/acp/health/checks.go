@@ -0,0 +1,344 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/idtoken"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the conditions reported in the status of ACPs.
+const (
+	reasonReady             = "Ready"
+	reasonResolved          = "Resolved"
+	reasonNotFound          = "NotFound"
+	reasonInvalidSecret     = "InvalidSecret"
+	reasonLookupFailed      = "LookupFailed"
+	reasonReachable         = "Reachable"
+	reasonUnreachable       = "Unreachable"
+	reasonValid             = "Valid"
+	reasonInvalidExpression = "InvalidExpression"
+)
+
+// clientSecretKey is the data key of the Secrets holding OAuth client secrets.
+const clientSecretKey = "clientSecret"
+
+// reference is a reference to a Secret or a ConfigMap an ACP depends on.
+type reference struct {
+	kind      string
+	namespace string
+	name      string
+
+	// keys lists the data keys the object must hold.
+	keys []string
+	// validate optionally validates the data of the object.
+	validate func(data map[string][]byte) error
+}
+
+func (r reference) String() string {
+	return fmt.Sprintf("%s %s/%s", r.kind, r.namespace, r.name)
+}
+
+// references returns the Secrets and ConfigMaps the given ACP depends on, read the same way the auth server does.
+func references(spec hubv1alpha1.AccessControlPolicySpec) ([]reference, error) {
+	refs, err := policyReferences(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := spec.IdentityToken; tok != nil {
+		if tok.Secret == nil || tok.Secret.Name == "" || tok.Secret.Namespace == "" {
+			return nil, errors.New("identity token Secret must have a name and a namespace")
+		}
+
+		refs = append(refs, reference{
+			kind:      "Secret",
+			namespace: tok.Secret.Namespace,
+			name:      tok.Secret.Name,
+			keys:      []string{idtoken.SigningKeySecretKey},
+			validate: func(data map[string][]byte) error {
+				_, err := idtoken.ParseKey(data[idtoken.SigningKeySecretKey])
+				return err
+			},
+		})
+	}
+
+	return refs, nil
+}
+
+// policyReferences returns the Secrets and ConfigMaps the authentication method of the given ACP depends on.
+func policyReferences(spec hubv1alpha1.AccessControlPolicySpec) ([]reference, error) {
+	clientSecret := func(policyType string, ref *corev1.SecretReference) ([]reference, error) {
+		if ref == nil || ref.Name == "" || ref.Namespace == "" {
+			return nil, fmt.Errorf("%s Secret must have a name and a namespace", policyType)
+		}
+
+		return []reference{{kind: "Secret", namespace: ref.Namespace, name: ref.Name, keys: []string{clientSecretKey}}}, nil
+	}
+
+	switch {
+	case spec.OIDC != nil:
+		return clientSecret("OIDC", spec.OIDC.Secret)
+
+	case spec.OIDCGoogle != nil:
+		return clientSecret("OIDC", spec.OIDCGoogle.Secret)
+
+	case spec.GitHub != nil:
+		return clientSecret("GitHub", spec.GitHub.Secret)
+
+	case spec.OAuthIntrospection != nil:
+		return clientSecret("OAuth introspection", spec.OAuthIntrospection.Secret)
+
+	case spec.JWT != nil && spec.JWT.KeysSecret != nil:
+		ref := spec.JWT.KeysSecret
+		if ref.Name == "" || ref.Namespace == "" {
+			return nil, errors.New("JWT keys Secret must have a name and a namespace")
+		}
+
+		return []reference{{
+			kind:      "Secret",
+			namespace: ref.Namespace,
+			name:      ref.Name,
+			validate: func(data map[string][]byte) error {
+				_, err := jwt.KeysFromSecretData(data)
+				return err
+			},
+		}}, nil
+
+	case spec.BasicAuth != nil && spec.BasicAuth.LDAP != nil:
+		ref := spec.BasicAuth.LDAP.BindSecret
+		if ref == nil || ref.Name == "" || ref.Namespace == "" {
+			return nil, errors.New("LDAP bind Secret must have a name and a namespace")
+		}
+
+		return []reference{{
+			kind:      "Secret",
+			namespace: ref.Namespace,
+			name:      ref.Name,
+			keys:      []string{basicauth.LDAPBindDNSecretKey, basicauth.LDAPBindPasswordSecretKey},
+		}}, nil
+
+	case spec.BasicAuth != nil && spec.BasicAuth.UsersSecret != nil:
+		ref := spec.BasicAuth.UsersSecret
+		if ref.Name == "" || ref.Namespace == "" {
+			return nil, errors.New("users Secret must have a name and a namespace")
+		}
+
+		return []reference{{
+			kind:      "Secret",
+			namespace: ref.Namespace,
+			name:      ref.Name,
+			validate: func(data map[string][]byte) error {
+				_, err := basicauth.UsersFromSecretData(data)
+				return err
+			},
+		}}, nil
+
+	case spec.MTLS != nil && spec.MTLS.CA != nil:
+		key := spec.MTLS.CA.Key
+		if key == "" {
+			key = mtls.DefaultCAKey
+		}
+
+		switch {
+		case spec.MTLS.CA.Secret != nil:
+			return []reference{{kind: "Secret", namespace: spec.MTLS.CA.Secret.Namespace, name: spec.MTLS.CA.Secret.Name, keys: []string{key}}}, nil
+		case spec.MTLS.CA.ConfigMap != nil:
+			return []reference{{kind: "ConfigMap", namespace: spec.MTLS.CA.ConfigMap.Namespace, name: spec.MTLS.CA.ConfigMap.Name, keys: []string{key}}}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// checkReferences returns the SecretResolved condition of the given ACP, or nil if it doesn't depend on any Secret
+// or ConfigMap.
+func (c *Controller) checkReferences(ctx context.Context, policy *hubv1alpha1.AccessControlPolicy) *metav1.Condition {
+	refs, err := references(policy.Spec)
+	if err != nil {
+		return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, reasonInvalidSecret, err.Error())
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	for _, ref := range refs {
+		data, err := c.getData(ctx, ref)
+		if err != nil {
+			if kerror.IsNotFound(err) {
+				return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, reasonNotFound, ref.String()+" not found")
+			}
+
+			return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionUnknown, reasonLookupFailed,
+				fmt.Sprintf("get %s: %v", ref, err))
+		}
+
+		for _, key := range ref.keys {
+			if len(data[key]) == 0 {
+				return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, reasonInvalidSecret,
+					fmt.Sprintf("%s has no %q key", ref, key))
+			}
+		}
+
+		if ref.validate != nil {
+			if err = ref.validate(data); err != nil {
+				return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, reasonInvalidSecret,
+					fmt.Sprintf("invalid %s: %v", ref, err))
+			}
+		}
+	}
+
+	return newCondition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionTrue, reasonResolved, "")
+}
+
+// getData returns the data of the given referenced object.
+func (c *Controller) getData(ctx context.Context, ref reference) (map[string][]byte, error) {
+	if ref.kind == "ConfigMap" {
+		configMap, err := c.kubeClientSet.CoreV1().ConfigMaps(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+
+		return data, nil
+	}
+
+	secret, err := c.kubeClientSet.CoreV1().Secrets(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Data, nil
+}
+
+// checkKeys returns the KeysReachable condition of the given ACP, or nil if it isn't a JWT ACP.
+func (c *Controller) checkKeys(ctx context.Context, cfg *acp.Config) *metav1.Condition {
+	if cfg.JWT == nil || (cfg.JWT.JWKsFile == "" && cfg.JWT.JWKsURL == "") {
+		return nil
+	}
+
+	if err := c.prober.ProbeKeys(ctx, cfg.JWT); err != nil {
+		return newCondition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionFalse, reasonUnreachable, err.Error())
+	}
+
+	return newCondition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionTrue, reasonReachable, "")
+}
+
+// checkDiscovery returns the DiscoveryReachable condition of the given ACP, or nil if it isn't an OIDC ACP.
+func (c *Controller) checkDiscovery(ctx context.Context, cfg *acp.Config) *metav1.Condition {
+	oidcCfg := cfg.OIDC
+	if oidcCfg == nil && cfg.OIDCGoogle != nil {
+		oidcCfg = &cfg.OIDCGoogle.Config
+	}
+
+	if oidcCfg == nil {
+		return nil
+	}
+
+	if err := c.prober.ProbeDiscovery(ctx, oidcCfg); err != nil {
+		return newCondition(hubv1alpha1.ConditionDiscoveryReachable, metav1.ConditionFalse, reasonUnreachable, err.Error())
+	}
+
+	return newCondition(hubv1alpha1.ConditionDiscoveryReachable, metav1.ConditionTrue, reasonReachable, "")
+}
+
+// checkExpressions returns the ExpressionValid condition of the given ACP, or nil if it has neither a claims
+// expression nor rules.
+func checkExpressions(cfg *acp.Config) *metav1.Condition {
+	claims := claimsExpression(cfg)
+	if claims == "" && len(cfg.Rules) == 0 {
+		return nil
+	}
+
+	if claims != "" {
+		if _, err := expr.Parse(claims); err != nil {
+			return newCondition(hubv1alpha1.ConditionExpressionValid, metav1.ConditionFalse, reasonInvalidExpression,
+				fmt.Sprintf("invalid claims expression: %v", err))
+		}
+	}
+
+	if err := acp.ValidateRules(cfg); err != nil {
+		return newCondition(hubv1alpha1.ConditionExpressionValid, metav1.ConditionFalse, reasonInvalidExpression,
+			fmt.Sprintf("invalid rules: %v", err))
+	}
+
+	return newCondition(hubv1alpha1.ConditionExpressionValid, metav1.ConditionTrue, reasonValid, "")
+}
+
+// claimsExpression returns the claims expression of the given ACP, if any.
+func claimsExpression(cfg *acp.Config) string {
+	switch {
+	case cfg.JWT != nil:
+		return cfg.JWT.Claims
+	case cfg.OIDC != nil:
+		return cfg.OIDC.Claims
+	case cfg.OIDCGoogle != nil:
+		return cfg.OIDCGoogle.Claims
+	case cfg.OAuthIntrospection != nil:
+		return cfg.OAuthIntrospection.Claims
+	case cfg.MTLS != nil:
+		return cfg.MTLS.Claims
+	default:
+		return ""
+	}
+}
+
+// readyCondition returns the Ready condition summarizing the given conditions.
+func readyCondition(conditions []metav1.Condition) metav1.Condition {
+	for _, condition := range conditions {
+		if condition.Status != metav1.ConditionTrue {
+			message := condition.Type
+			if condition.Message != "" {
+				message += ": " + condition.Message
+			}
+
+			return *newCondition(hubv1alpha1.ConditionReady, condition.Status, condition.Reason, message)
+		}
+	}
+
+	return *newCondition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, reasonReady, "")
+}
+
+func newCondition(typ string, status metav1.ConditionStatus, reason, message string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    typ,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
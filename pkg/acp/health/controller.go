@@ -0,0 +1,263 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package health maintains the status conditions of ACPs, reflecting whether the auth server is able to enforce them.
+package health
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/reviewer"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
+	hublistersv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/listers/hub/v1alpha1"
+	"github.com/traefik/hub-agent-kubernetes/pkg/kubevers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+)
+
+// probeTimeout is the maximum duration of the probes of the dependencies of an ACP.
+const probeTimeout = 10 * time.Second
+
+// managedConditions lists the types of the conditions maintained by the Controller, Ready excepted.
+var managedConditions = []string{
+	hubv1alpha1.ConditionSecretResolved,
+	hubv1alpha1.ConditionKeysReachable,
+	hubv1alpha1.ConditionDiscoveryReachable,
+	hubv1alpha1.ConditionExpressionValid,
+}
+
+// Prober probes the external services ACPs depend on.
+type Prober interface {
+	ProbeKeys(ctx context.Context, cfg *jwt.Config) error
+	ProbeDiscovery(ctx context.Context, cfg *oidc.Config) error
+}
+
+// HTTPProber probes the external services ACPs depend on over HTTP, the same way the auth server does.
+type HTTPProber struct{}
+
+// ProbeKeys makes sure the JWKs of the given JWT configuration can be loaded.
+func (HTTPProber) ProbeKeys(ctx context.Context, cfg *jwt.Config) error {
+	return cfg.CheckKeys(ctx)
+}
+
+// ProbeDiscovery makes sure the discovery document of the provider of the given OIDC configuration can be fetched.
+func (HTTPProber) ProbeDiscovery(ctx context.Context, cfg *oidc.Config) error {
+	return cfg.CheckDiscovery(ctx)
+}
+
+// Controller periodically probes the dependencies of ACPs and reports their health in their status conditions, along
+// with the number of ingresses referencing them. Condition transitions are recorded as Kubernetes events.
+type Controller struct {
+	interval time.Duration
+
+	policies      hublistersv1alpha1.AccessControlPolicyLister
+	hubClientSet  hubclientset.Interface
+	kubeClientSet clientset.Interface
+	kubeInformer  informers.SharedInformerFactory
+	recorder      record.EventRecorder
+	prober        Prober
+
+	supportsNetV1Ingresses bool
+
+	now func() time.Time
+}
+
+// NewController returns a new Controller.
+func NewController(interval time.Duration, policies hublistersv1alpha1.AccessControlPolicyLister, hubClientSet hubclientset.Interface,
+	kubeClientSet clientset.Interface, kubeInformer informers.SharedInformerFactory, kubeVersion string, recorder record.EventRecorder, prober Prober,
+) *Controller {
+	return &Controller{
+		interval:               interval,
+		policies:               policies,
+		hubClientSet:           hubClientSet,
+		kubeClientSet:          kubeClientSet,
+		kubeInformer:           kubeInformer,
+		recorder:               recorder,
+		prober:                 prober,
+		supportsNetV1Ingresses: kubevers.SupportsNetV1Ingresses(kubeVersion),
+		now:                    time.Now,
+	}
+}
+
+// Run runs the Controller control loop.
+func (c *Controller) Run(ctx context.Context) {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping ACP health controller")
+			return
+		case <-t.C:
+			if err := c.sync(ctx); err != nil {
+				log.Error().Err(err).Msg("Unable to update ACP statuses")
+			}
+		}
+	}
+}
+
+func (c *Controller) sync(ctx context.Context) error {
+	policies, err := c.policies.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("list ACPs: %w", err)
+	}
+
+	ingressCounts, err := c.countIngresses()
+	if err != nil {
+		return fmt.Errorf("count ingresses: %w", err)
+	}
+
+	for _, policy := range policies {
+		if err = c.syncPolicy(ctx, policy, ingressCounts[policy.Name]); err != nil {
+			log.Error().Err(err).Str("acp_name", policy.Name).Msg("Unable to update ACP status")
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) syncPolicy(ctx context.Context, policy *hubv1alpha1.AccessControlPolicy, ingressCount int) error {
+	conditions := c.conditions(ctx, policy)
+
+	updated := policy.DeepCopy()
+	updated.Status.IngressCount = ingressCount
+
+	// Conditions which don't apply anymore, for instance because the type of the ACP changed, are removed.
+	for _, typ := range managedConditions {
+		if meta.FindStatusCondition(conditions, typ) == nil && meta.FindStatusCondition(updated.Status.Conditions, typ) != nil {
+			meta.RemoveStatusCondition(&updated.Status.Conditions, typ)
+		}
+	}
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&updated.Status.Conditions, condition)
+	}
+
+	if reflect.DeepEqual(policy.Status, updated.Status) {
+		return nil
+	}
+
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := c.hubClientSet.HubV1alpha1().AccessControlPolicies().Update(ctxUpdate, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update ACP: %w", err)
+	}
+
+	c.recordTransitions(policy, updated)
+
+	return nil
+}
+
+// conditions returns the conditions of the given ACP, Ready being the last one.
+func (c *Controller) conditions(ctx context.Context, policy *hubv1alpha1.AccessControlPolicy) []metav1.Condition {
+	ctxProbe, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	cfg := acp.ConfigFromPolicy(policy)
+
+	var conditions []metav1.Condition
+	for _, condition := range []*metav1.Condition{
+		c.checkReferences(ctxProbe, policy),
+		c.checkKeys(ctxProbe, cfg),
+		c.checkDiscovery(ctxProbe, cfg),
+		checkExpressions(cfg),
+	} {
+		if condition == nil {
+			continue
+		}
+
+		condition.ObservedGeneration = policy.Generation
+		condition.LastTransitionTime = metav1.NewTime(c.now())
+		conditions = append(conditions, *condition)
+	}
+
+	ready := readyCondition(conditions)
+	ready.ObservedGeneration = policy.Generation
+	ready.LastTransitionTime = metav1.NewTime(c.now())
+
+	return append(conditions, ready)
+}
+
+// recordTransitions records an event for each condition of the given ACP whose status changed.
+func (c *Controller) recordTransitions(previous, current *hubv1alpha1.AccessControlPolicy) {
+	for _, condition := range current.Status.Conditions {
+		old := meta.FindStatusCondition(previous.Status.Conditions, condition.Type)
+		if old != nil && old.Status == condition.Status {
+			continue
+		}
+
+		eventType := corev1.EventTypeNormal
+		if condition.Status != metav1.ConditionTrue {
+			eventType = corev1.EventTypeWarning
+		}
+
+		message := fmt.Sprintf("%s is %s", condition.Type, condition.Status)
+		if condition.Message != "" {
+			message += ": " + condition.Message
+		}
+
+		c.recorder.Event(current, eventType, condition.Reason, message)
+	}
+}
+
+// countIngresses returns the number of ingresses referencing each ACP, by ACP name.
+func (c *Controller) countIngresses() (map[string]int, error) {
+	counts := make(map[string]int)
+
+	if !c.supportsNetV1Ingresses {
+		// As the minimum supported version is 1.14, we don't need to support the extension group.
+		ingresses, err := c.kubeInformer.Networking().V1beta1().Ingresses().Lister().List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("list legacy ingresses: %w", err)
+		}
+
+		for _, ing := range ingresses {
+			if polName := ing.Annotations[reviewer.AnnotationHubAuth]; polName != "" {
+				counts[polName]++
+			}
+		}
+
+		return counts, nil
+	}
+
+	ingresses, err := c.kubeInformer.Networking().V1().Ingresses().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list ingresses: %w", err)
+	}
+
+	for _, ing := range ingresses {
+		if polName := ing.Annotations[reviewer.AnnotationHubAuth]; polName != "" {
+			counts[polName]++
+		}
+	}
+
+	return counts, nil
+}
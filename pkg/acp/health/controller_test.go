@@ -0,0 +1,305 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/reviewer"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	hubkubemock "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned/fake"
+	hubinformer "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubemock "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+type proberMock struct {
+	keysErr      error
+	discoveryErr error
+}
+
+func (p proberMock) ProbeKeys(context.Context, *jwt.Config) error {
+	return p.keysErr
+}
+
+func (p proberMock) ProbeDiscovery(context.Context, *oidc.Config) error {
+	return p.discoveryErr
+}
+
+func TestController_sync(t *testing.T) {
+	now := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	before := metav1.NewTime(now.Add(-time.Hour))
+
+	condition := func(typ string, status metav1.ConditionStatus, reason, message string, ltt metav1.Time) metav1.Condition {
+		return metav1.Condition{
+			Type:               typ,
+			Status:             status,
+			ObservedGeneration: 1,
+			LastTransitionTime: ltt,
+			Reason:             reason,
+			Message:            message,
+		}
+	}
+
+	kubeObjects := []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: "default"},
+			// Password is "test".
+			Data: map[string][]byte{"users": []byte("test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "oidc", Namespace: "default"},
+			Data:       map[string][]byte{"other": []byte("value")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "oidc-client", Namespace: "default"},
+			Data:       map[string][]byte{"clientSecret": []byte("secret")},
+		},
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "default",
+				Annotations: map[string]string{reviewer.AnnotationHubAuth: "acp"},
+			},
+		},
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "api",
+				Namespace:   "apps",
+				Annotations: map[string]string{reviewer.AnnotationHubAuth: "acp"},
+			},
+		},
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "default"},
+		},
+	}
+
+	tests := []struct {
+		desc           string
+		spec           hubv1alpha1.AccessControlPolicySpec
+		status         hubv1alpha1.AccessControlPolicyStatus
+		prober         proberMock
+		wantConditions []metav1.Condition
+		wantEvents     []string
+	}{
+		{
+			desc: "healthy basic auth policy",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				BasicAuth: &hubv1alpha1.AccessControlPolicyBasicAuth{
+					UsersSecret: &corev1.SecretReference{Name: "users", Namespace: "default"},
+				},
+			},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionTrue, "Resolved", "", now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, "Ready", "", now),
+			},
+			wantEvents: []string{
+				"Normal Resolved SecretResolved is True",
+				"Normal Ready Ready is True",
+			},
+		},
+		{
+			desc: "OIDC policy with an invalid Secret",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Issuer: "https://idp.example.com",
+					Secret: &corev1.SecretReference{Name: "oidc", Namespace: "default"},
+				},
+			},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, "InvalidSecret", `Secret default/oidc has no "clientSecret" key`, now),
+				condition(hubv1alpha1.ConditionDiscoveryReachable, metav1.ConditionTrue, "Reachable", "", now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionFalse, "InvalidSecret", `SecretResolved: Secret default/oidc has no "clientSecret" key`, now),
+			},
+			wantEvents: []string{
+				`Warning InvalidSecret SecretResolved is False: Secret default/oidc has no "clientSecret" key`,
+				"Normal Reachable DiscoveryReachable is True",
+				`Warning InvalidSecret Ready is False: SecretResolved: Secret default/oidc has no "clientSecret" key`,
+			},
+		},
+		{
+			desc: "OIDC policy with a missing Secret and an unreachable provider",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Issuer: "https://idp.example.com",
+					Secret: &corev1.SecretReference{Name: "missing", Namespace: "default"},
+				},
+			},
+			prober: proberMock{discoveryErr: errors.New("connection refused")},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, "NotFound", "Secret default/missing not found", now),
+				condition(hubv1alpha1.ConditionDiscoveryReachable, metav1.ConditionFalse, "Unreachable", "connection refused", now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionFalse, "NotFound", "SecretResolved: Secret default/missing not found", now),
+			},
+			wantEvents: []string{
+				"Warning NotFound SecretResolved is False: Secret default/missing not found",
+				"Warning Unreachable DiscoveryReachable is False: connection refused",
+				"Warning NotFound Ready is False: SecretResolved: Secret default/missing not found",
+			},
+		},
+		{
+			desc: "OIDC policy with a state cookie and no session",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlOIDC{
+					Issuer:      "https://idp.example.com",
+					Secret:      &corev1.SecretReference{Name: "oidc-client", Namespace: "default"},
+					StateCookie: &hubv1alpha1.StateCookie{Path: "/"},
+				},
+			},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionTrue, "Resolved", "", now),
+				condition(hubv1alpha1.ConditionDiscoveryReachable, metav1.ConditionTrue, "Reachable", "", now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, "Ready", "", now),
+			},
+			wantEvents: []string{
+				"Normal Resolved SecretResolved is True",
+				"Normal Reachable DiscoveryReachable is True",
+				"Normal Ready Ready is True",
+			},
+		},
+		{
+			desc: "JWT policy with unreachable keys and an invalid claims expression",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					JWKsURL: "https://idp.example.com/jwks.json",
+					Claims:  "Equals(`grp`)",
+				},
+			},
+			status: hubv1alpha1.AccessControlPolicyStatus{
+				Conditions: []metav1.Condition{
+					condition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionTrue, "Reachable", "", before),
+					condition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, "Ready", "", before),
+				},
+			},
+			prober: proberMock{keysErr: errors.New("unexpected status code")},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionFalse, "Unreachable", "unexpected status code", now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionFalse, "Unreachable", "KeysReachable: unexpected status code", now),
+				condition(hubv1alpha1.ConditionExpressionValid, metav1.ConditionFalse, "InvalidExpression",
+					"invalid claims expression: unable to parse expression: Equals: expected 2 arguments, got 1", now),
+			},
+			wantEvents: []string{
+				"Warning Unreachable KeysReachable is False: unexpected status code",
+				"Warning Unreachable Ready is False: KeysReachable: unexpected status code",
+				"Warning InvalidExpression ExpressionValid is False: invalid claims expression: unable to parse expression: Equals: expected 2 arguments, got 1",
+			},
+		},
+		{
+			desc: "JWT policy with an invalid keys Secret",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					KeysSecret: &corev1.SecretReference{Name: "oidc", Namespace: "default"},
+				},
+			},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionSecretResolved, metav1.ConditionFalse, "InvalidSecret",
+					`invalid Secret default/oidc: at least one of the "signingSecret", "publicKey", "jwks" or "decryptionKey" keys is required`, now),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionFalse, "InvalidSecret",
+					`SecretResolved: invalid Secret default/oidc: at least one of the "signingSecret", "publicKey", "jwks" or "decryptionKey" keys is required`, now),
+			},
+			wantEvents: []string{
+				`Warning InvalidSecret SecretResolved is False: invalid Secret default/oidc: at least one of the "signingSecret", "publicKey", "jwks" or "decryptionKey" keys is required`,
+				`Warning InvalidSecret Ready is False: SecretResolved: invalid Secret default/oidc: at least one of the "signingSecret", "publicKey", "jwks" or "decryptionKey" keys is required`,
+			},
+		},
+		{
+			desc: "unchanged conditions",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{
+					JWKsURL: "https://idp.example.com/jwks.json",
+				},
+			},
+			status: hubv1alpha1.AccessControlPolicyStatus{
+				Conditions: []metav1.Condition{
+					condition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionTrue, "Reachable", "", before),
+					condition(hubv1alpha1.ConditionExpressionValid, metav1.ConditionTrue, "Valid", "", before),
+					condition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, "Ready", "", before),
+				},
+			},
+			wantConditions: []metav1.Condition{
+				condition(hubv1alpha1.ConditionKeysReachable, metav1.ConditionTrue, "Reachable", "", before),
+				condition(hubv1alpha1.ConditionReady, metav1.ConditionTrue, "Ready", "", before),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "acp", Generation: 1},
+				Spec:       test.spec,
+				Status:     test.status,
+			}
+
+			kubeClientSet := kubemock.NewSimpleClientset(kubeObjects...)
+			hubClientSet := hubkubemock.NewSimpleClientset(policy)
+
+			kubeInformer := informers.NewSharedInformerFactory(kubeClientSet, 0)
+			hubInformer := hubinformer.NewSharedInformerFactory(hubClientSet, 0)
+
+			policies := hubInformer.Hub().V1alpha1().AccessControlPolicies().Lister()
+			kubeInformer.Networking().V1().Ingresses().Informer()
+
+			hubInformer.Start(ctx.Done())
+			hubInformer.WaitForCacheSync(ctx.Done())
+
+			kubeInformer.Start(ctx.Done())
+			kubeInformer.WaitForCacheSync(ctx.Done())
+
+			recorder := record.NewFakeRecorder(10)
+
+			c := NewController(time.Minute, policies, hubClientSet, kubeClientSet, kubeInformer, "v1.22", recorder, test.prober)
+			c.now = func() time.Time { return now.Time }
+
+			err := c.sync(ctx)
+			require.NoError(t, err)
+
+			got, err := hubClientSet.HubV1alpha1().AccessControlPolicies().Get(ctx, "acp", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, 2, got.Status.IngressCount)
+			assert.Equal(t, test.wantConditions, got.Status.Conditions)
+
+			close(recorder.Events)
+			var gotEvents []string
+			for event := range recorder.Events {
+				gotEvents = append(gotEvents, event)
+			}
+			assert.Equal(t, test.wantEvents, gotEvents)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package identity allows ACP handlers authenticating requests to report the identity they verified to the handler
+// which invoked them.
+package identity
+
+import (
+	"context"
+	"sync"
+)
+
+type recorderKey struct{}
+
+// Recorder records the claims verified by an ACP handler.
+type Recorder struct {
+	mu     sync.Mutex
+	claims map[string]interface{}
+}
+
+// WithRecorder returns a copy of the given context holding a new Recorder.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	rec := &Recorder{}
+
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// Claims returns the recorded claims, or nil if no claims were recorded.
+func (r *Recorder) Claims() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.claims
+}
+
+// RecordClaims records the given verified claims in the Recorder held by the given context, if any.
+func RecordClaims(ctx context.Context, claims map[string]interface{}) {
+	rec, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.claims = claims
+}
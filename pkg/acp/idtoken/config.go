@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package idtoken mints short-lived signed JWTs describing the principal authenticated by an ACP, forwarded to
+// upstreams so that they can verify them with standard libraries, using the keys published by the auth server.
+package idtoken
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SigningKeySecretKey is the key of the Secret holding the PEM encoded private key signing identity tokens.
+const SigningKeySecretKey = "signingKey"
+
+// Defaults of identity tokens.
+const (
+	DefaultHeader = "X-Hub-Identity"
+	DefaultTTL    = time.Minute
+)
+
+// MaxTTL is the longest lifetime of identity tokens. Keys which are no longer used are still published for MaxTTL, so
+// that the tokens they signed can be verified until they expire.
+const MaxTTL = time.Hour
+
+// Claims set by the auth server, which can't be copied from the authenticated identity.
+const (
+	ClaimPolicy = "policy"
+	ClaimMethod = "auth_method"
+)
+
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", ClaimPolicy, ClaimMethod}
+
+// Config configures the identity tokens minted for the requests allowed by an ACP.
+type Config struct {
+	// Secret references the Secret holding the PEM encoded RSA, ECDSA or Ed25519 private key signing tokens, under the
+	// `signingKey` key.
+	Secret *corev1.SecretReference `json:"secret,omitempty"`
+	// Header is the header tokens are forwarded in. Defaults to X-Hub-Identity.
+	Header string `json:"header,omitempty"`
+	// Audience is the audience of tokens, if any.
+	Audience string `json:"audience,omitempty"`
+	// Claims lists the claims of the authenticated identity copied in tokens.
+	Claims []string `json:"claims,omitempty"`
+	// TTL is the lifetime of tokens, in seconds. Defaults to 60.
+	TTL int `json:"ttl,omitempty"`
+
+	// SigningKey is the PEM encoded private key read from Secret.
+	SigningKey []byte `json:"-"`
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.Secret == nil || cfg.Secret.Name == "" {
+		return errors.New("missing signing key secret")
+	}
+
+	if cfg.TTL < 0 || time.Duration(cfg.TTL)*time.Second > MaxTTL {
+		return fmt.Errorf("ttl must be between 0 and %d seconds", int(MaxTTL.Seconds()))
+	}
+
+	for _, name := range cfg.Claims {
+		for _, reserved := range reservedClaims {
+			if name == reserved {
+				return fmt.Errorf("claim %q is reserved", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// HeaderName returns the header tokens are forwarded in.
+func (cfg *Config) HeaderName() string {
+	if cfg.Header == "" {
+		return DefaultHeader
+	}
+
+	return http.CanonicalHeaderKey(cfg.Header)
+}
+
+func (cfg *Config) ttl() time.Duration {
+	if cfg.TTL == 0 {
+		return DefaultTTL
+	}
+
+	return time.Duration(cfg.TTL) * time.Second
+}
@@ -0,0 +1,251 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package idtoken
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Paths of the documents published by the Issuer, relative to its URL.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/.well-known/jwks.json"
+)
+
+type publishedKey struct {
+	key *Key
+	// retiredAt is the time the key stopped being used, zero if it's still used.
+	retiredAt time.Time
+}
+
+// Issuer mints identity tokens and publishes the keys verifying them along with an OpenID Connect discovery document.
+type Issuer struct {
+	url string
+
+	keysMu sync.RWMutex
+	keys   map[string]publishedKey
+
+	now func() time.Time
+}
+
+// NewIssuer returns a new Issuer identified by the given URL, which is the base URL the auth server can be reached on
+// by upstreams.
+func NewIssuer(url string) *Issuer {
+	return &Issuer{
+		url:  strings.TrimSuffix(url, "/"),
+		keys: make(map[string]publishedKey),
+		now:  time.Now,
+	}
+}
+
+// SetKeys sets the keys currently signing identity tokens. Keys which are no longer used are published for MaxTTL.
+func (i *Issuer) SetKeys(keys []*Key) {
+	i.keysMu.Lock()
+	defer i.keysMu.Unlock()
+
+	now := i.now()
+
+	used := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		used[key.id] = struct{}{}
+		i.keys[key.id] = publishedKey{key: key}
+	}
+
+	for id, published := range i.keys {
+		if _, ok := used[id]; ok {
+			continue
+		}
+
+		switch {
+		case published.retiredAt.IsZero():
+			published.retiredAt = now
+			i.keys[id] = published
+		case now.Sub(published.retiredAt) >= MaxTTL:
+			delete(i.keys, id)
+		}
+	}
+}
+
+// Handler returns the given ACP handler, forwarding an identity token signed by the given key for each request it
+// allows.
+func (i *Issuer) Handler(handler http.Handler, cfg *Config, key *Key, policy, typ string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx, rec := identity.WithRecorder(req.Context())
+
+		trw := &tokenResponseWriter{
+			ResponseWriter: rw,
+			mint: func() (string, error) {
+				return i.mint(cfg, key, rec.Claims(), policy, typ)
+			},
+			header: cfg.HeaderName(),
+			policy: policy,
+		}
+
+		handler.ServeHTTP(trw, req.WithContext(ctx))
+
+		// Claims are reported to the handler which invoked this one, if any.
+		if claims := rec.Claims(); claims != nil {
+			identity.RecordClaims(req.Context(), claims)
+		}
+	})
+}
+
+func (i *Issuer) mint(cfg *Config, key *Key, identityClaims map[string]interface{}, policy, typ string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("generate token ID: %w", err)
+	}
+
+	now := i.now()
+
+	claims := jwt.MapClaims{
+		"iss":       i.url,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       now.Add(cfg.ttl()).Unix(),
+		"jti":       hex.EncodeToString(jti),
+		ClaimPolicy: policy,
+		ClaimMethod: typ,
+	}
+
+	if cfg.Audience != "" {
+		claims["aud"] = cfg.Audience
+	}
+
+	if sub, ok := identityClaims["sub"].(string); ok && sub != "" {
+		claims["sub"] = sub
+	}
+
+	for _, name := range cfg.Claims {
+		if value, ok := identityClaims[name]; ok {
+			claims[name] = value
+		}
+	}
+
+	return key.sign(claims)
+}
+
+// ServeJWKS serves the keys verifying identity tokens.
+func (i *Issuer) ServeJWKS(rw http.ResponseWriter, _ *http.Request) {
+	i.keysMu.RLock()
+	keySet := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(i.keys))}
+	for _, published := range i.keys {
+		keySet.Keys = append(keySet.Keys, published.key.JWK())
+	}
+	i.keysMu.RUnlock()
+
+	sort.Slice(keySet.Keys, func(a, b int) bool {
+		return keySet.Keys[a].KeyID < keySet.Keys[b].KeyID
+	})
+
+	writeJSON(rw, keySet)
+}
+
+// ServeDiscovery serves the OpenID Connect discovery document of the Issuer.
+func (i *Issuer) ServeDiscovery(rw http.ResponseWriter, _ *http.Request) {
+	i.keysMu.RLock()
+	var algs []string
+	for _, published := range i.keys {
+		if alg := published.key.method.Alg(); !contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	i.keysMu.RUnlock()
+
+	sort.Strings(algs)
+
+	writeJSON(rw, map[string]interface{}{
+		"issuer":                                i.url,
+		"jwks_uri":                              i.url + JWKSPath,
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": algs,
+		"claims_supported":                      append([]string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}, ClaimPolicy, ClaimMethod),
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "public, max-age=60")
+
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Unable to write identity token issuer document")
+	}
+}
+
+// tokenResponseWriter is an http.ResponseWriter adding an identity token to allowing responses.
+type tokenResponseWriter struct {
+	http.ResponseWriter
+
+	mint   func() (string, error)
+	header string
+	policy string
+
+	wroteHeader bool
+}
+
+func (w *tokenResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	if code == http.StatusOK {
+		token, err := w.mint()
+		if err != nil {
+			log.Error().Err(err).Str("acp_name", w.policy).Msg("Unable to mint identity token")
+			code = http.StatusInternalServerError
+		} else {
+			w.Header().Set(w.header, token)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *tokenResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,241 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package idtoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestIssuer_Handler(t *testing.T) {
+	key := generateKey(t)
+	now := time.Now().Truncate(time.Second)
+
+	issuer := NewIssuer("http://auth.hub.svc/")
+	issuer.now = func() time.Time { return now }
+	issuer.SetKeys([]*Key{key})
+
+	allow := func(claims map[string]interface{}) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if claims != nil {
+				identity.RecordClaims(req.Context(), claims)
+			}
+			rw.WriteHeader(http.StatusOK)
+		})
+	}
+
+	tests := []struct {
+		desc       string
+		cfg        Config
+		handler    http.Handler
+		wantCode   int
+		wantHeader string
+		wantClaims jwt.MapClaims
+	}{
+		{
+			desc: "allowed request",
+			cfg: Config{
+				Audience: "billing",
+				Claims:   []string{"groups", "missing"},
+			},
+			handler: allow(map[string]interface{}{
+				"sub":    "jane",
+				"email":  "jane@example.com",
+				"groups": []interface{}{"admin"},
+			}),
+			wantCode:   http.StatusOK,
+			wantHeader: "X-Hub-Identity",
+			wantClaims: jwt.MapClaims{
+				"iss":         "http://auth.hub.svc",
+				"sub":         "jane",
+				"aud":         "billing",
+				"iat":         float64(now.Unix()),
+				"nbf":         float64(now.Unix()),
+				"exp":         float64(now.Add(time.Minute).Unix()),
+				"policy":      "my-policy",
+				"auth_method": "JWT",
+				"groups":      []interface{}{"admin"},
+			},
+		},
+		{
+			desc:       "allowed request without identity",
+			cfg:        Config{Header: "x-identity", TTL: 30},
+			handler:    allow(nil),
+			wantCode:   http.StatusOK,
+			wantHeader: "X-Identity",
+			wantClaims: jwt.MapClaims{
+				"iss":         "http://auth.hub.svc",
+				"iat":         float64(now.Unix()),
+				"nbf":         float64(now.Unix()),
+				"exp":         float64(now.Add(30 * time.Second).Unix()),
+				"policy":      "my-policy",
+				"auth_method": "JWT",
+			},
+		},
+		{
+			desc: "denied request",
+			handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusUnauthorized)
+			}),
+			wantCode:   http.StatusUnauthorized,
+			wantHeader: "X-Hub-Identity",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := issuer.Handler(test.handler, &test.cfg, key, "my-policy", "JWT")
+
+			ctx, rec := identity.WithRecorder(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+			req := httptest.NewRequest(http.MethodGet, "http://auth.hub.svc/my-policy", nil).WithContext(ctx)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+
+			raw := rw.Header().Get(test.wantHeader)
+			if test.wantClaims == nil {
+				assert.Empty(t, raw)
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			_, err := jwt.ParseWithClaims(raw, claims, jwksKeyFunc(t, issuer))
+			require.NoError(t, err)
+
+			assert.NotEmpty(t, claims["jti"])
+			delete(claims, "jti")
+			assert.Equal(t, test.wantClaims, claims)
+
+			// Claims are reported to the invoking handler.
+			if sub, ok := test.wantClaims["sub"]; ok {
+				assert.Equal(t, sub, rec.Claims()["sub"])
+			}
+		})
+	}
+}
+
+func TestIssuer_SetKeys(t *testing.T) {
+	key1 := generateKey(t)
+	key2 := generateKey(t)
+
+	now := time.Now()
+
+	issuer := NewIssuer("http://auth.hub.svc")
+	issuer.now = func() time.Time { return now }
+
+	issuer.SetKeys([]*Key{key1})
+	assert.Equal(t, []string{key1.ID()}, publishedKeyIDs(t, issuer))
+
+	// Retired keys are still published so that the tokens they signed can be verified.
+	issuer.SetKeys([]*Key{key2})
+	assert.ElementsMatch(t, []string{key1.ID(), key2.ID()}, publishedKeyIDs(t, issuer))
+
+	now = now.Add(MaxTTL)
+	issuer.SetKeys([]*Key{key2})
+	assert.Equal(t, []string{key2.ID()}, publishedKeyIDs(t, issuer))
+}
+
+func TestIssuer_ServeDiscovery(t *testing.T) {
+	issuer := NewIssuer("http://auth.hub.svc")
+	issuer.SetKeys([]*Key{generateKey(t)})
+
+	rw := httptest.NewRecorder()
+	issuer.ServeDiscovery(rw, httptest.NewRequest(http.MethodGet, DiscoveryPath, nil))
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&doc))
+
+	assert.Equal(t, "http://auth.hub.svc", doc["issuer"])
+	assert.Equal(t, "http://auth.hub.svc/.well-known/jwks.json", doc["jwks_uri"])
+	assert.Equal(t, []interface{}{"ES256"}, doc["id_token_signing_alg_values_supported"])
+}
+
+func generateKey(t *testing.T) *Key {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(private)
+	require.NoError(t, err)
+
+	key, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	return key
+}
+
+func fetchKeySet(t *testing.T, issuer *Issuer) jose.JSONWebKeySet {
+	t.Helper()
+
+	rw := httptest.NewRecorder()
+	issuer.ServeJWKS(rw, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	var keySet jose.JSONWebKeySet
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&keySet))
+
+	return keySet
+}
+
+func publishedKeyIDs(t *testing.T, issuer *Issuer) []string {
+	t.Helper()
+
+	var ids []string
+	for _, key := range fetchKeySet(t, issuer).Keys {
+		ids = append(ids, key.KeyID)
+	}
+
+	return ids
+}
+
+func jwksKeyFunc(t *testing.T, issuer *Issuer) jwt.Keyfunc {
+	t.Helper()
+
+	keySet := fetchKeySet(t, issuer)
+
+	return func(tok *jwt.Token) (interface{}, error) {
+		keys := keySet.Key(tok.Header["kid"].(string))
+		require.Len(t, keys, 1)
+
+		return keys[0].Key, nil
+	}
+}
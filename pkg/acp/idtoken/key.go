@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package idtoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Key is a private key signing identity tokens.
+type Key struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// ParseKey parses the given PEM encoded RSA, ECDSA or Ed25519 private key, in PKCS #1, SEC 1 or PKCS #8 form. Its ID is
+// the RFC 7638 thumbprint of its public key.
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	private, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := signingMethod(private)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbprint, err := (&jose.JSONWebKey{Key: private.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("compute thumbprint: %w", err)
+	}
+
+	return &Key{
+		id:      base64.RawURLEncoding.EncodeToString(thumbprint),
+		method:  method,
+		private: private,
+	}, nil
+}
+
+// ID returns the ID of the key.
+func (k *Key) ID() string {
+	return k.id
+}
+
+// JWK returns the public key, as a JSON Web Key.
+func (k *Key) JWK() jose.JSONWebKey {
+	return jose.JSONWebKey{
+		Key:       k.private.Public(),
+		KeyID:     k.id,
+		Algorithm: k.method.Alg(),
+		Use:       "sig",
+	}
+}
+
+func (k *Key) sign(claims jwt.MapClaims) (string, error) {
+	tok := jwt.NewWithClaims(k.method, claims)
+	tok.Header["kid"] = k.id
+
+	return tok.SignedString(k.private)
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("unsupported private key format")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+func signingMethod(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package idtoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc    string
+		data    []byte
+		wantAlg string
+		wantErr string
+	}{
+		{
+			desc:    "PKCS #1 RSA key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			wantAlg: "RS256",
+		},
+		{
+			desc:    "SEC 1 ECDSA key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+			wantAlg: "ES384",
+		},
+		{
+			desc:    "PKCS #8 Ed25519 key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}),
+			wantAlg: "EdDSA",
+		},
+		{
+			desc:    "not PEM encoded",
+			data:    []byte("signing-key"),
+			wantErr: "no PEM data found",
+		},
+		{
+			desc:    "not a private key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")}),
+			wantErr: "unsupported private key format",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			key, err := ParseKey(test.data)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			jwk := key.JWK()
+			assert.Equal(t, test.wantAlg, jwk.Algorithm)
+			assert.Equal(t, key.ID(), jwk.KeyID)
+			assert.True(t, jwk.IsPublic())
+			assert.True(t, jwk.Valid())
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ipallowlist
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Config configures an IP allow list ACP handler.
+type Config struct {
+	// SourceRange lists the allowed IPs and CIDRs.
+	SourceRange []string `json:"sourceRange,omitempty"`
+	// DeniedSourceRange lists the denied IPs and CIDRs. It takes precedence over SourceRange.
+	DeniedSourceRange []string `json:"deniedSourceRange,omitempty"`
+	// Depth is the number of trusted proxies between the client and the ingress controller. The client IP is the
+	// X-Forwarded-For entry at this position, counted from the right starting at 0.
+	Depth int `json:"depth,omitempty"`
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	if len(cfg.SourceRange) == 0 {
+		return errors.New("missing source range")
+	}
+
+	if cfg.Depth < 0 {
+		return errors.New("depth must be positive")
+	}
+
+	if _, err := parseRanges(cfg.SourceRange); err != nil {
+		return fmt.Errorf("invalid source range: %w", err)
+	}
+
+	if _, err := parseRanges(cfg.DeniedSourceRange); err != nil {
+		return fmt.Errorf("invalid denied source range: %w", err)
+	}
+
+	return nil
+}
+
+// Handler is an IP allow list ACP Handler.
+type Handler struct {
+	name string
+
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+	depth   int
+}
+
+// NewHandler returns a new IP allow list ACP Handler.
+func NewHandler(cfg *Config, polName string) (*Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	// Ranges have been validated already.
+	allowed, _ := parseRanges(cfg.SourceRange)
+	denied, _ := parseRanges(cfg.DeniedSourceRange)
+
+	return &Handler{
+		name:    polName,
+		allowed: allowed,
+		denied:  denied,
+		depth:   cfg.Depth,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "IPAllowList").Str("handler_name", h.name).Logger()
+
+	ip, err := h.clientIP(req)
+	if err != nil {
+		l.Debug().Err(err).Msg("Unable to determine client IP")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if contains(h.denied, ip) {
+		l.Debug().Str("client_ip", ip.String()).Msg("Client IP is denied")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !contains(h.allowed, ip) {
+		l.Debug().Str("client_ip", ip.String()).Msg("Client IP is not allowed")
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// clientIP returns the IP of the client. Traefik ForwardAuth and Nginx auth requests carry the client IP as the last
+// X-Forwarded-For entry, proxies in front of them adding entries to the left. X-Real-Ip is used when X-Forwarded-For
+// is missing, in which case no proxy can be skipped.
+func (h *Handler) clientIP(req *http.Request) (netip.Addr, error) {
+	var entries []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if len(entries) == 0 {
+		if realIP := strings.TrimSpace(req.Header.Get("X-Real-Ip")); realIP != "" && h.depth == 0 {
+			entries = []string{realIP}
+		}
+	}
+
+	if h.depth >= len(entries) {
+		return netip.Addr{}, fmt.Errorf("not enough forwarded IPs for depth %d", h.depth)
+	}
+
+	ip, err := netip.ParseAddr(entries[len(entries)-1-h.depth])
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("parse client IP: %w", err)
+	}
+
+	return ip.Unmap(), nil
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRanges parses the given IPs and CIDRs.
+func parseRanges(ranges []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+
+		if !strings.Contains(r, "/") {
+			addr, err := netip.ParseAddr(r)
+			if err != nil {
+				return nil, fmt.Errorf("parse IP %q: %w", r, err)
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("parse CIDR %q: %w", r, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ipallowlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler_validation(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc:    "missing source range",
+			cfg:     Config{},
+			wantErr: "validate configuration: missing source range",
+		},
+		{
+			desc:    "negative depth",
+			cfg:     Config{SourceRange: []string{"10.0.0.0/8"}, Depth: -1},
+			wantErr: "validate configuration: depth must be positive",
+		},
+		{
+			desc:    "invalid CIDR",
+			cfg:     Config{SourceRange: []string{"10.0.0.0/33"}},
+			wantErr: `validate configuration: invalid source range: parse CIDR "10.0.0.0/33": netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+		},
+		{
+			desc:    "invalid denied IP",
+			cfg:     Config{SourceRange: []string{"10.0.0.0/8"}, DeniedSourceRange: []string{"foo"}},
+			wantErr: `validate configuration: invalid denied source range: parse IP "foo": ParseAddr("foo"): unable to parse IP`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewHandler(&test.cfg, "acp")
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc     string
+		cfg      Config
+		headers  map[string][]string
+		wantCode int
+	}{
+		{
+			desc:     "no forwarded IP",
+			cfg:      Config{SourceRange: []string{"0.0.0.0/0"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "allowed IPv4",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"10.1.2.3"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "allowed single IP",
+			cfg:      Config{SourceRange: []string{"192.168.1.10"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"192.168.1.10"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "not allowed IPv4",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"192.168.1.10"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "allowed IPv6",
+			cfg:      Config{SourceRange: []string{"2001:db8::/32"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"2001:db8::1"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "IPv4-mapped IPv6",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"::ffff:10.1.2.3"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc: "denied range takes precedence",
+			cfg: Config{
+				SourceRange:       []string{"10.0.0.0/8"},
+				DeniedSourceRange: []string{"10.1.0.0/16"},
+			},
+			headers:  map[string][]string{"X-Forwarded-For": {"10.1.2.3"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "spoofed entries are ignored without depth",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"10.1.2.3, 203.0.113.1"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "depth skips trusted proxies",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}, Depth: 2},
+			headers:  map[string][]string{"X-Forwarded-For": {"203.0.113.1", "10.0.0.1, 10.0.0.2"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "depth selects the client IP",
+			cfg:      Config{SourceRange: []string{"203.0.113.0/24"}, Depth: 2},
+			headers:  map[string][]string{"X-Forwarded-For": {"10.1.2.3, 203.0.113.1", "172.16.0.1, 172.16.0.2"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "depth greater than forwarded IPs",
+			cfg:      Config{SourceRange: []string{"0.0.0.0/0"}, Depth: 2},
+			headers:  map[string][]string{"X-Forwarded-For": {"10.1.2.3, 172.16.0.1"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "X-Real-Ip fallback",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}},
+			headers:  map[string][]string{"X-Real-Ip": {"10.1.2.3"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "X-Real-Ip is ignored with depth",
+			cfg:      Config{SourceRange: []string{"10.0.0.0/8"}, Depth: 1},
+			headers:  map[string][]string{"X-Real-Ip": {"10.1.2.3"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "malformed IP",
+			cfg:      Config{SourceRange: []string{"0.0.0.0/0"}},
+			headers:  map[string][]string{"X-Forwarded-For": {"foo"}},
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&test.cfg, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			for name, values := range test.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+		})
+	}
+}
@@ -19,9 +19,13 @@ package expr
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vulcand/predicate"
 )
@@ -29,8 +33,42 @@ import (
 // Predicate represents a function that can be evaluated to get the result of an expression.
 type Predicate func(a map[string]interface{}) bool
 
+// Call is the evaluation of a function called by an expression.
+type Call struct {
+	// Expr is the function call, for instance `Equals("grp", "admin")`.
+	Expr string
+	// Result is the result of the function.
+	Result bool
+}
+
 // Parse returns a predicate from the given expression.
 func Parse(expr string) (Predicate, error) {
+	return parse(expr, nil)
+}
+
+// Trace evaluates the given expression against the given claims. It returns the result of the expression along with the
+// functions it called, in order. Operators short-circuit, so functions which didn't need to be evaluated are omitted.
+func Trace(expr string, claims map[string]interface{}) (bool, []Call, error) {
+	var calls []Call
+	pred, err := parse(expr, func(call string, pred Predicate) Predicate {
+		return func(v map[string]interface{}) bool {
+			res := pred(v)
+			calls = append(calls, Call{Expr: call, Result: res})
+
+			return res
+		}
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return pred(claims), calls, nil
+}
+
+// tracer wraps the predicate returned by the given function call.
+type tracer func(call string, pred Predicate) Predicate
+
+func parse(expr string, trace tracer) (Predicate, error) {
 	parser, err := predicate.NewParser(predicate.Def{
 		Operators: predicate.Operators{
 			AND: andFunc,
@@ -38,11 +76,22 @@ func Parse(expr string) (Predicate, error) {
 			NOT: notFunc,
 		},
 		Functions: map[string]interface{}{
-			"Equals":        equals,
-			"Prefix":        prefix,
-			"Contains":      contains,
-			"SplitContains": splitContains,
-			"Ohubf":         ohubf,
+			"Equals":        function("Equals", trace, equals),
+			"Prefix":        function("Prefix", trace, prefix),
+			"Contains":      function("Contains", trace, contains),
+			"SplitContains": function("SplitContains", trace, splitContains),
+			"Ohubf":         function("Ohubf", trace, ohubf),
+			"Gt":            function("Gt", trace, compare(func(a, b float64) bool { return a > b })),
+			"Gte":           function("Gte", trace, compare(func(a, b float64) bool { return a >= b })),
+			"Lt":            function("Lt", trace, compare(func(a, b float64) bool { return a < b })),
+			"Lte":           function("Lte", trace, compare(func(a, b float64) bool { return a <= b })),
+			"Matches":       function("Matches", trace, matchesRegexp),
+			"In":            function("In", trace, in),
+			"AnyOf":         function("AnyOf", trace, anyOf),
+			"AllOf":         function("AllOf", trace, allOf),
+			"Exists":        function("Exists", trace, exists),
+			"Before":        function("Before", trace, before),
+			"After":         function("After", trace, after),
 		},
 	})
 	if err != nil {
@@ -54,7 +103,72 @@ func Parse(expr string) (Predicate, error) {
 		return nil, fmt.Errorf("unable to parse expression: %w", err)
 	}
 
-	return p.(Predicate), nil
+	pred, ok := p.(Predicate)
+	if !ok {
+		return nil, errors.New("unable to parse expression: expression is not a predicate")
+	}
+
+	return pred, nil
+}
+
+// function wraps the given expression function so that calls with an invalid number or type of arguments are
+// reported with a clear error, instead of a panic recovered by the parser.
+func function(name string, trace tracer, fn interface{}) func(args ...interface{}) (Predicate, error) {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+
+	return func(args ...interface{}) (Predicate, error) {
+		minArgs := fnType.NumIn()
+		if fnType.IsVariadic() {
+			minArgs--
+		}
+
+		if len(args) < minArgs || (!fnType.IsVariadic() && len(args) > minArgs) {
+			return nil, fmt.Errorf("%s: expected %d arguments, got %d", name, minArgs, len(args))
+		}
+
+		values := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if fnType.IsVariadic() && i >= minArgs {
+				paramType = fnType.In(minArgs).Elem()
+			} else {
+				paramType = fnType.In(i)
+			}
+
+			argValue := reflect.ValueOf(arg)
+			if !argValue.IsValid() || !argValue.Type().AssignableTo(paramType) {
+				return nil, fmt.Errorf("%s: argument %d must be a %s, got %T", name, i+1, paramType, arg)
+			}
+
+			values[i] = argValue
+		}
+
+		ret := fnValue.Call(values)
+		if len(ret) == 2 && !ret[1].IsNil() {
+			return nil, fmt.Errorf("%s: %w", name, ret[1].Interface().(error))
+		}
+
+		pred := ret[0].Interface().(Predicate)
+		if trace != nil {
+			pred = trace(formatCall(name, args), pred)
+		}
+
+		return pred, nil
+	}
+}
+
+func formatCall(name string, args []interface{}) string {
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			strArgs[i] = strconv.Quote(s)
+			continue
+		}
+		strArgs[i] = fmt.Sprint(arg)
+	}
+
+	return name + "(" + strings.Join(strArgs, ", ") + ")"
 }
 
 func andFunc(a, b Predicate) Predicate {
@@ -179,6 +293,202 @@ func ohubf(claimName string, expected ...string) Predicate {
 	}
 }
 
+// compare returns a function building predicates comparing a numeric claim to the expected value with the given
+// comparison function.
+func compare(cmp func(claim, expected float64) bool) func(claimName string, expected interface{}) (Predicate, error) {
+	return func(claimName string, expected interface{}) (Predicate, error) {
+		exp, ok := toFloat(expected)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a number", expected)
+		}
+
+		return func(claims map[string]interface{}) bool {
+			claim, ok := resolve(claimName, claims)
+			if !ok {
+				return false
+			}
+
+			val, ok := toFloat(claim)
+			if !ok {
+				return false
+			}
+
+			return cmp(val, exp)
+		}, nil
+	}
+}
+
+func matchesRegexp(claimName, expr string) (Predicate, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	return func(claims map[string]interface{}) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		str, ok := claim.(string)
+		if !ok {
+			return false
+		}
+
+		return re.MatchString(str)
+	}, nil
+}
+
+func in(claimName string, expected ...string) (Predicate, error) {
+	if len(expected) == 0 {
+		return nil, errors.New("at least one value is required")
+	}
+
+	return func(claims map[string]interface{}) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		for _, exp := range expected {
+			if matches(claim, exp) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+func anyOf(claimName string, expected ...string) (Predicate, error) {
+	if len(expected) == 0 {
+		return nil, errors.New("at least one value is required")
+	}
+
+	return func(claims map[string]interface{}) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		values := toSlice(claim)
+
+		for _, exp := range expected {
+			if containsValue(values, exp) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+func allOf(claimName string, expected ...string) (Predicate, error) {
+	if len(expected) == 0 {
+		return nil, errors.New("at least one value is required")
+	}
+
+	return func(claims map[string]interface{}) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		values := toSlice(claim)
+
+		for _, exp := range expected {
+			if !containsValue(values, exp) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+func exists(claimName string) Predicate {
+	return func(claims map[string]interface{}) bool {
+		_, ok := resolve(claimName, claims)
+		return ok
+	}
+}
+
+// before returns a predicate checking that the time claim, in seconds since the epoch, is before now shifted by the
+// given duration (e.g. `-1h`, `30m`).
+func before(claimName, offset string) (Predicate, error) {
+	return compareTime(claimName, offset, func(claim, ref time.Time) bool { return claim.Before(ref) })
+}
+
+// after returns a predicate checking that the time claim, in seconds since the epoch, is after now shifted by the
+// given duration (e.g. `-1h`, `30m`).
+func after(claimName, offset string) (Predicate, error) {
+	return compareTime(claimName, offset, func(claim, ref time.Time) bool { return claim.After(ref) })
+}
+
+func compareTime(claimName, offset string, cmp func(claim, ref time.Time) bool) (Predicate, error) {
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration: %w", err)
+	}
+
+	return func(claims map[string]interface{}) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		sec, ok := toFloat(claim)
+		if !ok {
+			return false
+		}
+
+		claimTime := time.Unix(0, int64(sec*float64(time.Second)))
+
+		return cmp(claimTime, time.Now().Add(d))
+	}, nil
+}
+
+func containsValue(values []interface{}, expected string) bool {
+	for _, v := range values {
+		if matches(v, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toSlice returns the values of the given array claim. Other claims are considered as single value arrays.
+func toSlice(v interface{}) []interface{} {
+	if values, ok := v.([]interface{}); ok {
+		return values
+	}
+
+	return []interface{}{v}
+}
+
+// toFloat converts the given number, or string holding a number, to a float.
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+
+	case int:
+		return float64(val), true
+
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+
+	default:
+		return 0, false
+	}
+}
+
 func matches(v interface{}, expected string) bool {
 	switch val := v.(type) {
 	case string:
@@ -20,7 +20,9 @@ package expr
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -213,6 +215,96 @@ func TestValidateCustomClaims(t *testing.T) {
 			expr:   "Equals(``, `bruce`)",
 			want:   false,
 		},
+		{
+			desc:   "numeric comparisons",
+			claims: `{"acr":2,"level":"3","score":1.5}`,
+			expr:   "Gte(`acr`, 2) && Gt(`level`, 2) && Lt(`score`, 2) && Lte(`score`, 1.5) && Gt(`acr`, `1`)",
+			want:   true,
+		},
+		{
+			desc:   "numeric comparison (false)",
+			claims: `{"acr":1}`,
+			expr:   "Gte(`acr`, 2)",
+			want:   false,
+		},
+		{
+			desc:   "numeric comparison on a non numeric claim",
+			claims: `{"acr":"high"}`,
+			expr:   "Gte(`acr`, 2)",
+			want:   false,
+		},
+		{
+			desc:   "matches regular expression",
+			claims: `{"email":"jane@example.com"}`,
+			expr:   "Matches(`email`, `^[a-z]+@example\\.com$`)",
+			want:   true,
+		},
+		{
+			desc:   "matches regular expression (false)",
+			claims: `{"email":"jane@example.org"}`,
+			expr:   "Matches(`email`, `^[a-z]+@example\\.com$`)",
+			want:   false,
+		},
+		{
+			desc:   "in expression",
+			claims: `{"tier":"gold","verified":true}`,
+			expr:   "In(`tier`, `silver`, `gold`) && In(`verified`, `true`)",
+			want:   true,
+		},
+		{
+			desc:   "anyOf expression",
+			claims: `{"groups":["dev","ops"]}`,
+			expr:   "AnyOf(`groups`, `admin`, `ops`)",
+			want:   true,
+		},
+		{
+			desc:   "anyOf expression (false)",
+			claims: `{"groups":["dev","ops"]}`,
+			expr:   "AnyOf(`groups`, `admin`, `sales`)",
+			want:   false,
+		},
+		{
+			desc:   "anyOf expression with a single value claim",
+			claims: `{"groups":"ops"}`,
+			expr:   "AnyOf(`groups`, `admin`, `ops`)",
+			want:   true,
+		},
+		{
+			desc:   "allOf expression",
+			claims: `{"groups":["dev","ops","admin"]}`,
+			expr:   "AllOf(`groups`, `admin`, `ops`)",
+			want:   true,
+		},
+		{
+			desc:   "allOf expression (false)",
+			claims: `{"groups":["dev","ops"]}`,
+			expr:   "AllOf(`groups`, `admin`, `ops`)",
+			want:   false,
+		},
+		{
+			desc:   "exists expression",
+			claims: `{"user":{"email":"jane@example.com"}}`,
+			expr:   "Exists(`user.email`) && !Exists(`user.phone`)",
+			want:   true,
+		},
+		{
+			desc:   "after expression",
+			claims: fmt.Sprintf(`{"auth_time":%d}`, time.Now().Add(-30*time.Minute).Unix()),
+			expr:   "After(`auth_time`, `-1h`)",
+			want:   true,
+		},
+		{
+			desc:   "after expression (false)",
+			claims: fmt.Sprintf(`{"auth_time":%d}`, time.Now().Add(-2*time.Hour).Unix()),
+			expr:   "After(`auth_time`, `-1h`)",
+			want:   false,
+		},
+		{
+			desc:   "before expression",
+			claims: fmt.Sprintf(`{"exp":%d}`, time.Now().Add(10*time.Minute).Unix()),
+			expr:   "Before(`exp`, `15m`) && !Before(`exp`, `5m`)",
+			want:   true,
+		},
 	}
 	for _, test := range tests {
 		test := test
@@ -232,3 +324,103 @@ func TestValidateCustomClaims(t *testing.T) {
 		})
 	}
 }
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		expr    string
+		wantErr string
+	}{
+		{
+			desc:    "unknown function",
+			expr:    "Foo(`grp`, `admin`)",
+			wantErr: "unable to parse expression: unsupported function: Foo",
+		},
+		{
+			desc:    "missing argument",
+			expr:    "Equals(`grp`)",
+			wantErr: "unable to parse expression: Equals: expected 2 arguments, got 1",
+		},
+		{
+			desc:    "too many arguments",
+			expr:    "Exists(`grp`, `admin`)",
+			wantErr: "unable to parse expression: Exists: expected 1 arguments, got 2",
+		},
+		{
+			desc:    "invalid argument type",
+			expr:    "Equals(`grp`, 2)",
+			wantErr: "unable to parse expression: Equals: argument 2 must be a string, got int",
+		},
+		{
+			desc:    "invalid number",
+			expr:    "Gt(`acr`, `two`)",
+			wantErr: "unable to parse expression: Gt: two is not a number",
+		},
+		{
+			desc:    "invalid regular expression",
+			expr:    "Matches(`email`, `[a-z`)",
+			wantErr: "unable to parse expression: Matches: invalid regular expression: error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			desc:    "missing values",
+			expr:    "AnyOf(`groups`)",
+			wantErr: "unable to parse expression: AnyOf: at least one value is required",
+		},
+		{
+			desc:    "invalid duration",
+			expr:    "After(`auth_time`, `1 hour`)",
+			wantErr: `unable to parse expression: After: invalid duration: time: unknown unit " hour" in duration "1 hour"`,
+		},
+		{
+			desc:    "not a predicate",
+			expr:    "`admin`",
+			wantErr: "unable to parse expression: expression is not a predicate",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(test.expr)
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	claims := map[string]interface{}{
+		"grp":   "dev",
+		"scope": "deploy",
+	}
+
+	got, calls, err := Trace("Equals(`scope`, `deploy`) && (Equals(`grp`, `admin`) || In(`grp`, `ops`, `sre`))", claims)
+	require.NoError(t, err)
+
+	assert.False(t, got)
+	assert.Equal(t, []Call{
+		{Expr: `Equals("scope", "deploy")`, Result: true},
+		{Expr: `Equals("grp", "admin")`, Result: false},
+		{Expr: `In("grp", "ops", "sre")`, Result: false},
+	}, calls)
+
+	_, _, err = Trace("Equals(`grp`)", claims)
+	assert.Error(t, err)
+}
+
+func TestTrace_numericArguments(t *testing.T) {
+	claims := map[string]interface{}{
+		"age":   17,
+		"score": 1.2,
+	}
+
+	got, calls, err := Trace("Gte(`age`, 18) || Gt(`score`, 1.5)", claims)
+	require.NoError(t, err)
+
+	assert.False(t, got)
+	assert.Equal(t, []Call{
+		{Expr: `Gte("age", 18)`, Result: false},
+		{Expr: `Gt("score", 1.5)`, Result: false},
+	}, calls)
+}
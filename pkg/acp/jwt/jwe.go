@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwtreq "github.com/golang-jwt/jwt/v4/request"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// jweKeyAlgorithms lists the supported key management algorithms of JWEs. RSA1_5 is left out as it is vulnerable to
+// padding oracle attacks.
+var jweKeyAlgorithms = []string{
+	string(jose.RSA_OAEP),
+	string(jose.RSA_OAEP_256),
+	string(jose.ECDH_ES),
+	string(jose.ECDH_ES_A128KW),
+	string(jose.ECDH_ES_A192KW),
+	string(jose.ECDH_ES_A256KW),
+}
+
+var errDecryption = errors.New("unable to decrypt JWE")
+
+// jweExtractor extracts JWTs from HTTP requests, decrypting the ones encrypted as JWEs in the compact serialization.
+// Decrypted JWEs must hold a signed JWT, which is then verified as any other JWT.
+type jweExtractor struct {
+	extractor     jwtreq.Extractor
+	decryptionKey interface{}
+}
+
+// ExtractToken extracts a JWT from an HTTP request, decrypting it if needed.
+func (e jweExtractor) ExtractToken(req *http.Request) (string, error) {
+	rawJWT, err := e.extractor.ExtractToken(req)
+	if err != nil {
+		return "", err
+	}
+
+	// JWEs in the compact serialization have five parts, while JWSs have three.
+	if strings.Count(rawJWT, ".") != 4 {
+		return rawJWT, nil
+	}
+
+	return e.decrypt(rawJWT)
+}
+
+// DecryptToken returns the JWT held by the given token, decrypting it with the decryption key of the configuration if
+// it's a JWE. Other tokens are returned unchanged.
+func (cfg *Config) DecryptToken(rawToken string) (string, error) {
+	if strings.Count(rawToken, ".") != 4 {
+		return rawToken, nil
+	}
+
+	var e jweExtractor
+	if len(cfg.SecretKeys.DecryptionKey) > 0 {
+		key, err := parseDecryptionKey(cfg.SecretKeys.DecryptionKey)
+		if err != nil {
+			return "", fmt.Errorf("parse decryption key: %w", err)
+		}
+		e.decryptionKey = key
+	}
+
+	return e.decrypt(rawToken)
+}
+
+func (e jweExtractor) decrypt(rawJWE string) (string, error) {
+	if e.decryptionKey == nil {
+		return "", fmt.Errorf("%w: no decryption key configured", errDecryption)
+	}
+
+	jwe, err := jose.ParseEncrypted(rawJWE)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errDecryption, err)
+	}
+
+	if !contains(jweKeyAlgorithms, jwe.Header.Algorithm) {
+		return "", fmt.Errorf("%w: unsupported key management algorithm %q", errDecryption, jwe.Header.Algorithm)
+	}
+
+	payload, err := jwe.Decrypt(e.decryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errDecryption, err)
+	}
+
+	return string(payload), nil
+}
+
+// parseDecryptionKey parses the given PEM-encoded RSA or ECDSA private key, in the PKCS #1, SEC 1 or PKCS #8 format.
+func parseDecryptionKey(key []byte) (interface{}, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("empty or ill-formatted private key")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS #1 private key: %w", err)
+		}
+		return privKey, nil
+
+	case "EC PRIVATE KEY":
+		privKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse SEC 1 private key: %w", err)
+		}
+		return privKey, nil
+
+	case "PRIVATE KEY":
+		privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS #8 private key: %w", err)
+		}
+
+		switch privKey.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+			return privKey, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", privKey)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestServeHTTP_jwe(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER})
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"grp": "admin"}).SignedString([]byte("bibi"))
+	require.NoError(t, err)
+
+	encrypt := func(alg jose.KeyAlgorithm, key interface{}, payload string) string {
+		enc, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: key},
+			(&jose.EncrypterOptions{}).WithContentType("JWT"))
+		require.NoError(t, err)
+
+		obj, err := enc.Encrypt([]byte(payload))
+		require.NoError(t, err)
+
+		token, err := obj.CompactSerialize()
+		require.NoError(t, err)
+
+		return token
+	}
+
+	tests := []struct {
+		desc          string
+		decryptionKey []byte
+		token         string
+
+		wantStatusCode int
+		wantHeader     http.Header
+	}{
+		{
+			desc:           "JWE encrypted with RSA-OAEP-256",
+			decryptionKey:  rsaPEM,
+			token:          encrypt(jose.RSA_OAEP_256, &rsaKey.PublicKey, signed),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "JWE encrypted with ECDH-ES+A256KW",
+			decryptionKey:  ecPEM,
+			token:          encrypt(jose.ECDH_ES_A256KW, &ecKey.PublicKey, signed),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "signed JWT along a decryption key",
+			decryptionKey:  rsaPEM,
+			token:          signed,
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "JWE without decryption key",
+			token:          encrypt(jose.RSA_OAEP_256, &rsaKey.PublicKey, signed),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "JWE encrypted for another key",
+			decryptionKey:  ecPEM,
+			token:          encrypt(jose.ECDH_ES, &newECDSAKey(t).PublicKey, signed),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "JWE encrypted with RSA1_5",
+			decryptionKey:  rsaPEM,
+			token:          encrypt(jose.RSA1_5, &rsaKey.PublicKey, signed),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "JWE holding an unsigned JWT",
+			decryptionKey:  rsaPEM,
+			token:          encrypt(jose.RSA_OAEP_256, &rsaKey.PublicKey, "eyJhbGciOiJub25lIn0.eyJncnAiOiJhZG1pbiJ9."),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				SigningSecret:  "bibi",
+				ForwardHeaders: map[string]string{"Group": "grp"},
+				SecretKeys:     SecretKeys{DecryptionKey: test.decryptionKey},
+			}
+			middleware, err := NewHandler(cfg, "acp@my-ns")
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			middleware.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Code)
+			for k := range test.wantHeader {
+				assert.Equal(t, test.wantHeader[k], rec.Header()[k])
+			}
+		})
+	}
+}
+
+func TestParseDecryptionKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecDER, err := x509.MarshalECPrivateKey(newECDSAKey(t))
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc    string
+		key     []byte
+		wantErr bool
+	}{
+		{
+			desc: "PKCS #1 RSA key",
+			key:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		},
+		{
+			desc: "SEC 1 ECDSA key",
+			key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+		},
+		{
+			desc:    "public key",
+			key:     []byte(validPubKey),
+			wantErr: true,
+		},
+		{
+			desc:    "not PEM",
+			key:     []byte("secret"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseDecryptionKey(test.key)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return key
+}
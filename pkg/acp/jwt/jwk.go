@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/pquerna/cachecontrol"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
 	"github.com/traefik/hub-agent-kubernetes/pkg/version"
 	"gopkg.in/square/go-jose.v2"
 )
@@ -211,7 +212,9 @@ func (s *RemoteKeySet) updateKeySet(ctx context.Context) error {
 		s.updating = newInflight()
 
 		go func() {
+			start := time.Now()
 			keySet, expiry, err := fetchKeys(ctx, s.client, s.url)
+			authmetrics.ObserveJWKSFetch(time.Since(start), err)
 
 			s.mu.Lock()
 			defer s.mu.Unlock()
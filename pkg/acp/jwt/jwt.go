@@ -19,20 +19,24 @@ package jwt
 
 import (
 	"context"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/pem"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	jwtreq "github.com/golang-jwt/jwt/v4/request"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // Config configures a JWT ACP handler.
@@ -46,6 +50,91 @@ type Config struct {
 	ForwardHeaders             map[string]string
 	TokenQueryKey              string
 	Claims                     string
+
+	// Issuers lists the accepted `iss` claims. All issuers are accepted when empty.
+	Issuers []string
+	// Audiences lists the accepted audiences. When set, the `aud` claim must hold at least one of them.
+	Audiences []string
+	// Leeway is the clock skew, in seconds, tolerated when validating the `exp`, `nbf` and `iat` claims.
+	Leeway int
+	// MaxAge is the maximum age of tokens, in seconds, computed from their `iat` claim which is then required.
+	MaxAge int
+	// Algorithms lists the accepted signing algorithms. Defaults to the HMAC algorithms when a signing secret is set,
+	// and to the RSA and ECDSA algorithms when a public key or JWKs are set.
+	Algorithms []string
+
+	// KeysSecret references a Secret holding key material under the SigningSecretSecretKey, PublicKeySecretKey,
+	// JWKsSecretKey and DecryptionKeySecretKey keys.
+	KeysSecret *corev1.SecretReference
+	// SecretKeys is the key material read from KeysSecret. Its entries take precedence over the inline ones.
+	SecretKeys SecretKeys `json:"-"`
+
+	// Rules are the rules of the ACP, set from its configuration.
+	Rules []rules.Config `json:"-"`
+}
+
+var (
+	hmacAlgorithms       = []string{"HS256", "HS384", "HS512"}
+	asymmetricAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// Validate validates the token validation options of the configuration. Algorithms must be supported and have the keys
+// they require configured, which prevents accepting tokens signed with the `none` algorithm or confusing HMAC secrets
+// with public keys.
+func (cfg *Config) Validate() error {
+	if cfg.Leeway < 0 {
+		return errors.New("leeway must be positive")
+	}
+	if cfg.MaxAge < 0 {
+		return errors.New("max age must be positive")
+	}
+
+	for _, alg := range cfg.Algorithms {
+		switch {
+		case contains(hmacAlgorithms, alg):
+			if !cfg.hasSigningSecret() && cfg.KeysSecret == nil {
+				return fmt.Errorf("algorithm %s requires a signing secret", alg)
+			}
+
+		case contains(asymmetricAlgorithms, alg):
+			if !cfg.hasPublicKeys() && cfg.KeysSecret == nil {
+				return fmt.Errorf("algorithm %s requires a public key or JWKs", alg)
+			}
+
+		default:
+			return fmt.Errorf("unsupported algorithm %q", alg)
+		}
+	}
+
+	return nil
+}
+
+// algorithms returns the accepted signing algorithms.
+func (cfg *Config) algorithms() []string {
+	if len(cfg.Algorithms) > 0 {
+		return cfg.Algorithms
+	}
+
+	var algs []string
+	if cfg.hasSigningSecret() {
+		algs = append(algs, hmacAlgorithms...)
+	}
+	if cfg.hasPublicKeys() {
+		algs = append(algs, asymmetricAlgorithms...)
+	}
+
+	return algs
+}
+
+// hasSigningSecret returns whether a signing secret is configured, inline or in the keys Secret.
+func (cfg *Config) hasSigningSecret() bool {
+	return cfg.SigningSecret != "" || cfg.SecretKeys.SigningSecret != ""
+}
+
+// hasPublicKeys returns whether a public key or JWKs are configured, inline or in the keys Secret.
+func (cfg *Config) hasPublicKeys() bool {
+	return cfg.PublicKey != "" || cfg.SecretKeys.PublicKey != "" ||
+		cfg.JWKsFile != "" || len(cfg.SecretKeys.JWKs) > 0 || cfg.JWKsURL != ""
 }
 
 func (cfg *Config) keySet() (KeySet, error) {
@@ -53,6 +142,14 @@ func (cfg *Config) keySet() (KeySet, error) {
 		return nil, nil
 	}
 
+	if len(cfg.SecretKeys.JWKs) > 0 {
+		ks, err := NewContentKeySet(cfg.SecretKeys.JWKs)
+		if err != nil {
+			return nil, fmt.Errorf("new content key set from Secret: %w", err)
+		}
+		return ks, nil
+	}
+
 	if cfg.JWKsFile != "" {
 		if cfg.JWKsFile.IsPath() {
 			return NewFileKeySet(cfg.JWKsFile.String()), nil
@@ -72,12 +169,28 @@ func (cfg *Config) keySet() (KeySet, error) {
 	return nil, nil
 }
 
+// CheckKeys makes sure the keys configured by the given configuration can be loaded, the same way the handler does.
+// JWKs URLs relative to the issuer of tokens can't be checked ahead of time and are ignored.
+func (cfg *Config) CheckKeys(ctx context.Context) error {
+	ks, err := cfg.keySet()
+	if err != nil || ks == nil {
+		return err
+	}
+
+	if _, err = ks.Key(ctx, ""); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Handler is a JWT ACP Handler.
 type Handler struct {
 	name string
 
 	signingSecret string
 	pubKey        interface{}
+	decryptionKey interface{}
 	tokQryKey     string
 
 	// Either `keySet` or `dynKeySets` should be set at a time.
@@ -91,15 +204,28 @@ type Handler struct {
 	stripAuthorization bool
 	fwdHeaders         map[string]string
 
+	issuers    []string
+	audiences  []string
+	leeway     time.Duration
+	maxAge     time.Duration
+	algorithms []string
+
 	validateCustomClaims expr.Predicate
+	rules                rules.Rules
+
+	now func() time.Time
 }
 
 // NewHandler returns a new JWT ACP Handler.
 func NewHandler(cfg *Config, polName string) (*Handler, error) {
-	if cfg.PublicKey == "" && cfg.SigningSecret == "" && cfg.JWKsFile == "" && cfg.JWKsURL == "" {
+	if !cfg.hasSigningSecret() && !cfg.hasPublicKeys() {
 		return nil, errors.New("at least a signing secret, public key or a JWKs file or URL is required")
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	var (
 		pred expr.Predicate
 		err  error
@@ -120,17 +246,28 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		}
 		signingSecret = string(b)
 	}
+	if cfg.SecretKeys.SigningSecret != "" {
+		signingSecret = cfg.SecretKeys.SigningSecret
+	}
+
+	publicKey := cfg.PublicKey
+	if cfg.SecretKeys.PublicKey != "" {
+		publicKey = cfg.SecretKeys.PublicKey
+	}
 
 	var pubKey interface{}
-	if cfg.PublicKey != "" {
-		block, _ := pem.Decode([]byte(cfg.PublicKey))
-		if block == nil {
-			return nil, errors.New("empty or ill-formatted public key")
+	if publicKey != "" {
+		pubKey, err = parsePublicKey(publicKey)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		pubKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	var decryptionKey interface{}
+	if len(cfg.SecretKeys.DecryptionKey) > 0 {
+		decryptionKey, err = parseDecryptionKey(cfg.SecretKeys.DecryptionKey)
 		if err != nil {
-			return nil, fmt.Errorf("parse public key: %w", err)
+			return nil, fmt.Errorf("parse decryption key: %w", err)
 		}
 	}
 
@@ -144,45 +281,96 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		return nil, err
 	}
 
+	rs, err := rules.New(cfg.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("compile rules: %w", err)
+	}
+
 	return &Handler{
 		name:                 polName,
 		signingSecret:        signingSecret,
 		pubKey:               pubKey,
+		decryptionKey:        decryptionKey,
 		jwksURL:              cfg.JWKsURL,
 		keySet:               ks,
 		dynKeySets:           make(map[string]*RemoteKeySet),
 		stripAuthorization:   cfg.StripAuthorizationHeader,
 		fwdHeaders:           cfg.ForwardHeaders,
 		tokQryKey:            tokenQueryKey,
+		issuers:              cfg.Issuers,
+		audiences:            cfg.Audiences,
+		leeway:               time.Duration(cfg.Leeway) * time.Second,
+		maxAge:               time.Duration(cfg.MaxAge) * time.Second,
+		algorithms:           cfg.algorithms(),
 		validateCustomClaims: pred,
+		rules:                rs,
+		now:                  time.Now,
 	}, nil
 }
 
 func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	l := log.With().Str("handler_type", "JWT").Str("handler_name", h.name).Logger()
 
-	extractor := jwtExtractor{tokQryKey: h.tokQryKey}
-	p := &jwt.Parser{UseJSONNumber: true}
+	rule := h.rules.Match(req)
+	if rule.ServeAction(rw, req) {
+		return
+	}
+
+	extractor := jweExtractor{
+		extractor:     jwtExtractor{tokQryKey: h.tokQryKey},
+		decryptionKey: h.decryptionKey,
+	}
+	// Registered claims are validated once the signature is verified, tolerating the configured leeway.
+	p := &jwt.Parser{UseJSONNumber: true, SkipClaimsValidation: true}
 	tok, err := jwtreq.ParseFromRequest(req, extractor, h.keyFunc(req.Context()), jwtreq.WithParser(p))
 	if err != nil {
 		var jwtErr *jwt.ValidationError
-		if errors.As(err, &jwtErr) && jwtErr.Errors&jwt.ValidationErrorUnverifiable != 0 {
+		switch {
+		case errors.Is(err, errNoJWT):
+			l.Error().Err(err).Msg("Unable to parse JWT")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonMissingCredentials)
+		case errors.Is(err, errDecryption):
+			l.Error().Err(err).Msg("Unable to decrypt JWT")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonDecryptionFailed)
+		case errors.Is(err, errAlgorithmNotAllowed):
+			l.Error().Err(err).Msg("Unable to parse JWT")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonAlgorithmNotAllowed)
+		case errors.Is(err, errIssuerNotAllowed):
+			l.Error().Err(err).Msg("Unable to parse JWT")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonIssuerMismatch)
+		case errors.As(err, &jwtErr) && jwtErr.Errors&jwt.ValidationErrorUnverifiable != 0:
 			l.Error().Err(err).Msg("Unable to verify the signing key")
-		} else {
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonUnverifiableToken)
+		default:
 			l.Error().Err(err).Msg("Unable to parse JWT")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidToken)
 		}
 
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	if reason, err := h.validateRegisteredClaims(tok.Claims.(jwt.MapClaims)); err != nil {
+		l.Debug().Err(err).Msg("Invalid JWT")
+		authmetrics.SetReason(req.Context(), reason)
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	if h.validateCustomClaims != nil {
 		if !h.validateCustomClaims(tok.Claims.(jwt.MapClaims)) {
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
 			rw.WriteHeader(http.StatusForbidden)
 			return
 		}
 	}
 
+	if !rule.AllowClaims(tok.Claims.(jwt.MapClaims)) {
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	hdrs, err := expr.PluckClaims(h.fwdHeaders, tok.Claims.(jwt.MapClaims))
 	if err != nil {
 		l.Error().Err(err).Msg("Unable to set forwarded header")
@@ -200,12 +388,59 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		rw.Header().Add("Authorization", "")
 	}
 
+	identity.RecordClaims(req.Context(), tok.Claims.(jwt.MapClaims))
+
 	rw.WriteHeader(http.StatusOK)
 }
 
+// validateRegisteredClaims validates the time, issuer and audience claims of the given verified token. It returns the
+// reason of the denial when they are invalid.
+func (h *Handler) validateRegisteredClaims(claims jwt.MapClaims) (string, error) {
+	now := h.now()
+
+	if !claims.VerifyExpiresAt(now.Add(-h.leeway).Unix(), false) {
+		return authmetrics.ReasonTokenExpired, errors.New("token is expired")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(h.leeway).Unix(), false) {
+		return authmetrics.ReasonTokenNotYetValid, errors.New("token is not valid yet")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(h.leeway).Unix(), false) {
+		return authmetrics.ReasonTokenNotYetValid, errors.New("token is issued in the future")
+	}
+
+	if h.maxAge > 0 {
+		iat, ok := numericDate(claims["iat"])
+		if !ok {
+			return authmetrics.ReasonInvalidToken, errors.New("missing `iat` claim required to enforce the max age")
+		}
+
+		if now.Sub(iat) > h.maxAge+h.leeway {
+			return authmetrics.ReasonTokenTooOld, fmt.Errorf("token is older than %s", h.maxAge)
+		}
+	}
+
+	if len(h.issuers) > 0 {
+		if iss, _ := claims["iss"].(string); !contains(h.issuers, iss) {
+			return authmetrics.ReasonIssuerMismatch, fmt.Errorf("issuer %q is not allowed", iss)
+		}
+	}
+
+	if len(h.audiences) > 0 && !hasAudience(claims, h.audiences) {
+		return authmetrics.ReasonAudienceMismatch, errors.New("token is not intended for an allowed audience")
+	}
+
+	return "", nil
+}
+
 // keyFunc returns a function to find the correct key to validate its given JWT's signature.
 func (h *Handler) keyFunc(ctx context.Context) jwt.Keyfunc {
 	return func(tok *jwt.Token) (key interface{}, err error) {
+		if !contains(h.algorithms, tok.Method.Alg()) {
+			return nil, fmt.Errorf("%w: %s", errAlgorithmNotAllowed, tok.Method.Alg())
+		}
+
 		var prefix string
 		if len(tok.Method.Alg()) > 2 {
 			prefix = tok.Method.Alg()[:2]
@@ -214,7 +449,7 @@ func (h *Handler) keyFunc(ctx context.Context) jwt.Keyfunc {
 		kid, _ := tok.Header["kid"].(string)
 
 		switch prefix {
-		case "RS", "ES":
+		case "RS", "PS", "ES":
 			if kid != "" {
 				return h.resolveKey(ctx, tok, kid)
 			}
@@ -253,6 +488,11 @@ func (h *Handler) resolveKey(ctx context.Context, tok *jwt.Token, kid string) (k
 			return nil, errors.New("expected `iss` claim to be a string")
 		}
 
+		// Keys must not be fetched from issuers which aren't allowed.
+		if len(h.issuers) > 0 && !contains(h.issuers, c["iss"].(string)) {
+			return nil, fmt.Errorf("%w: %q", errIssuerNotAllowed, c["iss"])
+		}
+
 		ks, err = h.remoteKeySet(c["iss"].(string))
 		if err != nil {
 			return nil, err
@@ -302,6 +542,12 @@ func (h *Handler) remoteKeySet(iss string) (*RemoteKeySet, error) {
 	return rks, nil
 }
 
+var (
+	errNoJWT               = errors.New("no JWT found in request")
+	errAlgorithmNotAllowed = errors.New("signing algorithm not allowed")
+	errIssuerNotAllowed    = errors.New("issuer not allowed")
+)
+
 // jwtExtractor extracts JWTs from HTTP requests.
 type jwtExtractor struct {
 	tokQryKey string
@@ -316,8 +562,48 @@ func (j jwtExtractor) ExtractToken(req *http.Request) (string, error) {
 	}
 
 	if rawJWT == "" {
-		return "", errors.New("no JWT found in request")
+		return "", errNoJWT
 	}
 
 	return rawJWT, nil
 }
+
+// numericDate returns the time held by the given NumericDate claim.
+func numericDate(claim interface{}) (time.Time, bool) {
+	var seconds float64
+	switch v := claim.(type) {
+	case float64:
+		seconds = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	default:
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// hasAudience returns whether the `aud` claim of the given claims holds at least one of the given audiences.
+func hasAudience(claims jwt.MapClaims, audiences []string) bool {
+	for _, aud := range audiences {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
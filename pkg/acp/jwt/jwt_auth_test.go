@@ -84,6 +84,56 @@ func TestNew(t *testing.T) {
 			jwtCfg:  Config{JWKsURL: "http://example.com"},
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "signing secret from Secret",
+			jwtCfg:  Config{SecretKeys: SecretKeys{SigningSecret: "foobar"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "JWKs from Secret",
+			jwtCfg:  Config{SecretKeys: SecretKeys{JWKs: []byte(`{"keys":[]}`)}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "decryption key without verification key",
+			jwtCfg:  Config{SecretKeys: SecretKeys{DecryptionKey: []byte("foobar")}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid decryption key",
+			jwtCfg:  Config{SigningSecret: "foobar", SecretKeys: SecretKeys{DecryptionKey: []byte("foobar")}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative leeway",
+			jwtCfg:  Config{SigningSecret: "foobar", Leeway: -1},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative max age",
+			jwtCfg:  Config{SigningSecret: "foobar", MaxAge: -1},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "HMAC algorithm without signing secret",
+			jwtCfg:  Config{PublicKey: validPubKey, Algorithms: []string{"HS256"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "asymmetric algorithm without public key",
+			jwtCfg:  Config{SigningSecret: "foobar", Algorithms: []string{"RS256"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unsupported algorithm",
+			jwtCfg:  Config{SigningSecret: "foobar", Algorithms: []string{"none"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "allowed algorithms",
+			jwtCfg:  Config{SigningSecret: "foobar", PublicKey: validPubKey, Algorithms: []string{"HS512", "RS256"}},
+			wantErr: assert.NoError,
+		},
 	}
 
 	for _, test := range tests {
@@ -212,6 +262,120 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestServeHTTP_registeredClaims(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		jwtCfg Config
+		claims jwt.MapClaims
+
+		wantStatusCode int
+	}{
+		{
+			name:           "token is expired within leeway",
+			jwtCfg:         Config{SigningSecret: "bibi", Leeway: 30},
+			claims:         jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "token is expired beyond leeway",
+			jwtCfg:         Config{SigningSecret: "bibi", Leeway: 30},
+			claims:         jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "token is not valid yet",
+			jwtCfg:         Config{SigningSecret: "bibi"},
+			claims:         jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "token is not valid yet within leeway",
+			jwtCfg:         Config{SigningSecret: "bibi", Leeway: 120},
+			claims:         jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "token is recent enough",
+			jwtCfg:         Config{SigningSecret: "bibi", MaxAge: 3600},
+			claims:         jwt.MapClaims{"iat": now.Add(-time.Minute).Unix()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "token is too old",
+			jwtCfg:         Config{SigningSecret: "bibi", MaxAge: 3600},
+			claims:         jwt.MapClaims{"iat": now.Add(-2 * time.Hour).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "max age requires iat",
+			jwtCfg:         Config{SigningSecret: "bibi", MaxAge: 3600},
+			claims:         jwt.MapClaims{"sub": "john"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "issuer is allowed",
+			jwtCfg:         Config{SigningSecret: "bibi", Issuers: []string{"https://a.example.com", "https://b.example.com"}},
+			claims:         jwt.MapClaims{"iss": "https://b.example.com"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "issuer is not allowed",
+			jwtCfg:         Config{SigningSecret: "bibi", Issuers: []string{"https://a.example.com"}},
+			claims:         jwt.MapClaims{"iss": "https://c.example.com"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "audience is allowed",
+			jwtCfg:         Config{SigningSecret: "bibi", Audiences: []string{"api"}},
+			claims:         jwt.MapClaims{"aud": []string{"web", "api"}},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "audience is not allowed",
+			jwtCfg:         Config{SigningSecret: "bibi", Audiences: []string{"api"}},
+			claims:         jwt.MapClaims{"aud": "web"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "audience is missing",
+			jwtCfg:         Config{SigningSecret: "bibi", Audiences: []string{"api"}},
+			claims:         jwt.MapClaims{"sub": "john"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "algorithm is not allowed",
+			jwtCfg:         Config{SigningSecret: "bibi", PublicKey: validPubKey, Algorithms: []string{"RS256"}},
+			claims:         jwt.MapClaims{"sub": "john"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			middleware, err := NewHandler(&test.jwtCfg, "acp@my-ns")
+			require.NoError(t, err)
+			middleware.now = func() time.Time { return now }
+
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, test.claims).SignedString([]byte("bibi"))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			middleware.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Code)
+		})
+	}
+}
+
 func TestExtractJWT(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -267,6 +431,8 @@ func TestExtractJWT(t *testing.T) {
 }
 
 func TestKeyFunc(t *testing.T) {
+	allAlgorithms := append(append([]string{}, hmacAlgorithms...), asymmetricAlgorithms...)
+
 	tests := []struct {
 		name    string
 		handler *Handler
@@ -277,34 +443,45 @@ func TestKeyFunc(t *testing.T) {
 		{
 			name: "signing secret found",
 			handler: &Handler{
+				algorithms:    allAlgorithms,
 				signingSecret: "signing-secret",
 			},
 			tok:     &jwt.Token{Method: jwt.SigningMethodHS512},
 			wantKey: []byte("signing-secret"),
 			wantErr: assert.NoError,
 		},
+		{
+			name: "signing algorithm not allowed",
+			handler: &Handler{
+				signingSecret: "signing-secret",
+				algorithms:    []string{"RS256"},
+			},
+			tok:     &jwt.Token{Method: jwt.SigningMethodHS512},
+			wantErr: assert.Error,
+		},
 		{
 			name:    "no signing secret found",
-			handler: &Handler{},
+			handler: &Handler{algorithms: allAlgorithms},
 			tok:     &jwt.Token{Method: jwt.SigningMethodHS512},
 			wantErr: assert.Error,
 		},
 		{
 			name:    "unsupported signing algorithm",
-			handler: &Handler{},
-			tok:     &jwt.Token{Method: jwt.SigningMethodPS512},
+			handler: &Handler{algorithms: []string{"EdDSA"}},
+			tok:     &jwt.Token{Method: jwt.SigningMethodEdDSA},
 			wantErr: assert.Error,
 		},
 		{
 			name:    "no public key found",
-			handler: &Handler{},
+			handler: &Handler{algorithms: allAlgorithms},
 			tok:     &jwt.Token{Method: jwt.SigningMethodRS512},
 			wantErr: assert.Error,
 		},
 		{
 			name: "public key found",
 			handler: &Handler{
-				pubKey: rsa.PublicKey{},
+				algorithms: allAlgorithms,
+				pubKey:     rsa.PublicKey{},
 			},
 			tok:     &jwt.Token{Method: jwt.SigningMethodRS512},
 			wantKey: rsa.PublicKey{},
@@ -313,6 +490,7 @@ func TestKeyFunc(t *testing.T) {
 		{
 			name: "jwks key found",
 			handler: &Handler{
+				algorithms: allAlgorithms,
 				keySet: &RemoteKeySet{
 					expiry: time.Now().Add(60 * time.Second),
 					keys: jose.JSONWebKeySet{
@@ -332,6 +510,7 @@ func TestKeyFunc(t *testing.T) {
 		{
 			name: "jwks key not found",
 			handler: &Handler{
+				algorithms: allAlgorithms,
 				keySet: &RemoteKeySet{
 					expiry: time.Now().Add(60 * time.Second),
 					keys: jose.JSONWebKeySet{
@@ -344,7 +523,7 @@ func TestKeyFunc(t *testing.T) {
 		},
 		{
 			name:    "jwks no keyset",
-			handler: &Handler{},
+			handler: &Handler{algorithms: allAlgorithms},
 			tok:     &jwt.Token{Method: jwt.SigningMethodRS512, Header: map[string]interface{}{"kid": "foo"}},
 			wantErr: assert.Error,
 		},
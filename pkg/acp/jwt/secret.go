@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Keys of the Secret holding the key material of a JWT ACP.
+const (
+	SigningSecretSecretKey = "signingSecret"
+	PublicKeySecretKey     = "publicKey"
+	JWKsSecretKey          = "jwks"
+	DecryptionKeySecretKey = "decryptionKey"
+)
+
+// SecretKeys is the key material read from the Secret referenced by a JWT ACP.
+type SecretKeys struct {
+	// SigningSecret is the secret verifying HMAC signatures. Unlike the inline signing secret, it is never
+	// base64-encoded as Secret data already is.
+	SigningSecret string
+	// PublicKey is the PEM-encoded public key verifying RSA and ECDSA signatures.
+	PublicKey string
+	// JWKs is the JSON web key set verifying RSA and ECDSA signatures.
+	JWKs []byte
+	// DecryptionKey is the PEM-encoded private key decrypting JWEs.
+	DecryptionKey []byte
+}
+
+// KeysFromSecretData returns the key material held by the given Secret data. It makes sure this key material can be
+// loaded by the handler.
+func KeysFromSecretData(data map[string][]byte) (SecretKeys, error) {
+	keys := SecretKeys{
+		SigningSecret: string(data[SigningSecretSecretKey]),
+		PublicKey:     string(data[PublicKeySecretKey]),
+		JWKs:          data[JWKsSecretKey],
+		DecryptionKey: data[DecryptionKeySecretKey],
+	}
+
+	if keys.SigningSecret == "" && keys.PublicKey == "" && len(keys.JWKs) == 0 && len(keys.DecryptionKey) == 0 {
+		return SecretKeys{}, fmt.Errorf("at least one of the %q, %q, %q or %q keys is required",
+			SigningSecretSecretKey, PublicKeySecretKey, JWKsSecretKey, DecryptionKeySecretKey)
+	}
+
+	if keys.PublicKey != "" {
+		if _, err := parsePublicKey(keys.PublicKey); err != nil {
+			return SecretKeys{}, fmt.Errorf("invalid %q key: %w", PublicKeySecretKey, err)
+		}
+	}
+
+	if len(keys.JWKs) > 0 {
+		if _, err := NewContentKeySet(keys.JWKs); err != nil {
+			return SecretKeys{}, fmt.Errorf("invalid %q key: %w", JWKsSecretKey, err)
+		}
+	}
+
+	if len(keys.DecryptionKey) > 0 {
+		if _, err := parseDecryptionKey(keys.DecryptionKey); err != nil {
+			return SecretKeys{}, fmt.Errorf("invalid %q key: %w", DecryptionKeySecretKey, err)
+		}
+	}
+
+	return keys, nil
+}
+
+// parsePublicKey parses the given PEM-encoded public key.
+func parsePublicKey(key string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("empty or ill-formatted public key")
+	}
+
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	return pubKey, nil
+}
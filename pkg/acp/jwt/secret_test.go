@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jwt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeysFromSecretData(t *testing.T) {
+	tests := []struct {
+		desc     string
+		data     map[string][]byte
+		wantKeys SecretKeys
+		wantErr  string
+	}{
+		{
+			desc: "signing secret and public key",
+			data: map[string][]byte{
+				"signingSecret": []byte("secret"),
+				"publicKey":     []byte(validPubKey),
+				"other":         []byte("ignored"),
+			},
+			wantKeys: SecretKeys{SigningSecret: "secret", PublicKey: validPubKey},
+		},
+		{
+			desc:     "JWKs",
+			data:     map[string][]byte{"jwks": []byte(`{"keys":[]}`)},
+			wantKeys: SecretKeys{JWKs: []byte(`{"keys":[]}`)},
+		},
+		{
+			desc:    "no key material",
+			data:    map[string][]byte{"other": []byte("value")},
+			wantErr: `at least one of the "signingSecret", "publicKey", "jwks" or "decryptionKey" keys is required`,
+		},
+		{
+			desc:    "invalid public key",
+			data:    map[string][]byte{"publicKey": []byte(invalidPubKey)},
+			wantErr: `invalid "publicKey" key: parse public key: `,
+		},
+		{
+			desc:    "invalid JWKs",
+			data:    map[string][]byte{"jwks": []byte("keys")},
+			wantErr: `invalid "jwks" key: unable to decode JWK set from content: `,
+		},
+		{
+			desc:    "invalid decryption key",
+			data:    map[string][]byte{"decryptionKey": []byte("secret")},
+			wantErr: `invalid "decryptionKey" key: empty or ill-formatted private key`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := KeysFromSecretData(test.data)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantKeys, keys)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package keyring manages the keys encrypting the session and state cookies of ACP handlers. Keys are stored in the
+// hub Secret: a primary key encrypts cookies while a staged next key and previous keys only decrypt them, so that keys
+// can be rotated without logging users out.
+package keyring
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecretName is the name of the Secret holding the keys.
+const SecretName = "hub-secret"
+
+// Keys of the Secret data.
+const (
+	primaryKey   = "key"
+	nextKey      = "nextKey"
+	previousKeys = "previousKeys"
+)
+
+// Keyring holds the keys encrypting cookies.
+type Keyring struct {
+	// Primary is the key encrypting cookies.
+	Primary string
+	// Secondary holds the keys only used to decrypt cookies: the staged next key followed by previous keys.
+	Secondary []string
+}
+
+// FromSecret returns the Keyring held by the given Secret.
+func FromSecret(secret *corev1.Secret) (Keyring, error) {
+	primary, found := secret.Data[primaryKey]
+	if !found {
+		return Keyring{}, errors.New("key not found")
+	}
+
+	keys := Keyring{Primary: deriveKey(primary)}
+
+	if next := secret.Data[nextKey]; len(next) > 0 {
+		keys.Secondary = append(keys.Secondary, deriveKey(next))
+	}
+
+	for _, previous := range splitKeys(secret.Data[previousKeys]) {
+		keys.Secondary = append(keys.Secondary, deriveKey([]byte(previous)))
+	}
+
+	return keys, nil
+}
+
+// deriveKey derives a 32 bytes long key, suitable for AES-256, from the given key material.
+func deriveKey(material []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(material))[:32]
+}
+
+func splitKeys(data []byte) []string {
+	var keys []string
+	for _, key := range strings.Split(string(data), "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
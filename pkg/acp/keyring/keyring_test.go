@@ -0,0 +1,79 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package keyring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFromSecret(t *testing.T) {
+	tests := []struct {
+		desc    string
+		data    map[string][]byte
+		want    Keyring
+		wantErr bool
+	}{
+		{
+			desc: "primary key only",
+			data: map[string][]byte{"key": []byte("my-key")},
+			want: Keyring{Primary: "5e78863ed1ffb9fc66b1d61634b126bf"},
+		},
+		{
+			desc: "next and previous keys",
+			data: map[string][]byte{
+				"key":          []byte("my-key"),
+				"nextKey":      []byte("next"),
+				"previousKeys": []byte("previous-1\n\nprevious-2\n"),
+			},
+			want: Keyring{
+				Primary: "5e78863ed1ffb9fc66b1d61634b126bf",
+				Secondary: []string{
+					deriveKey([]byte("next")),
+					deriveKey([]byte("previous-1")),
+					deriveKey([]byte("previous-2")),
+				},
+			},
+		},
+		{
+			desc:    "missing primary key",
+			data:    map[string][]byte{"nextKey": []byte("next")},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := FromSecret(&corev1.Secret{Data: test.data})
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
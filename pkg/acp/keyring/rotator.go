@@ -0,0 +1,159 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package keyring
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// Annotations of the Secret tracking the rotation of its keys.
+const (
+	annotationRotatedAt = "hub.traefik.io/keys-rotated-at"
+	annotationStagedAt  = "hub.traefik.io/next-key-staged-at"
+)
+
+const (
+	// propagationDelay is the duration during which a next key is only used for decryption before being promoted, so
+	// that all auth servers know it before it starts encrypting cookies.
+	propagationDelay = 5 * time.Minute
+	// maxPreviousKeys is the number of previous keys kept to decrypt cookies encrypted before a rotation.
+	maxPreviousKeys = 2
+)
+
+// Rotator periodically rotates the keys held by the hub Secret. Rotations happen in two steps: a next key is staged
+// for decryption, then promoted as primary key once auth servers had time to reload it. The former primary key is
+// kept as a previous key, so the rotation interval must exceed the lifetime of sessions.
+type Rotator struct {
+	client    clientset.Interface
+	namespace string
+	interval  time.Duration
+
+	rand io.Reader
+	now  func() time.Time
+}
+
+// NewRotator returns a new Rotator rotating the keys of the hub Secret in the given namespace every interval.
+func NewRotator(client clientset.Interface, namespace string, interval time.Duration) *Rotator {
+	return &Rotator{
+		client:    client,
+		namespace: namespace,
+		interval:  interval,
+		rand:      rand.Reader,
+		now:       time.Now,
+	}
+}
+
+// Run rotates keys when needed until the given context is done.
+func (r *Rotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := r.rotate(ctx); err != nil {
+			log.Error().Err(err).Msg("Unable to rotate keys")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rotate stages a next key once the interval elapsed since the last rotation, and promotes it once propagated.
+func (r *Rotator) rotate(ctx context.Context) error {
+	secret, err := r.client.CoreV1().Secrets(r.namespace).Get(ctx, SecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get secret: %w", err)
+	}
+
+	now := r.now()
+	secret = secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+
+	switch {
+	case len(secret.Data[nextKey]) > 0:
+		if now.Before(parseTime(secret.Annotations[annotationStagedAt], now).Add(propagationDelay)) {
+			return nil
+		}
+
+		previous := append([]string{string(secret.Data[primaryKey])}, splitKeys(secret.Data[previousKeys])...)
+		if len(previous) > maxPreviousKeys {
+			previous = previous[:maxPreviousKeys]
+		}
+
+		secret.Data[primaryKey] = secret.Data[nextKey]
+		secret.Data[previousKeys] = []byte(strings.Join(previous, "\n"))
+		delete(secret.Data, nextKey)
+		delete(secret.Annotations, annotationStagedAt)
+		secret.Annotations[annotationRotatedAt] = now.UTC().Format(time.RFC3339)
+
+		log.Info().Msg("Promoting next key")
+
+	default:
+		if now.Before(parseTime(secret.Annotations[annotationRotatedAt], secret.CreationTimestamp.Time).Add(r.interval)) {
+			return nil
+		}
+
+		key := make([]byte, 32)
+		if _, err = io.ReadFull(r.rand, key); err != nil {
+			return fmt.Errorf("generate key: %w", err)
+		}
+
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[nextKey] = []byte(base64.RawStdEncoding.EncodeToString(key))
+		secret.Annotations[annotationStagedAt] = now.UTC().Format(time.RFC3339)
+
+		log.Info().Msg("Staging next key")
+	}
+
+	if _, err = r.client.CoreV1().Secrets(r.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		// Another controller replica rotated the keys concurrently.
+		if kerror.IsConflict(err) {
+			return nil
+		}
+		return fmt.Errorf("update secret: %w", err)
+	}
+
+	return nil
+}
+
+func parseTime(value string, fallback time.Time) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fallback
+	}
+
+	return t
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubemock "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRotator_rotate(t *testing.T) {
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	client := kubemock.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              SecretName,
+			Namespace:         "hub",
+			CreationTimestamp: metav1.NewTime(createdAt),
+		},
+		Data: map[string][]byte{
+			"key":          []byte("key-1"),
+			"previousKeys": []byte("key-0"),
+		},
+	})
+
+	rotator := NewRotator(client, "hub", 24*time.Hour)
+	rotator.rand = bytes.NewReader(append(bytes.Repeat([]byte{'a'}, 32), bytes.Repeat([]byte{'b'}, 32)...))
+
+	steps := []struct {
+		desc     string
+		now      time.Time
+		wantData map[string][]byte
+	}{
+		{
+			desc: "interval not elapsed",
+			now:  createdAt.Add(time.Hour),
+			wantData: map[string][]byte{
+				"key":          []byte("key-1"),
+				"previousKeys": []byte("key-0"),
+			},
+		},
+		{
+			desc: "next key staged",
+			now:  createdAt.Add(24 * time.Hour),
+			wantData: map[string][]byte{
+				"key":          []byte("key-1"),
+				"nextKey":      []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE"),
+				"previousKeys": []byte("key-0"),
+			},
+		},
+		{
+			desc: "next key not propagated",
+			now:  createdAt.Add(24*time.Hour + time.Minute),
+			wantData: map[string][]byte{
+				"key":          []byte("key-1"),
+				"nextKey":      []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE"),
+				"previousKeys": []byte("key-0"),
+			},
+		},
+		{
+			desc: "next key promoted",
+			now:  createdAt.Add(24*time.Hour + propagationDelay),
+			wantData: map[string][]byte{
+				"key":          []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE"),
+				"previousKeys": []byte("key-1\nkey-0"),
+			},
+		},
+		{
+			desc: "interval since promotion not elapsed",
+			now:  createdAt.Add(47 * time.Hour),
+			wantData: map[string][]byte{
+				"key":          []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE"),
+				"previousKeys": []byte("key-1\nkey-0"),
+			},
+		},
+		{
+			desc: "second next key staged",
+			now:  createdAt.Add(49*time.Hour + propagationDelay),
+			wantData: map[string][]byte{
+				"key":          []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE"),
+				"nextKey":      []byte("YmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmI"),
+				"previousKeys": []byte("key-1\nkey-0"),
+			},
+		},
+		{
+			desc: "second next key promoted, oldest previous key dropped",
+			now:  createdAt.Add(49*time.Hour + 2*propagationDelay),
+			wantData: map[string][]byte{
+				"key":          []byte("YmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmI"),
+				"previousKeys": []byte("YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE\nkey-1"),
+			},
+		},
+	}
+
+	for _, step := range steps {
+		now := step.now
+		rotator.now = func() time.Time { return now }
+
+		require.NoError(t, rotator.rotate(context.Background()), step.desc)
+
+		secret, err := client.CoreV1().Secrets("hub").Get(context.Background(), SecretName, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, step.wantData, secret.Data, step.desc)
+	}
+}
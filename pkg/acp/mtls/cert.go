@@ -0,0 +1,279 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var oidDomainComponent = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+
+// parseCertHeader parses the certificates forwarded in the PEM header by Traefik: comma-separated base64 DER
+// certificates, the first one being the client certificate.
+func parseCertHeader(value string) ([]*x509.Certificate, error) {
+	// Older Traefik versions URL-encode the header. PathUnescape is used as base64 content may hold `+` characters.
+	unescaped, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("unescape certificate header: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for _, rawCert := range strings.Split(unescaped, ",") {
+		rawCert = strings.NewReplacer(
+			"-----BEGIN CERTIFICATE-----", "",
+			"-----END CERTIFICATE-----", "",
+			"\n", "",
+			"\r", "",
+			" ", "",
+		).Replace(rawCert)
+		if rawCert == "" {
+			continue
+		}
+
+		der, err := base64.StdEncoding.DecodeString(rawCert)
+		if err != nil {
+			return nil, fmt.Errorf("decode certificate: %w", err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found in header")
+	}
+
+	return certs, nil
+}
+
+// certClaims returns the claims describing the given certificate.
+func certClaims(cert *x509.Certificate) map[string]interface{} {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	claims := map[string]interface{}{
+		"subject":     nameClaims(cert.Subject),
+		"issuer":      nameClaims(cert.Issuer),
+		"notBefore":   json.Number(strconv.FormatInt(cert.NotBefore.Unix(), 10)),
+		"notAfter":    json.Number(strconv.FormatInt(cert.NotAfter.Unix(), 10)),
+		"fingerprint": hex.EncodeToString(fingerprint[:]),
+	}
+
+	if cert.SerialNumber != nil {
+		claims["serialNumber"] = cert.SerialNumber.String()
+	}
+
+	sans := make(map[string]interface{})
+	addValues(sans, "dns", cert.DNSNames)
+	addValues(sans, "email", cert.EmailAddresses)
+	for _, ip := range cert.IPAddresses {
+		addValues(sans, "ip", []string{ip.String()})
+	}
+	for _, uri := range cert.URIs {
+		addValues(sans, "uri", []string{uri.String()})
+	}
+	claims["sans"] = sans
+
+	return claims
+}
+
+func nameClaims(name pkix.Name) map[string]interface{} {
+	claims := map[string]interface{}{
+		"dn": name.String(),
+	}
+
+	addValue(claims, "commonName", name.CommonName)
+	addValue(claims, "serialNumber", name.SerialNumber)
+	addValues(claims, "country", name.Country)
+	addValues(claims, "province", name.Province)
+	addValues(claims, "locality", name.Locality)
+	addValues(claims, "organization", name.Organization)
+	addValues(claims, "organizationalUnit", name.OrganizationalUnit)
+
+	for _, atv := range name.Names {
+		if !atv.Type.Equal(oidDomainComponent) {
+			continue
+		}
+
+		if dc, ok := atv.Value.(string); ok {
+			addValues(claims, "domainComponent", []string{dc})
+		}
+	}
+
+	return claims
+}
+
+// parseInfoHeader parses the certificate information forwarded in the info header by Traefik. For instance:
+//
+//	Subject="C=FR,O=Cheese,CN=billing";Issuer="DC=org,DC=cheese,CN=Cheese CA";NB="1544094616";NA="1607166616";SAN="billing.internal"
+//
+// Only the first certificate, which is the client certificate, is considered.
+func parseInfoHeader(value string) (map[string]interface{}, error) {
+	unescaped, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("unescape certificate info header: %w", err)
+	}
+
+	certInfo := splitUnquoted(unescaped, ',')[0]
+
+	claims := make(map[string]interface{})
+	for _, field := range splitUnquoted(certInfo, ';') {
+		key, val, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			return nil, fmt.Errorf("malformed certificate info field %q", field)
+		}
+		val = strings.Trim(val, `"`)
+
+		switch key {
+		case "Subject":
+			claims["subject"] = infoNameClaims(val)
+		case "Issuer":
+			claims["issuer"] = infoNameClaims(val)
+		case "SerialNumber":
+			claims["serialNumber"] = val
+		case "NB":
+			claims["notBefore"] = json.Number(val)
+		case "NA":
+			claims["notAfter"] = json.Number(val)
+		case "SAN":
+			claims["sans"] = infoSANClaims(val)
+		}
+	}
+
+	if _, ok := claims["subject"]; !ok {
+		return nil, errors.New("no subject found in certificate info")
+	}
+
+	return claims, nil
+}
+
+var infoNameKeys = map[string]string{
+	"C":            "country",
+	"ST":           "province",
+	"L":            "locality",
+	"O":            "organization",
+	"OU":           "organizationalUnit",
+	"DC":           "domainComponent",
+	"CN":           "commonName",
+	"SerialNumber": "serialNumber",
+}
+
+func infoNameClaims(value string) map[string]interface{} {
+	claims := make(map[string]interface{})
+
+	for _, attr := range strings.Split(value, ",") {
+		key, val, found := strings.Cut(attr, "=")
+		if !found {
+			continue
+		}
+
+		name, ok := infoNameKeys[key]
+		if !ok {
+			continue
+		}
+
+		if name == "commonName" || name == "serialNumber" {
+			addValue(claims, name, val)
+			continue
+		}
+
+		addValues(claims, name, []string{val})
+	}
+
+	return claims
+}
+
+// infoSANClaims classifies the SANs listed in the info header, as their type is not part of it.
+func infoSANClaims(value string) map[string]interface{} {
+	claims := make(map[string]interface{})
+
+	for _, san := range strings.Split(value, ",") {
+		switch {
+		case san == "":
+		case net.ParseIP(san) != nil:
+			addValues(claims, "ip", []string{san})
+		case strings.Contains(san, "://"):
+			addValues(claims, "uri", []string{san})
+		case strings.Contains(san, "@"):
+			addValues(claims, "email", []string{san})
+		default:
+			addValues(claims, "dns", []string{san})
+		}
+	}
+
+	return claims
+}
+
+// splitUnquoted splits s on sep, ignoring separators found between double quotes.
+func splitUnquoted(s string, sep rune) []string {
+	var (
+		parts   []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+
+		_, _ = current.WriteRune(r)
+	}
+
+	return append(parts, current.String())
+}
+
+func addValue(claims map[string]interface{}, name, value string) {
+	if value != "" {
+		claims[name] = value
+	}
+}
+
+// addValues appends values to the list claim with the given name. Lists are stored as []interface{} so they can be
+// used with the Contains predicate.
+func addValues(claims map[string]interface{}, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	list, _ := claims[name].([]interface{})
+	for _, v := range values {
+		list = append(list, v)
+	}
+	claims[name] = list
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mtls
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+)
+
+// Headers set by the Traefik PassTLSClientCert middleware.
+const (
+	HeaderClientCert     = "X-Forwarded-Tls-Client-Cert"
+	HeaderClientCertInfo = "X-Forwarded-Tls-Client-Cert-Info"
+)
+
+// DefaultCAKey is the default data key of the Secret or ConfigMap holding the CA bundle.
+const DefaultCAKey = "ca.crt"
+
+// Config configures an mTLS client certificate ACP handler.
+type Config struct {
+	// CA references the PEM-encoded CA bundle used to verify client certificates.
+	// When not set, client certificates are expected to have been verified by Traefik already, and certificate
+	// information may be read from the info header when the PEM header is missing.
+	CA *CAReference `json:"ca,omitempty"`
+	// CABundle is the PEM-encoded CA bundle resolved from CA.
+	CABundle []byte `json:"-"`
+
+	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the
+	// client certificate. For example: `subject.commonName`, `sans.dns` or `issuer.organization`.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	// Claims defines an expression to perform validation on the client certificate. For example:
+	//     Equals(`subject.commonName`, `billing`) && Contains(`sans.dns`, `billing.internal`)
+	Claims string `json:"claims,omitempty"`
+}
+
+// CAReference references a CA bundle held by either a Secret or a ConfigMap.
+type CAReference struct {
+	Secret    *ObjectReference `json:"secret,omitempty"`
+	ConfigMap *ObjectReference `json:"configMap,omitempty"`
+	Key       string           `json:"key,omitempty"`
+}
+
+// ObjectReference references a namespaced object.
+type ObjectReference struct {
+	Name      string
+	Namespace string
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil || cfg.CA == nil {
+		return nil
+	}
+
+	if (cfg.CA.Secret == nil) == (cfg.CA.ConfigMap == nil) {
+		return errors.New("CA must reference either a Secret or a ConfigMap")
+	}
+
+	if len(cfg.CABundle) == 0 {
+		return errors.New("missing CA bundle")
+	}
+
+	return nil
+}
+
+// Handler is an mTLS client certificate ACP Handler.
+type Handler struct {
+	name string
+
+	roots      *x509.CertPool
+	fwdHeaders map[string]string
+
+	validateClaims expr.Predicate
+
+	now func() time.Time
+}
+
+// NewHandler returns a new mTLS client certificate ACP Handler.
+func NewHandler(cfg *Config, polName string) (*Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if cfg.CA != nil {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(cfg.CABundle) {
+			return nil, errors.New("no certificate found in CA bundle")
+		}
+	}
+
+	var (
+		pred expr.Predicate
+		err  error
+	)
+	if cfg.Claims != "" {
+		pred, err = expr.Parse(cfg.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("make predicate: %w", err)
+		}
+	}
+
+	return &Handler{
+		name:           polName,
+		roots:          roots,
+		fwdHeaders:     cfg.ForwardHeaders,
+		validateClaims: pred,
+		now:            time.Now,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "MTLS").Str("handler_name", h.name).Logger()
+
+	claims, err := h.certificateClaims(req)
+	if err != nil {
+		l.Debug().Err(err).Msg("Unable to authenticate client certificate")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if h.validateClaims != nil && !h.validateClaims(claims) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	hdrs, err := expr.PluckClaims(h.fwdHeaders, claims)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to set forwarded header")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, vals := range hdrs {
+		for _, val := range vals {
+			rw.Header().Add(name, val)
+		}
+	}
+
+	identity.RecordClaims(req.Context(), claims)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// certificateClaims returns the claims of the client certificate forwarded with the given request.
+// The PEM header is verified against the CA bundle when one is configured. The info header, which cannot be verified,
+// is only used when no CA bundle is configured and the PEM header is missing.
+func (h *Handler) certificateClaims(req *http.Request) (map[string]interface{}, error) {
+	rawCerts := req.Header.Get(HeaderClientCert)
+	if rawCerts == "" {
+		if h.roots != nil {
+			return nil, errors.New("no client certificate found in request")
+		}
+
+		rawInfo := req.Header.Get(HeaderClientCertInfo)
+		if rawInfo == "" {
+			return nil, errors.New("no client certificate found in request")
+		}
+
+		return parseInfoHeader(rawInfo)
+	}
+
+	certs, err := parseCertHeader(rawCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err = certs[0].Verify(x509.VerifyOptions{
+			Roots:         h.roots,
+			Intermediates: intermediates,
+			CurrentTime:   h.now(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("verify client certificate: %w", err)
+		}
+	}
+
+	return certClaims(certs[0]), nil
+}
@@ -0,0 +1,293 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler_validation(t *testing.T) {
+	_, err := NewHandler(&Config{CA: &CAReference{}}, "acp")
+	assert.EqualError(t, err, "validate configuration: CA must reference either a Secret or a ConfigMap")
+
+	_, err = NewHandler(&Config{CA: &CAReference{Secret: &ObjectReference{Name: "ca"}}}, "acp")
+	assert.EqualError(t, err, "validate configuration: missing CA bundle")
+
+	_, err = NewHandler(&Config{CA: &CAReference{Secret: &ObjectReference{Name: "ca"}}, CABundle: []byte("foo")}, "acp")
+	assert.EqualError(t, err, "no certificate found in CA bundle")
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	ca := newTestCA(t, "Root CA")
+	intermediate := ca.issueCA(t, "Intermediate CA")
+	otherCA := newTestCA(t, "Other CA")
+
+	client := ca.issueLeaf(t)
+	clientFromIntermediate := intermediate.issueLeaf(t)
+	clientFromOtherCA := otherCA.issueLeaf(t)
+
+	info := url.QueryEscape(`Subject="O=Traefik,CN=billing";Issuer="CN=Root CA";SerialNumber="42";NB="1672531200";NA="1704067200";SAN="billing.internal,10.0.0.1"`)
+
+	tests := []struct {
+		desc        string
+		withCA      bool
+		claims      string
+		headers     map[string]string
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		{
+			desc:     "no certificate",
+			withCA:   true,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "certificate issued by the CA",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: traefikPEM(client)},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-Client-CN":  "billing",
+				"X-Client-Org": "Traefik",
+				"X-Client-DNS": "billing.internal",
+				"X-Issuer-CN":  "Root CA",
+			},
+		},
+		{
+			desc:     "URL-encoded certificate issued by the CA",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: url.QueryEscape(traefikPEM(client))},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "certificate issued by an intermediate CA",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: traefikPEM(clientFromIntermediate, intermediate.cert)},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-Issuer-CN": "Intermediate CA",
+			},
+		},
+		{
+			desc:     "certificate issued by an intermediate CA without the intermediate",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: traefikPEM(clientFromIntermediate)},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "certificate issued by another CA",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: traefikPEM(clientFromOtherCA)},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "malformed certificate",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCert: "Zm9v"},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "certificate matching claims",
+			withCA:   true,
+			claims:   "Equals(`subject.commonName`, `billing`) && Contains(`sans.ip`, `10.0.0.1`)",
+			headers:  map[string]string{HeaderClientCert: traefikPEM(client)},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "certificate not matching claims",
+			withCA:   true,
+			claims:   "Equals(`issuer.commonName`, `Intermediate CA`)",
+			headers:  map[string]string{HeaderClientCert: traefikPEM(client)},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			desc:     "info header is ignored when a CA is configured",
+			withCA:   true,
+			headers:  map[string]string{HeaderClientCertInfo: info},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "info header without CA",
+			claims:   "Equals(`serialNumber`, `42`) && Contains(`sans.ip`, `10.0.0.1`)",
+			headers:  map[string]string{HeaderClientCertInfo: info},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-Client-CN":  "billing",
+				"X-Client-Org": "Traefik",
+				"X-Client-DNS": "billing.internal",
+				"X-Issuer-CN":  "Root CA",
+			},
+		},
+		{
+			desc:     "certificate issued by any CA without CA",
+			headers:  map[string]string{HeaderClientCert: traefikPEM(clientFromOtherCA)},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				ForwardHeaders: map[string]string{
+					"X-Client-CN":  "subject.commonName",
+					"X-Client-Org": "subject.organization",
+					"X-Client-DNS": "sans.dns",
+					"X-Issuer-CN":  "issuer.commonName",
+				},
+				Claims: test.claims,
+			}
+			if test.withCA {
+				cfg.CA = &CAReference{ConfigMap: &ObjectReference{Name: "ca", Namespace: "default"}}
+				cfg.CABundle = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+			}
+
+			handler, err := NewHandler(cfg, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, value := range test.wantHeaders {
+				assert.Equal(t, value, rw.Header().Get(name))
+			}
+		})
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (c testCA) issueCA(t *testing.T, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (c testCA) issueLeaf(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject: pkix.Name{
+			CommonName:   "billing",
+			Organization: []string{"Traefik"},
+		},
+		DNSNames:    []string{"billing.internal"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+// traefikPEM formats certificates the way the Traefik PassTLSClientCert middleware does.
+func traefikPEM(certs ...*x509.Certificate) string {
+	var value string
+	for i, cert := range certs {
+		if i > 0 {
+			value += ","
+		}
+		value += base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+
+	return value
+}
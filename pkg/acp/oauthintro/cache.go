@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oauthintro
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries is the maximum number of introspection results kept in memory.
+const maxCacheEntries = 10000
+
+type cacheEntry struct {
+	claims    map[string]interface{}
+	expiresAt time.Time
+}
+
+// cache holds introspection results until they expire.
+// A nil claims map means the token is inactive.
+type cache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+func newCache() *cache {
+	return &cache{
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Get returns the cached claims for the given key, if any.
+func (c *cache) Get(key string, now time.Time) (map[string]interface{}, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	if !now.Before(entry.expiresAt) {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+
+		return nil, false
+	}
+
+	return entry.claims, true
+}
+
+// Set caches the given claims until expiresAt. When the cache is full, expired entries are evicted first and the
+// result is not cached if no room could be made.
+func (c *cache) Set(key string, claims map[string]interface{}, expiresAt, now time.Time) {
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+
+	c.entries[key] = cacheEntry{
+		claims:    claims,
+		expiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oauthintro
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/version"
+)
+
+// inactiveTTL is the duration during which an inactive token is remembered as such.
+const inactiveTTL = time.Minute
+
+// Config configures an OAuth 2.0 token introspection ACP handler.
+type Config struct {
+	URL          string           `json:"url,omitempty"`
+	ClientID     string           `json:"clientId,omitempty"`
+	ClientSecret string           `json:"-"`
+	Secret       *SecretReference `json:"secret,omitempty"`
+
+	TokenTypeHint            string `json:"tokenTypeHint,omitempty"`
+	TokenQueryKey            string `json:"tokenQueryKey,omitempty"`
+	StripAuthorizationHeader bool   `json:"stripAuthorizationHeader,omitempty"`
+
+	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the
+	// introspection response.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	// Claims defines an expression to perform validation on the introspection response. For example:
+	//     Equals(`scope`, `deploy`) && Prefix(`sub`, `svc-`)
+	Claims string `json:"claims,omitempty"`
+}
+
+// Validate validates configuration.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.URL == "" {
+		return errors.New("missing introspection URL")
+	}
+
+	if _, err := url.ParseRequestURI(cfg.URL); err != nil {
+		return fmt.Errorf("invalid introspection URL: %w", err)
+	}
+
+	if cfg.ClientID == "" {
+		return errors.New("missing client ID")
+	}
+
+	if cfg.ClientSecret == "" {
+		return errors.New("missing client secret")
+	}
+
+	return nil
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string
+	Namespace string
+}
+
+// Handler is an OAuth 2.0 token introspection ACP Handler.
+// See https://www.rfc-editor.org/rfc/rfc7662.
+type Handler struct {
+	name string
+
+	url           string
+	clientID      string
+	clientSecret  string
+	tokenTypeHint string
+	tokQryKey     string
+
+	stripAuthorization bool
+	fwdHeaders         map[string]string
+
+	validateClaims expr.Predicate
+
+	client *http.Client
+	cache  *cache
+	now    func() time.Time
+}
+
+// NewHandler returns a new OAuth 2.0 token introspection ACP Handler.
+func NewHandler(cfg *Config, polName string) (*Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate configuration: %w", err)
+	}
+
+	var (
+		pred expr.Predicate
+		err  error
+	)
+	if cfg.Claims != "" {
+		pred, err = expr.Parse(cfg.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("make predicate: %w", err)
+		}
+	}
+
+	tokenQueryKey := "access_token"
+	if cfg.TokenQueryKey != "" {
+		tokenQueryKey = cfg.TokenQueryKey
+	}
+
+	return &Handler{
+		name:               polName,
+		url:                cfg.URL,
+		clientID:           cfg.ClientID,
+		clientSecret:       cfg.ClientSecret,
+		tokenTypeHint:      cfg.TokenTypeHint,
+		tokQryKey:          tokenQueryKey,
+		stripAuthorization: cfg.StripAuthorizationHeader,
+		fwdHeaders:         cfg.ForwardHeaders,
+		validateClaims:     pred,
+		client:             newHTTPClient(),
+		cache:              newCache(),
+		now:                time.Now,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "OAuthIntrospection").Str("handler_name", h.name).Logger()
+
+	token := h.extractToken(req)
+	if token == "" {
+		l.Debug().Msg("No token found in request")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := h.introspect(req.Context(), token)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to introspect token")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if claims == nil {
+		l.Debug().Msg("Inactive token")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if h.validateClaims != nil && !h.validateClaims(claims) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	hdrs, err := expr.PluckClaims(h.fwdHeaders, claims)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to set forwarded header")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, vals := range hdrs {
+		for _, val := range vals {
+			rw.Header().Add(name, val)
+		}
+	}
+
+	if h.stripAuthorization {
+		rw.Header().Add("Authorization", "")
+	}
+
+	identity.RecordClaims(req.Context(), claims)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// extractToken extracts an access token from an HTTP request. It first looks in the "Authorization" header then in a
+// query parameter named as configured by `tokQryKey`. An "Authorization" header not using the Bearer scheme holds no
+// token, so other credentials are never sent to the introspection endpoint.
+func (h *Handler) extractToken(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return req.URL.Query().Get(h.tokQryKey)
+	}
+
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// introspect returns the claims of the given token, or nil if the token is not active.
+// Results are cached until the token expires.
+func (h *Handler) introspect(ctx context.Context, token string) (map[string]interface{}, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	now := h.now()
+	if claims, ok := h.cache.Get(key, now); ok {
+		return claims, nil
+	}
+
+	claims, err := h.callEndpoint(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	active, _ := claims["active"].(bool)
+	if !active {
+		h.cache.Set(key, nil, now.Add(inactiveTTL), now)
+		return nil, nil
+	}
+
+	if exp, ok := expiry(claims); ok {
+		h.cache.Set(key, claims, exp, now)
+	}
+
+	return claims, nil
+}
+
+func (h *Handler) callEndpoint(ctx context.Context, token string) (map[string]interface{}, error) {
+	form := url.Values{"token": {token}}
+	if h.tokenTypeHint != "" {
+		form.Set("token_type_hint", h.tokenTypeHint)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("build introspection request: %w", err)
+	}
+
+	version.SetUserAgent(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// Client credentials must be form-encoded before being used as basic auth credentials.
+	// See https://www.rfc-editor.org/rfc/rfc6749#section-2.3.1.
+	req.SetBasicAuth(url.QueryEscape(h.clientID), url.QueryEscape(h.clientSecret))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call introspection endpoint: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %q", resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+
+	var claims map[string]interface{}
+	if err = dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("decode introspection response: %w", err)
+	}
+
+	return claims, nil
+}
+
+// expiry returns the expiration time advertised by the `exp` member of an introspection response.
+func expiry(claims map[string]interface{}) (time.Time, bool) {
+	num, ok := claims["exp"].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	exp, err := num.Int64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(exp, 0), true
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			Proxy:               http.ProxyFromEnvironment,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
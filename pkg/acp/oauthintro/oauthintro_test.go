@@ -0,0 +1,279 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oauthintro
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler_validation(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc:    "missing URL",
+			cfg:     Config{ClientID: "id", ClientSecret: "secret"},
+			wantErr: "validate configuration: missing introspection URL",
+		},
+		{
+			desc:    "missing client ID",
+			cfg:     Config{URL: "https://idp.example.com/introspect", ClientSecret: "secret"},
+			wantErr: "validate configuration: missing client ID",
+		},
+		{
+			desc:    "missing client secret",
+			cfg:     Config{URL: "https://idp.example.com/introspect", ClientID: "id"},
+			wantErr: "validate configuration: missing client secret",
+		},
+		{
+			desc: "invalid claims",
+			cfg: Config{
+				URL:          "https://idp.example.com/introspect",
+				ClientID:     "id",
+				ClientSecret: "secret",
+				Claims:       "Equals(",
+			},
+			wantErr: "make predicate: unable to parse expression",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewHandler(&test.cfg, "acp")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		desc          string
+		token         string
+		authorization string
+		query         string
+		response      string
+		status        int
+		claims        string
+		wantCode      int
+		wantHeaders   map[string]string
+	}{
+		{
+			desc:     "no token",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "inactive token",
+			token:    "inactive",
+			response: `{"active":false}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "introspection endpoint failure",
+			token:    "token",
+			status:   http.StatusServiceUnavailable,
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			desc:     "active token",
+			token:    "token",
+			response: `{"active":true,"sub":"jdoe","scope":"read","exp":` + itoa(exp) + `}`,
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-User": "jdoe",
+			},
+		},
+		{
+			desc:          "lowercase Bearer scheme",
+			authorization: "bearer token",
+			response:      `{"active":true,"sub":"jdoe","scope":"read"}`,
+			wantCode:      http.StatusOK,
+		},
+		{
+			desc:          "Basic credentials",
+			authorization: "Basic dXNlcjpwYXNz",
+			response:      `{"active":true,"sub":"jdoe","scope":"read"}`,
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			desc:          "missing scheme",
+			authorization: "token",
+			response:      `{"active":true,"sub":"jdoe","scope":"read"}`,
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			desc:     "active token in query",
+			query:    "?access_token=token",
+			response: `{"active":true,"sub":"jdoe","scope":"read"}`,
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-User": "jdoe",
+			},
+		},
+		{
+			desc:     "active token matching claims",
+			token:    "token",
+			response: `{"active":true,"sub":"jdoe","scope":"read"}`,
+			claims:   "Equals(`scope`, `read`)",
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:     "active token not matching claims",
+			token:    "token",
+			response: `{"active":true,"sub":"jdoe","scope":"read"}`,
+			claims:   "Equals(`scope`, `write`)",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				user, pass, ok := req.BasicAuth()
+				if !ok || user != "client-id" || pass != "client%2Fsecret" {
+					rw.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				if err := req.ParseForm(); err != nil || req.PostForm.Get("token") == "" {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				if test.status != 0 {
+					rw.WriteHeader(test.status)
+					return
+				}
+
+				rw.Header().Set("Content-Type", "application/json")
+				_, _ = rw.Write([]byte(test.response))
+			}))
+			t.Cleanup(srv.Close)
+
+			handler, err := NewHandler(&Config{
+				URL:            srv.URL,
+				ClientID:       "client-id",
+				ClientSecret:   "client/secret",
+				ForwardHeaders: map[string]string{"X-User": "sub"},
+				Claims:         test.claims,
+			}, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp"+test.query, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			for name, value := range test.wantHeaders {
+				assert.Equal(t, value, rw.Header().Get(name))
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_cache(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		if err := req.ParseForm(); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.PostForm.Get("token") {
+		case "active":
+			_, _ = rw.Write([]byte(`{"active":true,"exp":` + itoa(time.Now().Add(time.Hour).Unix()) + `}`))
+		case "active-no-exp":
+			_, _ = rw.Write([]byte(`{"active":true}`))
+		default:
+			_, _ = rw.Write([]byte(`{"active":false}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	handler, err := NewHandler(&Config{
+		URL:          srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}, "acp")
+	require.NoError(t, err)
+
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://auth.example.com/acp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rw := httptest.NewRecorder()
+
+		handler.ServeHTTP(rw, req)
+
+		return rw.Code
+	}
+
+	// Active tokens with an expiration time are cached.
+	assert.Equal(t, http.StatusOK, serve("active"))
+	assert.Equal(t, http.StatusOK, serve("active"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Inactive tokens are cached.
+	assert.Equal(t, http.StatusUnauthorized, serve("inactive"))
+	assert.Equal(t, http.StatusUnauthorized, serve("inactive"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Active tokens without an expiration time are not cached.
+	assert.Equal(t, http.StatusOK, serve("active-no-exp"))
+	assert.Equal(t, http.StatusOK, serve("active-no-exp"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// Entries are dropped once expired.
+	handler.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.Equal(t, http.StatusOK, serve("active"))
+	assert.Equal(t, http.StatusUnauthorized, serve("inactive"))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
@@ -19,6 +19,9 @@ package oidc
 
 import (
 	"errors"
+	"fmt"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
 )
 
 // Config holds the configuration for the OIDC middleware.
@@ -35,12 +38,25 @@ type Config struct {
 	Key         string            `json:"-"`
 	StateCookie *AuthStateCookie  `json:"stateCookie,omitempty"`
 	Session     *AuthSession      `json:"session,omitempty"`
+	// PKCE enables Proof Key for Code Exchange using S256 code challenges. When not set, PKCE is enabled if the
+	// provider advertises support for S256 code challenges.
+	PKCE *bool `json:"pkce,omitempty"`
+	// PostLogoutRedirectURL is the URL the provider redirects users to once logged out through RP-initiated logout.
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl,omitempty"`
+	// UserInfo enables merging claims from the UserInfo endpoint with the ID token ones.
+	UserInfo *UserInfo `json:"userInfo,omitempty"`
+	// Provider configures the policy for a well-known provider.
+	Provider *ProviderConfig `json:"provider,omitempty"`
+	// DecryptionKeys holds keys only used to decrypt cookies, encrypted before a rotation of Key.
+	DecryptionKeys []string `json:"-"`
 
 	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the ID token.
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	// Claims defines an expression to perform validation on the ID token. For example:
 	//     Equals(`grp`, `admin`) && Equals(`scope`, `deploy`)
 	Claims string `json:"claims,omitempty"`
+	// Rules are the rules of the ACP, set from its configuration.
+	Rules []rules.Config `json:"-"`
 }
 
 // ApplyDefaultValues applies default values on the given dynamic configuration.
@@ -49,6 +65,16 @@ func (cfg *Config) ApplyDefaultValues() {
 		return
 	}
 
+	if cfg.Provider != nil {
+		if cfg.Issuer == "" {
+			cfg.Issuer = cfg.Provider.issuer()
+		}
+
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = cfg.Provider.scopes()
+		}
+	}
+
 	if len(cfg.Scopes) == 0 {
 		cfg.Scopes = []string{"openid"}
 	}
@@ -81,6 +107,10 @@ func (cfg *Config) ApplyDefaultValues() {
 		cfg.Session.Refresh = ptrBool(true)
 	}
 
+	if cfg.Session.Store == "" {
+		cfg.Session.Store = SessionStoreCookie
+	}
+
 	if cfg.RedirectURL == "" {
 		cfg.RedirectURL = "/callback"
 	}
@@ -92,6 +122,10 @@ func (cfg *Config) Validate() error {
 		return nil
 	}
 
+	if err := cfg.Provider.Validate(); err != nil {
+		return fmt.Errorf("invalid provider: %w", err)
+	}
+
 	cfg.ApplyDefaultValues()
 
 	if cfg.Issuer == "" {
@@ -110,20 +144,58 @@ func (cfg *Config) Validate() error {
 		return errors.New("missing key")
 	}
 
-	switch len(cfg.Key) {
-	case 16, 24, 32:
-		break
-	default:
-		return errors.New("key must be 16, 24 or 32 characters long")
+	for _, key := range append([]string{cfg.Key}, cfg.DecryptionKeys...) {
+		switch len(key) {
+		case 16, 24, 32:
+			break
+		default:
+			return errors.New("key must be 16, 24 or 32 characters long")
+		}
 	}
 
 	if cfg.RedirectURL == "" {
 		return errors.New("missing redirect URL")
 	}
 
+	switch cfg.Session.Store {
+	case SessionStoreCookie, SessionStoreMemory, SessionStoreSecret:
+	default:
+		return fmt.Errorf("unsupported session store %q", cfg.Session.Store)
+	}
+
+	if cfg.Session.TTL < 0 {
+		return errors.New("session TTL must not be negative")
+	}
+
+	if cfg.UserInfo != nil {
+		switch cfg.UserInfo.Precedence {
+		case "", UserInfoPrecedenceIDToken, UserInfoPrecedenceUserInfo:
+		default:
+			return fmt.Errorf("unsupported UserInfo precedence %q", cfg.UserInfo.Precedence)
+		}
+	}
+
 	return nil
 }
 
+// claimsExpression returns the claims expression to validate, enforcing the groups and domains allowed by the provider
+// configuration.
+func (cfg *Config) claimsExpression() string {
+	var providerClaims string
+	if cfg.Provider != nil {
+		providerClaims = cfg.Provider.claims()
+	}
+
+	switch {
+	case providerClaims == "":
+		return cfg.Claims
+	case cfg.Claims == "":
+		return providerClaims
+	default:
+		return "(" + providerClaims + ")&&(" + cfg.Claims + ")"
+	}
+}
+
 // SecretReference represents a Secret Reference.
 // It has enough information to retrieve secret in any namespace.
 type SecretReference struct {
@@ -139,6 +211,18 @@ type AuthStateCookie struct {
 	Secure   bool   `json:"secure,omitempty"`
 }
 
+// Supported UserInfo claims precedences.
+const (
+	UserInfoPrecedenceIDToken  = "idToken"
+	UserInfoPrecedenceUserInfo = "userInfo"
+)
+
+// UserInfo carries the configuration of the retrieval of claims from the UserInfo endpoint.
+type UserInfo struct {
+	// Precedence defines which claims prevail when both the ID token and the UserInfo response hold the same claim.
+	Precedence string `json:"precedence,omitempty"`
+}
+
 // AuthSession carries session and session cookie configuration.
 type AuthSession struct {
 	Path     string `json:"path,omitempty"`
@@ -146,6 +230,10 @@ type AuthSession struct {
 	SameSite string `json:"sameSite,omitempty"`
 	Secure   bool   `json:"secure,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	// Store is the session store: SessionStoreCookie, SessionStoreMemory or SessionStoreSecret.
+	Store string `json:"store,omitempty"`
+	// TTL is the number of seconds server-side sessions are kept for.
+	TTL int `json:"ttl,omitempty"`
 }
 
 // ptrBool returns a pointer to boolean.
@@ -19,9 +19,11 @@ package oidc
 
 import (
 	"bytes"
+	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -40,24 +42,25 @@ type CookieSessionStore struct {
 	cfg     *AuthSession
 	maxSize int
 
-	block cipher.Block
-	rand  Randr
+	blocks []cipher.Block
+	rand   Randr
 }
 
-// NewCookieSessionStore creates a cookie session store.
-func NewCookieSessionStore(name string, block cipher.Block, cfg *AuthSession, rand Randr, maxSize int) *CookieSessionStore {
+// NewCookieSessionStore creates a cookie session store. Sessions are encrypted with the first of the given blocks and
+// decrypted with any of them.
+func NewCookieSessionStore(name string, blocks []cipher.Block, cfg *AuthSession, rand Randr, maxSize int) *CookieSessionStore {
 	return &CookieSessionStore{
 		name:    name,
 		cfg:     cfg,
 		maxSize: maxSize,
-		block:   block,
+		blocks:  blocks,
 		rand:    rand,
 	}
 }
 
 // Create stores the session data into the request cookies.
-func (s *CookieSessionStore) Create(w http.ResponseWriter, data SessionData) error {
-	value, err := s.encode(data)
+func (s *CookieSessionStore) Create(w http.ResponseWriter, _ *http.Request, data SessionData) error {
+	value, err := encodeSession(s.blocks[0], s.rand, data)
 	if err != nil {
 		return fmt.Errorf("unable to encode session payload: %w", err)
 	}
@@ -95,8 +98,8 @@ func (s *CookieSessionStore) Create(w http.ResponseWriter, data SessionData) err
 }
 
 // Update is the same as Create and only exists to satisfy the SessionStore interface.
-func (s *CookieSessionStore) Update(w http.ResponseWriter, _ *http.Request, data SessionData) error {
-	return s.Create(w, data)
+func (s *CookieSessionStore) Update(w http.ResponseWriter, r *http.Request, data SessionData) error {
+	return s.Create(w, r, data)
 }
 
 // Delete sets the cookie on the HTTP response to be expired, effectively
@@ -125,7 +128,7 @@ func (s *CookieSessionStore) Get(r *http.Request) (*SessionData, error) {
 		return nil, nil
 	}
 
-	sess, err := s.decode(b)
+	sess, err := decodeSession(s.blocks, b)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode session: %w", err)
 	}
@@ -168,8 +171,9 @@ func (s *CookieSessionStore) RemoveCookie(rw http.ResponseWriter, r *http.Reques
 	}
 }
 
-func (s *CookieSessionStore) encode(session SessionData) ([]byte, error) {
-	blockSize := s.block.BlockSize()
+// encodeSession serializes and encrypts the given session.
+func encodeSession(block cipher.Block, rand Randr, session SessionData) ([]byte, error) {
+	blockSize := block.BlockSize()
 
 	ser, err := json.Marshal(session)
 	if err != nil {
@@ -177,9 +181,9 @@ func (s *CookieSessionStore) encode(session SessionData) ([]byte, error) {
 	}
 
 	encrypted := make([]byte, blockSize+len(ser))
-	iv := s.rand.Bytes(blockSize)
+	iv := rand.Bytes(blockSize)
 	copy(encrypted[:blockSize], iv)
-	stream := cipher.NewCTR(s.block, iv)
+	stream := cipher.NewCTR(block, iv)
 	stream.XORKeyStream(encrypted[blockSize:], ser)
 
 	encoded := make([]byte, base64.RawURLEncoding.EncodedLen(len(encrypted)))
@@ -188,27 +192,58 @@ func (s *CookieSessionStore) encode(session SessionData) ([]byte, error) {
 	return encoded, nil
 }
 
-func (s *CookieSessionStore) decode(p []byte) (SessionData, error) {
-	blockSize := s.block.BlockSize()
-
+// decodeSession decrypts and deserializes the given session.
+func decodeSession(blocks []cipher.Block, p []byte) (SessionData, error) {
 	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(len(p)))
 	if _, err := base64.RawURLEncoding.Decode(decoded, p); err != nil {
 		return SessionData{}, fmt.Errorf("unable to decode session: %w", err)
 	}
 
-	decrypted := make([]byte, len(decoded)-blockSize)
-	iv := decoded[:blockSize]
-	stream := cipher.NewCTR(s.block, iv)
-	stream.XORKeyStream(decrypted, decoded[blockSize:])
-
 	var sess SessionData
-	if err := json.Unmarshal(decrypted, &sess); err != nil {
+	if err := decrypt(blocks, decoded, &sess); err != nil {
 		return SessionData{}, fmt.Errorf("unable to deserialize session: %w", err)
 	}
 
 	return sess, nil
 }
 
+// decrypt decrypts the given payload and deserializes it into v. Payloads aren't authenticated, so each block is tried
+// in turn until the payload deserializes successfully, which is only expected with the block which encrypted it.
+func decrypt(blocks []cipher.Block, p []byte, v interface{}) error {
+	var err error
+	for _, block := range blocks {
+		blockSize := block.BlockSize()
+		if len(p) < blockSize {
+			return errors.New("payload too short")
+		}
+
+		decrypted := make([]byte, len(p)-blockSize)
+		stream := cipher.NewCTR(block, p[:blockSize])
+		stream.XORKeyStream(decrypted, p[blockSize:])
+
+		if err = json.Unmarshal(decrypted, v); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// newBlocks returns the ciphers of the given keys.
+func newBlocks(keys ...string) ([]cipher.Block, error) {
+	blocks := make([]cipher.Block, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
 func chunkBytes(b []byte, lim int) [][]byte {
 	chunks := make([][]byte, 0, len(b)/lim+1)
 
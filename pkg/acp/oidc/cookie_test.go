@@ -19,6 +19,7 @@ package oidc
 
 import (
 	"crypto/aes"
+	"crypto/cipher"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -31,7 +32,7 @@ func TestCookieSessionStore_Delete(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -73,7 +74,7 @@ func TestCookieSessionStore_RemoveCookieOnlyRemovesOurCookie(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -108,7 +109,7 @@ func TestCookieSessionStore_Create(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -122,7 +123,7 @@ func TestCookieSessionStore_Create(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 
-	err = store.Create(rec, sess)
+	err = store.Create(rec, httptest.NewRequest(http.MethodGet, "/", nil), sess)
 	require.NoError(t, err)
 
 	assert.Equal(t, "test-name=AQEBAQEBAQEBAQEBAQEBAQPCeonj6H8bgW-y-xdlkLmaN-_ouVkUUyQPAE3ccSugJPjEn0E6eB61jItErDH-XxhNXvoLnh92YAV1rATcOmBVdxP1Ahk4cwyUfBgI5_9x_42fkm4WB8NnvtMReWKFnYdOTBvPfLO1sh0; Path=/; Domain=example.com; Max-Age=86400; HttpOnly; Secure; SameSite=Lax", rec.Header().Get("Set-Cookie"))
@@ -132,7 +133,7 @@ func TestCookieSessionStore_CreateCanChunkCookies(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -146,7 +147,7 @@ func TestCookieSessionStore_CreateCanChunkCookies(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 
-	err = store.Create(rec, sess)
+	err = store.Create(rec, httptest.NewRequest(http.MethodGet, "/", nil), sess)
 	require.NoError(t, err)
 
 	want := []string{
@@ -170,7 +171,7 @@ func TestCookieSessionStore_Update(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -194,7 +195,7 @@ func TestCookieSessionStore_Get(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 	req.AddCookie(&http.Cookie{
@@ -213,7 +214,7 @@ func TestCookieSessionStore_GetHandlesChunkedCookies(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 	req.AddCookie(&http.Cookie{
@@ -236,11 +237,50 @@ func TestCookieSessionStore_GetHandlesChunkedCookies(t *testing.T) {
 	assert.Equal(t, "test2", sess.IDToken)
 }
 
+func TestCookieSessionStore_GetWithRotatedKeys(t *testing.T) {
+	previous, err := aes.NewCipher([]byte("secret1234567890"))
+	require.NoError(t, err)
+
+	primary, err := aes.NewCipher([]byte("secret0987654321"))
+	require.NoError(t, err)
+
+	store := NewCookieSessionStore("test-name", []cipher.Block{primary, previous}, &AuthSession{}, RandrMock{}, 200)
+
+	// The session was encrypted with the previous key.
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	req.AddCookie(&http.Cookie{
+		Name:  "test-name",
+		Value: "AQEBAQEBAQEBAQEBAQEBAQPCeonj6H8bgW-y-xdlkLmaN-_ouVkUUzkkP0fZQDzye_iK2BBiaG6t",
+	})
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "test1", sess.AccessToken)
+	assert.Equal(t, "test2", sess.IDToken)
+
+	// Updated sessions are encrypted with the primary key only.
+	rec := httptest.NewRecorder()
+	require.NoError(t, store.Update(rec, req, *sess))
+
+	req = httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	primaryOnly := NewCookieSessionStore("test-name", []cipher.Block{primary}, &AuthSession{}, RandrMock{}, 200)
+
+	sess, err = primaryOnly.Get(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "test1", sess.AccessToken)
+}
+
 func TestCookieSessionStore_GetReturnsNilIfNoSessionExists(t *testing.T) {
 	block, err := aes.NewCipher([]byte("secret1234567890"))
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", []cipher.Block{block}, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 
@@ -0,0 +1,227 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rs/zerolog/log"
+)
+
+// backChannelLogoutEvent is the member the events claim of logout tokens must hold.
+// See https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutToken holds the claims of a back-channel logout token.
+type logoutToken struct {
+	Subject   string                 `json:"sub"`
+	SessionID string                 `json:"sid"`
+	Nonce     string                 `json:"nonce"`
+	Events    map[string]interface{} `json:"events"`
+}
+
+// ServeBackChannelLogout implements the OpenID Connect Back-Channel Logout endpoint: it verifies the logout token
+// POSTed by the provider and revokes the sessions it designates.
+// See https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (h *Handler) ServeBackChannelLogout(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "OIDC").Str("handler_name", h.name).Logger()
+
+	rw.Header().Set("Cache-Control", "no-store")
+
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := h.verifyLogoutToken(req.Context(), req.PostFormValue("logout_token"))
+	if err != nil {
+		logger.Debug().Err(err).Msg("Invalid logout token")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = h.revocations.revoke(req.Context(), token.Subject, token.SessionID); err != nil {
+		logger.Error().Err(err).Msg("Unable to revoke sessions")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debug().Str("sub", token.Subject).Str("sid", token.SessionID).Msg("Sessions revoked")
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// verifyLogoutToken verifies the given logout token.
+// spec: Back-Channel Logout section 2.6.
+func (h *Handler) verifyLogoutToken(ctx context.Context, raw string) (*logoutToken, error) {
+	if raw == "" {
+		return nil, errors.New("missing logout token")
+	}
+
+	idToken, err := h.logoutVerifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var token logoutToken
+	if err = idToken.Claims(&token); err != nil {
+		return nil, fmt.Errorf("unmarshal claims: %w", err)
+	}
+
+	if _, ok := token.Events[backChannelLogoutEvent]; !ok {
+		return nil, errors.New("missing back-channel logout event")
+	}
+
+	if token.Nonce != "" {
+		return nil, errors.New("logout tokens must not hold a nonce")
+	}
+
+	if token.Subject == "" && token.SessionID == "" {
+		return nil, errors.New("missing sub and sid claims")
+	}
+
+	return &token, nil
+}
+
+// revocations keeps track of sessions revoked through back-channel logout, by subject and by provider session ID.
+// Refreshed sessions may otherwise be kept forever, so sessions older than revocations are kept for are rejected.
+type revocations struct {
+	name    string
+	backend SessionBackend
+	ttl     time.Duration
+
+	now func() time.Time
+}
+
+func newRevocations(name string, backend SessionBackend, ttl time.Duration) *revocations {
+	return &revocations{
+		name:    name,
+		backend: backend,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// revoke revokes the sessions of the given subject created so far, and the sessions bound to the given provider
+// session ID. Revocations are kept for the maximum age of sessions, so that sessions can't outlive them.
+func (r *revocations) revoke(ctx context.Context, sub, sid string) error {
+	revokedAt := []byte(strconv.FormatInt(r.now().Unix(), 10))
+
+	if sub != "" {
+		if err := r.backend.Set(ctx, r.key("sub", sub), revokedAt, r.ttl); err != nil {
+			return fmt.Errorf("revoke subject: %w", err)
+		}
+	}
+
+	if sid != "" {
+		if err := r.backend.Set(ctx, r.key("sid", sid), revokedAt, r.ttl); err != nil {
+			return fmt.Errorf("revoke session ID: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// isRevoked returns whether the given session has been revoked.
+func (r *revocations) isRevoked(ctx context.Context, sess *SessionData) (bool, error) {
+	if sess.SessionID != "" {
+		revokedAt, err := r.backend.Get(ctx, r.key("sid", sess.SessionID))
+		if err != nil {
+			return false, err
+		}
+		if revokedAt != nil {
+			return true, nil
+		}
+	}
+
+	if sess.Subject == "" {
+		return false, nil
+	}
+
+	value, err := r.backend.Get(ctx, r.key("sub", sess.Subject))
+	if err != nil || value == nil {
+		return false, err
+	}
+
+	revokedAt, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parse revocation time: %w", err)
+	}
+
+	// Sessions created after the revocation, once the user logged in again, are valid.
+	return sess.CreatedAt <= revokedAt, nil
+}
+
+// outlived returns whether the given session is older than revocations are kept for. Sessions created before their
+// creation time was recorded are not bounded.
+func (r *revocations) outlived(sess *SessionData) bool {
+	if sess.CreatedAt == 0 {
+		return false
+	}
+
+	return !r.now().Before(time.Unix(sess.CreatedAt, 0).Add(r.ttl))
+}
+
+func (r *revocations) key(kind, value string) string {
+	sum := sha256.Sum256([]byte(r.name + "\x00revoked-" + kind + "\x00" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// endSessionEndpoint returns the RP-initiated logout endpoint advertised by the provider in its discovery document.
+// See https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata
+func endSessionEndpoint(provider *oidc.Provider) string {
+	var discovery struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return ""
+	}
+
+	return discovery.EndSessionEndpoint
+}
+
+// endSessionURL returns the URL users are redirected to in order to log out from the provider.
+// spec: RP-Initiated Logout section 2.
+func (h *Handler) endSessionURL(req *http.Request, sess *SessionData) (string, error) {
+	u, err := url.Parse(h.endSessionEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse end session endpoint: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("client_id", h.cfg.ClientID)
+	if sess != nil && sess.IDToken != "" {
+		query.Set("id_token_hint", sess.IDToken)
+	}
+	if h.cfg.PostLogoutRedirectURL != "" {
+		query.Set("post_logout_redirect_uri", resolveURL(req, h.cfg.PostLogoutRedirectURL))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
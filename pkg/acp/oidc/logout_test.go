@@ -0,0 +1,302 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_ServeBackChannelLogout(t *testing.T) {
+	events := map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}}
+
+	tests := []struct {
+		desc       string
+		method     string
+		claims     map[string]interface{}
+		wantCode   int
+		wantRevoke []string
+	}{
+		{
+			desc:       "revoke subject",
+			method:     http.MethodPost,
+			claims:     map[string]interface{}{"aud": "client-12345", "sub": "alice", "events": events},
+			wantCode:   http.StatusOK,
+			wantRevoke: []string{"sub"},
+		},
+		{
+			desc:       "revoke subject and session ID",
+			method:     http.MethodPost,
+			claims:     map[string]interface{}{"aud": "client-12345", "sub": "alice", "sid": "session", "events": events},
+			wantCode:   http.StatusOK,
+			wantRevoke: []string{"sub", "sid"},
+		},
+		{
+			desc:     "missing event",
+			method:   http.MethodPost,
+			claims:   map[string]interface{}{"aud": "client-12345", "sub": "alice"},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "nonce",
+			method:   http.MethodPost,
+			claims:   map[string]interface{}{"aud": "client-12345", "sub": "alice", "nonce": "nonce", "events": events},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "missing sub and sid",
+			method:   http.MethodPost,
+			claims:   map[string]interface{}{"aud": "client-12345", "events": events},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "invalid audience",
+			method:   http.MethodPost,
+			claims:   map[string]interface{}{"aud": "other", "sub": "alice", "events": events},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "missing token",
+			method:   http.MethodPost,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "unsupported method",
+			method:   http.MethodGet,
+			claims:   map[string]interface{}{"aud": "client-12345", "sub": "alice", "events": events},
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			backend := NewMemorySessionBackend()
+
+			handler := buildHandler(t)
+			handler.logoutVerifier = handler.verifier
+			handler.revocations = newRevocations("test", backend, time.Hour)
+
+			form := url.Values{}
+			if test.claims != nil {
+				form.Set("logout_token", newToken(t, test.claims))
+			}
+
+			req := httptest.NewRequest(test.method, "http://auth.example.com/test/backchannel-logout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rw := httptest.NewRecorder()
+
+			handler.ServeBackChannelLogout(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+			assert.Len(t, backend.sessions, len(test.wantRevoke))
+			for _, kind := range test.wantRevoke {
+				value, _ := test.claims[kind].(string)
+				assert.Contains(t, backend.sessions, handler.revocations.key(kind, value))
+			}
+		})
+	}
+}
+
+func TestRevocations_isRevoked(t *testing.T) {
+	now := time.Unix(1672531200, 0)
+
+	revs := newRevocations("test", NewMemorySessionBackend(), time.Hour)
+	revs.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, revs.revoke(ctx, "alice", ""))
+	require.NoError(t, revs.revoke(ctx, "", "session"))
+
+	tests := []struct {
+		desc string
+		sess SessionData
+		want bool
+	}{
+		{
+			desc: "subject revoked after session creation",
+			sess: SessionData{Subject: "alice", CreatedAt: now.Add(-time.Minute).Unix()},
+			want: true,
+		},
+		{
+			desc: "subject revoked before session creation",
+			sess: SessionData{Subject: "alice", CreatedAt: now.Add(time.Minute).Unix()},
+		},
+		{
+			desc: "session ID revoked",
+			sess: SessionData{Subject: "bob", SessionID: "session", CreatedAt: now.Add(time.Minute).Unix()},
+			want: true,
+		},
+		{
+			desc: "not revoked",
+			sess: SessionData{Subject: "bob", SessionID: "other"},
+		},
+		{
+			desc: "session without identity",
+			sess: SessionData{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			revoked, err := revs.isRevoked(ctx, &test.sess)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, revoked)
+		})
+	}
+}
+
+func TestRevocations_outlived(t *testing.T) {
+	now := time.Unix(1672531200, 0)
+
+	revs := newRevocations("test", NewMemorySessionBackend(), revocationTTL(&AuthSession{}))
+	revs.now = func() time.Time { return now }
+
+	tests := []struct {
+		desc string
+		sess SessionData
+		want bool
+	}{
+		{
+			desc: "session refreshed beyond its TTL",
+			sess: SessionData{Subject: "alice", CreatedAt: now.Add(-2 * defaultSessionTTL).Unix()},
+		},
+		{
+			desc: "session older than revocations",
+			sess: SessionData{Subject: "alice", CreatedAt: now.Add(-minSessionMaxAge).Unix()},
+			want: true,
+		},
+		{
+			desc: "session without creation time",
+			sess: SessionData{Subject: "alice"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, revs.outlived(&test.sess))
+		})
+	}
+}
+
+func TestMiddleware_RedirectsRevokedSessions(t *testing.T) {
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(&SessionData{Subject: "alice", CreatedAt: 1}, nil).Once().
+		OnDeleteRaw(mock.Anything, mock.Anything).TypedReturns(nil).Once().
+		Parent
+
+	oauth := newOAuthProviderMock(t).
+		OnAuthCodeURLRaw(mock.Anything, mock.Anything).TypedReturns("https://idp.example.com/authorize").Once().
+		Parent
+
+	handler := buildHandler(t)
+	handler.cfg = &Config{
+		RedirectURL: "/callback",
+		StateCookie: &AuthStateCookie{},
+		Session:     &AuthSession{Refresh: ptrBool(false)},
+	}
+	handler.session = session
+	handler.oauth = oauth
+
+	require.NoError(t, handler.revocations.revoke(context.Background(), "alice", ""))
+
+	req := httptest.NewRequest(http.MethodGet, "http://app.example.com/foo", nil)
+	req.Header.Set("X-Forwarded-Method", req.Method)
+	req.Header.Set("X-Forwarded-Proto", "http")
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	req.Header.Set("X-Forwarded-URI", req.URL.RequestURI())
+	req.Header.Set("Accept", "text/html")
+	rw := httptest.NewRecorder()
+
+	handler.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "https://idp.example.com/authorize", rw.Header().Get("Location"))
+}
+
+func TestMiddleware_LogsOutFromProvider(t *testing.T) {
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(&SessionData{IDToken: "id-token"}, nil).Once().
+		OnDeleteRaw(mock.Anything, mock.Anything).TypedReturns(nil).Once().
+		Parent
+
+	handler := buildHandler(t)
+	handler.cfg = &Config{
+		ClientID:              "client-12345",
+		LogoutURL:             "/logout",
+		PostLogoutRedirectURL: "/bye",
+	}
+	handler.session = session
+	handler.endSessionEndpoint = "https://idp.example.com/logout?foo=bar"
+
+	req := httptest.NewRequest(http.MethodGet, "http://app.example.com/logout", nil)
+	req.Header.Set("X-Forwarded-Method", req.Method)
+	req.Header.Set("X-Forwarded-Proto", "http")
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	req.Header.Set("X-Forwarded-URI", req.URL.RequestURI())
+	rw := httptest.NewRecorder()
+
+	handler.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	location, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", location.Host)
+	assert.Equal(t, "/logout", location.Path)
+	assert.Equal(t, url.Values{
+		"foo":                      {"bar"},
+		"client_id":                {"client-12345"},
+		"id_token_hint":            {"id-token"},
+		"post_logout_redirect_uri": {"http://app.example.com/bye"},
+	}, location.Query())
+}
+
+// newToken returns an ES256 JWT holding the given claims. Its signature isn't valid, tests verify tokens using a key
+// set accepting any signature.
+func newToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	parts := strings.Split(jwtToken, ".")
+
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+}
@@ -366,11 +366,11 @@ func newSessionStoreMock(tb testing.TB) *sessionStoreMock {
 	return m
 }
 
-func (_m *sessionStoreMock) Create(aParam http.ResponseWriter, bParam SessionData) error {
-	_ret := _m.Called(aParam, bParam)
+func (_m *sessionStoreMock) Create(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) error {
+	_ret := _m.Called(aParam, bParam, cParam)
 
-	if _rf, ok := _ret.Get(0).(func(http.ResponseWriter, SessionData) error); ok {
-		return _rf(aParam, bParam)
+	if _rf, ok := _ret.Get(0).(func(http.ResponseWriter, *http.Request, SessionData) error); ok {
+		return _rf(aParam, bParam, cParam)
 	}
 
 	_ra0 := _ret.Error(0)
@@ -378,12 +378,12 @@ func (_m *sessionStoreMock) Create(aParam http.ResponseWriter, bParam SessionDat
 	return _ra0
 }
 
-func (_m *sessionStoreMock) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return &sessionStoreCreateCall{Call: _m.Mock.On("Create", aParam, bParam), Parent: _m}
+func (_m *sessionStoreMock) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return &sessionStoreCreateCall{Call: _m.Mock.On("Create", aParam, bParam, cParam), Parent: _m}
 }
 
-func (_m *sessionStoreMock) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return &sessionStoreCreateCall{Call: _m.Mock.On("Create", aParam, bParam), Parent: _m}
+func (_m *sessionStoreMock) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return &sessionStoreCreateCall{Call: _m.Mock.On("Create", aParam, bParam, cParam), Parent: _m}
 }
 
 type sessionStoreCreateCall struct {
@@ -436,22 +436,23 @@ func (_c *sessionStoreCreateCall) TypedReturns(a error) *sessionStoreCreateCall
 	return _c
 }
 
-func (_c *sessionStoreCreateCall) ReturnsFn(fn func(http.ResponseWriter, SessionData) error) *sessionStoreCreateCall {
+func (_c *sessionStoreCreateCall) ReturnsFn(fn func(http.ResponseWriter, *http.Request, SessionData) error) *sessionStoreCreateCall {
 	_c.Call = _c.Return(fn)
 	return _c
 }
 
-func (_c *sessionStoreCreateCall) TypedRun(fn func(http.ResponseWriter, SessionData)) *sessionStoreCreateCall {
+func (_c *sessionStoreCreateCall) TypedRun(fn func(http.ResponseWriter, *http.Request, SessionData)) *sessionStoreCreateCall {
 	_c.Call = _c.Call.Run(func(args mock.Arguments) {
 		_aParam, _ := args.Get(0).(http.ResponseWriter)
-		_bParam, _ := args.Get(1).(SessionData)
-		fn(_aParam, _bParam)
+		_bParam, _ := args.Get(1).(*http.Request)
+		_cParam, _ := args.Get(2).(SessionData)
+		fn(_aParam, _bParam, _cParam)
 	})
 	return _c
 }
 
-func (_c *sessionStoreCreateCall) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return _c.Parent.OnCreate(aParam, bParam)
+func (_c *sessionStoreCreateCall) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return _c.Parent.OnCreate(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreCreateCall) OnDelete(aParam http.ResponseWriter, bParam *http.Request) *sessionStoreDeleteCall {
@@ -470,8 +471,8 @@ func (_c *sessionStoreCreateCall) OnUpdate(aParam http.ResponseWriter, bParam *h
 	return _c.Parent.OnUpdate(aParam, bParam, cParam)
 }
 
-func (_c *sessionStoreCreateCall) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return _c.Parent.OnCreateRaw(aParam, bParam)
+func (_c *sessionStoreCreateCall) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return _c.Parent.OnCreateRaw(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreCreateCall) OnDeleteRaw(aParam interface{}, bParam interface{}) *sessionStoreDeleteCall {
@@ -574,8 +575,8 @@ func (_c *sessionStoreDeleteCall) TypedRun(fn func(http.ResponseWriter, *http.Re
 	return _c
 }
 
-func (_c *sessionStoreDeleteCall) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return _c.Parent.OnCreate(aParam, bParam)
+func (_c *sessionStoreDeleteCall) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return _c.Parent.OnCreate(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreDeleteCall) OnDelete(aParam http.ResponseWriter, bParam *http.Request) *sessionStoreDeleteCall {
@@ -594,8 +595,8 @@ func (_c *sessionStoreDeleteCall) OnUpdate(aParam http.ResponseWriter, bParam *h
 	return _c.Parent.OnUpdate(aParam, bParam, cParam)
 }
 
-func (_c *sessionStoreDeleteCall) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return _c.Parent.OnCreateRaw(aParam, bParam)
+func (_c *sessionStoreDeleteCall) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return _c.Parent.OnCreateRaw(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreDeleteCall) OnDeleteRaw(aParam interface{}, bParam interface{}) *sessionStoreDeleteCall {
@@ -698,8 +699,8 @@ func (_c *sessionStoreGetCall) TypedRun(fn func(*http.Request)) *sessionStoreGet
 	return _c
 }
 
-func (_c *sessionStoreGetCall) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return _c.Parent.OnCreate(aParam, bParam)
+func (_c *sessionStoreGetCall) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return _c.Parent.OnCreate(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreGetCall) OnDelete(aParam http.ResponseWriter, bParam *http.Request) *sessionStoreDeleteCall {
@@ -718,8 +719,8 @@ func (_c *sessionStoreGetCall) OnUpdate(aParam http.ResponseWriter, bParam *http
 	return _c.Parent.OnUpdate(aParam, bParam, cParam)
 }
 
-func (_c *sessionStoreGetCall) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return _c.Parent.OnCreateRaw(aParam, bParam)
+func (_c *sessionStoreGetCall) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return _c.Parent.OnCreateRaw(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreGetCall) OnDeleteRaw(aParam interface{}, bParam interface{}) *sessionStoreDeleteCall {
@@ -804,8 +805,8 @@ func (_c *sessionStoreRemoveCookieCall) TypedRun(fn func(http.ResponseWriter, *h
 	return _c
 }
 
-func (_c *sessionStoreRemoveCookieCall) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return _c.Parent.OnCreate(aParam, bParam)
+func (_c *sessionStoreRemoveCookieCall) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return _c.Parent.OnCreate(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreRemoveCookieCall) OnDelete(aParam http.ResponseWriter, bParam *http.Request) *sessionStoreDeleteCall {
@@ -824,8 +825,8 @@ func (_c *sessionStoreRemoveCookieCall) OnUpdate(aParam http.ResponseWriter, bPa
 	return _c.Parent.OnUpdate(aParam, bParam, cParam)
 }
 
-func (_c *sessionStoreRemoveCookieCall) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return _c.Parent.OnCreateRaw(aParam, bParam)
+func (_c *sessionStoreRemoveCookieCall) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return _c.Parent.OnCreateRaw(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreRemoveCookieCall) OnDeleteRaw(aParam interface{}, bParam interface{}) *sessionStoreDeleteCall {
@@ -929,8 +930,8 @@ func (_c *sessionStoreUpdateCall) TypedRun(fn func(http.ResponseWriter, *http.Re
 	return _c
 }
 
-func (_c *sessionStoreUpdateCall) OnCreate(aParam http.ResponseWriter, bParam SessionData) *sessionStoreCreateCall {
-	return _c.Parent.OnCreate(aParam, bParam)
+func (_c *sessionStoreUpdateCall) OnCreate(aParam http.ResponseWriter, bParam *http.Request, cParam SessionData) *sessionStoreCreateCall {
+	return _c.Parent.OnCreate(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreUpdateCall) OnDelete(aParam http.ResponseWriter, bParam *http.Request) *sessionStoreDeleteCall {
@@ -949,8 +950,8 @@ func (_c *sessionStoreUpdateCall) OnUpdate(aParam http.ResponseWriter, bParam *h
 	return _c.Parent.OnUpdate(aParam, bParam, cParam)
 }
 
-func (_c *sessionStoreUpdateCall) OnCreateRaw(aParam interface{}, bParam interface{}) *sessionStoreCreateCall {
-	return _c.Parent.OnCreateRaw(aParam, bParam)
+func (_c *sessionStoreUpdateCall) OnCreateRaw(aParam interface{}, bParam interface{}, cParam interface{}) *sessionStoreCreateCall {
+	return _c.Parent.OnCreateRaw(aParam, bParam, cParam)
 }
 
 func (_c *sessionStoreUpdateCall) OnDeleteRaw(aParam interface{}, bParam interface{}) *sessionStoreDeleteCall {
@@ -19,9 +19,9 @@ package oidc
 
 import (
 	"context"
-	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -34,7 +34,10 @@ import (
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/identity"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/rules"
 	"golang.org/x/oauth2"
 )
 
@@ -75,6 +78,16 @@ type SessionData struct {
 
 	// Expiry is the expiration time of the access token.
 	Expiry time.Time
+
+	// Subject and SessionID are the `sub` and `sid` claims of the ID token the session was created with. They are used
+	// to revoke sessions on back-channel logout.
+	Subject   string `json:",omitempty"`
+	SessionID string `json:",omitempty"`
+	// CreatedAt is the Unix time the session was created at.
+	CreatedAt int64 `json:",omitempty"`
+
+	// UserInfo holds the claims fetched from the UserInfo endpoint, if enabled.
+	UserInfo map[string]interface{} `json:",omitempty"`
 }
 
 // IsExpired determines if the current access token is expired.
@@ -94,7 +107,7 @@ func (d SessionData) ToToken() *oauth2.Token {
 
 // SessionStore represents a type that can manage a session for a given request.
 type SessionStore interface {
-	Create(http.ResponseWriter, SessionData) error
+	Create(http.ResponseWriter, *http.Request, SessionData) error
 	Update(http.ResponseWriter, *http.Request, SessionData) error
 	Delete(http.ResponseWriter, *http.Request) error
 	Get(*http.Request) (*SessionData, error)
@@ -109,17 +122,31 @@ type Handler struct {
 	verifier IDTokenVerifier
 	oauth    OAuthProvider
 	session  SessionStore
-	block    cipher.Block
+	blocks   []cipher.Block
+	userInfo UserInfoFetcher
+
+	// logoutVerifier verifies back-channel logout tokens.
+	logoutVerifier IDTokenVerifier
+	revocations    *revocations
+	// endSessionEndpoint is the provider RP-initiated logout endpoint, if any.
+	endSessionEndpoint string
 
 	validateClaims expr.Predicate
+	rules          rules.Rules
+
+	// pkce enables Proof Key for Code Exchange.
+	pkce bool
 
 	client *http.Client
 
 	cfg *Config
+
+	now func() time.Time
 }
 
-// NewHandler creates a new instance of a Handler from an auth source.
-func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error) {
+// NewHandler creates a new instance of a Handler from an auth source. The given backends store sessions when using a
+// server-side session store, and session revocations.
+func NewHandler(ctx context.Context, cfg *Config, name string, backends SessionBackends) (*Handler, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validate configuration: %w", err)
 	}
@@ -132,18 +159,49 @@ func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error)
 	}
 
 	var pred expr.Predicate
-	if cfg.Claims != "" {
-		pred, err = expr.Parse(cfg.Claims)
+	if claims := cfg.claimsExpression(); claims != "" {
+		pred, err = expr.Parse(claims)
 		if err != nil {
 			return nil, fmt.Errorf("unable to make predicate: %w", err)
 		}
 	}
 
-	block, err := aes.NewCipher([]byte(cfg.Key))
+	rs, err := rules.New(cfg.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("compile rules: %w", err)
+	}
+
+	// Cookies are encrypted with the primary key, and decrypted with any key so that keys can be rotated.
+	blocks, err := newBlocks(append([]string{cfg.Key}, cfg.DecryptionKeys...)...)
 	if err != nil {
 		return nil, fmt.Errorf("new cipher: %w", err)
 	}
 
+	var session SessionStore
+	switch cfg.Session.Store {
+	case SessionStoreMemory, SessionStoreSecret:
+		backend := backends[cfg.Session.Store]
+		if backend == nil {
+			return nil, fmt.Errorf("session store %q is not available", cfg.Session.Store)
+		}
+		session = NewServerSessionStore(name+"-session", blocks, cfg.Session, newRandom(), backend)
+	default:
+		session = NewCookieSessionStore(name+"-session", blocks, cfg.Session, newRandom(), maxCookieSize)
+	}
+
+	// Revocations are kept in Secrets whatever the session store, so that they are shared between replicas and survive
+	// restarts. They are only kept in memory when Secrets aren't available.
+	revocationBackend := backends[SessionStoreSecret]
+	if revocationBackend == nil {
+		revocationBackend = backends[SessionStoreMemory]
+	}
+	if revocationBackend == nil {
+		revocationBackend = NewMemorySessionBackend()
+	}
+
+	// Logout tokens may not hold an expiration time.
+	logoutVerifier := provider.Verifier(&oidc.Config{ClientID: cfg.ClientID, SkipExpiryCheck: true})
+
 	return &Handler{
 		name:     name,
 		cfg:      cfg,
@@ -154,14 +212,59 @@ func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error)
 			Endpoint:     provider.Endpoint(),
 			Scopes:       cfg.Scopes,
 		},
-		rand:           newRandom(),
-		session:        NewCookieSessionStore(name+"-session", block, cfg.Session, newRandom(), maxCookieSize),
-		block:          block,
-		validateClaims: pred,
-		client:         client,
+		rand:               newRandom(),
+		session:            session,
+		blocks:             blocks,
+		userInfo:           newUserInfoFetcher(provider, client),
+		logoutVerifier:     logoutVerifier,
+		revocations:        newRevocations(name, revocationBackend, revocationTTL(cfg.Session)),
+		endSessionEndpoint: endSessionEndpoint(provider),
+		validateClaims:     pred,
+		rules:              rs,
+		pkce:               usePKCE(provider, cfg.PKCE),
+		client:             client,
+		now:                time.Now,
 	}, nil
 }
 
+// CheckDiscovery makes sure the discovery document of the provider configured by the given configuration can be
+// fetched, the same way the handler does.
+func (cfg *Config) CheckDiscovery(ctx context.Context) error {
+	issuer := cfg.Issuer
+	if issuer == "" && cfg.Provider != nil {
+		issuer = cfg.Provider.issuer()
+	}
+
+	if _, err := oidc.NewProvider(oidc.ClientContext(ctx, newHTTPClient()), issuer); err != nil {
+		return fmt.Errorf("unable to create provider: %w", err)
+	}
+
+	return nil
+}
+
+// usePKCE returns whether PKCE must be used. Unless explicitly configured, PKCE is used if the provider advertises
+// support for S256 code challenges in its discovery document.
+func usePKCE(provider *oidc.Provider, enabled *bool) bool {
+	if enabled != nil {
+		return *enabled
+	}
+
+	var discovery struct {
+		CodeChallengeMethods []string `json:"code_challenge_methods_supported"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return false
+	}
+
+	for _, method := range discovery.CodeChallengeMethods {
+		if method == "S256" {
+			return true
+		}
+	}
+
+	return false
+}
+
 // The implementation below should be compliant with the Authorization Code Flow
 // of the specification at
 // https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth , which is
@@ -188,19 +291,66 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			logger.Debug().Err(err).Msg("Unable to delete the session")
 		}
 
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonLogout)
 		rw.WriteHeader(http.StatusNoContent)
 
 		return
 	}
 
+	// Users navigating to the logout URL are logged out from the provider too, if it supports RP-initiated logout.
+	if equalURL(forwardedURL, logoutURL) && forwardedMethod == http.MethodGet && h.endSessionEndpoint != "" {
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonLogout)
+		h.logout(rw, req)
+
+		return
+	}
+
+	// Rules don't apply to the provider callback, so that users can always complete their login.
+	rule := h.rules.Match(req)
+	if !equalURL(forwardedURL, resolveURL(req, h.cfg.RedirectURL)) && rule.ServeAction(rw, req) {
+		return
+	}
+
 	sess, err := h.session.Get(req)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Unable to get the session")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidCredentials)
 		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 
 		return
 	}
 
+	// Sessions older than revocations are kept for may have been revoked, users must log in again.
+	if sess != nil && h.revocations.outlived(sess) {
+		logger.Debug().Msg("Session outlived revocations")
+
+		if err = h.session.Delete(rw, req); err != nil {
+			logger.Debug().Err(err).Msg("Unable to delete the session")
+		}
+		sess = nil
+	}
+
+	if sess != nil {
+		var revoked bool
+		revoked, err = h.revocations.isRevoked(req.Context(), sess)
+		if err != nil {
+			logger.Error().Err(err).Msg("Unable to check whether the session is revoked")
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		if revoked {
+			logger.Debug().Msg("Session revoked by the provider")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonSessionRevoked)
+
+			if err = h.session.Delete(rw, req); err != nil {
+				logger.Debug().Err(err).Msg("Unable to delete the session")
+			}
+			sess = nil
+		}
+	}
+
 	// We get in here either because we're in the initial run (no session yet),
 	// or if we have an expired session, but session refreshing is disabled by
 	// configuration. For the gritty details, it means we don't need to refresh tokens (so
@@ -211,6 +361,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 		if equalURL(forwardedURL, redirectURL) {
 			logger.Debug().Msg("Handle provider callback")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonCallback)
 			// 5th step of the diagram, we're handling the redirected response from the auth server.
 			// spec: receiving response of section 3.1.2.5
 			h.handleProviderCallback(rw, req, redirectURL)
@@ -218,6 +369,10 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		if sess == nil {
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonNoSession)
+		}
+
 		if !h.shouldRedirect(req) {
 			logger.Debug().Msg("Received a request that should not be redirected")
 			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
@@ -235,6 +390,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	sess, refreshSession, err = h.maybeRefreshSession(req.Context(), sess)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Unable to refresh the session")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonRefreshFailed)
 
 		if err = h.session.Delete(rw, req); err != nil {
 			logger.Debug().Err(err).Msg("Unable to delete the session")
@@ -257,6 +413,8 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	// Refresh the session is possible only if we can return a redirect to the user.
 	// If we can't, we check the token and continue without update the session user.
 	if refreshSession && h.shouldRedirect(req) {
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonSessionRefreshed)
+
 		if err = h.session.Update(rw, req, *sess); err != nil {
 			logger.Debug().Err(err).Msg("Unable to refresh the session")
 			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -281,6 +439,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	idToken, err = h.verifier.Verify(req.Context(), sess.IDToken)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Invalid ID token")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonInvalidToken)
 		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 
 		return
@@ -294,8 +453,30 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	claims = h.mergeClaims(claims, sess.UserInfo)
+
+	if h.cfg.Provider != nil {
+		claims, err = h.cfg.Provider.normalizeGroups(claims)
+		if err != nil {
+			logger.Debug().Err(err).Msg("Unable to normalize groups")
+			authmetrics.SetReason(req.Context(), authmetrics.ReasonGroupsMismatch)
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+	}
+
 	if h.validateClaims != nil && !h.validateClaims(claims) {
 		logger.Debug().Err(err).Msg("Unauthorized claim")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	if !rule.AllowClaims(claims) {
+		logger.Debug().Msg("Claims don't satisfy the matching rule")
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonClaimsMismatch)
 		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 
 		return
@@ -308,6 +489,8 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	identity.RecordClaims(req.Context(), claims)
+
 	// 10th step of diagram.
 	rw.Header().Set("Authorization", "Bearer "+sess.AccessToken)
 	h.session.RemoveCookie(rw, req)
@@ -341,6 +524,7 @@ func (h *Handler) maybeRefreshSession(ctx context.Context, sess *SessionData) (s
 	// spec: section 12.
 	ts := h.oauth.TokenSource(ctx, sess.ToToken())
 	tok, err := ts.Token()
+	authmetrics.ObserveTokenRefresh(h.name, err)
 	if err != nil {
 		return nil, false, err
 	}
@@ -356,7 +540,16 @@ func (h *Handler) maybeRefreshSession(ctx context.Context, sess *SessionData) (s
 		RefreshToken: tok.RefreshToken,
 		IDToken:      rawIDToken,
 		Expiry:       tok.Expiry,
+		Subject:      sess.Subject,
+		SessionID:    sess.SessionID,
+		CreatedAt:    sess.CreatedAt,
+	}
+
+	sess.UserInfo, err = h.fetchUserInfo(ctx, tok, sess.Subject)
+	if err != nil {
+		return nil, false, err
 	}
+
 	return sess, true, nil
 }
 
@@ -372,6 +565,11 @@ func (h *Handler) redirectToProvider(rw http.ResponseWriter, req *http.Request,
 		OriginURL:  originalURL,
 	}
 
+	if h.pkce {
+		// spec: RFC 7636 section 4.1.
+		state.CodeVerifier = h.rand.String(64)
+	}
+
 	stateCookie, err := h.newStateCookie(state)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Unable to create state cookie")
@@ -388,6 +586,14 @@ func (h *Handler) redirectToProvider(rw http.ResponseWriter, req *http.Request,
 		oidc.Nonce(state.Nonce),
 	}
 
+	if state.CodeVerifier != "" {
+		// spec: RFC 7636 section 4.3.
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", codeChallenge(state.CodeVerifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
 	if *h.cfg.Session.Refresh {
 		// We want a refresh token in the response, which requires AccessTypeOffline,
 		// which in turn requires consent prompt.
@@ -450,6 +656,11 @@ func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Reque
 		oauth2.SetAuthURLParam("redirect_uri", redirectURL),
 	}
 
+	if state.CodeVerifier != "" {
+		// spec: RFC 7636 section 4.5.
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", state.CodeVerifier))
+	}
+
 	// 6th and 7th step of diagram.
 	// spec: section 3.1.3.1.
 	oauth2Token, err := h.oauth.Exchange(
@@ -487,6 +698,15 @@ func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	var sid struct {
+		SessionID string `json:"sid"`
+	}
+	if err = idToken.Claims(&sid); err != nil {
+		logger.Debug().Err(err).Msg("Unable to unmarshal claims")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
 	// 8th step of diagram.
 	sess := &SessionData{
 		AccessToken:  oauth2Token.AccessToken,
@@ -494,8 +714,19 @@ func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Reque
 		RefreshToken: oauth2Token.RefreshToken,
 		IDToken:      rawIDToken,
 		Expiry:       oauth2Token.Expiry,
+		Subject:      idToken.Subject,
+		SessionID:    sid.SessionID,
+		CreatedAt:    h.now().Unix(),
 	}
-	if err = h.session.Create(rw, *sess); err != nil {
+
+	sess.UserInfo, err = h.fetchUserInfo(req.Context(), oauth2Token, idToken.Subject)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Unable to fetch UserInfo claims")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err = h.session.Create(rw, req, *sess); err != nil {
 		logger.Debug().Err(err).Msg("Unable to create session")
 		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -506,6 +737,41 @@ func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Reque
 	http.Redirect(rw, req, state.OriginURL, http.StatusFound)
 }
 
+// logout deletes the session and redirects the user to the provider end session endpoint.
+func (h *Handler) logout(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "OIDC").Str("handler_name", h.name).Logger()
+
+	sess, err := h.session.Get(req)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Unable to get the session")
+	}
+
+	if err = h.session.Delete(rw, req); err != nil {
+		logger.Debug().Err(err).Msg("Unable to delete the session")
+	}
+
+	endSessionURL, err := h.endSessionURL(req, sess)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to build end session URL")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Header.Get("From") == "nginx" {
+		rw.Header().Add("url_redirect", endSessionURL)
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	http.Redirect(rw, req, endSessionURL, http.StatusFound)
+}
+
+// codeChallenge returns the S256 code challenge of the given code verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 func (h *Handler) getStateCookie(r *http.Request) (*StateData, error) {
 	stateCookie, ok := getCookie(r, h.name+"-state")
 	if !ok {
@@ -518,13 +784,7 @@ func (h *Handler) getStateCookie(r *http.Request) (*StateData, error) {
 		return nil, fmt.Errorf("decode state: %w", err)
 	}
 
-	blockSize := h.block.BlockSize()
-	decrypted := make([]byte, len(decoded)-blockSize)
-	iv := decoded[:blockSize]
-	stream := cipher.NewCTR(h.block, iv)
-	stream.XORKeyStream(decrypted, decoded[blockSize:])
-
-	if err := json.Unmarshal(decrypted, &state); err != nil {
+	if err := decrypt(h.blocks, decoded, &state); err != nil {
 		return nil, fmt.Errorf("deserialize state: %w", err)
 	}
 	return &state, nil
@@ -536,11 +796,11 @@ func (h *Handler) newStateCookie(state StateData) (*http.Cookie, error) {
 		return nil, fmt.Errorf("serialize state: %w", err)
 	}
 
-	blockSize := h.block.BlockSize()
+	blockSize := h.blocks[0].BlockSize()
 	encrypted := make([]byte, blockSize+len(statePayload))
 	iv := h.rand.Bytes(blockSize)
 	copy(encrypted[:blockSize], iv)
-	stream := cipher.NewCTR(h.block, iv)
+	stream := cipher.NewCTR(h.blocks[0], iv)
 	stream.XORKeyStream(encrypted[blockSize:], statePayload)
 
 	return &http.Cookie{
@@ -20,6 +20,7 @@ package oidc
 import (
 	"context"
 	"crypto/aes"
+	"crypto/cipher"
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
@@ -68,7 +69,7 @@ func TestNewMiddlewareFromSource_ValidatesConfiguration(t *testing.T) {
 		test := test
 		t.Run(test.desc, func(t *testing.T) {
 			test.cfg.ApplyDefaultValues()
-			_, err := NewHandler(context.Background(), test.cfg, test.desc)
+			_, err := NewHandler(context.Background(), test.cfg, test.desc, nil)
 
 			if test.wantErr != "" {
 				assert.Error(t, err)
@@ -302,17 +303,20 @@ func TestMiddleware_ExchangesTokenOnCallback(t *testing.T) {
 		AccessToken: oauth2tok.AccessToken,
 		IDToken:     jwtToken,
 		TokenType:   oauth2tok.TokenType,
+		Subject:     "alice",
+		CreatedAt:   1672531200,
 	}
 
 	session := newSessionStoreMock(t).
 		OnGetRaw(mock.Anything).TypedReturns(nil, nil).Once().
-		OnCreateRaw(mock.Anything, wantSession).TypedReturns(nil).Once().
+		OnCreateRaw(mock.Anything, mock.Anything, wantSession).TypedReturns(nil).Once().
 		Parent
 
 	handler := buildHandler(t)
 	handler.oauth = oauth
 	handler.session = session
 	handler.cfg = &cfg
+	handler.now = func() time.Time { return time.Unix(1672531200, 0) }
 
 	state := StateData{
 		RedirectID: "aaaaa",
@@ -339,6 +343,187 @@ func TestMiddleware_ExchangesTokenOnCallback(t *testing.T) {
 	assert.Equal(t, "test-state=; Path=/; Max-Age=0", w.Header().Get("Set-Cookie"))
 }
 
+func TestMiddleware_RedirectsWithPKCE(t *testing.T) {
+	tests := []struct {
+		desc     string
+		pkce     bool
+		wantPKCE bool
+	}{
+		{
+			desc:     "PKCE enabled",
+			pkce:     true,
+			wantPKCE: true,
+		},
+		{
+			desc: "PKCE disabled",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := &Config{RedirectURL: "http://example.com/callback"}
+			cfg.ApplyDefaultValues()
+
+			session := newSessionStoreMock(t).
+				OnGetRaw(mock.Anything).TypedReturns(nil, nil).Once().
+				Parent
+
+			handler := buildHandler(t)
+			handler.oauth = &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "http://foobar.com"}}
+			handler.session = session
+			handler.cfg = cfg
+			handler.pkce = test.pkce
+
+			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req.Header.Add("X-Forwarded-Method", req.Method)
+			req.Header.Add("X-Forwarded-Proto", "http")
+			req.Header.Add("X-Forwarded-Host", "test.com")
+			req.Header.Add("X-Forwarded-URI", req.URL.RequestURI())
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusFound, w.Code)
+
+			u, err := url.Parse(w.Header().Get("location"))
+			require.NoError(t, err)
+
+			callback := httptest.NewRequest(http.MethodGet, "http://example.com/callback", nil)
+			for _, c := range w.Result().Cookies() {
+				callback.AddCookie(c)
+			}
+
+			state, err := handler.getStateCookie(callback)
+			require.NoError(t, err)
+			require.NotNil(t, state)
+
+			if !test.wantPKCE {
+				assert.Empty(t, state.CodeVerifier)
+				assert.Empty(t, u.Query().Get("code_challenge"))
+				assert.Empty(t, u.Query().Get("code_challenge_method"))
+				return
+			}
+
+			assert.Len(t, state.CodeVerifier, 64)
+			assert.Equal(t, codeChallenge(state.CodeVerifier), u.Query().Get("code_challenge"))
+			assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		})
+	}
+}
+
+func TestMiddleware_ExchangesTokenOnCallbackWithPKCE(t *testing.T) {
+	var gotVerifier string
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gotVerifier = req.PostForm.Get("code_verifier")
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"access_token":"access-token","token_type":"bearer","id_token":"` + jwtToken + `"}`))
+	}))
+	t.Cleanup(tokenSrv.Close)
+
+	cfg := Config{
+		RedirectURL: "http://foobar.com/callback",
+		Session:     &AuthSession{Refresh: boolPtr(false)},
+	}
+	cfg.ApplyDefaultValues()
+
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(nil, nil).Once().
+		OnCreateRaw(mock.Anything, mock.Anything, mock.Anything).TypedReturns(nil).Once().
+		Parent
+
+	handler := buildHandler(t)
+	handler.oauth = &oauth2.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: tokenSrv.URL},
+	}
+	handler.session = session
+	handler.cfg = &cfg
+
+	stateCookie, err := handler.newStateCookie(StateData{
+		RedirectID:   "aaaaa",
+		Nonce:        "n-0S6_WzA2Mj",
+		OriginURL:    "http://app.bar.com",
+		CodeVerifier: "verifier",
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "http://foobar.com/callback?state=aaaaa&code=code", nil)
+	r.Header.Set("X-Forwarded-Method", r.Method)
+	r.Header.Set("X-Forwarded-Proto", "http")
+	r.Header.Set("X-Forwarded-Host", r.Host)
+	r.Header.Set("X-Forwarded-URI", r.URL.RequestURI())
+	r.AddCookie(stateCookie)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "verifier", gotVerifier)
+}
+
+func TestUsePKCE(t *testing.T) {
+	tests := []struct {
+		desc      string
+		enabled   *bool
+		discovery string
+		want      bool
+	}{
+		{
+			desc:      "S256 advertised",
+			discovery: `"code_challenge_methods_supported":["plain","S256"]`,
+			want:      true,
+		},
+		{
+			desc:      "only plain advertised",
+			discovery: `"code_challenge_methods_supported":["plain"]`,
+		},
+		{
+			desc: "nothing advertised",
+		},
+		{
+			desc:      "explicitly disabled",
+			enabled:   boolPtr(false),
+			discovery: `"code_challenge_methods_supported":["S256"]`,
+		},
+		{
+			desc:    "explicitly enabled",
+			enabled: boolPtr(true),
+			want:    true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				discovery := `"issuer":"` + srv.URL + `","authorization_endpoint":"` + srv.URL + `/auth"`
+				if test.discovery != "" {
+					discovery += "," + test.discovery
+				}
+
+				rw.Header().Set("Content-Type", "application/json")
+				_, _ = rw.Write([]byte("{" + discovery + "}"))
+			}))
+			t.Cleanup(srv.Close)
+
+			provider, err := gooidc.NewProvider(context.Background(), srv.URL)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, usePKCE(provider, test.enabled))
+		})
+	}
+}
+
 func TestMiddleware_ForwardsCorrectly(t *testing.T) {
 	tests := []struct {
 		desc    string
@@ -576,11 +761,13 @@ func buildHandler(t *testing.T) *Handler {
 	require.NoError(t, err)
 
 	return &Handler{
-		name:     "test",
-		block:    stateBlock,
-		rand:     newRandom(),
-		client:   client,
-		verifier: verifier,
+		name:        "test",
+		blocks:      []cipher.Block{stateBlock},
+		rand:        newRandom(),
+		client:      client,
+		verifier:    verifier,
+		revocations: newRevocations("test", NewMemorySessionBackend(), time.Hour),
+		now:         time.Now,
 	}
 }
 
@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+)
+
+// Supported provider presets.
+const (
+	ProviderAzureAD  = "azureAD"
+	ProviderOkta     = "okta"
+	ProviderKeycloak = "keycloak"
+	ProviderGitLab   = "gitlab"
+)
+
+// GroupsClaim is the claim provider-specific group claims are normalized into.
+const GroupsClaim = "groups"
+
+const defaultGitLabURL = "https://gitlab.com"
+
+// ProviderConfig configures an OIDC policy for a well-known provider. The issuer, the default scopes and the claim
+// holding the groups of users are derived from it.
+type ProviderConfig struct {
+	// Name is the name of the provider.
+	Name string `json:"name,omitempty"`
+	// TenantID is the Azure AD tenant ID.
+	TenantID string `json:"tenantId,omitempty"`
+	// URL is the URL of the Okta organization, of the Keycloak server or of a self-managed GitLab instance.
+	URL string `json:"url,omitempty"`
+	// Realm is the Keycloak realm.
+	Realm string `json:"realm,omitempty"`
+
+	// GroupsClaim overrides the claim holding the groups of users, copied into the `groups` claim.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// AllowedGroups lists the groups users must be a member of at least one of.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// AllowedDomains lists the domains the email of users must belong to one of.
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+}
+
+// Validate validates configuration.
+func (p *ProviderConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	switch p.Name {
+	case ProviderAzureAD:
+		if p.TenantID == "" {
+			return errors.New("missing Azure AD tenant ID")
+		}
+	case ProviderOkta:
+		if p.URL == "" {
+			return errors.New("missing Okta organization URL")
+		}
+	case ProviderKeycloak:
+		if p.URL == "" || p.Realm == "" {
+			return errors.New("missing Keycloak URL or realm")
+		}
+	case ProviderGitLab:
+	default:
+		return fmt.Errorf("unsupported provider %q", p.Name)
+	}
+
+	if p.URL != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return fmt.Errorf("parse URL: %w", err)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+		}
+	}
+
+	if claims := p.claims(); claims != "" {
+		if _, err := expr.Parse(claims); err != nil {
+			return fmt.Errorf("invalid allowed groups or domains: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// issuer returns the issuer of the provider.
+func (p *ProviderConfig) issuer() string {
+	baseURL := strings.TrimSuffix(p.URL, "/")
+
+	switch p.Name {
+	case ProviderAzureAD:
+		return "https://login.microsoftonline.com/" + url.PathEscape(p.TenantID) + "/v2.0"
+	case ProviderKeycloak:
+		return baseURL + "/realms/" + url.PathEscape(p.Realm)
+	case ProviderGitLab:
+		if baseURL == "" {
+			return defaultGitLabURL
+		}
+		return baseURL
+	default:
+		return baseURL
+	}
+}
+
+// scopes returns the scopes to request by default.
+func (p *ProviderConfig) scopes() []string {
+	switch p.Name {
+	case ProviderOkta:
+		return []string{"openid", "profile", "email", "groups"}
+	default:
+		return []string{"openid", "profile", "email"}
+	}
+}
+
+// groupsClaim returns the claim holding the groups of users.
+func (p *ProviderConfig) groupsClaim() string {
+	if p.GroupsClaim != "" {
+		return p.GroupsClaim
+	}
+
+	switch p.Name {
+	case ProviderKeycloak:
+		return "realm_access.roles"
+	case ProviderGitLab:
+		return "groups_direct"
+	default:
+		return GroupsClaim
+	}
+}
+
+// emailClaim returns the claim holding the email of users.
+func (p *ProviderConfig) emailClaim() string {
+	if p.Name == ProviderAzureAD {
+		// Azure AD only sets the email claim when configured as an optional claim.
+		return "preferred_username"
+	}
+
+	return "email"
+}
+
+// claims returns the claims expression enforcing allowed groups and domains.
+func (p *ProviderConfig) claims() string {
+	var preds []string
+
+	if len(p.AllowedGroups) > 0 {
+		groups := make([]string, 0, len(p.AllowedGroups))
+		for _, group := range p.AllowedGroups {
+			groups = append(groups, fmt.Sprintf("%q", group))
+		}
+
+		preds = append(preds, fmt.Sprintf("AnyOf(%q,%s)", GroupsClaim, strings.Join(groups, ",")))
+	}
+
+	if len(p.AllowedDomains) > 0 {
+		domains := make([]string, 0, len(p.AllowedDomains))
+		for _, domain := range p.AllowedDomains {
+			domains = append(domains, regexp.QuoteMeta(strings.ToLower(domain)))
+		}
+
+		pattern := "(?i)^[^@]+@(?:" + strings.Join(domains, "|") + ")$"
+		preds = append(preds, fmt.Sprintf("Matches(%q,%q)", p.emailClaim(), pattern))
+	}
+
+	return strings.Join(preds, "&&")
+}
+
+// normalizeGroups copies the groups of users from the provider-specific claim into the `groups` claim.
+func (p *ProviderConfig) normalizeGroups(claims map[string]interface{}) (map[string]interface{}, error) {
+	claim := p.groupsClaim()
+	if claim == GroupsClaim {
+		return claims, nil
+	}
+
+	groups, err := expr.PluckClaim(claim, claims)
+	if err != nil {
+		return nil, fmt.Errorf("read groups from claim %q: %w", claim, err)
+	}
+
+	normalized := make(map[string]interface{}, len(claims)+1)
+	for name, value := range claims {
+		normalized[name] = value
+	}
+
+	values := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		values = append(values, group)
+	}
+	normalized[GroupsClaim] = values
+
+	return normalized, nil
+}
@@ -0,0 +1,257 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+)
+
+func TestProviderConfig_Validate(t *testing.T) {
+	tests := []struct {
+		desc     string
+		provider *ProviderConfig
+		wantErr  string
+	}{
+		{
+			desc:     "Azure AD",
+			provider: &ProviderConfig{Name: ProviderAzureAD, TenantID: "tenant"},
+		},
+		{
+			desc:     "Azure AD without tenant",
+			provider: &ProviderConfig{Name: ProviderAzureAD},
+			wantErr:  "missing Azure AD tenant ID",
+		},
+		{
+			desc:     "Okta without URL",
+			provider: &ProviderConfig{Name: ProviderOkta},
+			wantErr:  "missing Okta organization URL",
+		},
+		{
+			desc:     "Keycloak without realm",
+			provider: &ProviderConfig{Name: ProviderKeycloak, URL: "https://keycloak.example.com"},
+			wantErr:  "missing Keycloak URL or realm",
+		},
+		{
+			desc:     "GitLab.com",
+			provider: &ProviderConfig{Name: ProviderGitLab},
+		},
+		{
+			desc:     "invalid URL scheme",
+			provider: &ProviderConfig{Name: ProviderGitLab, URL: "gitlab.example.com"},
+			wantErr:  `unsupported URL scheme ""`,
+		},
+		{
+			desc:     "unsupported provider",
+			provider: &ProviderConfig{Name: "auth0"},
+			wantErr:  `unsupported provider "auth0"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.provider.Validate()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfig_ApplyDefaultValues_provider(t *testing.T) {
+	tests := []struct {
+		desc       string
+		cfg        *Config
+		wantIssuer string
+		wantScopes []string
+	}{
+		{
+			desc:       "Azure AD",
+			cfg:        &Config{Provider: &ProviderConfig{Name: ProviderAzureAD, TenantID: "my-tenant"}},
+			wantIssuer: "https://login.microsoftonline.com/my-tenant/v2.0",
+			wantScopes: []string{"openid", "profile", "email"},
+		},
+		{
+			desc:       "Okta",
+			cfg:        &Config{Provider: &ProviderConfig{Name: ProviderOkta, URL: "https://example.okta.com/"}},
+			wantIssuer: "https://example.okta.com",
+			wantScopes: []string{"openid", "profile", "email", "groups"},
+		},
+		{
+			desc:       "Keycloak",
+			cfg:        &Config{Provider: &ProviderConfig{Name: ProviderKeycloak, URL: "https://keycloak.example.com", Realm: "hub"}},
+			wantIssuer: "https://keycloak.example.com/realms/hub",
+			wantScopes: []string{"openid", "profile", "email"},
+		},
+		{
+			desc:       "GitLab.com",
+			cfg:        &Config{Provider: &ProviderConfig{Name: ProviderGitLab}},
+			wantIssuer: "https://gitlab.com",
+			wantScopes: []string{"openid", "profile", "email"},
+		},
+		{
+			desc: "explicit issuer and scopes",
+			cfg: &Config{
+				Issuer:   "https://gitlab.example.com",
+				Scopes:   []string{"openid"},
+				Provider: &ProviderConfig{Name: ProviderGitLab},
+			},
+			wantIssuer: "https://gitlab.example.com",
+			wantScopes: []string{"openid"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			test.cfg.ApplyDefaultValues()
+
+			assert.Equal(t, test.wantIssuer, test.cfg.Issuer)
+			assert.Equal(t, test.wantScopes, test.cfg.Scopes)
+		})
+	}
+}
+
+func TestConfig_claimsExpression_provider(t *testing.T) {
+	cfg := &Config{
+		Claims: "Equals(`tier`, `gold`)",
+		Provider: &ProviderConfig{
+			Name:           ProviderKeycloak,
+			AllowedGroups:  []string{"admin", "ops"},
+			AllowedDomains: []string{"example.com", "example.org"},
+		},
+	}
+
+	pred, err := expr.Parse(cfg.claimsExpression())
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc   string
+		claims map[string]interface{}
+		want   bool
+	}{
+		{
+			desc: "allowed",
+			claims: map[string]interface{}{
+				"realm_access": map[string]interface{}{"roles": []interface{}{"ops", "user"}},
+				"email":        "jane@Example.org",
+				"tier":         "gold",
+			},
+			want: true,
+		},
+		{
+			desc: "group not allowed",
+			claims: map[string]interface{}{
+				"realm_access": map[string]interface{}{"roles": []interface{}{"user"}},
+				"email":        "jane@example.com",
+				"tier":         "gold",
+			},
+		},
+		{
+			desc: "domain not allowed",
+			claims: map[string]interface{}{
+				"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+				"email":        "jane@example.com.evil.com",
+				"tier":         "gold",
+			},
+		},
+		{
+			desc: "custom claims not satisfied",
+			claims: map[string]interface{}{
+				"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+				"email":        "jane@example.com",
+				"tier":         "silver",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			claims, err := cfg.Provider.normalizeGroups(test.claims)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, pred(claims))
+		})
+	}
+}
+
+func TestProviderConfig_normalizeGroups(t *testing.T) {
+	tests := []struct {
+		desc     string
+		provider *ProviderConfig
+		claims   map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			desc:     "groups claim",
+			provider: &ProviderConfig{Name: ProviderAzureAD},
+			claims:   map[string]interface{}{"groups": []interface{}{"a"}},
+			want:     map[string]interface{}{"groups": []interface{}{"a"}},
+		},
+		{
+			desc:     "GitLab groups",
+			provider: &ProviderConfig{Name: ProviderGitLab},
+			claims:   map[string]interface{}{"groups_direct": []interface{}{"org/team"}},
+			want: map[string]interface{}{
+				"groups_direct": []interface{}{"org/team"},
+				"groups":        []interface{}{"org/team"},
+			},
+		},
+		{
+			desc:     "custom claim",
+			provider: &ProviderConfig{Name: ProviderAzureAD, GroupsClaim: "roles"},
+			claims:   map[string]interface{}{"roles": "admin", "groups": []interface{}{"id"}},
+			want: map[string]interface{}{
+				"roles":  "admin",
+				"groups": []interface{}{"admin"},
+			},
+		},
+		{
+			desc:     "missing claim",
+			provider: &ProviderConfig{Name: ProviderKeycloak},
+			claims:   map[string]interface{}{"sub": "jane"},
+			want:     map[string]interface{}{"sub": "jane", "groups": []interface{}{}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := test.provider.normalizeGroups(test.claims)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Supported session stores.
+const (
+	// SessionStoreCookie keeps sessions in encrypted cookies.
+	SessionStoreCookie = "cookie"
+	// SessionStoreMemory keeps sessions in the memory of the auth server.
+	SessionStoreMemory = "memory"
+	// SessionStoreSecret keeps sessions in Kubernetes Secrets.
+	SessionStoreSecret = "secret"
+)
+
+const (
+	defaultSessionTTL = 24 * time.Hour
+	// minSessionMaxAge is the minimum time sessions can be refreshed for after users logged in.
+	minSessionMaxAge = 7 * 24 * time.Hour
+	sessionIDLength  = 32
+)
+
+// SessionBackends holds session backends by session store name.
+type SessionBackends map[string]SessionBackend
+
+// SessionBackend stores server-side sessions. Stored values are encrypted by the ServerSessionStore.
+type SessionBackend interface {
+	// Get returns the value stored under the given key, or nil if there is none or if it has expired.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores the given value under the given key for the given duration.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes the value stored under the given key.
+	Delete(ctx context.Context, key string) error
+}
+
+// ServerSessionStore keeps sessions in a SessionBackend and only sets an opaque session ID cookie.
+type ServerSessionStore struct {
+	name    string
+	cfg     *AuthSession
+	ttl     time.Duration
+	backend SessionBackend
+
+	blocks []cipher.Block
+	rand   Randr
+}
+
+// NewServerSessionStore creates a server-side session store. Sessions are encrypted with the first of the given blocks
+// and decrypted with any of them.
+func NewServerSessionStore(name string, blocks []cipher.Block, cfg *AuthSession, rand Randr, backend SessionBackend) *ServerSessionStore {
+	return &ServerSessionStore{
+		name:    name,
+		cfg:     cfg,
+		ttl:     sessionTTL(cfg),
+		backend: backend,
+		blocks:  blocks,
+		rand:    rand,
+	}
+}
+
+// Create stores the session data in the backend under a new session ID and sets the session ID cookie.
+func (s *ServerSessionStore) Create(w http.ResponseWriter, r *http.Request, data SessionData) error {
+	id := string(s.rand.Bytes(sessionIDLength))
+
+	if err := s.store(r.Context(), id, data); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    id,
+		Path:     s.cfg.Path,
+		Domain:   s.cfg.Domain,
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: parseSameSite(s.cfg.SameSite),
+		Secure:   s.cfg.Secure,
+	})
+
+	return nil
+}
+
+// Update updates the session data of the session identified by the request cookie. A new session is created if the
+// request has no session ID cookie.
+func (s *ServerSessionStore) Update(w http.ResponseWriter, r *http.Request, data SessionData) error {
+	c, err := r.Cookie(s.name)
+	if err != nil {
+		return s.Create(w, r, data)
+	}
+
+	return s.store(r.Context(), c.Value, data)
+}
+
+// Delete deletes the session identified by the request cookie and expires the cookie.
+func (s *ServerSessionStore) Delete(w http.ResponseWriter, r *http.Request) error {
+	c, err := r.Cookie(s.name)
+	if err != nil {
+		return nil
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   s.name,
+		Path:   s.cfg.Path,
+		Domain: s.cfg.Domain,
+		MaxAge: -1, // Invalidates the cookie.
+	})
+
+	if err = s.backend.Delete(r.Context(), s.key(c.Value)); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the session identified by the request cookie.
+func (s *ServerSessionStore) Get(r *http.Request) (*SessionData, error) {
+	c, err := r.Cookie(s.name)
+	if err != nil || c.Value == "" {
+		return nil, nil
+	}
+
+	value, err := s.backend.Get(r.Context(), s.key(c.Value))
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	sess, err := decodeSession(s.blocks, value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode session: %w", err)
+	}
+
+	return &sess, nil
+}
+
+// RemoveCookie removes the session ID cookie from the request.
+func (s *ServerSessionStore) RemoveCookie(rw http.ResponseWriter, r *http.Request) {
+	cs := r.Cookies()
+
+	rw.Header().Del("Cookie")
+	for _, c := range cs {
+		if c.Name != s.name {
+			rw.Header().Add("Cookie", c.String())
+		}
+	}
+}
+
+func (s *ServerSessionStore) store(ctx context.Context, id string, data SessionData) error {
+	value, err := encodeSession(s.blocks[0], s.rand, data)
+	if err != nil {
+		return fmt.Errorf("unable to encode session payload: %w", err)
+	}
+
+	if err = s.backend.Set(ctx, s.key(id), value, s.ttl); err != nil {
+		return fmt.Errorf("store session: %w", err)
+	}
+
+	return nil
+}
+
+// key returns the backend key of the given session ID. Session IDs are hashed so that whoever can read the backend
+// can't hijack sessions, and scoped to the store so that backends can be shared by several policies.
+func (s *ServerSessionStore) key(id string) string {
+	sum := sha256.Sum256([]byte(s.name + "\x00" + id))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionTTL returns how long sessions are kept for.
+func sessionTTL(cfg *AuthSession) time.Duration {
+	if cfg == nil || cfg.TTL <= 0 {
+		return defaultSessionTTL
+	}
+
+	return time.Duration(cfg.TTL) * time.Second
+}
+
+// revocationTTL returns how long session revocations are kept for, which is also the maximum age of sessions.
+func revocationTTL(cfg *AuthSession) time.Duration {
+	ttl := sessionTTL(cfg)
+	if ttl < minSessionMaxAge {
+		return minSessionMaxAge
+	}
+
+	return ttl
+}
+
+type memorySession struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemorySessionBackend is a SessionBackend keeping sessions in memory. Sessions are lost when the auth server restarts
+// and aren't shared between replicas.
+type MemorySessionBackend struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+
+	now func() time.Time
+}
+
+// NewMemorySessionBackend returns a new MemorySessionBackend.
+func NewMemorySessionBackend() *MemorySessionBackend {
+	return &MemorySessionBackend{
+		sessions: make(map[string]memorySession),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value stored under the given key.
+func (b *MemorySessionBackend) Get(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sess, ok := b.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+
+	if !b.now().Before(sess.expiresAt) {
+		delete(b.sessions, key)
+		return nil, nil
+	}
+
+	return sess.value, nil
+}
+
+// Set stores the given value under the given key for the given duration.
+func (b *MemorySessionBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sessions[key] = memorySession{value: value, expiresAt: b.now().Add(ttl)}
+
+	return nil
+}
+
+// Delete deletes the value stored under the given key.
+func (b *MemorySessionBackend) Delete(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sessions, key)
+
+	return nil
+}
+
+// Run evicts expired sessions every given interval until the context is canceled.
+func (b *MemorySessionBackend) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.evict()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *MemorySessionBackend) evict() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for key, sess := range b.sessions {
+		if !now.Before(sess.expiresAt) {
+			delete(b.sessions, key)
+		}
+	}
+}
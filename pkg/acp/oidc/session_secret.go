@@ -0,0 +1,237 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+)
+
+// SessionSecretLabel is the label set on Secrets holding sessions.
+const SessionSecretLabel = "hub.traefik.io/session"
+
+const (
+	sessionSecretPrefix           = "hub-session-"
+	sessionSecretKey              = "session"
+	sessionSecretExpiryAnnotation = "hub.traefik.io/session-expires-at"
+
+	// pendingWriteTTL is how long values written by the backend are served from memory, waiting for the informer to
+	// observe them.
+	pendingWriteTTL = 30 * time.Second
+)
+
+// pendingWrite is a value written by the SecretSessionBackend which may not be in the informer cache yet.
+type pendingWrite struct {
+	// value is the written value, nil if it was deleted.
+	value     []byte
+	expiresAt time.Time
+	until     time.Time
+}
+
+// SecretSessionBackend is a SessionBackend keeping sessions in Kubernetes Secrets, one per session. Sessions are
+// shared between auth server replicas and survive restarts. Secrets are read from an informer and written through the
+// client, so that authenticating requests doesn't hit the API server.
+type SecretSessionBackend struct {
+	client    clientset.Interface
+	secrets   corelistersv1.SecretNamespaceLister
+	namespace string
+
+	pendingMu sync.Mutex
+	pending   map[string]pendingWrite
+
+	now func() time.Time
+}
+
+// NewSecretSessionBackend returns a new SecretSessionBackend storing Secrets in the given namespace. The Secret
+// informer of the given factory must watch the Secrets of this namespace holding the SessionSecretLabel label, and be
+// started by the caller.
+func NewSecretSessionBackend(client clientset.Interface, informer informers.SharedInformerFactory, namespace string) *SecretSessionBackend {
+	return &SecretSessionBackend{
+		client:    client,
+		secrets:   informer.Core().V1().Secrets().Lister().Secrets(namespace),
+		namespace: namespace,
+		pending:   make(map[string]pendingWrite),
+		now:       time.Now,
+	}
+}
+
+// Get returns the value stored under the given key.
+func (b *SecretSessionBackend) Get(_ context.Context, key string) ([]byte, error) {
+	if write, ok := b.pendingWrite(key); ok {
+		if write.value == nil || !b.now().Before(write.expiresAt) {
+			return nil, nil
+		}
+
+		return write.value, nil
+	}
+
+	secret, err := b.secrets.Get(sessionSecretPrefix + key)
+	if err != nil {
+		if kerror.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get secret: %w", err)
+	}
+
+	if b.expired(secret) {
+		return nil, nil
+	}
+
+	return secret.Data[sessionSecretKey], nil
+}
+
+// Set stores the given value under the given key for the given duration.
+func (b *SecretSessionBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	expiresAt := b.now().Add(ttl)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sessionSecretPrefix + key,
+			Namespace: b.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "traefik-hub",
+				SessionSecretLabel:             "true",
+			},
+			Annotations: map[string]string{
+				sessionSecretExpiryAnnotation: expiresAt.UTC().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{sessionSecretKey: value},
+	}
+
+	secrets := b.client.CoreV1().Secrets(b.namespace)
+
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if kerror.IsAlreadyExists(err) {
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("store secret: %w", err)
+	}
+
+	b.setPendingWrite(key, pendingWrite{value: value, expiresAt: expiresAt})
+
+	return nil
+}
+
+// Delete deletes the value stored under the given key.
+func (b *SecretSessionBackend) Delete(ctx context.Context, key string) error {
+	err := b.client.CoreV1().Secrets(b.namespace).Delete(ctx, sessionSecretPrefix+key, metav1.DeleteOptions{})
+	if err != nil && !kerror.IsNotFound(err) {
+		return fmt.Errorf("delete secret: %w", err)
+	}
+
+	b.setPendingWrite(key, pendingWrite{})
+
+	return nil
+}
+
+// Run deletes the Secrets of expired sessions every given interval until the context is canceled.
+func (b *SecretSessionBackend) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.evict(ctx); err != nil {
+				log.Error().Err(err).Msg("Unable to evict expired sessions")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *SecretSessionBackend) evict(ctx context.Context) error {
+	b.evictPendingWrites()
+
+	secrets, err := b.secrets.List(labels.SelectorFromSet(labels.Set{SessionSecretLabel: "true"}))
+	if err != nil {
+		return fmt.Errorf("list secrets: %w", err)
+	}
+
+	for _, secret := range secrets {
+		if !b.expired(secret) {
+			continue
+		}
+
+		if err = b.client.CoreV1().Secrets(b.namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil && !kerror.IsNotFound(err) {
+			return fmt.Errorf("delete secret %q: %w", secret.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// pendingWrite returns the value written under the given key which may not be in the informer cache yet, if any.
+func (b *SecretSessionBackend) pendingWrite(key string) (pendingWrite, bool) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+
+	write, ok := b.pending[key]
+	if !ok || !b.now().Before(write.until) {
+		return pendingWrite{}, false
+	}
+
+	return write, true
+}
+
+func (b *SecretSessionBackend) setPendingWrite(key string, write pendingWrite) {
+	write.until = b.now().Add(pendingWriteTTL)
+
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+
+	b.pending[key] = write
+}
+
+func (b *SecretSessionBackend) evictPendingWrites() {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+
+	now := b.now()
+	for key, write := range b.pending {
+		if !now.Before(write.until) {
+			delete(b.pending, key)
+		}
+	}
+}
+
+// expired returns whether the session held by the given Secret has expired. Secrets with an invalid expiration date
+// are considered expired.
+func (b *SecretSessionBackend) expired(secret *corev1.Secret) bool {
+	expiresAt, err := time.Parse(time.RFC3339, secret.Annotations[sessionSecretExpiryAnnotation])
+	if err != nil {
+		return true
+	}
+
+	return !b.now().Before(expiresAt)
+}
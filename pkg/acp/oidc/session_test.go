@@ -0,0 +1,223 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	kubemock "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServerSessionStore(t *testing.T) {
+	block, err := aes.NewCipher([]byte("secret1234567890"))
+	require.NoError(t, err)
+
+	backend := NewMemorySessionBackend()
+	store := NewServerSessionStore("test-name", []cipher.Block{block}, &AuthSession{
+		Path:     "/",
+		Domain:   "example.com",
+		SameSite: "lax",
+		Secure:   true,
+		TTL:      3600,
+	}, newRandom(), backend)
+
+	rec := httptest.NewRecorder()
+	err = store.Create(rec, httptest.NewRequest(http.MethodGet, "/", nil), SessionData{AccessToken: "test1", IDToken: "test2"})
+	require.NoError(t, err)
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "test-name", cookies[0].Name)
+	assert.Len(t, cookies[0].Value, sessionIDLength)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Len(t, backend.sessions, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	req.AddCookie(cookies[0])
+	req.AddCookie(&http.Cookie{Name: "other", Value: "value"})
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.Equal(t, "test1", sess.AccessToken)
+	assert.Equal(t, "test2", sess.IDToken)
+
+	rec = httptest.NewRecorder()
+	err = store.Update(rec, req, SessionData{AccessToken: "test3", IDToken: "test4"})
+	require.NoError(t, err)
+	assert.Empty(t, rec.Result().Cookies())
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.Equal(t, "test3", sess.AccessToken)
+	assert.Equal(t, "test4", sess.IDToken)
+
+	rec = httptest.NewRecorder()
+	store.RemoveCookie(rec, req)
+	assert.Equal(t, []string{"other=value"}, rec.Header().Values("Cookie"))
+
+	rec = httptest.NewRecorder()
+	err = store.Delete(rec, req)
+	require.NoError(t, err)
+	assert.Equal(t, "test-name=; Path=/; Domain=example.com; Max-Age=0", rec.Header().Get("Set-Cookie"))
+	assert.Empty(t, backend.sessions)
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestServerSessionStore_GetReturnsNilIfNoSessionExists(t *testing.T) {
+	block, err := aes.NewCipher([]byte("secret1234567890"))
+	require.NoError(t, err)
+
+	store := NewServerSessionStore("test-name", []cipher.Block{block}, &AuthSession{}, newRandom(), NewMemorySessionBackend())
+
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	req.AddCookie(&http.Cookie{Name: "test-name", Value: "unknown"})
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestMemorySessionBackend_expiration(t *testing.T) {
+	now := time.Now()
+
+	backend := NewMemorySessionBackend()
+	backend.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, backend.Set(ctx, "short", []byte("value"), time.Minute))
+	require.NoError(t, backend.Set(ctx, "long", []byte("value"), time.Hour))
+
+	value, err := backend.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	now = now.Add(30 * time.Minute)
+
+	value, err = backend.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, backend.Set(ctx, "expired", []byte("value"), time.Minute))
+	now = now.Add(time.Minute)
+
+	backend.evict()
+
+	assert.Len(t, backend.sessions, 1)
+	assert.Contains(t, backend.sessions, "long")
+}
+
+func TestSecretSessionBackend(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	client := kubemock.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace("hub-agent"),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = SessionSecretLabel + "=true"
+		}),
+	)
+
+	backend := NewSecretSessionBackend(client, informer, "hub-agent")
+	backend.now = func() time.Time { return now }
+
+	informer.Start(ctx.Done())
+	informer.WaitForCacheSync(ctx.Done())
+
+	require.NoError(t, backend.Set(ctx, "key", []byte("value"), time.Hour))
+
+	secret, err := client.CoreV1().Secrets("hub-agent").Get(ctx, "hub-session-key", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", secret.Labels[SessionSecretLabel])
+	assert.Equal(t, "2023-01-01T01:00:00Z", secret.Annotations[sessionSecretExpiryAnnotation])
+	assert.Equal(t, []byte("value"), secret.Data[sessionSecretKey])
+
+	require.NoError(t, backend.Set(ctx, "key", []byte("updated"), 2*time.Hour))
+
+	// Written values are served from memory until the informer observes them.
+	value, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("updated"), value)
+
+	value, err = backend.Get(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, backend.Set(ctx, "other", []byte("value"), time.Hour))
+	_, err = client.CoreV1().Secrets("hub-agent").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "hub-agent"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		secrets, listErr := backend.secrets.List(labels.SelectorFromSet(labels.Set{SessionSecretLabel: "true"}))
+		return listErr == nil && len(secrets) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	now = now.Add(90 * time.Minute)
+
+	// Values are then read from the informer.
+	value, err = backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("updated"), value)
+
+	value, err = backend.Get(ctx, "other")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, backend.evict(ctx))
+	assert.Empty(t, backend.pending)
+
+	secrets, err := client.CoreV1().Secrets("hub-agent").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range secrets.Items {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"hub-session-key", "unrelated"}, names)
+
+	require.NoError(t, backend.Delete(ctx, "key"))
+	require.NoError(t, backend.Delete(ctx, "key"))
+
+	// Deleted values are no longer served, even if the informer didn't observe the deletion yet.
+	value, err = backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
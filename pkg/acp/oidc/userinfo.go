@@ -0,0 +1,89 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// UserInfoFetcher fetches the claims of the user authenticated by the given token from the UserInfo endpoint.
+type UserInfoFetcher func(ctx context.Context, token *oauth2.Token) (map[string]interface{}, error)
+
+func newUserInfoFetcher(provider *oidc.Provider, client *http.Client) UserInfoFetcher {
+	return func(ctx context.Context, token *oauth2.Token) (map[string]interface{}, error) {
+		info, err := provider.UserInfo(oidc.ClientContext(ctx, client), oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, err
+		}
+
+		claims := make(map[string]interface{})
+		if err = info.Claims(&claims); err != nil {
+			return nil, fmt.Errorf("unmarshal claims: %w", err)
+		}
+
+		return claims, nil
+	}
+}
+
+// fetchUserInfo returns the UserInfo claims of the given subject, or nil if UserInfo is disabled.
+func (h *Handler) fetchUserInfo(ctx context.Context, token *oauth2.Token, sub string) (map[string]interface{}, error) {
+	if h.cfg.UserInfo == nil {
+		return nil, nil
+	}
+
+	claims, err := h.userInfo(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("fetch UserInfo: %w", err)
+	}
+
+	// spec: section 5.3.4, the sub claim must match the ID token one to prevent token substitution attacks.
+	if claims["sub"] != sub {
+		return nil, errors.New("UserInfo subject doesn't match the ID token one")
+	}
+
+	return claims, nil
+}
+
+// mergeClaims merges the given UserInfo claims with the given ID token claims, following the configured precedence.
+func (h *Handler) mergeClaims(idTokenClaims, userInfoClaims map[string]interface{}) map[string]interface{} {
+	if len(userInfoClaims) == 0 {
+		return idTokenClaims
+	}
+
+	overwrite := h.cfg.UserInfo != nil && h.cfg.UserInfo.Precedence == UserInfoPrecedenceUserInfo
+
+	merged := make(map[string]interface{}, len(idTokenClaims)+len(userInfoClaims))
+	for name, value := range idTokenClaims {
+		merged[name] = value
+	}
+
+	for name, value := range userInfoClaims {
+		if _, ok := merged[name]; ok && !overwrite {
+			continue
+		}
+		merged[name] = value
+	}
+
+	return merged
+}
@@ -0,0 +1,198 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+	"golang.org/x/oauth2"
+)
+
+func TestHandler_mergeClaims(t *testing.T) {
+	idTokenClaims := map[string]interface{}{"sub": "alice", "group": "dev"}
+	userInfoClaims := map[string]interface{}{"sub": "alice", "group": "admin", "roles": []interface{}{"editor"}}
+
+	tests := []struct {
+		desc     string
+		userInfo *UserInfo
+		want     map[string]interface{}
+	}{
+		{
+			desc:     "ID token precedence by default",
+			userInfo: &UserInfo{},
+			want:     map[string]interface{}{"sub": "alice", "group": "dev", "roles": []interface{}{"editor"}},
+		},
+		{
+			desc:     "ID token precedence",
+			userInfo: &UserInfo{Precedence: UserInfoPrecedenceIDToken},
+			want:     map[string]interface{}{"sub": "alice", "group": "dev", "roles": []interface{}{"editor"}},
+		},
+		{
+			desc:     "UserInfo precedence",
+			userInfo: &UserInfo{Precedence: UserInfoPrecedenceUserInfo},
+			want:     map[string]interface{}{"sub": "alice", "group": "admin", "roles": []interface{}{"editor"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := &Handler{cfg: &Config{UserInfo: test.userInfo}}
+
+			assert.Equal(t, test.want, handler.mergeClaims(idTokenClaims, userInfoClaims))
+		})
+	}
+}
+
+func TestMiddleware_ExchangesTokenOnCallbackWithUserInfo(t *testing.T) {
+	tests := []struct {
+		desc         string
+		userInfo     map[string]interface{}
+		userInfoErr  error
+		wantStatus   int
+		wantUserInfo map[string]interface{}
+	}{
+		{
+			desc:         "stores UserInfo claims in the session",
+			userInfo:     map[string]interface{}{"sub": "alice", "groups": []interface{}{"admin"}},
+			wantStatus:   http.StatusFound,
+			wantUserInfo: map[string]interface{}{"sub": "alice", "groups": []interface{}{"admin"}},
+		},
+		{
+			desc:       "subject mismatch",
+			userInfo:   map[string]interface{}{"sub": "bob", "groups": []interface{}{"admin"}},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:        "UserInfo error",
+			userInfoErr: errors.New("boom"),
+			wantStatus:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := Config{
+				RedirectURL: "http://foobar.com/callback",
+				StateCookie: &AuthStateCookie{Path: "/"},
+				Session:     &AuthSession{Refresh: boolPtr(false)},
+				UserInfo:    &UserInfo{},
+			}
+
+			oauth2tok := (&oauth2.Token{AccessToken: "access-token", TokenType: "bearer"}).
+				WithExtra(map[string]interface{}{"id_token": jwtToken})
+
+			oauth := newOAuthProviderMock(t).
+				OnExchangeRaw(mock.Anything, mock.Anything).TypedReturns(oauth2tok, nil).Once().
+				Parent
+
+			session := newSessionStoreMock(t).
+				OnGetRaw(mock.Anything).TypedReturns(nil, nil).Once().
+				Parent
+
+			if test.wantStatus == http.StatusFound {
+				session.OnCreateRaw(mock.Anything, mock.Anything, mock.MatchedBy(func(data SessionData) bool {
+					return assert.Equal(t, test.wantUserInfo, data.UserInfo)
+				})).TypedReturns(nil).Once()
+			}
+
+			handler := buildHandler(t)
+			handler.oauth = oauth
+			handler.session = session
+			handler.cfg = &cfg
+			handler.userInfo = func(_ context.Context, token *oauth2.Token) (map[string]interface{}, error) {
+				assert.Equal(t, "access-token", token.AccessToken)
+				return test.userInfo, test.userInfoErr
+			}
+
+			state := StateData{
+				RedirectID: "aaaaa",
+				Nonce:      "n-0S6_WzA2Mj",
+				OriginURL:  "http://app.bar.com",
+			}
+
+			stateCookie, err := handler.newStateCookie(state)
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodGet, "http://foobar.com/callback?state=aaaaa", nil)
+			r.Header.Set("X-Forwarded-Method", r.Method)
+			r.Header.Set("X-Forwarded-Proto", "http")
+			r.Header.Set("X-Forwarded-Host", r.Host)
+			r.Header.Set("X-Forwarded-URI", r.URL.RequestURI())
+			r.AddCookie(stateCookie)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestMiddleware_ForwardsUserInfoClaims(t *testing.T) {
+	cfg := &Config{
+		RedirectURL: "http://foo.com",
+		Claims:      "Contains(`groups`, `admin`)",
+		ForwardHeaders: map[string]string{
+			"X-Groups": "groups",
+			"X-Group":  "group",
+		},
+		UserInfo: &UserInfo{},
+	}
+	cfg.ApplyDefaultValues()
+
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(&SessionData{
+		AccessToken: "test",
+		IDToken:     jwtToken,
+		Expiry:      time.Now().Add(time.Minute),
+		UserInfo:    map[string]interface{}{"sub": "alice", "group": "dev", "groups": []interface{}{"admin"}},
+	}, nil).Once().
+		OnRemoveCookieRaw(mock.Anything, mock.Anything).Once().
+		Parent
+
+	pred, err := expr.Parse(cfg.Claims)
+	require.NoError(t, err)
+
+	handler := buildHandler(t)
+	handler.session = session
+	handler.validateClaims = pred
+	handler.cfg = cfg
+
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin", w.Header().Get("X-Groups"))
+	// The ID token claim prevails.
+	assert.Equal(t, "admin", w.Header().Get("X-Group"))
+}
@@ -0,0 +1,306 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/authmetrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt/expr"
+)
+
+// Action is the action applied to requests matching a rule.
+type Action string
+
+// Supported actions.
+const (
+	// ActionAllow allows requests without authenticating them.
+	ActionAllow Action = "allow"
+	// ActionDeny denies requests without authenticating them.
+	ActionDeny Action = "deny"
+)
+
+// Config configures a rule. Requests match a rule when they match all its matchers, empty matchers matching all
+// requests. Matching requests are either allowed or denied as defined by Action, or must satisfy the Claims expression
+// once authenticated, in addition to the claims expression of the policy.
+type Config struct {
+	// Methods lists the matched methods.
+	Methods []string `json:"methods,omitempty"`
+	// Path is a glob matching the percent-decoded and cleaned path of requests. `*` matches any sequence of characters
+	// but `/`, `**` matches any sequence of characters and `?` matches any character but `/`.
+	Path string `json:"path,omitempty"`
+	// PathRegex is a regular expression matching the whole path of requests. It can't be used along with Path.
+	PathRegex string `json:"pathRegex,omitempty"`
+	// Host is a glob matching the host of requests, ignoring case. `*` matches any sequence of characters but `.`.
+	Host string `json:"host,omitempty"`
+
+	// Action is the action applied to matching requests. It can't be used along with Claims.
+	Action Action `json:"action,omitempty"`
+	// Claims is the claims expression matching requests must satisfy.
+	Claims string `json:"claims,omitempty"`
+}
+
+// Rule is a compiled rule.
+type Rule struct {
+	methods map[string]struct{}
+	path    *regexp.Regexp
+	host    *regexp.Regexp
+
+	action Action
+	claims expr.Predicate
+}
+
+// Rules is an ordered list of rules.
+type Rules []*Rule
+
+// ambiguousPathRule is the rule matched by requests whose path can't be normalized unambiguously. Those requests are
+// denied, as upstreams could serve a path other than the matched one.
+var ambiguousPathRule = &Rule{action: ActionDeny}
+
+// New compiles the given rules.
+func New(cfgs []Config) (Rules, error) {
+	rules := make(Rules, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		rule, err := newRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Validate validates the given rules.
+func Validate(cfgs []Config) error {
+	_, err := New(cfgs)
+	return err
+}
+
+func newRule(cfg Config) (*Rule, error) {
+	rule := &Rule{action: cfg.Action}
+
+	switch {
+	case cfg.Action != "" && cfg.Claims != "":
+		return nil, errors.New("action and claims are mutually exclusive")
+	case cfg.Action == "" && cfg.Claims == "":
+		return nil, errors.New("missing action or claims")
+	case cfg.Action != "" && cfg.Action != ActionAllow && cfg.Action != ActionDeny:
+		return nil, fmt.Errorf("unsupported action %q", cfg.Action)
+	}
+
+	if cfg.Claims != "" {
+		pred, err := expr.Parse(cfg.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("invalid claims expression: %w", err)
+		}
+		rule.claims = pred
+	}
+
+	if len(cfg.Methods) > 0 {
+		rule.methods = make(map[string]struct{}, len(cfg.Methods))
+		for _, method := range cfg.Methods {
+			if method == "" || strings.ContainsAny(method, " \t/") {
+				return nil, fmt.Errorf("invalid method %q", method)
+			}
+			rule.methods[strings.ToUpper(method)] = struct{}{}
+		}
+	}
+
+	var err error
+	switch {
+	case cfg.Path != "" && cfg.PathRegex != "":
+		return nil, errors.New("path and pathRegex are mutually exclusive")
+	case cfg.Path != "":
+		if !strings.HasPrefix(cfg.Path, "/") {
+			return nil, errors.New("path must start with a /")
+		}
+		rule.path = regexp.MustCompile(globExpr(cfg.Path, '/'))
+	case cfg.PathRegex != "":
+		rule.path, err = regexp.Compile("^(?:" + cfg.PathRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex: %w", err)
+		}
+	}
+
+	if cfg.Host != "" {
+		rule.host = regexp.MustCompile("(?i)" + globExpr(cfg.Host, '.'))
+	}
+
+	return rule, nil
+}
+
+// globExpr returns a regular expression equivalent to the given glob, `*` and `?` not matching the given separator.
+func globExpr(glob string, sep byte) string {
+	notSep := "[^" + regexp.QuoteMeta(string(sep)) + "]"
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				continue
+			}
+			b.WriteString(notSep + "*")
+		case '?':
+			b.WriteString(notSep)
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String()
+}
+
+// Match returns the first rule matching the forwarded method, path and host of the given request, or nil if none does.
+// Paths are percent-decoded and cleaned before being matched, and requests with ambiguous paths match a rule denying
+// them.
+func (r Rules) Match(req *http.Request) *Rule {
+	if len(r) == 0 {
+		return nil
+	}
+
+	method := req.Header.Get("X-Forwarded-Method")
+
+	rawPath, _, _ := strings.Cut(req.Header.Get("X-Forwarded-Uri"), "?")
+	path, ok := normalizePath(rawPath)
+	if !ok {
+		return ambiguousPathRule
+	}
+
+	host := req.Header.Get("X-Forwarded-Host")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, rule := range r {
+		if rule.match(method, path, host) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// normalizePath returns the percent-decoded and cleaned version of the given request path. It returns false if the path
+// is ambiguous, meaning upstreams could resolve it differently: when it isn't absolute, holds invalid or encoded
+// separators, double encoded characters or dot segments.
+func normalizePath(p string) (string, bool) {
+	// Requests without forwarded URI only match rules without path.
+	if p == "" {
+		return "", true
+	}
+	if !strings.HasPrefix(p, "/") || hasEncodedSeparator(p) {
+		return "", false
+	}
+
+	decoded, err := url.PathUnescape(p)
+	if err != nil {
+		return "", false
+	}
+	if strings.ContainsAny(decoded, "\\\x00") || hasEncodedSeparator(decoded) || strings.Contains(strings.ToLower(decoded), "%2e") {
+		return "", false
+	}
+
+	for _, segment := range strings.Split(decoded, "/") {
+		if segment == "." || segment == ".." {
+			return "", false
+		}
+	}
+
+	cleaned := path.Clean(decoded)
+	if strings.HasSuffix(decoded, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned, true
+}
+
+// hasEncodedSeparator returns whether the given path holds a percent-encoded slash or backslash.
+func hasEncodedSeparator(p string) bool {
+	p = strings.ToLower(p)
+	return strings.Contains(p, "%2f") || strings.Contains(p, "%5c")
+}
+
+func (r *Rule) match(method, path, host string) bool {
+	if r.methods != nil {
+		if _, ok := r.methods[strings.ToUpper(method)]; !ok {
+			return false
+		}
+	}
+
+	if r.path != nil && !r.path.MatchString(path) {
+		return false
+	}
+
+	if r.host != nil && !r.host.MatchString(host) {
+		return false
+	}
+
+	return true
+}
+
+// ServeAction responds to the given request if the rule allows or denies it regardless of the identity of the
+// requester, and returns whether it did. It is safe to call on a nil Rule.
+func (r *Rule) ServeAction(rw http.ResponseWriter, req *http.Request) bool {
+	if r == nil {
+		return false
+	}
+
+	switch r.action {
+	case ActionAllow:
+		authmetrics.SetReason(req.Context(), authmetrics.ReasonRuleAllowed)
+		rw.WriteHeader(http.StatusOK)
+		return true
+
+	case ActionDeny:
+		reason := authmetrics.ReasonRuleDenied
+		if r == ambiguousPathRule {
+			reason = authmetrics.ReasonAmbiguousPath
+		}
+
+		authmetrics.SetReason(req.Context(), reason)
+		rw.WriteHeader(http.StatusForbidden)
+		return true
+
+	default:
+		return false
+	}
+}
+
+// AllowClaims returns whether the given claims satisfy the claims expression of the rule. It is safe to call on a nil
+// Rule.
+func (r *Rule) AllowClaims(claims map[string]interface{}) bool {
+	if r == nil || r.claims == nil {
+		return true
+	}
+
+	return r.claims(claims)
+}
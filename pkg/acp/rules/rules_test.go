@@ -0,0 +1,370 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfgs    []Config
+		wantErr string
+	}{
+		{
+			desc: "valid rules",
+			cfgs: []Config{
+				{Path: "/healthz", Action: ActionAllow},
+				{Methods: []string{"post", "DELETE"}, PathRegex: "/api/v[0-9]+/.*", Claims: "Equals(`grp`, `admin`)"},
+				{Host: "*.example.com", Action: ActionDeny},
+			},
+		},
+		{
+			desc:    "missing action or claims",
+			cfgs:    []Config{{Path: "/healthz"}},
+			wantErr: "rule 0: missing action or claims",
+		},
+		{
+			desc:    "action and claims",
+			cfgs:    []Config{{Action: ActionAllow, Claims: "Equals(`grp`, `admin`)"}},
+			wantErr: "rule 0: action and claims are mutually exclusive",
+		},
+		{
+			desc:    "unsupported action",
+			cfgs:    []Config{{Path: "/", Action: "maybe"}},
+			wantErr: `rule 0: unsupported action "maybe"`,
+		},
+		{
+			desc:    "invalid claims expression",
+			cfgs:    []Config{{Action: ActionAllow}, {Claims: "Equals(`grp`)"}},
+			wantErr: "rule 1: invalid claims expression: unable to parse expression: Equals: expected 2 arguments, got 1",
+		},
+		{
+			desc:    "invalid method",
+			cfgs:    []Config{{Methods: []string{""}, Action: ActionDeny}},
+			wantErr: `rule 0: invalid method ""`,
+		},
+		{
+			desc:    "path and path regex",
+			cfgs:    []Config{{Path: "/", PathRegex: "/.*", Action: ActionDeny}},
+			wantErr: "rule 0: path and pathRegex are mutually exclusive",
+		},
+		{
+			desc:    "relative path",
+			cfgs:    []Config{{Path: "public/*", Action: ActionAllow}},
+			wantErr: "rule 0: path must start with a /",
+		},
+		{
+			desc:    "invalid path regex",
+			cfgs:    []Config{{PathRegex: "/[a-z", Action: ActionAllow}},
+			wantErr: "rule 0: invalid path regex: error parsing regexp: missing closing ]: `[a-z)$`",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(test.cfgs)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRules_Match(t *testing.T) {
+	rules, err := New([]Config{
+		{Path: "/healthz", Action: ActionAllow},
+		{Path: "/public/**", Action: ActionAllow},
+		{Methods: []string{"GET"}, Path: "/api/*", Claims: "Contains(`groups`, `reader`)"},
+		{Methods: []string{"POST", "DELETE"}, PathRegex: "/api/.+", Claims: "Contains(`groups`, `admin`)"},
+		{Host: "admin.*.example.com", Action: ActionDeny},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		method   string
+		uri      string
+		host     string
+		wantRule int
+	}{
+		{
+			desc:     "exact path",
+			method:   http.MethodGet,
+			uri:      "/healthz",
+			host:     "app.example.com",
+			wantRule: 0,
+		},
+		{
+			desc:     "exact path with a query",
+			method:   http.MethodGet,
+			uri:      "/healthz?verbose=1",
+			host:     "app.example.com",
+			wantRule: 0,
+		},
+		{
+			desc:     "double star matches nested paths",
+			method:   http.MethodGet,
+			uri:      "/public/assets/app.js",
+			host:     "app.example.com",
+			wantRule: 1,
+		},
+		{
+			desc:     "method and path",
+			method:   http.MethodGet,
+			uri:      "/api/users",
+			host:     "app.example.com",
+			wantRule: 2,
+		},
+		{
+			desc:     "star doesn't match nested paths",
+			method:   http.MethodPost,
+			uri:      "/api/users/1",
+			host:     "app.example.com",
+			wantRule: 3,
+		},
+		{
+			desc:     "host with a port",
+			method:   http.MethodPut,
+			uri:      "/api/users/1",
+			host:     "ADMIN.eu.example.com:8443",
+			wantRule: 4,
+		},
+		{
+			desc:     "no match",
+			method:   http.MethodPut,
+			uri:      "/api/users/1",
+			host:     "app.example.com",
+			wantRule: -1,
+		},
+		{
+			desc:     "percent-encoded path",
+			method:   http.MethodGet,
+			uri:      "/%68ealthz",
+			host:     "app.example.com",
+			wantRule: 0,
+		},
+		{
+			desc:     "duplicate slashes",
+			method:   http.MethodGet,
+			uri:      "//public//assets/app.js",
+			host:     "app.example.com",
+			wantRule: 1,
+		},
+		{
+			desc:     "trailing slash is kept",
+			method:   http.MethodGet,
+			uri:      "/healthz/",
+			host:     "app.example.com",
+			wantRule: -1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/acp", nil)
+			req.Header.Set("X-Forwarded-Method", test.method)
+			req.Header.Set("X-Forwarded-Uri", test.uri)
+			req.Header.Set("X-Forwarded-Host", test.host)
+
+			got := rules.Match(req)
+			if test.wantRule < 0 {
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.Same(t, rules[test.wantRule], got)
+		})
+	}
+}
+
+func TestRules_Match_ambiguousPath(t *testing.T) {
+	rules, err := New([]Config{
+		{Path: "/public/**", Action: ActionAllow},
+		{Path: "/admin/**", Action: ActionDeny},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		uri      string
+		wantRule *Rule
+	}{
+		{
+			desc:     "dot-dot segment",
+			uri:      "/public/../admin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "encoded dot-dot segment",
+			uri:      "/public/%2e%2e/admin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "partially encoded dot-dot segment",
+			uri:      "/public/.%2E/admin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "double encoded dot-dot segment",
+			uri:      "/public/%252e%252e/admin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "dot segment",
+			uri:      "/./admin/users",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "encoded slash",
+			uri:      "/public%2F..%2Fadmin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "encoded backslash",
+			uri:      "/public/..%5Cadmin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "backslash",
+			uri:      "/public/..\\admin",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "invalid escape",
+			uri:      "/public/%zz",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "absolute URI",
+			uri:      "http://app.example.com/admin/users",
+			wantRule: ambiguousPathRule,
+		},
+		{
+			desc:     "duplicate slash before a denied path",
+			uri:      "//admin/users",
+			wantRule: rules[1],
+		},
+		{
+			desc:     "encoded character in a denied path",
+			uri:      "/%61dmin/users",
+			wantRule: rules[1],
+		},
+		{
+			desc:     "dots in a file name",
+			uri:      "/public/app..js",
+			wantRule: rules[0],
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/acp", nil)
+			req.Header.Set("X-Forwarded-Method", http.MethodGet)
+			req.Header.Set("X-Forwarded-Uri", test.uri)
+			req.Header.Set("X-Forwarded-Host", "app.example.com")
+
+			got := rules.Match(req)
+			assert.Same(t, test.wantRule, got)
+
+			if test.wantRule == ambiguousPathRule {
+				rw := httptest.NewRecorder()
+				assert.True(t, got.ServeAction(rw, req))
+				assert.Equal(t, http.StatusForbidden, rw.Code)
+			}
+		})
+	}
+}
+
+func TestRule_ServeAction(t *testing.T) {
+	rules, err := New([]Config{
+		{Path: "/healthz", Action: ActionAllow},
+		{Path: "/admin/**", Action: ActionDeny},
+		{Claims: "Equals(`grp`, `admin`)"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc        string
+		rule        *Rule
+		wantServed  bool
+		wantCode    int
+		wantAllowed bool
+	}{
+		{
+			desc:        "allow",
+			rule:        rules[0],
+			wantServed:  true,
+			wantCode:    http.StatusOK,
+			wantAllowed: true,
+		},
+		{
+			desc:        "deny",
+			rule:        rules[1],
+			wantServed:  true,
+			wantCode:    http.StatusForbidden,
+			wantAllowed: true,
+		},
+		{
+			desc:        "claims",
+			rule:        rules[2],
+			wantCode:    http.StatusOK,
+			wantAllowed: false,
+		},
+		{
+			desc:        "no rule",
+			wantCode:    http.StatusOK,
+			wantAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+
+			assert.Equal(t, test.wantServed, test.rule.ServeAction(rw, httptest.NewRequest(http.MethodGet, "/acp", nil)))
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, test.wantAllowed, test.rule.AllowClaims(map[string]interface{}{"grp": "dev"}))
+		})
+	}
+}
@@ -188,7 +188,31 @@ func needUpdate(a ACP, policy *hubv1alpha1.AccessControlPolicy) bool {
 }
 
 func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
-	spec := hubv1alpha1.AccessControlPolicySpec{}
+	spec := hubv1alpha1.AccessControlPolicySpec{
+		Enforcement: string(a.Enforcement),
+	}
+
+	for _, rule := range a.Rules {
+		spec.Rules = append(spec.Rules, hubv1alpha1.AccessControlPolicyRule{
+			Methods:   rule.Methods,
+			Path:      rule.Path,
+			PathRegex: rule.PathRegex,
+			Host:      rule.Host,
+			Action:    string(rule.Action),
+			Claims:    rule.Claims,
+		})
+	}
+
+	if tok := a.IdentityToken; tok != nil {
+		spec.IdentityToken = &hubv1alpha1.AccessControlPolicyIdentityToken{
+			Secret:   tok.Secret,
+			Header:   tok.Header,
+			Audience: tok.Audience,
+			Claims:   tok.Claims,
+			TTL:      tok.TTL,
+		}
+	}
+
 	switch {
 	case a.OIDCGoogle != nil:
 		spec.OIDCGoogle = &hubv1alpha1.AccessControlOIDCGoogle{
@@ -198,6 +222,7 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 			AuthParams:     a.OIDCGoogle.AuthParams,
 			ForwardHeaders: a.OIDCGoogle.ForwardHeaders,
 			Emails:         a.OIDCGoogle.Emails,
+			PKCE:           a.OIDCGoogle.PKCE,
 		}
 
 		if a.OIDCGoogle.Secret != nil {
@@ -223,18 +248,22 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 				Domain:   a.OIDCGoogle.Session.Domain,
 				Path:     a.OIDCGoogle.Session.Path,
 				Refresh:  a.OIDCGoogle.Session.Refresh,
+				Store:    a.OIDCGoogle.Session.Store,
+				TTL:      a.OIDCGoogle.Session.TTL,
 			}
 		}
 	case a.OIDC != nil:
 		spec.OIDC = &hubv1alpha1.AccessControlOIDC{
-			Issuer:         a.OIDC.Issuer,
-			ClientID:       a.OIDC.ClientID,
-			RedirectURL:    a.OIDC.RedirectURL,
-			LogoutURL:      a.OIDC.LogoutURL,
-			AuthParams:     a.OIDC.AuthParams,
-			Scopes:         a.OIDC.Scopes,
-			ForwardHeaders: a.OIDC.ForwardHeaders,
-			Claims:         a.OIDC.Claims,
+			Issuer:                a.OIDC.Issuer,
+			ClientID:              a.OIDC.ClientID,
+			RedirectURL:           a.OIDC.RedirectURL,
+			LogoutURL:             a.OIDC.LogoutURL,
+			PostLogoutRedirectURL: a.OIDC.PostLogoutRedirectURL,
+			AuthParams:            a.OIDC.AuthParams,
+			Scopes:                a.OIDC.Scopes,
+			ForwardHeaders:        a.OIDC.ForwardHeaders,
+			Claims:                a.OIDC.Claims,
+			PKCE:                  a.OIDC.PKCE,
 		}
 
 		if a.OIDC.Secret != nil {
@@ -244,6 +273,22 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 			}
 		}
 
+		if a.OIDC.UserInfo != nil {
+			spec.OIDC.UserInfo = &hubv1alpha1.UserInfo{Precedence: a.OIDC.UserInfo.Precedence}
+		}
+
+		if a.OIDC.Provider != nil {
+			spec.OIDC.Provider = &hubv1alpha1.OIDCProvider{
+				Name:           a.OIDC.Provider.Name,
+				TenantID:       a.OIDC.Provider.TenantID,
+				URL:            a.OIDC.Provider.URL,
+				Realm:          a.OIDC.Provider.Realm,
+				GroupsClaim:    a.OIDC.Provider.GroupsClaim,
+				AllowedGroups:  a.OIDC.Provider.AllowedGroups,
+				AllowedDomains: a.OIDC.Provider.AllowedDomains,
+			}
+		}
+
 		if a.OIDC.StateCookie != nil {
 			spec.OIDC.StateCookie = &hubv1alpha1.StateCookie{
 				SameSite: a.OIDC.StateCookie.SameSite,
@@ -260,6 +305,8 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 				Domain:   a.OIDC.Session.Domain,
 				Path:     a.OIDC.Session.Path,
 				Refresh:  a.OIDC.Session.Refresh,
+				Store:    a.OIDC.Session.Store,
+				TTL:      a.OIDC.Session.TTL,
 			}
 		}
 
@@ -274,15 +321,152 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 			ForwardHeaders:             a.JWT.ForwardHeaders,
 			TokenQueryKey:              a.JWT.TokenQueryKey,
 			Claims:                     a.JWT.Claims,
+			Issuers:                    a.JWT.Issuers,
+			Audiences:                  a.JWT.Audiences,
+			Leeway:                     a.JWT.Leeway,
+			MaxAge:                     a.JWT.MaxAge,
+			Algorithms:                 a.JWT.Algorithms,
+			KeysSecret:                 a.JWT.KeysSecret,
 		}
 
 	case a.BasicAuth != nil:
 		spec.BasicAuth = &hubv1alpha1.AccessControlPolicyBasicAuth{
 			Users:                    a.BasicAuth.Users,
+			UsersSecret:              a.BasicAuth.UsersSecret,
 			Realm:                    a.BasicAuth.Realm,
 			StripAuthorizationHeader: a.BasicAuth.StripAuthorizationHeader,
 			ForwardUsernameHeader:    a.BasicAuth.ForwardUsernameHeader,
 		}
+
+		if ldapCfg := a.BasicAuth.LDAP; ldapCfg != nil {
+			spec.BasicAuth.LDAP = &hubv1alpha1.AccessControlPolicyBasicAuthLDAP{
+				URL:                 ldapCfg.URL,
+				StartTLS:            ldapCfg.StartTLS,
+				BindSecret:          ldapCfg.BindSecret,
+				BaseDN:              ldapCfg.BaseDN,
+				UserFilter:          ldapCfg.UserFilter,
+				GroupAttribute:      ldapCfg.GroupAttribute,
+				RequiredGroups:      ldapCfg.RequiredGroups,
+				ForwardGroupsHeader: ldapCfg.ForwardGroupsHeader,
+				CacheTTL:            ldapCfg.CacheTTL,
+			}
+		}
+
+	case a.GitHub != nil:
+		spec.GitHub = &hubv1alpha1.AccessControlPolicyGitHub{
+			ClientID:       a.GitHub.ClientID,
+			BaseURL:        a.GitHub.BaseURL,
+			RedirectURL:    a.GitHub.RedirectURL,
+			LogoutURL:      a.GitHub.LogoutURL,
+			Orgs:           a.GitHub.Orgs,
+			Teams:          a.GitHub.Teams,
+			ForwardHeaders: a.GitHub.ForwardHeaders,
+		}
+
+		if a.GitHub.Secret != nil {
+			spec.GitHub.Secret = &corev1.SecretReference{
+				Name:      a.GitHub.Secret.Name,
+				Namespace: a.GitHub.Secret.Namespace,
+			}
+		}
+
+		if a.GitHub.StateCookie != nil {
+			spec.GitHub.StateCookie = &hubv1alpha1.StateCookie{
+				SameSite: a.GitHub.StateCookie.SameSite,
+				Secure:   a.GitHub.StateCookie.Secure,
+				Domain:   a.GitHub.StateCookie.Domain,
+				Path:     a.GitHub.StateCookie.Path,
+			}
+		}
+
+		if a.GitHub.Session != nil {
+			spec.GitHub.Session = &hubv1alpha1.Session{
+				SameSite: a.GitHub.Session.SameSite,
+				Secure:   a.GitHub.Session.Secure,
+				Domain:   a.GitHub.Session.Domain,
+				Path:     a.GitHub.Session.Path,
+				Refresh:  a.GitHub.Session.Refresh,
+				Store:    a.GitHub.Session.Store,
+				TTL:      a.GitHub.Session.TTL,
+			}
+		}
+
+	case a.OAuthIntrospection != nil:
+		spec.OAuthIntrospection = &hubv1alpha1.AccessControlPolicyOAuthIntrospection{
+			URL:                      a.OAuthIntrospection.URL,
+			ClientID:                 a.OAuthIntrospection.ClientID,
+			TokenTypeHint:            a.OAuthIntrospection.TokenTypeHint,
+			TokenQueryKey:            a.OAuthIntrospection.TokenQueryKey,
+			StripAuthorizationHeader: a.OAuthIntrospection.StripAuthorizationHeader,
+			ForwardHeaders:           a.OAuthIntrospection.ForwardHeaders,
+			Claims:                   a.OAuthIntrospection.Claims,
+		}
+
+		if a.OAuthIntrospection.Secret != nil {
+			spec.OAuthIntrospection.Secret = &corev1.SecretReference{
+				Name:      a.OAuthIntrospection.Secret.Name,
+				Namespace: a.OAuthIntrospection.Secret.Namespace,
+			}
+		}
+
+	case a.APIKey != nil:
+		spec.APIKey = &hubv1alpha1.AccessControlPolicyAPIKey{
+			Header:          a.APIKey.Header,
+			Query:           a.APIKey.Query,
+			Cookie:          a.APIKey.Cookie,
+			Keys:            a.APIKey.Keys,
+			SecretNamespace: a.APIKey.SecretNamespace,
+			SecretSelector:  a.APIKey.SecretSelector,
+			ForwardHeaders:  a.APIKey.ForwardHeaders,
+		}
+
+	case a.MTLS != nil:
+		spec.MTLS = &hubv1alpha1.AccessControlPolicyMTLS{
+			ForwardHeaders: a.MTLS.ForwardHeaders,
+			Claims:         a.MTLS.Claims,
+		}
+
+		if ca := a.MTLS.CA; ca != nil {
+			spec.MTLS.CA = &hubv1alpha1.AccessControlPolicyMTLSCA{Key: ca.Key}
+
+			if ca.Secret != nil {
+				spec.MTLS.CA.Secret = &corev1.SecretReference{
+					Name:      ca.Secret.Name,
+					Namespace: ca.Secret.Namespace,
+				}
+			}
+
+			if ca.ConfigMap != nil {
+				spec.MTLS.CA.ConfigMap = &hubv1alpha1.ConfigMapReference{
+					Name:      ca.ConfigMap.Name,
+					Namespace: ca.ConfigMap.Namespace,
+				}
+			}
+		}
+
+	case a.IPAllowList != nil:
+		spec.IPAllowList = &hubv1alpha1.AccessControlPolicyIPAllowList{
+			SourceRange:       a.IPAllowList.SourceRange,
+			DeniedSourceRange: a.IPAllowList.DeniedSourceRange,
+			Depth:             a.IPAllowList.Depth,
+		}
+
+	case a.Composite != nil:
+		spec.Composite = &hubv1alpha1.AccessControlPolicyComposite{
+			AllOf: a.Composite.AllOf,
+			AnyOf: a.Composite.AnyOf,
+		}
+
+	case a.ExternalAuthz != nil:
+		spec.ExternalAuthz = &hubv1alpha1.AccessControlPolicyExternalAuthz{
+			URL:                  a.ExternalAuthz.URL,
+			AuthenticationPolicy: a.ExternalAuthz.AuthenticationPolicy,
+			RequestHeaders:       a.ExternalAuthz.RequestHeaders,
+			ResponseHeaders:      a.ExternalAuthz.ResponseHeaders,
+			Timeout:              a.ExternalAuthz.Timeout,
+			FailOpen:             a.ExternalAuthz.FailOpen,
+			CacheTTL:             a.ExternalAuthz.CacheTTL,
+		}
 	}
 
 	return spec
@@ -47,10 +47,33 @@ type AccessControlPolicy struct {
 
 // AccessControlPolicySpec configures an access control policy.
 type AccessControlPolicySpec struct {
-	JWT        *AccessControlPolicyJWT       `json:"jwt,omitempty"`
-	BasicAuth  *AccessControlPolicyBasicAuth `json:"basicAuth,omitempty"`
-	OIDC       *AccessControlOIDC            `json:"oidc,omitempty"`
-	OIDCGoogle *AccessControlOIDCGoogle      `json:"oidcGoogle,omitempty"`
+	JWT                *AccessControlPolicyJWT                `json:"jwt,omitempty"`
+	BasicAuth          *AccessControlPolicyBasicAuth          `json:"basicAuth,omitempty"`
+	OIDC               *AccessControlOIDC                     `json:"oidc,omitempty"`
+	OIDCGoogle         *AccessControlOIDCGoogle               `json:"oidcGoogle,omitempty"`
+	GitHub             *AccessControlPolicyGitHub             `json:"github,omitempty"`
+	OAuthIntrospection *AccessControlPolicyOAuthIntrospection `json:"oauthIntrospection,omitempty"`
+	APIKey             *AccessControlPolicyAPIKey             `json:"apiKey,omitempty"`
+	MTLS               *AccessControlPolicyMTLS               `json:"mtls,omitempty"`
+	IPAllowList        *AccessControlPolicyIPAllowList        `json:"ipAllowList,omitempty"`
+	Composite          *AccessControlPolicyComposite          `json:"composite,omitempty"`
+	ExternalAuthz      *AccessControlPolicyExternalAuthz      `json:"externalAuthz,omitempty"`
+
+	// Enforcement defines whether decisions are enforced. In `audit` mode, requests are evaluated but never denied,
+	// the decision which would have been made being forwarded upstream in the X-Hub-Acp-Decision header. Redirections
+	// to the login page of providers are still made, so that sessions can be established. Policies referenced by other
+	// policies are always enforced by them. Defaults to `enforce`.
+	// +kubebuilder:validation:Enum=enforce;audit
+	Enforcement string `json:"enforcement,omitempty"`
+
+	// Rules is an ordered list of rules matching requests on their forwarded method, path and host. The first matching
+	// rule applies. Paths are percent-decoded and cleaned before being matched, requests with ambiguous paths, holding
+	// encoded slashes or dot segments, being denied. Rules are only supported by JWT, OIDC and basic auth policies.
+	Rules []AccessControlPolicyRule `json:"rules,omitempty"`
+
+	// IdentityToken makes the auth server forward a signed identity token describing the authenticated principal to
+	// upstreams, for each request the policy allows.
+	IdentityToken *AccessControlPolicyIdentityToken `json:"identityToken,omitempty"`
 }
 
 // Hash return AccessControlPolicySpec hash.
@@ -66,6 +89,46 @@ func (a AccessControlPolicySpec) Hash() (string, error) {
 	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
 }
 
+// AccessControlPolicyRule matches requests on their forwarded method, path and host. Matching requests are either
+// allowed or denied without being authenticated, or must satisfy the rule claims expression, in addition to the policy
+// one, once authenticated. Exactly one of Action and Claims must be set.
+type AccessControlPolicyRule struct {
+	// Methods lists the matched methods. All methods are matched when empty.
+	Methods []string `json:"methods,omitempty"`
+	// Path is a glob matching the percent-decoded and cleaned path of requests. `*` matches any sequence of characters
+	// but `/`, `**` matches any sequence of characters and `?` matches any character but `/`.
+	Path string `json:"path,omitempty"`
+	// PathRegex is a regular expression matching the whole path of requests. It can't be used along with Path.
+	PathRegex string `json:"pathRegex,omitempty"`
+	// Host is a glob matching the host of requests, ignoring case. `*` matches any sequence of characters but `.`.
+	Host string `json:"host,omitempty"`
+
+	// Action allows or denies matching requests without authenticating them.
+	// +kubebuilder:validation:Enum=allow;deny
+	Action string `json:"action,omitempty"`
+	// Claims is the claims expression matching requests must satisfy.
+	Claims string `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyIdentityToken configures the identity tokens forwarded to upstreams. Tokens are JWTs holding the
+// `sub`, `policy` and `auth_method` claims, along with the selected claims of the authenticated identity. They can be
+// verified using the keys published by the auth server under `/.well-known/jwks.json`.
+type AccessControlPolicyIdentityToken struct {
+	// Secret references the Secret holding the PEM encoded RSA, ECDSA or Ed25519 private key signing tokens, under the
+	// `signingKey` key.
+	Secret *corev1.SecretReference `json:"secret"`
+	// Header is the header tokens are forwarded in. Defaults to X-Hub-Identity.
+	Header string `json:"header,omitempty"`
+	// Audience is the audience of tokens, if any.
+	Audience string `json:"audience,omitempty"`
+	// Claims lists the claims of the authenticated identity copied in tokens.
+	Claims []string `json:"claims,omitempty"`
+	// TTL is the lifetime of tokens, in seconds. Defaults to 60.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	TTL int `json:"ttl,omitempty"`
+}
+
 // AccessControlPolicyJWT configures a JWT access control policy.
 type AccessControlPolicyJWT struct {
 	SigningSecret              string            `json:"signingSecret,omitempty"`
@@ -77,14 +140,63 @@ type AccessControlPolicyJWT struct {
 	ForwardHeaders             map[string]string `json:"forwardHeaders,omitempty"`
 	TokenQueryKey              string            `json:"tokenQueryKey,omitempty"`
 	Claims                     string            `json:"claims,omitempty"`
+
+	// Issuers lists the accepted `iss` claims. All issuers are accepted when empty.
+	Issuers []string `json:"issuers,omitempty"`
+	// Audiences lists the accepted audiences. When set, the `aud` claim must hold at least one of them.
+	Audiences []string `json:"audiences,omitempty"`
+	// Leeway is the clock skew, in seconds, tolerated when validating the `exp`, `nbf` and `iat` claims.
+	// +kubebuilder:validation:Minimum=0
+	Leeway int `json:"leeway,omitempty"`
+	// MaxAge is the maximum age of tokens, in seconds, computed from their `iat` claim which is then required.
+	// +kubebuilder:validation:Minimum=0
+	MaxAge int `json:"maxAge,omitempty"`
+	// Algorithms lists the accepted signing algorithms. Defaults to HS256, HS384 and HS512 when a signing secret is set,
+	// and to the RS, PS and ES algorithms when a public key or JWKs are set.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// KeysSecret references a Secret holding key material, which isn't synced to the platform unlike inline values.
+	// It may hold a signing secret under the `signingSecret` key, a PEM-encoded public key under the `publicKey` key,
+	// a JWK set under the `jwks` key, and a PEM-encoded private key decrypting JWEs under the `decryptionKey` key.
+	// Its entries take precedence over the inline ones.
+	KeysSecret *corev1.SecretReference `json:"keysSecret,omitempty"`
 }
 
 // AccessControlPolicyBasicAuth holds the HTTP basic authentication configuration.
 type AccessControlPolicyBasicAuth struct {
-	Users                    []string `json:"users,omitempty"`
-	Realm                    string   `json:"realm,omitempty"`
-	StripAuthorizationHeader bool     `json:"stripAuthorizationHeader,omitempty"`
-	ForwardUsernameHeader    string   `json:"forwardUsernameHeader,omitempty"`
+	Users []string `json:"users,omitempty"`
+	// UsersSecret references a Secret holding users in the htpasswd format under the `users` key.
+	// These users are merged with the inline users.
+	UsersSecret              *corev1.SecretReference `json:"usersSecret,omitempty"`
+	Realm                    string                  `json:"realm,omitempty"`
+	StripAuthorizationHeader bool                    `json:"stripAuthorizationHeader,omitempty"`
+	ForwardUsernameHeader    string                  `json:"forwardUsernameHeader,omitempty"`
+	// LDAP authenticates users against an LDAP server instead of Users and UsersSecret.
+	LDAP *AccessControlPolicyBasicAuthLDAP `json:"ldap,omitempty"`
+}
+
+// AccessControlPolicyBasicAuthLDAP holds the configuration of the authentication of basic auth users against an LDAP
+// server.
+type AccessControlPolicyBasicAuthLDAP struct {
+	// URL is the URL of the LDAP server, using the `ldap` or `ldaps` scheme.
+	URL string `json:"url,omitempty"`
+	// StartTLS upgrades `ldap` connections to TLS.
+	StartTLS bool `json:"startTls,omitempty"`
+	// BindSecret references the Secret holding the credentials of the service account used to search users, under
+	// the `bindDN` and `bindPassword` keys.
+	BindSecret *corev1.SecretReference `json:"bindSecret,omitempty"`
+	// BaseDN is the DN users are searched from.
+	BaseDN string `json:"baseDn,omitempty"`
+	// UserFilter is the filter used to search users, `%s` being replaced by the username. Defaults to `(uid=%s)`.
+	UserFilter string `json:"userFilter,omitempty"`
+	// GroupAttribute is the user attribute listing the groups of the user. Defaults to `memberOf`.
+	GroupAttribute string `json:"groupAttribute,omitempty"`
+	// RequiredGroups lists the groups users must be a member of at least one of.
+	RequiredGroups []string `json:"requiredGroups,omitempty"`
+	// ForwardGroupsHeader is the header populated with the groups of the user.
+	ForwardGroupsHeader string `json:"forwardGroupsHeader,omitempty"`
+	// CacheTTL is the number of seconds successful authentications are cached for. Defaults to 60, negative values
+	// disable caching.
+	CacheTTL int `json:"cacheTtl,omitempty"`
 }
 
 // AccessControlOIDC holds the OIDC authentication configuration.
@@ -101,6 +213,22 @@ type AccessControlOIDC struct {
 	StateCookie *StateCookie `json:"stateCookie,omitempty"`
 	Session     *Session     `json:"session,omitempty"`
 
+	// PKCE enables Proof Key for Code Exchange (RFC 7636) using S256 code challenges. It defaults to true when the
+	// provider advertises support for S256 code challenges.
+	PKCE *bool `json:"pkce,omitempty"`
+
+	// PostLogoutRedirectURL is the URL the provider redirects users to once logged out. Users navigating to the logout
+	// URL are also logged out from the provider when it supports RP-initiated logout.
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl,omitempty"`
+
+	// UserInfo enables fetching claims from the provider UserInfo endpoint after login and on session refresh. Those
+	// claims are merged with the ID token ones before evaluating the claims expression and forwarding headers.
+	UserInfo *UserInfo `json:"userInfo,omitempty"`
+
+	// Provider configures the policy for a well-known provider. The issuer, the default scopes and the claim holding
+	// the groups of users, normalized into the `groups` claim, are derived from it.
+	Provider *OIDCProvider `json:"provider,omitempty"`
+
 	Scopes         []string          `json:"scopes,omitempty"`
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	Claims         string            `json:"claims,omitempty"`
@@ -119,11 +247,134 @@ type AccessControlOIDCGoogle struct {
 	StateCookie *StateCookie `json:"stateCookie,omitempty"`
 	Session     *Session     `json:"session,omitempty"`
 
+	// PKCE enables Proof Key for Code Exchange (RFC 7636) using S256 code challenges. It defaults to true when the
+	// provider advertises support for S256 code challenges.
+	PKCE *bool `json:"pkce,omitempty"`
+
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	// Emails are the allowed emails to connect.
 	Emails []string `json:"emails"`
 }
 
+// AccessControlPolicyGitHub holds the GitHub OAuth2 authentication configuration.
+type AccessControlPolicyGitHub struct {
+	ClientID string `json:"clientId,omitempty"`
+
+	Secret *corev1.SecretReference `json:"secret,omitempty"`
+
+	// BaseURL is the URL of the GitHub instance. It defaults to https://github.com and must be set to the URL of the
+	// instance when using GitHub Enterprise Server.
+	BaseURL string `json:"baseUrl,omitempty"`
+
+	RedirectURL string `json:"redirectUrl,omitempty"`
+	LogoutURL   string `json:"logoutUrl,omitempty"`
+
+	StateCookie *StateCookie `json:"stateCookie,omitempty"`
+	// Session configures the session cookie. Its TTL is the number of seconds memberships are trusted for before
+	// being resolved again. Only the cookie store is supported.
+	Session *Session `json:"session,omitempty"`
+
+	// Orgs lists the organizations users must be a member of at least one of.
+	Orgs []string `json:"orgs,omitempty"`
+	// Teams lists the teams users must be a member of at least one of, formatted as `org/team-slug`.
+	Teams []string `json:"teams,omitempty"`
+
+	// ForwardHeaders defines headers populated with the `login`, `id`, `name`, `email`, `orgs` or `teams` of the user.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// AccessControlPolicyOAuthIntrospection holds the OAuth 2.0 token introspection (RFC 7662) configuration.
+type AccessControlPolicyOAuthIntrospection struct {
+	URL      string `json:"url,omitempty"`
+	ClientID string `json:"clientId,omitempty"`
+
+	Secret *corev1.SecretReference `json:"secret,omitempty"`
+
+	TokenTypeHint            string            `json:"tokenTypeHint,omitempty"`
+	TokenQueryKey            string            `json:"tokenQueryKey,omitempty"`
+	StripAuthorizationHeader bool              `json:"stripAuthorizationHeader,omitempty"`
+	ForwardHeaders           map[string]string `json:"forwardHeaders,omitempty"`
+	Claims                   string            `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyAPIKey holds the API key configuration.
+type AccessControlPolicyAPIKey struct {
+	Header string `json:"header,omitempty"`
+	Query  string `json:"query,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+
+	// Keys are inline keys given as `consumer:sha256-hex-hash`.
+	Keys []string `json:"keys,omitempty"`
+
+	// SecretSelector selects the Secrets holding keys in SecretNamespace, which is required when SecretSelector is set.
+	SecretNamespace string            `json:"secretNamespace,omitempty"`
+	SecretSelector  map[string]string `json:"secretSelector,omitempty"`
+
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// AccessControlPolicyMTLS holds the mTLS client certificate configuration.
+type AccessControlPolicyMTLS struct {
+	// CA references the CA bundle used to verify client certificates.
+	CA *AccessControlPolicyMTLSCA `json:"ca,omitempty"`
+
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	Claims         string            `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyMTLSCA references a PEM-encoded CA bundle held by either a Secret or a ConfigMap.
+type AccessControlPolicyMTLSCA struct {
+	Secret    *corev1.SecretReference `json:"secret,omitempty"`
+	ConfigMap *ConfigMapReference     `json:"configMap,omitempty"`
+	// Key is the data key holding the bundle. Defaults to `ca.crt`.
+	Key string `json:"key,omitempty"`
+}
+
+// ConfigMapReference references a ConfigMap in any namespace.
+type ConfigMapReference struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AccessControlPolicyIPAllowList holds the IP allow list configuration.
+type AccessControlPolicyIPAllowList struct {
+	// SourceRange lists the allowed IPs and CIDRs.
+	SourceRange []string `json:"sourceRange,omitempty"`
+	// DeniedSourceRange lists the denied IPs and CIDRs. It takes precedence over SourceRange.
+	DeniedSourceRange []string `json:"deniedSourceRange,omitempty"`
+	// Depth is the number of trusted proxies in front of the ingress controller.
+	Depth int `json:"depth,omitempty"`
+}
+
+// AccessControlPolicyComposite holds the configuration of a policy combining other policies, referenced by name.
+// Exactly one of AllOf and AnyOf must be set.
+type AccessControlPolicyComposite struct {
+	// AllOf requires all the referenced policies to allow the request.
+	AllOf []string `json:"allOf,omitempty"`
+	// AnyOf requires at least one of the referenced policies to allow the request.
+	AnyOf []string `json:"anyOf,omitempty"`
+}
+
+// AccessControlPolicyExternalAuthz holds the configuration of a policy delegating authorization decisions to an external
+// service.
+type AccessControlPolicyExternalAuthz struct {
+	// URL is the URL decision requests are POSTed to.
+	URL string `json:"url"`
+	// AuthenticationPolicy optionally references a JWT or OIDC policy authenticating requests before asking for a
+	// decision. The verified claims are sent along with the decision request.
+	AuthenticationPolicy string `json:"authenticationPolicy,omitempty"`
+	// RequestHeaders lists the request headers sent along with the decision request.
+	RequestHeaders []string `json:"requestHeaders,omitempty"`
+	// ResponseHeaders lists the headers the decision service is allowed to add to the request.
+	ResponseHeaders []string `json:"responseHeaders,omitempty"`
+	// Timeout is the number of seconds to wait for a decision. Defaults to 5.
+	Timeout int `json:"timeout,omitempty"`
+	// FailOpen allows requests when no decision could be obtained. Requests are denied by default.
+	FailOpen bool `json:"failOpen,omitempty"`
+	// CacheTTL is the number of seconds decisions are cached for, per subject and route. Caching is disabled by default.
+	CacheTTL int `json:"cacheTtl,omitempty"`
+}
+
 // StateCookie holds state cookie configuration.
 type StateCookie struct {
 	SameSite string `json:"sameSite,omitempty"`
@@ -132,6 +383,34 @@ type StateCookie struct {
 	Path     string `json:"path,omitempty"`
 }
 
+// OIDCProvider holds the configuration of a well-known OIDC provider.
+type OIDCProvider struct {
+	// Name is the name of the provider.
+	// +kubebuilder:validation:Enum=azureAD;okta;keycloak;gitlab
+	Name string `json:"name"`
+	// TenantID is the Azure AD tenant ID.
+	TenantID string `json:"tenantId,omitempty"`
+	// URL is the URL of the Okta organization, of the Keycloak server or of a self-managed GitLab instance.
+	URL string `json:"url,omitempty"`
+	// Realm is the Keycloak realm.
+	Realm string `json:"realm,omitempty"`
+
+	// GroupsClaim overrides the provider claim holding the groups of users.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// AllowedGroups lists the groups users must be a member of at least one of.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// AllowedDomains lists the domains the email of users must belong to one of.
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+}
+
+// UserInfo holds the configuration of the retrieval of claims from the UserInfo endpoint.
+type UserInfo struct {
+	// Precedence defines which claims prevail when both the ID token and the UserInfo response hold the same claim.
+	// Defaults to `idToken`.
+	// +kubebuilder:validation:Enum=idToken;userInfo
+	Precedence string `json:"precedence,omitempty"`
+}
+
 // Session holds session configuration.
 type Session struct {
 	SameSite string `json:"sameSite,omitempty"`
@@ -139,6 +418,15 @@ type Session struct {
 	Domain   string `json:"domain,omitempty"`
 	Path     string `json:"path,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	// Store defines where sessions are stored. The `cookie` store keeps the whole session in encrypted cookies, while
+	// the `memory` and `secret` stores keep it server-side and only set an opaque session ID cookie. The `memory` store
+	// can only be used with a single auth-server replica, the `secret` store keeps sessions in Kubernetes Secrets shared
+	// by all replicas. Defaults to `cookie`.
+	// +kubebuilder:validation:Enum=cookie;memory;secret
+	Store string `json:"store,omitempty"`
+	// TTL is the number of seconds server-side sessions are kept for. Defaults to 86400. Refreshed sessions expire, and
+	// users must log in again, once the greater of the TTL and 7 days has elapsed since they logged in.
+	TTL int `json:"ttl,omitempty"`
 }
 
 // AccessControlPolicyStatus is the status of the access control policy.
@@ -146,8 +434,29 @@ type AccessControlPolicyStatus struct {
 	Version  string      `json:"version,omitempty"`
 	SyncedAt metav1.Time `json:"syncedAt,omitempty"`
 	SpecHash string      `json:"specHash,omitempty"`
+
+	// Conditions reports the health of the policy and of the resources and services it depends on.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// IngressCount is the number of ingresses referencing the policy.
+	IngressCount int `json:"ingressCount,omitempty"`
 }
 
+// Types of the conditions reported in the status of access control policies.
+const (
+	// ConditionReady reports whether all the other conditions are true.
+	ConditionReady = "Ready"
+	// ConditionSecretResolved reports whether the Secrets and ConfigMaps referenced by the policy exist and hold the
+	// expected keys.
+	ConditionSecretResolved = "SecretResolved"
+	// ConditionKeysReachable reports whether the JWKs of a JWT policy can be loaded.
+	ConditionKeysReachable = "KeysReachable"
+	// ConditionDiscoveryReachable reports whether the discovery document of the OIDC provider can be fetched.
+	ConditionDiscoveryReachable = "DiscoveryReachable"
+	// ConditionExpressionValid reports whether the claims expressions and the rules of the policy are valid.
+	ConditionExpressionValid = "ExpressionValid"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // AccessControlPolicyList defines a list of access control policy.
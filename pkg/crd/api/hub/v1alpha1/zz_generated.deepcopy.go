@@ -23,6 +23,7 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -51,6 +52,21 @@ func (in *AccessControlOIDC) DeepCopyInto(out *AccessControlOIDC) {
 		*out = new(Session)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PKCE != nil {
+		in, out := &in.PKCE, &out.PKCE
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UserInfo != nil {
+		in, out := &in.UserInfo, &out.UserInfo
+		*out = new(UserInfo)
+		**out = **in
+	}
+	if in.Provider != nil {
+		in, out := &in.Provider, &out.Provider
+		*out = new(OIDCProvider)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Scopes != nil {
 		in, out := &in.Scopes, &out.Scopes
 		*out = make([]string, len(*in))
@@ -101,6 +117,11 @@ func (in *AccessControlOIDCGoogle) DeepCopyInto(out *AccessControlOIDCGoogle) {
 		*out = new(Session)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PKCE != nil {
+		in, out := &in.PKCE, &out.PKCE
+		*out = new(bool)
+		**out = **in
+	}
 	if in.ForwardHeaders != nil {
 		in, out := &in.ForwardHeaders, &out.ForwardHeaders
 		*out = make(map[string]string, len(*in))
@@ -154,6 +175,41 @@ func (in *AccessControlPolicy) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyAPIKey) DeepCopyInto(out *AccessControlPolicyAPIKey) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretSelector != nil {
+		in, out := &in.SecretSelector, &out.SecretSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyAPIKey.
+func (in *AccessControlPolicyAPIKey) DeepCopy() *AccessControlPolicyAPIKey {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyAPIKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicyBasicAuth) DeepCopyInto(out *AccessControlPolicyBasicAuth) {
 	*out = *in
@@ -162,6 +218,16 @@ func (in *AccessControlPolicyBasicAuth) DeepCopyInto(out *AccessControlPolicyBas
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.UsersSecret != nil {
+		in, out := &in.UsersSecret, &out.UsersSecret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.LDAP != nil {
+		in, out := &in.LDAP, &out.LDAP
+		*out = new(AccessControlPolicyBasicAuthLDAP)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -175,6 +241,184 @@ func (in *AccessControlPolicyBasicAuth) DeepCopy() *AccessControlPolicyBasicAuth
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyBasicAuthLDAP) DeepCopyInto(out *AccessControlPolicyBasicAuthLDAP) {
+	*out = *in
+	if in.BindSecret != nil {
+		in, out := &in.BindSecret, &out.BindSecret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.RequiredGroups != nil {
+		in, out := &in.RequiredGroups, &out.RequiredGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyBasicAuthLDAP.
+func (in *AccessControlPolicyBasicAuthLDAP) DeepCopy() *AccessControlPolicyBasicAuthLDAP {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyBasicAuthLDAP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyComposite) DeepCopyInto(out *AccessControlPolicyComposite) {
+	*out = *in
+	if in.AllOf != nil {
+		in, out := &in.AllOf, &out.AllOf
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AnyOf != nil {
+		in, out := &in.AnyOf, &out.AnyOf
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyComposite.
+func (in *AccessControlPolicyComposite) DeepCopy() *AccessControlPolicyComposite {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyComposite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyExternalAuthz) DeepCopyInto(out *AccessControlPolicyExternalAuthz) {
+	*out = *in
+	if in.RequestHeaders != nil {
+		in, out := &in.RequestHeaders, &out.RequestHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResponseHeaders != nil {
+		in, out := &in.ResponseHeaders, &out.ResponseHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyExternalAuthz.
+func (in *AccessControlPolicyExternalAuthz) DeepCopy() *AccessControlPolicyExternalAuthz {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyExternalAuthz)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyGitHub) DeepCopyInto(out *AccessControlPolicyGitHub) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.StateCookie != nil {
+		in, out := &in.StateCookie, &out.StateCookie
+		*out = new(StateCookie)
+		**out = **in
+	}
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = new(Session)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Orgs != nil {
+		in, out := &in.Orgs, &out.Orgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Teams != nil {
+		in, out := &in.Teams, &out.Teams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyGitHub.
+func (in *AccessControlPolicyGitHub) DeepCopy() *AccessControlPolicyGitHub {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyGitHub)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyIPAllowList) DeepCopyInto(out *AccessControlPolicyIPAllowList) {
+	*out = *in
+	if in.SourceRange != nil {
+		in, out := &in.SourceRange, &out.SourceRange
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedSourceRange != nil {
+		in, out := &in.DeniedSourceRange, &out.DeniedSourceRange
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyIPAllowList.
+func (in *AccessControlPolicyIPAllowList) DeepCopy() *AccessControlPolicyIPAllowList {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyIPAllowList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyIdentityToken) DeepCopyInto(out *AccessControlPolicyIdentityToken) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyIdentityToken.
+func (in *AccessControlPolicyIdentityToken) DeepCopy() *AccessControlPolicyIdentityToken {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyIdentityToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicyJWT) DeepCopyInto(out *AccessControlPolicyJWT) {
 	*out = *in
@@ -185,6 +429,26 @@ func (in *AccessControlPolicyJWT) DeepCopyInto(out *AccessControlPolicyJWT) {
 			(*out)[key] = val
 		}
 	}
+	if in.Issuers != nil {
+		in, out := &in.Issuers, &out.Issuers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Algorithms != nil {
+		in, out := &in.Algorithms, &out.Algorithms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeysSecret != nil {
+		in, out := &in.KeysSecret, &out.KeysSecret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
 	return
 }
 
@@ -231,6 +495,109 @@ func (in *AccessControlPolicyList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyMTLS) DeepCopyInto(out *AccessControlPolicyMTLS) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(AccessControlPolicyMTLSCA)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyMTLS.
+func (in *AccessControlPolicyMTLS) DeepCopy() *AccessControlPolicyMTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyMTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyMTLSCA) DeepCopyInto(out *AccessControlPolicyMTLSCA) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapReference)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyMTLSCA.
+func (in *AccessControlPolicyMTLSCA) DeepCopy() *AccessControlPolicyMTLSCA {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyMTLSCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyOAuthIntrospection) DeepCopyInto(out *AccessControlPolicyOAuthIntrospection) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyOAuthIntrospection.
+func (in *AccessControlPolicyOAuthIntrospection) DeepCopy() *AccessControlPolicyOAuthIntrospection {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyOAuthIntrospection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyRule) DeepCopyInto(out *AccessControlPolicyRule) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyRule.
+func (in *AccessControlPolicyRule) DeepCopy() *AccessControlPolicyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicySpec) DeepCopyInto(out *AccessControlPolicySpec) {
 	*out = *in
@@ -254,6 +621,53 @@ func (in *AccessControlPolicySpec) DeepCopyInto(out *AccessControlPolicySpec) {
 		*out = new(AccessControlOIDCGoogle)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.GitHub != nil {
+		in, out := &in.GitHub, &out.GitHub
+		*out = new(AccessControlPolicyGitHub)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OAuthIntrospection != nil {
+		in, out := &in.OAuthIntrospection, &out.OAuthIntrospection
+		*out = new(AccessControlPolicyOAuthIntrospection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(AccessControlPolicyAPIKey)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MTLS != nil {
+		in, out := &in.MTLS, &out.MTLS
+		*out = new(AccessControlPolicyMTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPAllowList != nil {
+		in, out := &in.IPAllowList, &out.IPAllowList
+		*out = new(AccessControlPolicyIPAllowList)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Composite != nil {
+		in, out := &in.Composite, &out.Composite
+		*out = new(AccessControlPolicyComposite)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalAuthz != nil {
+		in, out := &in.ExternalAuthz, &out.ExternalAuthz
+		*out = new(AccessControlPolicyExternalAuthz)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]AccessControlPolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IdentityToken != nil {
+		in, out := &in.IdentityToken, &out.IdentityToken
+		*out = new(AccessControlPolicyIdentityToken)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -271,6 +685,13 @@ func (in *AccessControlPolicySpec) DeepCopy() *AccessControlPolicySpec {
 func (in *AccessControlPolicyStatus) DeepCopyInto(out *AccessControlPolicyStatus) {
 	*out = *in
 	in.SyncedAt.DeepCopyInto(&out.SyncedAt)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -430,6 +851,22 @@ func (in *CatalogStatus) DeepCopy() *CatalogStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
+func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EdgeIngress) DeepCopyInto(out *EdgeIngress) {
 	*out = *in
@@ -648,6 +1085,32 @@ func (in *IngressClassSpec) DeepCopy() *IngressClassSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProvider) DeepCopyInto(out *OIDCProvider) {
+	*out = *in
+	if in.AllowedGroups != nil {
+		in, out := &in.AllowedGroups, &out.AllowedGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedDomains != nil {
+		in, out := &in.AllowedDomains, &out.AllowedDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProvider.
+func (in *OIDCProvider) DeepCopy() *OIDCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Session) DeepCopyInto(out *Session) {
 	*out = *in
@@ -684,3 +1147,19 @@ func (in *StateCookie) DeepCopy() *StateCookie {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserInfo) DeepCopyInto(out *UserInfo) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserInfo.
+func (in *UserInfo) DeepCopy() *UserInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(UserInfo)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -36,9 +36,65 @@ type Middleware struct {
 
 // MiddlewareSpec holds the Middleware configuration.
 type MiddlewareSpec struct {
-	ForwardAuth      *ForwardAuth      `json:"forwardAuth,omitempty"`
-	StripPrefixRegex *StripPrefixRegex `json:"stripPrefixRegex,omitempty"`
-	AddPrefix        *AddPrefix        `json:"addPrefix,omitempty"`
+	ForwardAuth       *ForwardAuth       `json:"forwardAuth,omitempty"`
+	StripPrefixRegex  *StripPrefixRegex  `json:"stripPrefixRegex,omitempty"`
+	AddPrefix         *AddPrefix         `json:"addPrefix,omitempty"`
+	PassTLSClientCert *PassTLSClientCert `json:"passTLSClientCert,omitempty"`
+	Chain             *Chain             `json:"chain,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// Chain holds the Chain configuration.
+type Chain struct {
+	Middlewares []MiddlewareRef `json:"middlewares,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// PassTLSClientCert holds the PassTLSClientCert configuration.
+type PassTLSClientCert struct {
+	PEM  bool                      `json:"pem,omitempty"`
+	Info *TLSClientCertificateInfo `json:"info,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// TLSClientCertificateInfo holds the client TLS certificate info configuration.
+type TLSClientCertificateInfo struct {
+	NotAfter     bool                               `json:"notAfter,omitempty"`
+	NotBefore    bool                               `json:"notBefore,omitempty"`
+	Sans         bool                               `json:"sans,omitempty"`
+	SerialNumber bool                               `json:"serialNumber,omitempty"`
+	Subject      *TLSClientCertificateSubjectDNInfo `json:"subject,omitempty"`
+	Issuer       *TLSClientCertificateIssuerDNInfo  `json:"issuer,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// TLSClientCertificateSubjectDNInfo holds the client TLS certificate subject distinguished name info configuration.
+type TLSClientCertificateSubjectDNInfo struct {
+	Country            bool `json:"country,omitempty"`
+	Province           bool `json:"province,omitempty"`
+	Locality           bool `json:"locality,omitempty"`
+	Organization       bool `json:"organization,omitempty"`
+	OrganizationalUnit bool `json:"organizationalUnit,omitempty"`
+	CommonName         bool `json:"commonName,omitempty"`
+	SerialNumber       bool `json:"serialNumber,omitempty"`
+	DomainComponent    bool `json:"domainComponent,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// TLSClientCertificateIssuerDNInfo holds the client TLS certificate issuer distinguished name info configuration.
+type TLSClientCertificateIssuerDNInfo struct {
+	Country         bool `json:"country,omitempty"`
+	Province        bool `json:"province,omitempty"`
+	Locality        bool `json:"locality,omitempty"`
+	Organization    bool `json:"organization,omitempty"`
+	CommonName      bool `json:"commonName,omitempty"`
+	SerialNumber    bool `json:"serialNumber,omitempty"`
+	DomainComponent bool `json:"domainComponent,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
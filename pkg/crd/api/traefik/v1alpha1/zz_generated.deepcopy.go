@@ -41,6 +41,27 @@ func (in *AddPrefix) DeepCopy() *AddPrefix {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Chain) DeepCopyInto(out *Chain) {
+	*out = *in
+	if in.Middlewares != nil {
+		in, out := &in.Middlewares, &out.Middlewares
+		*out = make([]MiddlewareRef, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Chain.
+func (in *Chain) DeepCopy() *Chain {
+	if in == nil {
+		return nil
+	}
+	out := new(Chain)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClientAuth) DeepCopyInto(out *ClientAuth) {
 	*out = *in
@@ -370,6 +391,16 @@ func (in *MiddlewareSpec) DeepCopyInto(out *MiddlewareSpec) {
 		*out = new(AddPrefix)
 		**out = **in
 	}
+	if in.PassTLSClientCert != nil {
+		in, out := &in.PassTLSClientCert, &out.PassTLSClientCert
+		*out = new(PassTLSClientCert)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chain != nil {
+		in, out := &in.Chain, &out.Chain
+		*out = new(Chain)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -445,6 +476,27 @@ func (in *ResponseForwarding) DeepCopy() *ResponseForwarding {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassTLSClientCert) DeepCopyInto(out *PassTLSClientCert) {
+	*out = *in
+	if in.Info != nil {
+		in, out := &in.Info, &out.Info
+		*out = new(TLSClientCertificateInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassTLSClientCert.
+func (in *PassTLSClientCert) DeepCopy() *PassTLSClientCert {
+	if in == nil {
+		return nil
+	}
+	out := new(PassTLSClientCert)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Route) DeepCopyInto(out *Route) {
 	*out = *in
@@ -591,6 +643,64 @@ func (in *TLS) DeepCopy() *TLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSClientCertificateInfo) DeepCopyInto(out *TLSClientCertificateInfo) {
+	*out = *in
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(TLSClientCertificateSubjectDNInfo)
+		**out = **in
+	}
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(TLSClientCertificateIssuerDNInfo)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSClientCertificateInfo.
+func (in *TLSClientCertificateInfo) DeepCopy() *TLSClientCertificateInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSClientCertificateInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSClientCertificateIssuerDNInfo) DeepCopyInto(out *TLSClientCertificateIssuerDNInfo) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSClientCertificateIssuerDNInfo.
+func (in *TLSClientCertificateIssuerDNInfo) DeepCopy() *TLSClientCertificateIssuerDNInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSClientCertificateIssuerDNInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSClientCertificateSubjectDNInfo) DeepCopyInto(out *TLSClientCertificateSubjectDNInfo) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSClientCertificateSubjectDNInfo.
+func (in *TLSClientCertificateSubjectDNInfo) DeepCopy() *TLSClientCertificateSubjectDNInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSClientCertificateSubjectDNInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSOption) DeepCopyInto(out *TLSOption) {
 	*out = *in
@@ -47,11 +47,23 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 				ForwardHeaders:             policy.Spec.JWT.ForwardHeaders,
 				TokenQueryKey:              policy.Spec.JWT.TokenQueryKey,
 				Claims:                     policy.Spec.JWT.Claims,
+				Issuers:                    policy.Spec.JWT.Issuers,
+				Audiences:                  policy.Spec.JWT.Audiences,
+				Leeway:                     policy.Spec.JWT.Leeway,
+				MaxAge:                     policy.Spec.JWT.MaxAge,
+				Algorithms:                 policy.Spec.JWT.Algorithms,
 			}
 
 			if policy.Spec.JWT.SigningSecret != "" {
 				acp.JWT.SigningSecret = "redacted"
 			}
+
+			if policy.Spec.JWT.KeysSecret != nil {
+				acp.JWT.KeysSecret = &SecretReference{
+					Name:      policy.Spec.JWT.KeysSecret.Name,
+					Namespace: policy.Spec.JWT.KeysSecret.Namespace,
+				}
+			}
 		case policy.Spec.BasicAuth != nil:
 			acp.Method = "basicAuth"
 			acp.BasicAuth = &AccessControlPolicyBasicAuth{
@@ -60,17 +72,46 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 				StripAuthorizationHeader: policy.Spec.BasicAuth.StripAuthorizationHeader,
 				ForwardUsernameHeader:    policy.Spec.BasicAuth.ForwardUsernameHeader,
 			}
+
+			if policy.Spec.BasicAuth.UsersSecret != nil {
+				acp.BasicAuth.UsersSecret = &SecretReference{
+					Name:      policy.Spec.BasicAuth.UsersSecret.Name,
+					Namespace: policy.Spec.BasicAuth.UsersSecret.Namespace,
+				}
+			}
+
+			if ldapCfg := policy.Spec.BasicAuth.LDAP; ldapCfg != nil {
+				acp.BasicAuth.LDAP = &AccessControlPolicyBasicAuthLDAP{
+					URL:                 ldapCfg.URL,
+					StartTLS:            ldapCfg.StartTLS,
+					BaseDN:              ldapCfg.BaseDN,
+					UserFilter:          ldapCfg.UserFilter,
+					GroupAttribute:      ldapCfg.GroupAttribute,
+					RequiredGroups:      ldapCfg.RequiredGroups,
+					ForwardGroupsHeader: ldapCfg.ForwardGroupsHeader,
+					CacheTTL:            ldapCfg.CacheTTL,
+				}
+
+				if ldapCfg.BindSecret != nil {
+					acp.BasicAuth.LDAP.BindSecret = &SecretReference{
+						Name:      ldapCfg.BindSecret.Name,
+						Namespace: ldapCfg.BindSecret.Namespace,
+					}
+				}
+			}
 		case policy.Spec.OIDC != nil:
 			acp.Method = "oidc"
 			acp.OIDC = &AccessControlPolicyOIDC{
-				Issuer:         policy.Spec.OIDC.Issuer,
-				ClientID:       policy.Spec.OIDC.ClientID,
-				RedirectURL:    policy.Spec.OIDC.RedirectURL,
-				LogoutURL:      policy.Spec.OIDC.LogoutURL,
-				Scopes:         policy.Spec.OIDC.Scopes,
-				AuthParams:     policy.Spec.OIDC.AuthParams,
-				ForwardHeaders: policy.Spec.OIDC.ForwardHeaders,
-				Claims:         policy.Spec.OIDC.Claims,
+				Issuer:                policy.Spec.OIDC.Issuer,
+				ClientID:              policy.Spec.OIDC.ClientID,
+				RedirectURL:           policy.Spec.OIDC.RedirectURL,
+				LogoutURL:             policy.Spec.OIDC.LogoutURL,
+				PostLogoutRedirectURL: policy.Spec.OIDC.PostLogoutRedirectURL,
+				Scopes:                policy.Spec.OIDC.Scopes,
+				AuthParams:            policy.Spec.OIDC.AuthParams,
+				ForwardHeaders:        policy.Spec.OIDC.ForwardHeaders,
+				Claims:                policy.Spec.OIDC.Claims,
+				PKCE:                  policy.Spec.OIDC.PKCE,
 			}
 
 			if policy.Spec.OIDC.Secret != nil {
@@ -80,6 +121,22 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 				}
 			}
 
+			if policy.Spec.OIDC.UserInfo != nil {
+				acp.OIDC.UserInfo = &UserInfo{Precedence: policy.Spec.OIDC.UserInfo.Precedence}
+			}
+
+			if policy.Spec.OIDC.Provider != nil {
+				acp.OIDC.Provider = &OIDCProvider{
+					Name:           policy.Spec.OIDC.Provider.Name,
+					TenantID:       policy.Spec.OIDC.Provider.TenantID,
+					URL:            policy.Spec.OIDC.Provider.URL,
+					Realm:          policy.Spec.OIDC.Provider.Realm,
+					GroupsClaim:    policy.Spec.OIDC.Provider.GroupsClaim,
+					AllowedGroups:  policy.Spec.OIDC.Provider.AllowedGroups,
+					AllowedDomains: policy.Spec.OIDC.Provider.AllowedDomains,
+				}
+			}
+
 			if policy.Spec.OIDC.StateCookie != nil {
 				acp.OIDC.StateCookie = &AuthStateCookie{
 					Path:     policy.Spec.OIDC.StateCookie.Path,
@@ -96,6 +153,8 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 					SameSite: policy.Spec.OIDC.Session.SameSite,
 					Secure:   policy.Spec.OIDC.Session.Secure,
 					Refresh:  policy.Spec.OIDC.Session.Refresh,
+					Store:    policy.Spec.OIDC.Session.Store,
+					TTL:      policy.Spec.OIDC.Session.TTL,
 				}
 			}
 		case policy.Spec.OIDCGoogle != nil:
@@ -107,6 +166,7 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 				AuthParams:     policy.Spec.OIDCGoogle.AuthParams,
 				ForwardHeaders: policy.Spec.OIDCGoogle.ForwardHeaders,
 				Emails:         policy.Spec.OIDCGoogle.Emails,
+				PKCE:           policy.Spec.OIDCGoogle.PKCE,
 			}
 
 			if policy.Spec.OIDCGoogle.Secret != nil {
@@ -132,8 +192,126 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 					SameSite: policy.Spec.OIDCGoogle.Session.SameSite,
 					Secure:   policy.Spec.OIDCGoogle.Session.Secure,
 					Refresh:  policy.Spec.OIDCGoogle.Session.Refresh,
+					Store:    policy.Spec.OIDCGoogle.Session.Store,
+					TTL:      policy.Spec.OIDCGoogle.Session.TTL,
 				}
 			}
+		case policy.Spec.GitHub != nil:
+			acp.Method = "github"
+			acp.GitHub = &AccessControlPolicyGitHub{
+				ClientID:       policy.Spec.GitHub.ClientID,
+				BaseURL:        policy.Spec.GitHub.BaseURL,
+				RedirectURL:    policy.Spec.GitHub.RedirectURL,
+				LogoutURL:      policy.Spec.GitHub.LogoutURL,
+				Orgs:           policy.Spec.GitHub.Orgs,
+				Teams:          policy.Spec.GitHub.Teams,
+				ForwardHeaders: policy.Spec.GitHub.ForwardHeaders,
+			}
+
+			if policy.Spec.GitHub.Secret != nil {
+				acp.GitHub.Secret = &SecretReference{
+					Name:      policy.Spec.GitHub.Secret.Name,
+					Namespace: policy.Spec.GitHub.Secret.Namespace,
+				}
+			}
+
+			if policy.Spec.GitHub.StateCookie != nil {
+				acp.GitHub.StateCookie = &AuthStateCookie{
+					Path:     policy.Spec.GitHub.StateCookie.Path,
+					Domain:   policy.Spec.GitHub.StateCookie.Domain,
+					SameSite: policy.Spec.GitHub.StateCookie.SameSite,
+					Secure:   policy.Spec.GitHub.StateCookie.Secure,
+				}
+			}
+
+			if policy.Spec.GitHub.Session != nil {
+				acp.GitHub.Session = &AuthSession{
+					Path:     policy.Spec.GitHub.Session.Path,
+					Domain:   policy.Spec.GitHub.Session.Domain,
+					SameSite: policy.Spec.GitHub.Session.SameSite,
+					Secure:   policy.Spec.GitHub.Session.Secure,
+					Refresh:  policy.Spec.GitHub.Session.Refresh,
+					Store:    policy.Spec.GitHub.Session.Store,
+					TTL:      policy.Spec.GitHub.Session.TTL,
+				}
+			}
+		case policy.Spec.OAuthIntrospection != nil:
+			acp.Method = "oauthIntrospection"
+			acp.OAuthIntrospection = &AccessControlPolicyOAuthIntrospection{
+				URL:                      policy.Spec.OAuthIntrospection.URL,
+				ClientID:                 policy.Spec.OAuthIntrospection.ClientID,
+				TokenTypeHint:            policy.Spec.OAuthIntrospection.TokenTypeHint,
+				TokenQueryKey:            policy.Spec.OAuthIntrospection.TokenQueryKey,
+				StripAuthorizationHeader: policy.Spec.OAuthIntrospection.StripAuthorizationHeader,
+				ForwardHeaders:           policy.Spec.OAuthIntrospection.ForwardHeaders,
+				Claims:                   policy.Spec.OAuthIntrospection.Claims,
+			}
+
+			if policy.Spec.OAuthIntrospection.Secret != nil {
+				acp.OAuthIntrospection.Secret = &SecretReference{
+					Name:      policy.Spec.OAuthIntrospection.Secret.Name,
+					Namespace: policy.Spec.OAuthIntrospection.Secret.Namespace,
+				}
+			}
+		case policy.Spec.APIKey != nil:
+			acp.Method = "apiKey"
+			acp.APIKey = &AccessControlPolicyAPIKey{
+				Header:          policy.Spec.APIKey.Header,
+				Query:           policy.Spec.APIKey.Query,
+				Cookie:          policy.Spec.APIKey.Cookie,
+				Keys:            redactPasswords(policy.Spec.APIKey.Keys),
+				SecretNamespace: policy.Spec.APIKey.SecretNamespace,
+				SecretSelector:  policy.Spec.APIKey.SecretSelector,
+				ForwardHeaders:  policy.Spec.APIKey.ForwardHeaders,
+			}
+		case policy.Spec.MTLS != nil:
+			acp.Method = "mtls"
+			acp.MTLS = &AccessControlPolicyMTLS{
+				ForwardHeaders: policy.Spec.MTLS.ForwardHeaders,
+				Claims:         policy.Spec.MTLS.Claims,
+			}
+
+			if ca := policy.Spec.MTLS.CA; ca != nil {
+				acp.MTLS.CA = &MTLSCAReference{Key: ca.Key}
+
+				if ca.Secret != nil {
+					acp.MTLS.CA.Secret = &SecretReference{
+						Name:      ca.Secret.Name,
+						Namespace: ca.Secret.Namespace,
+					}
+				}
+
+				if ca.ConfigMap != nil {
+					acp.MTLS.CA.ConfigMap = &ConfigMapReference{
+						Name:      ca.ConfigMap.Name,
+						Namespace: ca.ConfigMap.Namespace,
+					}
+				}
+			}
+		case policy.Spec.IPAllowList != nil:
+			acp.Method = "ipAllowList"
+			acp.IPAllowList = &AccessControlPolicyIPAllowList{
+				SourceRange:       policy.Spec.IPAllowList.SourceRange,
+				DeniedSourceRange: policy.Spec.IPAllowList.DeniedSourceRange,
+				Depth:             policy.Spec.IPAllowList.Depth,
+			}
+		case policy.Spec.Composite != nil:
+			acp.Method = "composite"
+			acp.Composite = &AccessControlPolicyComposite{
+				AllOf: policy.Spec.Composite.AllOf,
+				AnyOf: policy.Spec.Composite.AnyOf,
+			}
+		case policy.Spec.ExternalAuthz != nil:
+			acp.Method = "externalAuthz"
+			acp.ExternalAuthz = &AccessControlPolicyExternalAuthz{
+				URL:                  policy.Spec.ExternalAuthz.URL,
+				AuthenticationPolicy: policy.Spec.ExternalAuthz.AuthenticationPolicy,
+				RequestHeaders:       policy.Spec.ExternalAuthz.RequestHeaders,
+				ResponseHeaders:      policy.Spec.ExternalAuthz.ResponseHeaders,
+				Timeout:              policy.Spec.ExternalAuthz.Timeout,
+				FailOpen:             policy.Spec.ExternalAuthz.FailOpen,
+				CacheTTL:             policy.Spec.ExternalAuthz.CacheTTL,
+			}
 		default:
 			continue
 		}
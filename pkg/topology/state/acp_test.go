@@ -51,6 +51,49 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc:    "basic auth with users Secret",
+			fixture: "fixtures/acp/basic-auth-users-secret.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "basicAuth",
+					BasicAuth: &AccessControlPolicyBasicAuth{
+						UsersSecret: &SecretReference{
+							Name:      "my-users",
+							Namespace: "default",
+						},
+						ForwardUsernameHeader: "Username",
+					},
+				},
+			},
+		},
+		{
+			desc:    "basic auth with LDAP",
+			fixture: "fixtures/acp/basic-auth-ldap.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "basicAuth",
+					BasicAuth: &AccessControlPolicyBasicAuth{
+						ForwardUsernameHeader: "Username",
+						LDAP: &AccessControlPolicyBasicAuthLDAP{
+							URL: "ldaps://ldap.example.org",
+							BindSecret: &SecretReference{
+								Name:      "ldap-bind",
+								Namespace: "default",
+							},
+							BaseDN:              "ou=people,dc=example,dc=org",
+							UserFilter:          "(uid=%s)",
+							GroupAttribute:      "memberOf",
+							RequiredGroups:      []string{"cn=admins,ou=groups,dc=example,dc=org"},
+							ForwardGroupsHeader: "Groups",
+							CacheTTL:            30,
+						},
+					},
+				},
+			},
+		},
 		{
 			desc:    "jwt",
 			fixture: "fixtures/acp/jwt.yml",
@@ -65,6 +108,28 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 						StripAuthorizationHeader:   true,
 						TokenQueryKey:              "token",
 						Claims:                     "Equals(`group`,`dev`)",
+						Issuers:                    []string{"https://issuer.example.com"},
+						Audiences:                  []string{"my-api"},
+						Leeway:                     30,
+						MaxAge:                     3600,
+						Algorithms:                 []string{"HS256"},
+					},
+				},
+			},
+		},
+		{
+			desc:    "jwt with keys Secret",
+			fixture: "fixtures/acp/jwt-keys-secret.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "jwt",
+					JWT: &AccessControlPolicyJWT{
+						ForwardHeaders: map[string]string{"Group": "grp"},
+						KeysSecret: &SecretReference{
+							Name:      "my-keys",
+							Namespace: "default",
+						},
 					},
 				},
 			},
@@ -83,9 +148,11 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 							Name:      "my-secret",
 							Namespace: "default",
 						},
-						RedirectURL: "https://foobar.com/callback",
-						LogoutURL:   "https://foobar.com/logout",
-						Scopes:      []string{"scope"},
+						RedirectURL:           "https://foobar.com/callback",
+						LogoutURL:             "https://foobar.com/logout",
+						PostLogoutRedirectURL: "https://foobar.com",
+						UserInfo:              &UserInfo{Precedence: "userInfo"},
+						Scopes:                []string{"scope"},
 						AuthParams: map[string]string{
 							"hd": "example.com",
 						},
@@ -95,7 +162,37 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 							SameSite: "lax",
 							Secure:   true,
 						},
+						Session: &AuthSession{
+							Store: "secret",
+							TTL:   3600,
+						},
 						Claims: "Equals(`group`,`dev`)",
+						PKCE:   ptrBool(false),
+					},
+				},
+			},
+		},
+		{
+			desc:    "oidc provider",
+			fixture: "fixtures/acp/oidc-provider.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "oidc",
+					OIDC: &AccessControlPolicyOIDC{
+						ClientID: "client-id",
+						Secret: &SecretReference{
+							Name:      "my-secret",
+							Namespace: "default",
+						},
+						RedirectURL: "https://foobar.com/callback",
+						Provider: &OIDCProvider{
+							Name:           "azureAD",
+							TenantID:       "my-tenant",
+							GroupsClaim:    "roles",
+							AllowedGroups:  []string{"admin"},
+							AllowedDomains: []string{"example.com"},
+						},
 					},
 				},
 			},
@@ -129,6 +226,149 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc:    "github",
+			fixture: "fixtures/acp/github.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "github",
+					GitHub: &AccessControlPolicyGitHub{
+						ClientID: "client-id",
+						Secret: &SecretReference{
+							Name:      "my-secret",
+							Namespace: "default",
+						},
+						BaseURL:     "https://github.example.com",
+						RedirectURL: "https://foobar.com/callback",
+						LogoutURL:   "https://foobar.com/logout",
+						Session: &AuthSession{
+							TTL: 600,
+						},
+						Orgs:  []string{"traefik"},
+						Teams: []string{"traefik/hub"},
+						ForwardHeaders: map[string]string{
+							"X-Login": "login",
+							"X-Email": "email",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:    "oauth introspection",
+			fixture: "fixtures/acp/oauth-introspection.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "oauthIntrospection",
+					OAuthIntrospection: &AccessControlPolicyOAuthIntrospection{
+						URL:      "https://idp.example.com/oauth2/introspect",
+						ClientID: "client-id",
+						Secret: &SecretReference{
+							Name:      "my-secret",
+							Namespace: "default",
+						},
+						TokenTypeHint:            "access_token",
+						StripAuthorizationHeader: true,
+						ForwardHeaders: map[string]string{
+							"X-User": "sub",
+						},
+						Claims: "Equals(`scope`,`read`)",
+					},
+				},
+			},
+		},
+		{
+			desc:    "api key",
+			fixture: "fixtures/acp/api-key.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "apiKey",
+					APIKey: &AccessControlPolicyAPIKey{
+						Header:          "X-Api-Key",
+						Keys:            "partner:redacted",
+						SecretNamespace: "default",
+						SecretSelector: map[string]string{
+							"app": "partners",
+						},
+						ForwardHeaders: map[string]string{
+							"X-Consumer": "consumer",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:    "mTLS",
+			fixture: "fixtures/acp/mtls.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "mtls",
+					MTLS: &AccessControlPolicyMTLS{
+						CA: &MTLSCAReference{
+							ConfigMap: &ConfigMapReference{
+								Name:      "internal-ca",
+								Namespace: "default",
+							},
+							Key: "bundle.pem",
+						},
+						ForwardHeaders: map[string]string{
+							"X-Client-CN": "subject.commonName",
+						},
+						Claims: "Equals(`subject.commonName`, `billing`)",
+					},
+				},
+			},
+		},
+		{
+			desc:    "IP allow list",
+			fixture: "fixtures/acp/ip-allow-list.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "ipAllowList",
+					IPAllowList: &AccessControlPolicyIPAllowList{
+						SourceRange:       []string{"10.0.0.0/8", "2001:db8::/32"},
+						DeniedSourceRange: []string{"10.1.0.0/16"},
+						Depth:             1,
+					},
+				},
+			},
+		},
+		{
+			desc:    "Composite",
+			fixture: "fixtures/acp/composite.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "composite",
+					Composite: &AccessControlPolicyComposite{
+						AnyOf: []string{"my-jwt-acp", "my-api-key-acp"},
+					},
+				},
+			},
+		},
+		{
+			desc:    "External authorization",
+			fixture: "fixtures/acp/external-authz.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "externalAuthz",
+					ExternalAuthz: &AccessControlPolicyExternalAuthz{
+						URL:                  "http://entitlements.default.svc:8080/decide",
+						AuthenticationPolicy: "my-jwt-acp",
+						RequestHeaders:       []string{"X-Tenant"},
+						ResponseHeaders:      []string{"X-Plan"},
+						Timeout:              2,
+						CacheTTL:             30,
+					},
+				},
+			},
+		},
 	}
 
 	err := hubv1alpha1.AddToScheme(scheme.Scheme)
@@ -156,3 +396,7 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 		})
 	}
 }
+
+func ptrBool(v bool) *bool {
+	return &v
+}
@@ -114,12 +114,19 @@ type RouteService struct {
 
 // AccessControlPolicy describes an Access Control Policy configured within a cluster.
 type AccessControlPolicy struct {
-	Name       string                         `json:"name"`
-	Method     string                         `json:"method"`
-	JWT        *AccessControlPolicyJWT        `json:"jwt,omitempty"`
-	BasicAuth  *AccessControlPolicyBasicAuth  `json:"basicAuth,omitempty"`
-	OIDC       *AccessControlPolicyOIDC       `json:"oidc,omitempty"`
-	OIDCGoogle *AccessControlPolicyOIDCGoogle `json:"oidcGoogle,omitempty"`
+	Name               string                                 `json:"name"`
+	Method             string                                 `json:"method"`
+	JWT                *AccessControlPolicyJWT                `json:"jwt,omitempty"`
+	BasicAuth          *AccessControlPolicyBasicAuth          `json:"basicAuth,omitempty"`
+	OIDC               *AccessControlPolicyOIDC               `json:"oidc,omitempty"`
+	OIDCGoogle         *AccessControlPolicyOIDCGoogle         `json:"oidcGoogle,omitempty"`
+	GitHub             *AccessControlPolicyGitHub             `json:"github,omitempty"`
+	OAuthIntrospection *AccessControlPolicyOAuthIntrospection `json:"oauthIntrospection,omitempty"`
+	APIKey             *AccessControlPolicyAPIKey             `json:"apiKey,omitempty"`
+	MTLS               *AccessControlPolicyMTLS               `json:"mtls,omitempty"`
+	IPAllowList        *AccessControlPolicyIPAllowList        `json:"ipAllowList,omitempty"`
+	Composite          *AccessControlPolicyComposite          `json:"composite,omitempty"`
+	ExternalAuthz      *AccessControlPolicyExternalAuthz      `json:"externalAuthz,omitempty"`
 }
 
 // AccessControlPolicyJWT describes the settings for JWT authentication within an access control policy.
@@ -133,14 +140,36 @@ type AccessControlPolicyJWT struct {
 	ForwardHeaders             map[string]string `json:"forwardHeaders,omitempty"`
 	TokenQueryKey              string            `json:"tokenQueryKey,omitempty"`
 	Claims                     string            `json:"claims,omitempty"`
+	Issuers                    []string          `json:"issuers,omitempty"`
+	Audiences                  []string          `json:"audiences,omitempty"`
+	Leeway                     int               `json:"leeway,omitempty"`
+	MaxAge                     int               `json:"maxAge,omitempty"`
+	Algorithms                 []string          `json:"algorithms,omitempty"`
+	KeysSecret                 *SecretReference  `json:"keysSecret,omitempty"`
 }
 
 // AccessControlPolicyBasicAuth holds the HTTP basic authentication configuration.
 type AccessControlPolicyBasicAuth struct {
-	Users                    string `json:"users,omitempty"`
-	Realm                    string `json:"realm,omitempty"`
-	StripAuthorizationHeader bool   `json:"stripAuthorizationHeader,omitempty"`
-	ForwardUsernameHeader    string `json:"forwardUsernameHeader,omitempty"`
+	Users                    string           `json:"users,omitempty"`
+	UsersSecret              *SecretReference `json:"usersSecret,omitempty"`
+	Realm                    string           `json:"realm,omitempty"`
+	StripAuthorizationHeader bool             `json:"stripAuthorizationHeader,omitempty"`
+	ForwardUsernameHeader    string           `json:"forwardUsernameHeader,omitempty"`
+
+	LDAP *AccessControlPolicyBasicAuthLDAP `json:"ldap,omitempty"`
+}
+
+// AccessControlPolicyBasicAuthLDAP holds the LDAP configuration of the HTTP basic authentication.
+type AccessControlPolicyBasicAuthLDAP struct {
+	URL                 string           `json:"url,omitempty"`
+	StartTLS            bool             `json:"startTls,omitempty"`
+	BindSecret          *SecretReference `json:"bindSecret,omitempty"`
+	BaseDN              string           `json:"baseDn,omitempty"`
+	UserFilter          string           `json:"userFilter,omitempty"`
+	GroupAttribute      string           `json:"groupAttribute,omitempty"`
+	RequiredGroups      []string         `json:"requiredGroups,omitempty"`
+	ForwardGroupsHeader string           `json:"forwardGroupsHeader,omitempty"`
+	CacheTTL            int              `json:"cacheTtl,omitempty"`
 }
 
 // AccessControlPolicyOIDC holds the OIDC configuration.
@@ -155,6 +184,11 @@ type AccessControlPolicyOIDC struct {
 	AuthParams  map[string]string `json:"authParams,omitempty"`
 	StateCookie *AuthStateCookie  `json:"stateCookie,omitempty"`
 	Session     *AuthSession      `json:"session,omitempty"`
+	PKCE        *bool             `json:"pkce,omitempty"`
+
+	PostLogoutRedirectURL string        `json:"postLogoutRedirectUrl,omitempty"`
+	UserInfo              *UserInfo     `json:"userInfo,omitempty"`
+	Provider              *OIDCProvider `json:"provider,omitempty"`
 
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	Claims         string            `json:"claims,omitempty"`
@@ -170,11 +204,97 @@ type AccessControlPolicyOIDCGoogle struct {
 	AuthParams  map[string]string `json:"authParams,omitempty"`
 	StateCookie *AuthStateCookie  `json:"stateCookie,omitempty"`
 	Session     *AuthSession      `json:"session,omitempty"`
+	PKCE        *bool             `json:"pkce,omitempty"`
 
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	Emails         []string          `json:"emails,omitempty"`
 }
 
+// AccessControlPolicyGitHub holds the GitHub OAuth2 configuration.
+type AccessControlPolicyGitHub struct {
+	ClientID string           `json:"clientId,omitempty"`
+	Secret   *SecretReference `json:"secret,omitempty"`
+	BaseURL  string           `json:"baseUrl,omitempty"`
+
+	RedirectURL string           `json:"redirectUrl,omitempty"`
+	LogoutURL   string           `json:"logoutUrl,omitempty"`
+	StateCookie *AuthStateCookie `json:"stateCookie,omitempty"`
+	Session     *AuthSession     `json:"session,omitempty"`
+
+	Orgs           []string          `json:"orgs,omitempty"`
+	Teams          []string          `json:"teams,omitempty"`
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// AccessControlPolicyOAuthIntrospection holds the OAuth 2.0 token introspection configuration.
+type AccessControlPolicyOAuthIntrospection struct {
+	URL      string           `json:"url,omitempty"`
+	ClientID string           `json:"clientId,omitempty"`
+	Secret   *SecretReference `json:"secret,omitempty"`
+
+	TokenTypeHint            string            `json:"tokenTypeHint,omitempty"`
+	TokenQueryKey            string            `json:"tokenQueryKey,omitempty"`
+	StripAuthorizationHeader bool              `json:"stripAuthorizationHeader,omitempty"`
+	ForwardHeaders           map[string]string `json:"forwardHeaders,omitempty"`
+	Claims                   string            `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyAPIKey holds the API key configuration.
+type AccessControlPolicyAPIKey struct {
+	Header          string            `json:"header,omitempty"`
+	Query           string            `json:"query,omitempty"`
+	Cookie          string            `json:"cookie,omitempty"`
+	Keys            string            `json:"keys,omitempty"`
+	SecretNamespace string            `json:"secretNamespace,omitempty"`
+	SecretSelector  map[string]string `json:"secretSelector,omitempty"`
+	ForwardHeaders  map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// AccessControlPolicyMTLS holds the mTLS client certificate configuration.
+type AccessControlPolicyMTLS struct {
+	CA             *MTLSCAReference  `json:"ca,omitempty"`
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	Claims         string            `json:"claims,omitempty"`
+}
+
+// MTLSCAReference references the CA bundle of an mTLS configuration.
+type MTLSCAReference struct {
+	Secret    *SecretReference    `json:"secret,omitempty"`
+	ConfigMap *ConfigMapReference `json:"configMap,omitempty"`
+	Key       string              `json:"key,omitempty"`
+}
+
+// ConfigMapReference represents a ConfigMap Reference.
+type ConfigMapReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AccessControlPolicyIPAllowList holds the IP allow list configuration.
+type AccessControlPolicyIPAllowList struct {
+	SourceRange       []string `json:"sourceRange,omitempty"`
+	DeniedSourceRange []string `json:"deniedSourceRange,omitempty"`
+	Depth             int      `json:"depth,omitempty"`
+}
+
+// AccessControlPolicyComposite holds the configuration of a policy combining other policies.
+type AccessControlPolicyComposite struct {
+	AllOf []string `json:"allOf,omitempty"`
+	AnyOf []string `json:"anyOf,omitempty"`
+}
+
+// AccessControlPolicyExternalAuthz holds the configuration of a policy delegating authorization decisions to an
+// external service.
+type AccessControlPolicyExternalAuthz struct {
+	URL                  string   `json:"url,omitempty"`
+	AuthenticationPolicy string   `json:"authenticationPolicy,omitempty"`
+	RequestHeaders       []string `json:"requestHeaders,omitempty"`
+	ResponseHeaders      []string `json:"responseHeaders,omitempty"`
+	Timeout              int      `json:"timeout,omitempty"`
+	FailOpen             bool     `json:"failOpen,omitempty"`
+	CacheTTL             int      `json:"cacheTtl,omitempty"`
+}
+
 // SecretReference represents a Secret Reference.
 // It has enough information to retrieve secret in any namespace.
 type SecretReference struct {
@@ -190,6 +310,22 @@ type AuthStateCookie struct {
 	Secure   bool   `json:"secure,omitempty"`
 }
 
+// OIDCProvider carries the configuration of a well-known OIDC provider.
+type OIDCProvider struct {
+	Name           string   `json:"name"`
+	TenantID       string   `json:"tenantId,omitempty"`
+	URL            string   `json:"url,omitempty"`
+	Realm          string   `json:"realm,omitempty"`
+	GroupsClaim    string   `json:"groupsClaim,omitempty"`
+	AllowedGroups  []string `json:"allowedGroups,omitempty"`
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+}
+
+// UserInfo carries the configuration of the retrieval of claims from the UserInfo endpoint.
+type UserInfo struct {
+	Precedence string `json:"precedence,omitempty"`
+}
+
 // AuthSession carries session and session cookie configuration.
 type AuthSession struct {
 	Path     string `json:"path,omitempty"`
@@ -197,6 +333,8 @@ type AuthSession struct {
 	SameSite string `json:"sameSite,omitempty"`
 	Secure   bool   `json:"secure,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	Store    string `json:"store,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
 }
 
 // EdgeIngress holds the definition of an EdgeIngress configuration.